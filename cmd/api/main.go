@@ -2,19 +2,29 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 	"time"
 
+	"todo_api/internal/authtoken"
 	"todo_api/internal/config"
 	"todo_api/internal/database"
 	"todo_api/internal/handlers"
+	"todo_api/internal/jobs"
+	"todo_api/internal/jwtkeys"
+	"todo_api/internal/mailer"
 	"todo_api/internal/middleware"
+	"todo_api/internal/notifier"
+	"todo_api/internal/pagination"
+	"todo_api/internal/quickadd"
+	"todo_api/internal/realtime"
+	"todo_api/internal/repository"
+	"todo_api/internal/revocation"
+	"todo_api/internal/service"
+	"todo_api/internal/utils"
 
-	// "todo_api/internal/repository"
-	// "todo_api/internal/service"
-
-	// "cloud.google.com/go/storage"
-
+	"cloud.google.com/go/storage"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool" // PostgreSQL 驅動程式的 connection pool 版本，提供高效連線管理
@@ -38,6 +48,94 @@ func main() {
 	}
 	defer pool.Close()
 
+	// access token 的簽章 key；設定錯了寧可起不來，也不要發出別人驗不過的 token
+	jwtKeys, err := jwtkeys.Load(cfg.JWTKeyFiles, cfg.JWTKeys, cfg.JWTActiveKID, cfg.JWTSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if kid := jwtKeys.ActiveKID(); kid != "" {
+		log.Printf("signing access tokens with kid %s", kid)
+	}
+	// 發 token 跟驗證 token 用同一份 iss / aud / 時鐘誤差設定
+	tokenOpts := authtoken.Options{Issuer: cfg.JWTIssuer, Audience: cfg.JWTAudience, Leeway: cfg.JWTLeeway}
+
+	// 附件的檔案內容：BLOB_STORAGE=gcs 放在 GCS_BUCKET_NAME，沒設定就放本機資料夾（開發用）
+	var blobs repository.BlobStorage
+	if cfg.BlobBackend == "gcs" {
+		storageClient, err := storage.NewClient(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer storageClient.Close()
+		blobs = repository.NewGCSBlobStorage(storageClient, cfg.GCSBucketName)
+	} else {
+		localBlobs, err := repository.NewLocalBlobStorage(cfg.BlobLocalDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		blobs = localBlobs
+	}
+
+	// 寄信：MAILER=smtp 走 SMTP_HOST，memory 只留在記憶體，預設存成 MAIL_OUTBOX_DIR 裡的 .eml 檔（本機開發直接打開看）
+	var mailSender mailer.Mailer
+	switch cfg.MailerBackend {
+	case "smtp":
+		mailSender = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "memory":
+		mailSender = mailer.NewMemoryMailer()
+	default:
+		fileMailer, err := mailer.NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+		if err != nil {
+			log.Fatal(err)
+		}
+		mailSender = fileMailer
+	}
+
+	// 驗證信的 token 要有簽章的 key；只用非對稱 key、沒設定 JWT_SECRET 的話臨時產生一把，重開之後還沒點的連結就失效了
+	verificationSecret := cfg.EmailVerificationSecret
+	if verificationSecret == "" {
+		log.Println("warning: EMAIL_VERIFICATION_SECRET is empty, using a random key; verification links will not survive a restart")
+		if verificationSecret, err = utils.NewRandomToken(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// 背景工作共用的 context，main 結束時一起取消
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// 定期清掉垃圾桶裡超過保留期限的 todos
+	jobs.StartTrashCleaner(jobsCtx, pool, cfg.TrashRetention, cfg.TrashCleanupInterval)
+
+	// 提醒排程：目前先印在 stdout，之後接 email / 推播只要換 Notifier 實作
+	jobs.StartReminderScheduler(jobsCtx, pool, notifier.NewLogNotifier(os.Stdout), cfg.ReminderInterval)
+
+	// 離線同步用的墓碑跟 mutation 紀錄，過了 token 的有效期限就清掉
+	jobs.StartSyncCleaner(jobsCtx, pool, cfg.SyncRetention, cfg.SyncCleanupInterval)
+
+	// 刪掉的附件、永久刪除的 todo 留下的檔案
+	jobs.StartBlobCleaner(jobsCtx, pool, blobs, cfg.BlobCleanupInterval)
+
+	// 忘了停的計時器
+	jobs.StartTimerSweeper(jobsCtx, pool, cfg.TimerMaxDuration, cfg.TimerSweepInterval)
+
+	// 過期的 refresh token
+	jobs.StartRefreshTokenCleaner(jobsCtx, pool, cfg.RefreshTokenCleanupInterval)
+
+	// 過期的 email 驗證 token
+	jobs.StartEmailVerificationCleaner(jobsCtx, pool, cfg.EmailVerificationCleanupInterval)
+
+	// 登出的 access token：AuthMiddleware 每個請求都會查，前面有一層 LRU 快取
+	revocations := revocation.NewStore(pool, cfg.RevocationCacheSize, cfg.RevocationCacheTTL)
+	jobs.StartRevocationCleaner(jobsCtx, pool, revocations, cfg.RevocationCleanupInterval)
+
+	// 手動排序的鍵太長時重新分配
+	jobs.StartRankRebalancer(jobsCtx, pool, cfg.RankRebalanceInterval)
+
+	// todo 變更的即時推送：獨立的連線 LISTEN，再分送給 GET /todos/stream 的連線
+	todoHub := realtime.NewHub(cfg.StreamBufferSize)
+	realtime.StartListener(jobsCtx, cfg.DatabaseURL, todoHub)
+
 	// =========================
 	// 暫時停用 GCS 相關初始化
 	// 等 Render 上的 GCS credentials 設定好後再打開
@@ -61,14 +159,38 @@ func main() {
 	// 建立 user service
 	// userService := service.NewUserService(pool, imageRepo)
 
+	// 清單分享相關的權限檢查都在 service 層
+	todoListService := service.NewTodoListService(pool)
+	todoItemService := service.NewTodoItemService(pool)
+	bulkTodoService := service.NewBulkTodoService(pool)
+	syncService := service.NewSyncService(pool)
+	attachmentService := service.NewTodoAttachmentService(pool, blobs, cfg.AttachmentMaxBytes, cfg.AttachmentQuotaBytes)
+	timeService := service.NewTimeTrackingService(pool)
+	labelService := service.NewLabelService(pool)
+	verificationService := service.NewEmailVerificationService(pool, mailSender, verificationSecret, cfg.EmailVerificationURL, cfg.EmailVerificationTTL, cfg.EmailResendCooldown)
+	authService := service.NewAuthService(pool, revocations, jwtKeys, tokenOpts, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+
+	// POST /todos/quick 解析「明天」「next Friday」時的現在時間
+	quickAddParser := quickadd.NewParser(time.Now)
+
+	// 列表 API 的 cursor 分頁 token 都用同一把 key 簽
+	cursorCodec := pagination.NewCodec(cfg.CursorSecret)
+
+	// 需要登入的路由都掛這個
+	authRequired := middleware.AuthMiddleware(jwtKeys, revocations, tokenOpts)
+	// 還沒驗證 email 的帳號不能用的功能：分享清單給別人、上傳檔案、匯入；要掛在 authRequired 後面
+	verifiedRequired := middleware.RequireVerifiedEmail(pool)
+
 	// create server
 	var router *gin.Engine = gin.Default()
 	router.SetTrustedProxies(nil)
 
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowOrigins: []string{"http://localhost:3000"},
+		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", "If-None-Match", "Last-Event-ID"},
+		// 前端要讀得到 ETag 才能在更新時帶 If-Match
+		ExposeHeaders:    []string{"ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -86,17 +208,90 @@ func main() {
 	})
 
 	// Todo routes
-	router.POST("/todos", handlers.CreateTodoHandler(pool))
-	router.GET("/todos", handlers.GetTodosHandler(pool))
-	router.GET("/todos/:id", handlers.GetTodoByIDHandler(pool))
-	router.PUT("/todos/:id", handlers.UpdateToDoHandler(pool))
+	// 每個 todo 都有擁有者，所以整組路由都要先經過 AuthMiddleware 拿到 user_id
+	todoRoutes := router.Group("/todos", authRequired)
+	todoRoutes.POST("", handlers.CreateTodoHandler(pool))
+	todoRoutes.GET("", handlers.GetTodosHandler(pool, cursorCodec))
+	todoRoutes.POST("/bulk", handlers.BulkTodoHandler(bulkTodoService))
+	todoRoutes.POST("/quick", handlers.QuickAddTodoHandler(quickAddParser, labelService))
+	todoRoutes.GET("/stream", handlers.StreamTodosHandler(pool, todoHub))
+	todoRoutes.GET("/export", handlers.ExportTodosHandler(pool))
+	todoRoutes.POST("/import", verifiedRequired, handlers.ImportTodosHandler(pool))
+	todoRoutes.GET("/trash", handlers.GetTrashHandler(pool))
+	todoRoutes.GET("/recurrence/preview", handlers.RecurrencePreviewHandler())
+	todoRoutes.DELETE("/trash", handlers.EmptyTrashHandler(pool))
+	todoRoutes.GET("/:id", handlers.GetTodoByIDHandler(pool))
+	todoRoutes.PUT("/:id", handlers.UpdateToDoHandler(pool))
+	todoRoutes.PATCH("/:id", handlers.PatchTodoHandler(pool))
+	todoRoutes.DELETE("/:id", handlers.DeleteTodoHandler(pool))
+	todoRoutes.POST("/:id/restore", handlers.RestoreTodoHandler(pool))
+	todoRoutes.DELETE("/:id/purge", handlers.PurgeTodoHandler(pool))
+	todoRoutes.POST("/:id/move", handlers.MoveTodoHandler(pool))
+	todoRoutes.GET("/:id/history", handlers.GetTodoHistoryHandler(pool))
+	todoRoutes.POST("/:id/undo", handlers.UndoTodoHandler(pool))
+	todoRoutes.GET("/:id/items", handlers.GetTodoItemsHandler(todoItemService))
+	todoRoutes.POST("/:id/items", handlers.CreateTodoItemHandler(todoItemService))
+	todoRoutes.PUT("/:id/items/:itemId", handlers.UpdateTodoItemHandler(todoItemService))
+	todoRoutes.DELETE("/:id/items/:itemId", handlers.DeleteTodoItemHandler(todoItemService))
+	todoRoutes.POST("/:id/attachments", verifiedRequired, handlers.UploadTodoAttachmentHandler(attachmentService))
+	todoRoutes.GET("/:id/attachments", handlers.GetTodoAttachmentsHandler(attachmentService))
+	todoRoutes.GET("/:id/attachments/:attachmentId", handlers.DownloadTodoAttachmentHandler(attachmentService))
+	todoRoutes.DELETE("/:id/attachments/:attachmentId", handlers.DeleteTodoAttachmentHandler(attachmentService))
+	todoRoutes.POST("/:id/timer/start", handlers.StartTimerHandler(timeService))
+	todoRoutes.POST("/:id/timer/stop", handlers.StopTimerHandler(timeService))
+	todoRoutes.GET("/:id/time-entries", handlers.GetTimeEntriesHandler(timeService))
+	todoRoutes.POST("/:id/time-entries", handlers.CreateTimeEntryHandler(timeService))
+	todoRoutes.PUT("/:id/time-entries/:entryId", handlers.UpdateTimeEntryHandler(timeService))
+	todoRoutes.DELETE("/:id/time-entries/:entryId", handlers.DeleteTimeEntryHandler(timeService))
+	todoRoutes.POST("/:id/labels", handlers.AddTodoLabelsHandler(labelService))
+	todoRoutes.DELETE("/:id/labels/:labelId", handlers.RemoveTodoLabelHandler(labelService))
+
+	// 計時：目前正在跑的計時器、時間報表
+	router.GET("/timer", authRequired, handlers.GetRunningTimerHandler(timeService))
+	router.GET("/reports/time", authRequired, handlers.GetTimeReportHandler(timeService))
+
+	// 標籤（每個使用者自己的，用來分類 todo）
+	labelRoutes := router.Group("/labels", authRequired)
+	labelRoutes.GET("", handlers.GetLabelsHandler(labelService))
+	labelRoutes.POST("", handlers.CreateLabelHandler(labelService))
+	labelRoutes.PUT("/:id", handlers.UpdateLabelHandler(labelService))
+	labelRoutes.DELETE("/:id", handlers.DeleteLabelHandler(labelService))
+	labelRoutes.POST("/:id/merge", handlers.MergeLabelHandler(labelService))
+
+	// 離線同步（行動版 client）
+	syncRoutes := router.Group("/sync", authRequired)
+	syncRoutes.GET("", handlers.GetSyncHandler(syncService, cursorCodec, cfg.SyncRetention))
+	syncRoutes.POST("", handlers.PostSyncHandler(syncService))
+
+	// Todo list routes（清單 / 專案，可以分享給其他人）
+	listRoutes := router.Group("/lists", authRequired)
+	listRoutes.POST("", handlers.CreateTodoListHandler(todoListService))
+	listRoutes.GET("", handlers.GetTodoListsHandler(todoListService))
+	listRoutes.GET("/:id", handlers.GetTodoListHandler(todoListService))
+	listRoutes.DELETE("/:id", handlers.DeleteTodoListHandler(todoListService))
+	listRoutes.GET("/:id/members", handlers.GetTodoListMembersHandler(todoListService))
+	listRoutes.DELETE("/:id/members/:userId", handlers.RemoveTodoListMemberHandler(todoListService))
+	listRoutes.POST("/:id/invites", verifiedRequired, handlers.InviteTodoListMemberHandler(todoListService))
+	listRoutes.GET("/:id/invites", handlers.GetTodoListInvitesHandler(todoListService))
+	listRoutes.DELETE("/:id/invites/:inviteId", handlers.RevokeTodoListInviteHandler(todoListService))
+	listRoutes.GET("/:id/todos", handlers.GetTodoListTodosHandler(todoListService, cursorCodec))
+	listRoutes.POST("/:id/todos", handlers.CreateTodoListTodoHandler(todoListService))
+	listRoutes.PUT("/:id/todos/:todoId", handlers.UpdateTodoListTodoHandler(todoListService))
+	router.POST("/invites/accept", authRequired, verifiedRequired, handlers.AcceptTodoListInviteHandler(todoListService))
 
 	// Auth routes
-	router.POST("/auth/register", handlers.CreateUserHandler(pool))
-	router.POST("/auth/login", handlers.LoginHandler(pool, cfg))
+	router.POST("/auth/register", handlers.CreateUserHandler(pool, verificationService))
+	router.POST("/auth/verify-email", handlers.VerifyEmailHandler(verificationService))
+	router.POST("/auth/resend-verification", authRequired, handlers.ResendVerificationHandler(verificationService))
+	router.GET("/.well-known/jwks.json", handlers.JWKSHandler(jwtKeys))
+	router.POST("/auth/login", handlers.LoginHandler(authService, cfg))
+	router.POST("/auth/refresh", handlers.RefreshTokenHandler(authService, cfg))
+	router.POST("/auth/logout", authRequired, handlers.LogoutHandler(authService, cfg))
+	router.POST("/auth/logout-all", authRequired, handlers.LogoutAllHandler(authService, cfg))
+	router.PUT("/auth/password", authRequired, handlers.ChangePasswordHandler(authService, cfg))
 
 	// Article routes
-	router.GET("/articles", handlers.GetArticlesHandler(pool))
+	router.GET("/articles", handlers.GetArticlesHandler(pool, cursorCodec))
 
 	// User routes
 	// 這條就是之後用 Postman / 前端測試頭像上傳的 API
@@ -104,12 +299,13 @@ func main() {
 	// router.PUT("/users/:id/profile-image", handlers.SetProfileImageHandler(userService))
 
 	// Middleware test route
-	router.GET("/protected-test", middleware.AuthMiddleware(cfg), handlers.TestProtectedHandler())
+	router.GET("/protected-test", authRequired, handlers.TestProtectedHandler())
 
 	// Product routes
 	router.POST("/products", handlers.CreatteProductHandler(pool))
-	router.GET("/products", handlers.GetAllProductsHandler(pool))
+	router.GET("/products", handlers.GetAllProductsHandler(pool, cursorCodec))
 	router.PUT("/products/:id", handlers.UpdateProductHandler(pool))
+	router.PATCH("/products/:id", handlers.PatchProductHandler(pool))
 	router.GET("/products/:id", handlers.GetProductByIDHandler(pool))
 	router.GET("/products/search", handlers.ListProductsHandler(pool))
 
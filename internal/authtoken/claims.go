@@ -0,0 +1,125 @@
+/*
+Package authtoken 定義 access token 的 claims，以及驗證 token 的規則：
+
+  - 簽章、演算法、kid 交給 jwtkeys.Manager
+  - exp / iat / jti / user_id 一定要有；nbf 有的話也會檢查；iss / aud 依設定檢查
+  - 時間相關的檢查都允許 Leeway 的時鐘誤差
+  - 驗證失敗一律回傳 *Error，Code 是固定的字串，前端可以依 Code 決定要 refresh 還是重新登入
+*/
+package authtoken
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"todo_api/internal/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// 驗證 token 的設定，來自 config.Config；Issuer / Audience 是空字串就不檢查
+type Options struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// access token 的內容；sub 跟 user_id 都是使用者 id，user_id 是給舊的 client 用的
+type Claims struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	// 發 token 時已經驗證過 email；false 不代表現在還沒驗證（可能是發 token 之後才驗證），RequireVerifiedEmail 會再查資料庫
+	EmailVerified bool `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// 發 token 時用：jti 是新的 UUID，iat / nbf 都是 now
+func NewClaims(userID string, email string, now time.Time, ttl time.Duration, opts Options) *Claims {
+	claims := &Claims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(), // 登出時用來撤銷這一個 token
+			Subject:   userID,
+			Issuer:    opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)), // 秒數時間戳
+		},
+	}
+	if opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{opts.Audience}
+	}
+	return claims
+}
+
+// jwt.Parser 驗完 exp / nbf / iat / iss / aud 之後會呼叫這裡，檢查我們自己一定要有的欄位
+func (c *Claims) Validate() error {
+	if c.UserID == "" {
+		return errors.New("user_id is required")
+	}
+	if c.Subject != "" && c.Subject != c.UserID {
+		return errors.New("sub does not match user_id")
+	}
+	// 沒有 jti 沒辦法撤銷，沒有 iat 沒辦法套用 tokens_valid_after
+	if c.ID == "" {
+		return errors.New("jti is required")
+	}
+	if c.IssuedAt == nil {
+		return errors.New("iat is required")
+	}
+	return nil
+}
+
+// 驗證簽章跟 claims，失敗回傳 *Error
+func Parse(tokenString string, keys *jwtkeys.Manager, opts Options) (*Claims, error) {
+	// 演算法由 keys.Keyfunc 檢查（alg=none 一定不行），不用 jwt.WithValidMethods：它擋掉的跟簽章錯誤是同一個錯誤，分不出 code
+	parserOptions := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(opts.Audience))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc, parserOptions...)
+	if err != nil {
+		// jwt 把 claim 型別不對（例如 "user_id": 123）也包成 ErrTokenMalformed，這種是 claims 的問題，不是格式的問題
+		if errors.Is(err, jwt.ErrTokenMalformed) && claimTypeMismatch(tokenString) {
+			return nil, NewError(CodeInvalidClaims, err)
+		}
+		return nil, classify(err)
+	}
+	if !token.Valid {
+		return nil, NewError(CodeInvalidToken, nil)
+	}
+	return claims, nil
+}
+
+// header 跟 claims 兩段都是合法的 JSON object，ParseWithClaims 還是失敗，代表是某個 claim 的型別跟 Claims 對不上
+func claimTypeMismatch(tokenString string) bool {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, segment := range parts[:2] {
+		raw, err := base64.RawURLEncoding.DecodeString(segment)
+		if err != nil {
+			return false
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,259 @@
+package authtoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"todo_api/internal/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-hmac-secret"
+
+var testOpts = Options{Issuer: "todo_api", Audience: "todo_api", Leeway: 30 * time.Second}
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	manager *jwtkeys.Manager // r1（RS256，active）+ e1（ES256）+ HS256 secret
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r1, err := jwtkeys.NewKey("r1", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e1, err := jwtkeys.NewKey("e1", ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := jwtkeys.NewManager([]*jwtkeys.Key{r1, e1}, "r1", testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKeys{rsa: rsaKey, ec: ecKey, manager: manager}
+}
+
+// 手動簽一個 token，header 可以帶任意的 kid
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func segment(t *testing.T, value any) string {
+	t.Helper()
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// 合法的 claims；with 改一個欄位，value 是 nil 代表拿掉
+func validClaims(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": "u1",
+		"email":   "a@example.com",
+		"sub":     "u1",
+		"jti":     "jti-1",
+		"iss":     "todo_api",
+		"aud":     "todo_api",
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Minute).Unix(),
+	}
+}
+
+func with(now time.Time, name string, value any) jwt.MapClaims {
+	claims := validClaims(now)
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestParseAcceptsIssuedTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+
+	token, err := keys.manager.Sign(NewClaims("u1", "a@example.com", now, time.Minute, testOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := Parse(token, keys.manager, testOpts)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if claims.UserID != "u1" || claims.Email != "a@example.com" || claims.ID == "" {
+		t.Fatalf("Parse() claims = %+v", claims)
+	}
+}
+
+func TestParseRejectsForgedAndMalformedTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+
+	pubDER, err := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", validClaims(now))
+	parts := strings.Split(good, ".")
+
+	// 沒有 JWT_SECRET 的設定：HS256 完全不接受
+	rsaOnly, err := jwtkeys.NewManager([]*jwtkeys.Key{mustKey(t, "r1", keys.rsa)}, "r1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		manager *jwtkeys.Manager
+		want    string // "" 代表要驗證成功
+	}{
+		// alg=none：不管有沒有簽章、有沒有 kid 都不接受
+		{"alg none", segment(t, map[string]string{"alg": "none", "typ": "JWT"}) + "." + segment(t, validClaims(now)) + ".", nil, CodeUnsupportedAlg},
+		{"alg none with signature", segment(t, map[string]string{"alg": "none"}) + "." + segment(t, validClaims(now)) + ".c2ln", nil, CodeUnsupportedAlg},
+		{"alg none with kid", segment(t, map[string]string{"alg": "none", "kid": "r1"}) + "." + segment(t, validClaims(now)) + ".", nil, CodeUnsupportedAlg},
+
+		// algorithm confusion：拿 RSA public key 當 HMAC secret
+		{"HS256 signed with RSA public PEM", sign(t, jwt.SigningMethodHS256, pubPEM, "r1", validClaims(now)), nil, CodeInvalidSignature},
+		{"HS256 signed with RSA public PEM, HMAC disabled", sign(t, jwt.SigningMethodHS256, pubPEM, "r1", validClaims(now)), rsaOnly, CodeUnsupportedAlg},
+		{"HS256 with wrong secret", sign(t, jwt.SigningMethodHS256, []byte("guess"), "", validClaims(now)), nil, CodeInvalidSignature},
+
+		// kid 跟 alg
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, keys.rsa, "nope", validClaims(now)), nil, CodeUnknownKey},
+		{"missing kid", sign(t, jwt.SigningMethodRS256, keys.rsa, "", validClaims(now)), nil, CodeUnknownKey},
+		{"ES256 with RSA kid", sign(t, jwt.SigningMethodES256, keys.ec, "r1", validClaims(now)), nil, CodeUnsupportedAlg},
+		{"RS256 with EC kid", sign(t, jwt.SigningMethodRS256, keys.rsa, "e1", validClaims(now)), nil, CodeUnsupportedAlg},
+		{"PS256 not accepted", sign(t, jwt.SigningMethodPS256, keys.rsa, "r1", validClaims(now)), nil, CodeUnsupportedAlg},
+
+		// 簽章
+		{"signed by another RSA key", sign(t, jwt.SigningMethodRS256, otherRSA, "r1", validClaims(now)), nil, CodeInvalidSignature},
+		{"tampered payload", parts[0] + "." + segment(t, with(now, "user_id", "admin")) + "." + parts[2], nil, CodeInvalidSignature},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])), nil, CodeInvalidSignature},
+
+		// 時間，Leeway 是 30 秒
+		{"expired", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "exp", now.Add(-time.Minute).Unix())), nil, CodeTokenExpired},
+		{"expired within leeway", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "exp", now.Add(-10*time.Second).Unix())), nil, ""},
+		{"nbf in the future", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "nbf", now.Add(time.Minute).Unix())), nil, CodeTokenNotYetValid},
+		{"nbf within leeway", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "nbf", now.Add(10*time.Second).Unix())), nil, ""},
+		{"iat in the future", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "iat", now.Add(time.Minute).Unix())), nil, CodeTokenNotYetValid},
+
+		// iss / aud
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "iss", "someone-else")), nil, CodeInvalidIssuer},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "aud", "someone-else")), nil, CodeInvalidAudience},
+		{"audience list containing ours", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "aud", []string{"other", "todo_api"})), nil, ""},
+		{"missing issuer", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "iss", nil)), nil, CodeInvalidClaims},
+
+		// 必要的 claims
+		{"missing user_id", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "user_id", nil)), nil, CodeInvalidClaims},
+		{"missing jti", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "jti", nil)), nil, CodeInvalidClaims},
+		{"missing iat", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "iat", nil)), nil, CodeInvalidClaims},
+		{"missing exp", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "exp", nil)), nil, CodeInvalidClaims},
+		{"sub differs from user_id", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "sub", "u2")), nil, CodeInvalidClaims},
+
+		// claim 型別不對
+		{"user_id is a number", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "user_id", 123)), nil, CodeInvalidClaims},
+		{"exp is a string", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "exp", "tomorrow")), nil, CodeInvalidClaims},
+		{"aud is a number", sign(t, jwt.SigningMethodRS256, keys.rsa, "r1", with(now, "aud", 42)), nil, CodeInvalidClaims},
+
+		// 格式
+		{"empty", "", nil, CodeMalformedToken},
+		{"not a jwt", "not-a-token", nil, CodeMalformedToken},
+		{"two segments", "abc.def", nil, CodeMalformedToken},
+		{"invalid base64", "!!.!!.!!", nil, CodeMalformedToken},
+		{"header is not JSON", base64.RawURLEncoding.EncodeToString([]byte("{")) + "." + segment(t, validClaims(now)) + ".sig", nil, CodeMalformedToken},
+		{"claims are not an object", parts[0] + "." + segment(t, []string{"u1"}) + "." + parts[2], nil, CodeMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := tt.manager
+			if manager == nil {
+				manager = keys.manager
+			}
+
+			_, err := Parse(tt.token, manager, testOpts)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Parse() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var authErr *Error
+			if !errors.As(err, &authErr) {
+				t.Fatalf("Parse() error = %v (%T), want *Error with code %q", err, err, tt.want)
+			}
+			if authErr.Code != tt.want {
+				t.Fatalf("Parse() code = %q, want %q (%v)", authErr.Code, tt.want, authErr.Err)
+			}
+		})
+	}
+}
+
+func TestErrorDoesNotLeakParserText(t *testing.T) {
+	keys := newTestKeys(t)
+
+	_, err := Parse("not-a-token", keys.manager, testOpts)
+	if err == nil {
+		t.Fatal("Parse() error = nil")
+	}
+	if got, want := err.Error(), codeMessages[CodeMalformedToken]; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+}
+
+func mustKey(t *testing.T, kid string, key *rsa.PrivateKey) *jwtkeys.Key {
+	t.Helper()
+
+	k, err := jwtkeys.NewKey(kid, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
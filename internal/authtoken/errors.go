@@ -0,0 +1,97 @@
+package authtoken
+
+import (
+	"errors"
+	"net/http"
+
+	"todo_api/internal/jwtkeys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 回應裡的 code，前端依這個判斷；這些字串不能改
+const (
+	CodeMissingToken       = "missing_token"         // 沒有 Authorization header
+	CodeInvalidHeader      = "invalid_auth_header"   // 不是 Bearer <token>
+	CodeMalformedToken     = "malformed_token"       // 不是 JWT 的格式
+	CodeUnsupportedAlg     = "unsupported_algorithm" // alg 不接受（包含 none）或跟 kid 的 key 不符
+	CodeUnknownKey         = "unknown_key"           // kid 找不到
+	CodeInvalidSignature   = "invalid_signature"
+	CodeTokenExpired       = "token_expired" // 前端可以用 refresh token 換新的
+	CodeTokenNotYetValid   = "token_not_yet_valid"
+	CodeInvalidIssuer      = "invalid_issuer"
+	CodeInvalidAudience    = "invalid_audience"
+	CodeInvalidClaims      = "invalid_claims" // 少了必要的 claim 或型別不對
+	CodeTokenRevoked       = "token_revoked"  // 已經登出，要重新登入
+	CodeInvalidToken       = "invalid_token"
+	CodeVerificationFailed = "auth_unavailable" // 伺服器查不到撤銷狀態，不是 token 的問題
+)
+
+// 固定的錯誤訊息，不把 jwt 套件的錯誤原文回給 client
+var codeMessages = map[string]string{
+	CodeMissingToken:       "authorization header required",
+	CodeInvalidHeader:      "invalid authorization header format",
+	CodeMalformedToken:     "token is malformed",
+	CodeUnsupportedAlg:     "token signing algorithm is not accepted",
+	CodeUnknownKey:         "token signing key is unknown",
+	CodeInvalidSignature:   "token signature is invalid",
+	CodeTokenExpired:       "token expired",
+	CodeTokenNotYetValid:   "token is not valid yet",
+	CodeInvalidIssuer:      "token issuer is invalid",
+	CodeInvalidAudience:    "token audience is invalid",
+	CodeInvalidClaims:      "invalid token claims",
+	CodeTokenRevoked:       "token has been revoked",
+	CodeInvalidToken:       "invalid token",
+	CodeVerificationFailed: "could not verify token",
+}
+
+// 驗證失敗的原因；Err 是原始錯誤，只用來寫 log
+type Error struct {
+	Code string
+	Err  error
+}
+
+func NewError(code string, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+func (e *Error) Error() string {
+	return codeMessages[e.Code]
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// 伺服器自己的問題回 500，其他都是 401
+func (e *Error) Status() int {
+	if e.Code == CodeVerificationFailed {
+		return http.StatusInternalServerError
+	}
+	return http.StatusUnauthorized
+}
+
+// 把 jwt.ParseWithClaims 的錯誤轉成固定的 code；順序重要，例如簽章錯誤的 token 也可能同時過期
+func classify(err error) *Error {
+	switch {
+	case errors.Is(err, jwtkeys.ErrUnknownKey):
+		return NewError(CodeUnknownKey, err)
+	case errors.Is(err, jwtkeys.ErrAlgorithmMismatch), errors.Is(err, jwtkeys.ErrAlgorithmNotAccepted):
+		return NewError(CodeUnsupportedAlg, err)
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return NewError(CodeMalformedToken, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return NewError(CodeInvalidSignature, err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return NewError(CodeTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return NewError(CodeTokenNotYetValid, err)
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return NewError(CodeInvalidIssuer, err)
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return NewError(CodeInvalidAudience, err)
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing), errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return NewError(CodeInvalidClaims, err)
+	}
+	return NewError(CodeInvalidToken, err)
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +14,85 @@ type Config struct {
 	Port          string
 	JWTSecret     string
 	GCSBucketName string
+
+	// 簽 cursor 分頁 token 用的 key，沒設定就沿用 JWTSecret
+	CursorSecret string
+
+	// 垃圾桶裡的 todo 保留多久才被背景工作永久刪除，以及多久檢查一次
+	TrashRetention       time.Duration
+	TrashCleanupInterval time.Duration
+
+	// 提醒排程多久檢查一次 remind_at
+	ReminderInterval time.Duration
+
+	// 多久檢查一次手動排序的鍵是不是太長、需要重新分配
+	RankRebalanceInterval time.Duration
+
+	// GET /todos/stream 每條連線最多暫存幾筆還沒送出的通知，滿了就斷線讓 client 重連
+	StreamBufferSize int
+
+	// 離線同步的 token 可以用多久，墓碑跟 mutation 紀錄也保留這麼久，以及多久清一次
+	SyncRetention       time.Duration
+	SyncCleanupInterval time.Duration
+
+	// 附件檔案放哪裡：gcs（GCSBucketName）或 local（預設，放在 BlobLocalDir）
+	BlobBackend  string
+	BlobLocalDir string
+	// 單一附件的大小上限，跟每個使用者所有附件加起來的上限
+	AttachmentMaxBytes   int64
+	AttachmentQuotaBytes int64
+	// 多久清一次已經刪除的附件檔案
+	BlobCleanupInterval time.Duration
+
+	// 計時器最多跑多久，超過就當作忘了停、由背景工作自動停掉，以及多久檢查一次
+	TimerMaxDuration   time.Duration
+	TimerSweepInterval time.Duration
+
+	// access token 的非對稱簽章 key（RS256 / ES256 / EdDSA），格式見 jwtkeys.Load
+	// JWTKeyFiles：逗號分隔的 PEM 檔（可以寫成 kid=path）；JWTKeys：直接放 PEM 內容
+	// JWTActiveKID：用哪一把簽，沒設定就用第一把有 private key 的；都沒設定就繼續用 JWTSecret（HS256）
+	JWTKeyFiles  string
+	JWTKeys      string
+	JWTActiveKID string
+
+	// access token 的 iss / aud，發 token 時寫進去、驗證時檢查；設成空字串就不檢查
+	// JWTLeeway：exp / nbf / iat 允許的時鐘誤差（多台機器的時間不會完全一樣）
+	JWTIssuer   string
+	JWTAudience string
+	JWTLeeway   time.Duration
+
+	// access token（JWT）跟 refresh token 的有效期限
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// 多久清一次過期的 refresh token
+	RefreshTokenCleanupInterval time.Duration
+	// refresh token cookie 只走 HTTPS；本機用 http 測試時設 REFRESH_COOKIE_SECURE=false
+	RefreshCookieSecure bool
+
+	// 撤銷檢查的快取：最多記幾筆，以及沒撤銷的結果快取多久（多台機器時別台撤銷最晚這麼久之後生效）
+	RevocationCacheSize int
+	RevocationCacheTTL  time.Duration
+	// 多久清一次過期的撤銷紀錄（資料庫跟快取）；撤銷紀錄只保留到 access token 過期，所以預設跟 access token 的有效期限一樣
+	RevocationCleanupInterval time.Duration
+
+	// 寄信的方式：smtp、file（預設，存成 MailOutboxDir 裡的 .eml 檔，本機開發用）或 memory（測試用，只留在記憶體）
+	MailerBackend string
+	MailOutboxDir string
+	MailFrom      string // 寄件人，只能是 email address，例如 no-reply@example.com
+	SMTPHost      string
+	SMTPPort      int
+	SMTPUsername  string
+	SMTPPassword  string
+
+	// 驗證信：連結是 EmailVerificationURL?token=...（前端的頁面，再由前端呼叫 POST /auth/verify-email）
+	// EmailVerificationSecret 簽 token 用，沒設定就沿用 JWTSecret
+	EmailVerificationURL    string
+	EmailVerificationSecret string
+	EmailVerificationTTL    time.Duration
+	// 同一個使用者兩次寄驗證信至少要隔多久
+	EmailResendCooldown time.Duration
+	// 多久清一次過期、用過的驗證 token
+	EmailVerificationCleanupInterval time.Duration
 }
 
 func Load() (*Config, error) {
@@ -29,12 +110,91 @@ func Load() (*Config, error) {
 		Port:          os.Getenv("PORT"),
 		JWTSecret:     os.Getenv("JWT_SECRET"),
 		GCSBucketName: os.Getenv("GCS_BUCKET_NAME"),
+
+		JWTKeyFiles:  os.Getenv("JWT_KEY_FILES"),
+		JWTKeys:      os.Getenv("JWT_KEYS"),
+		JWTActiveKID: os.Getenv("JWT_ACTIVE_KID"),
+		JWTLeeway:    time.Duration(getEnvInt("JWT_LEEWAY_SECONDS", 30)) * time.Second,
+
+		TrashRetention:       time.Duration(getEnvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		TrashCleanupInterval: time.Duration(getEnvInt("TRASH_CLEANUP_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		ReminderInterval: time.Duration(getEnvInt("REMINDER_INTERVAL_SECONDS", 60)) * time.Second,
+
+		RankRebalanceInterval: time.Duration(getEnvInt("RANK_REBALANCE_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		StreamBufferSize: getEnvInt("STREAM_BUFFER_SIZE", 64),
+
+		SyncRetention:       time.Duration(getEnvInt("SYNC_RETENTION_DAYS", 90)) * 24 * time.Hour,
+		SyncCleanupInterval: time.Duration(getEnvInt("SYNC_CLEANUP_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		BlobBackend:          os.Getenv("BLOB_STORAGE"),
+		BlobLocalDir:         os.Getenv("BLOB_LOCAL_DIR"),
+		AttachmentMaxBytes:   int64(getEnvInt("ATTACHMENT_MAX_MB", 10)) << 20,
+		AttachmentQuotaBytes: int64(getEnvInt("ATTACHMENT_QUOTA_MB", 100)) << 20,
+		BlobCleanupInterval:  time.Duration(getEnvInt("BLOB_CLEANUP_INTERVAL_MINUTES", 5)) * time.Minute,
+
+		TimerMaxDuration:   time.Duration(getEnvInt("TIMER_MAX_HOURS", 12)) * time.Hour,
+		TimerSweepInterval: time.Duration(getEnvInt("TIMER_SWEEP_INTERVAL_MINUTES", 5)) * time.Minute,
+
+		AccessTokenTTL:      time.Duration(getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15)) * time.Minute,
+		RefreshTokenTTL:     time.Duration(getEnvInt("REFRESH_TOKEN_TTL_DAYS", 30)) * 24 * time.Hour,
+		RefreshCookieSecure: os.Getenv("REFRESH_COOKIE_SECURE") != "false",
+
+		RefreshTokenCleanupInterval: time.Duration(getEnvInt("REFRESH_TOKEN_CLEANUP_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		RevocationCacheSize: getEnvInt("REVOCATION_CACHE_SIZE", 10000),
+		RevocationCacheTTL:  time.Duration(getEnvInt("REVOCATION_CACHE_TTL_SECONDS", 30)) * time.Second,
+
+		RevocationCleanupInterval: time.Duration(getEnvInt("REVOCATION_CLEANUP_INTERVAL_MINUTES", 15)) * time.Minute,
+
+		MailerBackend: os.Getenv("MAILER"),
+		MailOutboxDir: os.Getenv("MAIL_OUTBOX_DIR"),
+		MailFrom:      os.Getenv("MAIL_FROM"),
+		SMTPHost:      os.Getenv("SMTP_HOST"),
+		SMTPPort:      getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:  os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:  os.Getenv("SMTP_PASSWORD"),
+
+		EmailVerificationURL:    os.Getenv("EMAIL_VERIFICATION_URL"),
+		EmailVerificationSecret: os.Getenv("EMAIL_VERIFICATION_SECRET"),
+		EmailVerificationTTL:    time.Duration(getEnvInt("EMAIL_VERIFICATION_TTL_HOURS", 24)) * time.Hour,
+		EmailResendCooldown:     time.Duration(getEnvInt("EMAIL_RESEND_COOLDOWN_SECONDS", 60)) * time.Second,
+
+		EmailVerificationCleanupInterval: time.Duration(getEnvInt("EMAIL_VERIFICATION_CLEANUP_INTERVAL_MINUTES", 60)) * time.Minute,
 	}
 
 	// 可選：本機預設值
 	if cfg.Port == "" {
 		cfg.Port = "8080"
 	}
+	if cfg.BlobBackend == "" {
+		cfg.BlobBackend = "local"
+	}
+	if cfg.BlobLocalDir == "" {
+		cfg.BlobLocalDir = "uploads"
+	}
+	if cfg.MailerBackend == "" {
+		cfg.MailerBackend = "file"
+	}
+	if cfg.MailOutboxDir == "" {
+		cfg.MailOutboxDir = "outbox"
+	}
+	if cfg.MailFrom == "" {
+		cfg.MailFrom = "no-reply@localhost"
+	}
+	if cfg.EmailVerificationURL == "" {
+		cfg.EmailVerificationURL = "http://localhost:3000/verify-email"
+	}
+	cfg.JWTIssuer = getEnvString("JWT_ISSUER", "todo_api")
+	cfg.JWTAudience = getEnvString("JWT_AUDIENCE", "todo_api")
+	cfg.CursorSecret = os.Getenv("CURSOR_SECRET")
+	if cfg.CursorSecret == "" {
+		cfg.CursorSecret = cfg.JWTSecret
+	}
+	if cfg.EmailVerificationSecret == "" {
+		cfg.EmailVerificationSecret = cfg.JWTSecret
+	}
 	log.Printf("DatabaseURL: %q", cfg.DatabaseURL)
 	if cfg.DatabaseURL == "" {
 		log.Println("warning: DATABASE_URL is empty")
@@ -42,3 +202,27 @@ func Load() (*Config, error) {
 
 	return cfg, nil
 }
+
+// 讀整數型的環境變數，沒設定或格式錯誤時用預設值，不要讓服務因為設定打錯就起不來
+func getEnvInt(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		log.Printf("warning: invalid %s=%q, using default %d", key, raw, fallback)
+		return fallback
+	}
+
+	return value
+}
+
+// 讀字串型的環境變數，沒設定時用預設值；明確設成空字串則保留空字串（例如 JWT_AUDIENCE= 代表不檢查 aud）
+func getEnvString(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -19,9 +19,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
+	"todo_api/internal/pagination"
 	"todo_api/internal/repository"
 
 	"github.com/gin-gonic/gin"
@@ -30,35 +31,33 @@ import (
 
 // 用途：
 // 這支 handler 是文章列表 API 的入口，它負責：
-// 1. 從 query string 讀取 page、pageSize、tag、difficulty
+// 1. 從 query string 讀取 page、pageSize（或 cursor）、tag、difficulty
 // 2. 做基本型別轉換與防呆
 // 3. 呼叫 repository 去查資料
 // 4. 把結果回傳給前端
 
 // 同時篩選標籤 + 難度
 // GET /api/v1/articles?page=1&pageSize=10&tag=practical&difficulty=beginner
-func GetArticlesHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+// cursor 分頁：GET /api/v1/articles?paging=cursor&pageSize=10 之後帶 cursor=<nextCursor>
+func GetArticlesHandler(pool *pgxpool.Pool, codec *pagination.Codec) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		pageStr := c.DefaultQuery("page", "1")
-
-		pageSizeStr := c.DefaultQuery("pageSize", "5")
-
 		tag := c.Query("tag")
 
 		difficulty := c.Query("difficulty")
 
-		// page 跟 pageSize 需要從 string 轉成 int，並且做基本的防呆
-		page, err := strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			page = 1
-		}
-		pageSize, err := strconv.Atoi(pageSizeStr)
-		if err != nil || pageSize < 1 {
-			pageSize = 5
+		// page / pageSize 或 cursor 的解析跟防呆都在 parsePagination
+		params, err := parsePagination(c, codec, 5)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		result, err := repository.GetArticles(pool, page, pageSize, tag, difficulty)
+		result, err := repository.GetArticles(pool, params, tag, difficulty)
 		if err != nil {
+			if errors.Is(err, pagination.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo_api/internal/config"
+	"todo_api/internal/jwtkeys"
+	"todo_api/internal/models"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refresh token 的 cookie 只送到 /auth 底下，一般 API 請求不會帶著它跑
+const (
+	refreshTokenCookie     = "refresh_token"
+	refreshTokenCookiePath = "/auth"
+)
+
+// refresh_token 沒放在 body 的話改讀 cookie
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func setRefreshTokenCookie(c *gin.Context, cfg *config.Config, token string, expiresAt time.Time) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    token,
+		Path:     refreshTokenCookiePath,
+		Expires:  expiresAt,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.RefreshCookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearRefreshTokenCookie(c *gin.Context, cfg *config.Config) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    "",
+		Path:     refreshTokenCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   cfg.RefreshCookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// useCookie 的話 refresh token 只放 cookie，body 裡不出現
+func writeTokenPair(c *gin.Context, cfg *config.Config, pair *models.TokenPair, useCookie bool) {
+	if useCookie {
+		setRefreshTokenCookie(c, cfg, pair.RefreshToken, pair.RefreshExpiresAt)
+		pair.RefreshToken = ""
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// 先看 body 再看 cookie；第二個回傳值表示是不是從 cookie 來的，回應時用同一種方式
+// required 是 false 的話兩邊都沒有也可以，回傳空字串
+func readRefreshToken(c *gin.Context, required bool) (string, bool, bool) {
+	var input RefreshTokenRequest
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false, false
+	}
+	if input.RefreshToken != "" {
+		return input.RefreshToken, false, true
+	}
+
+	if cookie, err := c.Cookie(refreshTokenCookie); err == nil && cookie != "" {
+		return cookie, true, true
+	}
+
+	if !required {
+		return "", false, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+	return "", false, false
+}
+
+// AuthMiddleware 放進 gin context 的 jti 跟 exp，取不到就回 401
+func currentToken(c *gin.Context) (string, time.Time, bool) {
+	jti := c.GetString("token_id")
+	expiresAt, ok := c.Get("token_expires_at")
+	if jti == "" || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return "", time.Time{}, false
+	}
+	return jti, expiresAt.(time.Time), true
+}
+
+/*
+POST /auth/refresh
+
+	{
+	    "refresh_token": "..."
+	}
+
+body 沒帶就讀 refresh_token cookie。每次都會換一個新的 refresh token，舊的不能再用；
+已經用過的 refresh token 再送來，整個登入（family）都會被撤銷，回 401 要重新登入
+*/
+func RefreshTokenHandler(authService *service.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, fromCookie, ok := readRefreshToken(c, true)
+		if !ok {
+			return
+		}
+
+		pair, err := authService.Refresh(c.Request.Context(), token, c.Request.UserAgent())
+		if err != nil {
+			if errors.Is(err, service.ErrRefreshTokenInvalid) || errors.Is(err, service.ErrRefreshTokenReused) {
+				if fromCookie {
+					clearRefreshTokenCookie(c, cfg)
+				}
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		writeTokenPair(c, cfg, pair, fromCookie)
+	}
+}
+
+/*
+POST /auth/logout（要帶 access token）
+
+目前這個 access token 馬上失效；body 或 cookie 有 refresh token 的話，這次登入換出來的 refresh token 也一起撤銷
+*/
+func LogoutHandler(authService *service.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		jti, expiresAt, ok := currentToken(c)
+		if !ok {
+			return
+		}
+
+		refreshToken, fromCookie, ok := readRefreshToken(c, false)
+		if !ok {
+			return
+		}
+
+		if err := authService.Logout(c.Request.Context(), userID, jti, expiresAt, refreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if fromCookie {
+			clearRefreshTokenCookie(c, cfg)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	}
+}
+
+// POST /auth/logout-all => 所有裝置都登出：之前發的 access token 跟 refresh token 全部失效，包含目前這一個
+func LogoutAllHandler(authService *service.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		if err := authService.LogoutAll(c.Request.Context(), userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		clearRefreshTokenCookie(c, cfg)
+		c.JSON(http.StatusOK, gin.H{"message": "logged out from all devices"})
+	}
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+	UseCookie       bool   `json:"use_cookie"`
+}
+
+/*
+PUT /auth/password
+
+	{
+	    "current_password": "old-secret",
+	    "new_password": "new-secret"
+	}
+
+其他裝置全部登出；回傳一組新的 token 給目前這個裝置（跟登入一樣，use_cookie 決定 refresh token 放哪裡）
+*/
+func ChangePasswordHandler(authService *service.AuthService, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input ChangePasswordRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pair, err := authService.ChangePassword(c.Request.Context(), userID, input.CurrentPassword, input.NewPassword, c.Request.UserAgent())
+		if err != nil {
+			if errors.Is(err, service.ErrWrongPassword) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		writeTokenPair(c, cfg, pair, input.UseCookie)
+	}
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+/*
+POST /auth/verify-email（不用登入，使用者可能在別的裝置上點信裡的連結）
+
+	{
+	    "token": "..."
+	}
+
+token 只能用一次；重寄之後舊信裡的 token 也不能用了
+*/
+func VerifyEmailHandler(verificationService *service.EmailVerificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input VerifyEmailRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		verifiedAt, err := verificationService.Verify(c.Request.Context(), input.Token)
+		if err != nil {
+			if errors.Is(err, service.ErrVerificationTokenInvalid) || errors.Is(err, service.ErrVerificationTokenExpired) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "email verified", "email_verified_at": verifiedAt})
+	}
+}
+
+// POST /auth/resend-verification（要帶 access token）=> 重寄驗證信，之前寄的連結全部失效
+func ResendVerificationHandler(verificationService *service.EmailVerificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		if err := verificationService.Resend(c.Request.Context(), userID); err != nil {
+			switch {
+			case errors.Is(err, service.ErrEmailAlreadyVerified):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, service.ErrVerificationResendTooSoon):
+				c.Header("Retry-After", strconv.Itoa(int(verificationService.ResendCooldown.Seconds())))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			default:
+				// 寄信失敗的原因（SMTP 的錯誤訊息）只寫 log
+				log.Printf("resend verification email: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+	}
+}
+
+// GET /.well-known/jwks.json => 驗證 access token 用的 public key（RFC 7517），其他服務依 token header 的 kid 挑 key
+func JWKSHandler(keys *jwtkeys.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		set, err := keys.JWKS()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 換 key 的時候新的 kid 要能在幾分鐘內被看到，不要快取太久
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, set)
+	}
+}
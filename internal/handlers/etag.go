@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag 直接用資料列的 version，例如 "3"；version 每次寫入都會 +1（見 migrations 的 bump_version）
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setVersionETag(c *gin.Context, version int) {
+	c.Header("ETag", versionETag(version))
+}
+
+// If-Match / If-None-Match 可以是 *，也可以是逗號分隔的多個 ETag
+// weak 為 true 時忽略 W/ 前綴（If-None-Match 用弱比對，If-Match 用強比對）
+func etagListMatches(header string, version int, weak bool) bool {
+	current := versionETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// GET 用：If-None-Match 對上目前的版本就回 304，呼叫端拿到 true 直接 return
+func notModified(c *gin.Context, version int) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" || !etagListMatches(header, version, true) {
+		return false
+	}
+
+	setVersionETag(c, version)
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// 更新用：有帶 If-Match 但對不上目前的版本就回 412，呼叫端拿到 false 直接 return
+// 沒帶 If-Match 不擋，寫入時一樣會比對讀出來的 version，中間被別人改過還是會 412
+func checkIfMatch(c *gin.Context, version int) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || etagListMatches(header, version, false) {
+		return true
+	}
+
+	setVersionETag(c, version)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "resource has been modified, fetch it again before updating"})
+	return false
+}
+
+// 不先讀資料的更新（例如 UpdateProduct）用：把 If-Match 轉成 version 交給 SQL 比對
+// 沒帶或是 * 回傳 nil（不限制）；格式不是我們發出去的 ETag 就不可能對上，直接回 412
+func ifMatchVersion(c *gin.Context) (*int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match must be a single ETag returned by this API"})
+		return nil, false
+	}
+
+	return &version, true
+}
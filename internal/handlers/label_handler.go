@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo_api/internal/repository"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateLabelRequest struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Color string `json:"color" binding:"omitempty,hexcolor,len=7"` // #rrggbb，沒傳就是灰色
+}
+
+// 只送要改的欄位
+type UpdateLabelRequest struct {
+	Name  *string `json:"name" binding:"omitempty,max=100"`
+	Color *string `json:"color" binding:"omitempty,hexcolor,len=7"`
+}
+
+type MergeLabelRequest struct {
+	Into string `json:"into" binding:"required,uuid"`
+}
+
+// label_ids 是現有的標籤，names 是用名稱指定（沒有的話會自動建立），至少要帶一個
+type AddTodoLabelsRequest struct {
+	LabelIDs []string `json:"label_ids" binding:"omitempty,dive,uuid"`
+	Names    []string `json:"names" binding:"omitempty,dive,required,max=100"`
+}
+
+func writeLabelError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrLabelNotFound), errors.Is(err, service.ErrTodoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrLabelSlugTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrUnknownLabel), errors.Is(err, service.ErrInvalidLabelName), errors.Is(err, service.ErrLabelMergeSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// 不是 UUID 的標籤一定找不到，不用送到資料庫；失敗時自己回 404
+func parseLabelID(c *gin.Context, param string) (string, bool) {
+	labelID := c.Param(param)
+	if _, err := uuid.Parse(labelID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrLabelNotFound.Error()})
+		return "", false
+	}
+	return labelID, true
+}
+
+// 解析 /todos/:id/labels/:labelId，失敗時自己回 400 / 404
+func parseTodoLabelIDs(c *gin.Context, withLabel bool) (int, string, bool) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+		return 0, "", false
+	}
+	if !withLabel {
+		return todoID, "", true
+	}
+
+	labelID, ok := parseLabelID(c, "labelId")
+	if !ok {
+		return 0, "", false
+	}
+	return todoID, labelID, true
+}
+
+// GET /labels => 自己的標籤（依名稱排序），todo_count 是目前掛了幾個 todo（不含垃圾桶）
+func GetLabelsHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		labels, err := labelService.List(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": labels})
+	}
+}
+
+/*
+POST /labels
+
+	{
+	    "name": "工作",
+	    "color": "#ff9800"
+	}
+
+同名（slug 相同，例如 Work 跟 work）的標籤已經存在回 409
+*/
+func CreateLabelHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input CreateLabelRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		label, err := labelService.Create(c.Request.Context(), userID, input.Name, input.Color)
+		if err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, label)
+	}
+}
+
+// PUT /labels/:id => 改名或換顏色，掛了這個標籤的 todo 會一起更新（version +1）
+func UpdateLabelHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		labelID, ok := parseLabelID(c, "id")
+		if !ok {
+			return
+		}
+
+		var input UpdateLabelRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		label, err := labelService.Update(c.Request.Context(), userID, labelID, service.LabelChanges{
+			Name:  input.Name,
+			Color: input.Color,
+		})
+		if err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, label)
+	}
+}
+
+/*
+POST /labels/:id/merge
+
+	{
+	    "into": "另一個標籤的 id"
+	}
+
+:id 掛過的 todo 全部改掛 into，然後刪掉 :id；回傳合併後的 into
+*/
+func MergeLabelHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		labelID, ok := parseLabelID(c, "id")
+		if !ok {
+			return
+		}
+
+		var input MergeLabelRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		label, err := labelService.Merge(c.Request.Context(), userID, labelID, input.Into)
+		if err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, label)
+	}
+}
+
+// DELETE /labels/:id => 從所有 todo 上拿掉並刪除
+func DeleteLabelHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		labelID, ok := parseLabelID(c, "id")
+		if !ok {
+			return
+		}
+
+		if err := labelService.Delete(c.Request.Context(), userID, labelID); err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "label deleted"})
+	}
+}
+
+/*
+POST /todos/:id/labels
+
+	{
+	    "label_ids": ["..."],
+	    "names": ["urgent-fix"]
+	}
+
+已經掛上的標籤不會重複；回傳 todo 目前所有的標籤
+*/
+func AddTodoLabelsHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTodoLabelIDs(c, false)
+		if !ok {
+			return
+		}
+
+		var input AddTodoLabelsRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(input.LabelIDs) == 0 && len(input.Names) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "label_ids or names is required"})
+			return
+		}
+
+		labels, err := labelService.AddToTodo(c.Request.Context(), userID, todoID, input.LabelIDs, input.Names)
+		if err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"labels": labels})
+	}
+}
+
+// DELETE /todos/:id/labels/:labelId => 回傳 todo 剩下的標籤；這個 todo 沒掛這個標籤回 404
+func RemoveTodoLabelHandler(labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, labelID, ok := parseTodoLabelIDs(c, true)
+		if !ok {
+			return
+		}
+
+		labels, err := labelService.RemoveFromTodo(c.Request.Context(), userID, todoID, labelID)
+		if err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"labels": labels})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"strconv"
+
+	"todo_api/internal/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cursor 模式一頁最多幾筆，避免前端一次要太多；offset 模式是舊的用法，不限制
+const maxPageSize = 100
+
+// 解析列表 API 共用的分頁參數
+// offset 模式：?page=2&pageSize=5（舊的用法，維持不變）
+// cursor 模式：第一頁 ?paging=cursor&pageSize=5，之後帶回傳的 ?cursor=nextCursor 或 prevCursor
+func parsePagination(c *gin.Context, codec *pagination.Codec, defaultPageSize int) (pagination.Params, error) {
+	params := pagination.Params{
+		Mode:     pagination.ModeOffset,
+		Page:     1,
+		PageSize: defaultPageSize,
+		Codec:    codec,
+	}
+
+	// page 跟 pageSize 需要從 string 轉成 int，並且做基本的防呆
+	if page, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && page >= 1 {
+		params.Page = page
+	}
+	if pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize))); err == nil && pageSize >= 1 {
+		params.PageSize = pageSize
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := codec.Decode(token)
+		if err != nil {
+			return params, err
+		}
+		params.Mode = pagination.ModeCursor
+		params.Cursor = cursor
+		params.Page = 0
+	} else if c.Query("paging") == pagination.ModeCursor {
+		params.Mode = pagination.ModeCursor
+		params.Page = 0
+	}
+
+	if params.IsCursor() {
+		params.PageSize = min(params.PageSize, maxPageSize)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"todo_api/internal/patch"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+/*
+PATCH 共用流程：
+1. current 是「可以修改的欄位」組成的文件（例如 TodoPatchDocument），先轉成 JSON
+2. 依 Content-Type 套用 merge patch 或 JSON patch
+3. 解回同一個型別（不認得的欄位 = 想改不能改的欄位，直接拒絕），再跑 binding tag 驗證
+
+失敗時這裡就會寫好 response，呼叫端拿到 false 直接 return
+*/
+func bindPatch[T any](c *gin.Context, current T) (T, bool) {
+	var patched T
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return patched, false
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return patched, false
+	}
+
+	result, err := patch.Apply(c.GetHeader("Content-Type"), doc, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, patch.ErrUnsupportedMediaType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":     err.Error(),
+				"supported": []string{patch.MergePatchContentType, patch.JSONPatchContentType},
+			})
+		case errors.Is(err, patch.ErrTestFailed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return patched, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(result))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return patched, false
+	}
+
+	if err := binding.Validator.ValidateStruct(&patched); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return patched, false
+	}
+
+	return patched, true
+}
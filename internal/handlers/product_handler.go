@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"todo_api/internal/models"
+	"todo_api/internal/pagination"
+	"todo_api/internal/patch"
 	"todo_api/internal/repository"
 
 	"github.com/gin-gonic/gin"
@@ -62,6 +66,34 @@ func (r *UpdateProductRequest) ToUpdates() map[string]interface{} {
 	return updates
 }
 
+// PATCH /products/:id 可以修改的欄位，跟 repository 的 editableFields 白名單一致
+// db tag 是欄位名稱，patch.Changes 比對完直接交給 UpdateProduct
+type ProductPatchDocument struct {
+	Title        string `json:"title" db:"title" binding:"required"`
+	Game         string `json:"game" db:"game" binding:"required"`
+	Platform     string `json:"platform" db:"platform" binding:"required"`
+	Username     string `json:"username" db:"username" binding:"required"`
+	Views        int    `json:"views" db:"views" binding:"min=0"`
+	MonthlyViews int    `json:"monthly_views" db:"monthly_views" binding:"min=0"`
+	Price        int    `json:"price" db:"price" binding:"min=0"`
+	Description  string `json:"description" db:"description" binding:"required"`
+	Country      string `json:"country" db:"country" binding:"required"`
+}
+
+func newProductPatchDocument(p *models.Product) ProductPatchDocument {
+	return ProductPatchDocument{
+		Title:        p.Title,
+		Game:         p.Game,
+		Platform:     p.Platform,
+		Username:     p.Username,
+		Views:        p.Views,
+		MonthlyViews: p.MonthlyViews,
+		Price:        p.Price,
+		Description:  p.Description,
+		Country:      p.Country,
+	}
+}
+
 func CreatteProductHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input CreateProductRequest
@@ -84,13 +116,42 @@ func CreatteProductHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 	}
 }
 
-func GetAllProductsHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+// 沒帶任何分頁參數 => 維持舊行為，一次回傳全部商品的陣列
+// GET /products?page=1&pageSize=20 => offset 分頁
+// GET /products?paging=cursor&pageSize=20 之後帶 cursor=<nextCursor> => cursor 分頁
+func GetAllProductsHandler(pool *pgxpool.Pool, codec *pagination.Codec) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		products, err := repository.GetAllProducts(pool)
+		_, hasPage := c.GetQuery("page")
+		_, hasPageSize := c.GetQuery("pageSize")
+		_, hasCursor := c.GetQuery("cursor")
+		_, hasPaging := c.GetQuery("paging")
+
+		if !hasPage && !hasPageSize && !hasCursor && !hasPaging {
+			products, err := repository.GetAllProducts(pool)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, products)
+			return
+		}
+
+		params, err := parsePagination(c, codec, 20)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := repository.GetProductsPage(pool, params)
 		if err != nil {
+			if errors.Is(err, pagination.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		c.JSON(http.StatusOK, products)
+		c.JSON(http.StatusOK, result)
 	}
 }
 
@@ -103,12 +164,19 @@ func GetProductByIDHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 		}
 		product, err := repository.GetProductById(pool, id)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "product not found"})
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
 				return
 			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+
+		if notModified(c, product.Version) {
+			return
+		}
+
+		setVersionETag(c, product.Version)
 		c.JSON(http.StatusOK, product)
 	}
 }
@@ -154,13 +222,83 @@ func UpdateProductHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// If-Match: "3" => 只有資料庫裡還是第 3 版才寫入，否則 412
+		expectedVersion, ok := ifMatchVersion(c)
+		if !ok {
+			return
+		}
+
 		// ✅ 呼叫新版 repository
-		updated, err := repository.UpdateProduct(pool, id, updates)
+		updated, err := repository.UpdateProduct(pool, id, updates, expectedVersion)
 		if err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		setVersionETag(c, updated.Version)
+		c.JSON(http.StatusOK, gin.H{"data": updated})
+	}
+}
+
+// PATCH /products/:id => 支援 application/merge-patch+json 跟 application/json-patch+json
+func PatchProductHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID PRODUCT ID"})
+			return
+		}
+
+		existing, err := repository.GetProductById(pool, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !checkIfMatch(c, existing.Version) {
+			return
+		}
+
+		before := newProductPatchDocument(existing)
+		after, ok := bindPatch(c, before)
+		if !ok {
+			return
+		}
+
+		updates := patch.Changes(before, after, "db")
+		if len(updates) == 0 {
+			setVersionETag(c, existing.Version)
+			c.JSON(http.StatusOK, gin.H{"data": existing})
+			return
+		}
+
+		// 一律帶著讀出來的 version 寫入，中間被別人改過就 412
+		updated, err := repository.UpdateProduct(pool, id, updates, &existing.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrVersionConflict):
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			case errors.Is(err, pgx.ErrNoRows):
+				c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		setVersionETag(c, updated.Version)
 		c.JSON(http.StatusOK, gin.H{"data": updated})
 	}
 }
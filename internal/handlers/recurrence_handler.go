@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo_api/internal/recurrence"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 預覽最多可以列幾筆
+const maxRecurrencePreview = 50
+
+/*
+GET /todos/recurrence/preview?rule=FREQ%3DMONTHLY%3BBYDAY%3D-1FR&start=2026-01-01T09:00:00%2B08:00&tz=Asia/Taipei&count=5
+
+存檔前先讓前端看接下來 N 次會落在哪幾天；start 沒傳就從現在開始
+rule 裡的 ; 一定要 URL encode（%3B），不然 query string 會解析失敗
+*/
+func RecurrencePreviewHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, err := recurrence.Parse(c.Query("rule"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		loc, err := recurrence.LoadLocation(c.Query("tz"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		start := time.Now()
+		if raw := c.Query("start"); raw != "" {
+			start, err = time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "start must be RFC3339"})
+				return
+			}
+		}
+		start = start.In(loc)
+
+		count := 5
+		if raw := c.Query("count"); raw != "" {
+			count, err = strconv.Atoi(raw)
+			if err != nil || count < 1 || count > maxRecurrencePreview {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and 50"})
+				return
+			}
+		}
+
+		// after 設成 start 的前一刻，start 本身符合規則的話也會列出來
+		dates := rule.Occurrences(start, start.Add(-time.Nanosecond), count)
+
+		c.JSON(http.StatusOK, gin.H{
+			"rule":  rule.String(),
+			"tz":    loc.String(),
+			"start": start,
+			"dates": dates,
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/pagination"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 一次最多幾個 mutation，跟 POST /todos/bulk 一樣
+const maxSyncMutations = 500
+
+// 同步 token 借用 cursor 的簽章：Sort 固定是 syncTokenKind，ID 是 snapshot 的 xmin，Time 是發出的時間
+const syncTokenKind = "sync"
+
+var errInvalidSyncToken = errors.New("invalid sync token")
+
+// todo 依 op 不同：create 是 CreateTodoRequest，update 是 UpdateTodoRequest，delete 不用
+type SyncMutationRequest struct {
+	ClientMutationID string          `json:"client_mutation_id" binding:"required,max=64"`
+	Op               string          `json:"op" binding:"required,oneof=create update delete"`
+	ID               int             `json:"id"`
+	Ref              string          `json:"ref" binding:"max=64"` // 離線新增還不知道 id 時，填那個 create 的 client_mutation_id
+	Todo             json.RawMessage `json:"todo"`
+	// client 做這個修改的時間，update / delete 一定要有
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+type SyncRequest struct {
+	Mutations []SyncMutationRequest `json:"mutations" binding:"required,min=1,dive"`
+}
+
+// 把 request 的一個 mutation 轉成 service 用的格式；欄位驗證失敗記在 Err，由 service 當作 rejected
+func (r *SyncMutationRequest) toMutation() service.SyncMutation {
+	mutation := service.SyncMutation{
+		ClientMutationID: r.ClientMutationID,
+		Op:               r.Op,
+		ID:               r.ID,
+		Ref:              r.Ref,
+	}
+
+	if r.Op == service.SyncOpCreate {
+		var input CreateTodoRequest
+		if mutation.Err = bindBulkTodo(r.Todo, &input); mutation.Err != nil {
+			return mutation
+		}
+		mutation.Todo = input.toTodo()
+		mutation.Err = validateTodoSchedule(mutation.Todo)
+		return mutation
+	}
+
+	if r.ID <= 0 && r.Ref == "" {
+		mutation.Err = errors.New("id or ref is required")
+		return mutation
+	}
+	if r.UpdatedAt == nil {
+		mutation.Err = errors.New("updated_at is required")
+		return mutation
+	}
+	mutation.UpdatedAt = *r.UpdatedAt
+
+	if r.Op == service.SyncOpUpdate {
+		var input UpdateTodoRequest
+		if mutation.Err = bindBulkTodo(r.Todo, &input); mutation.Err != nil {
+			return mutation
+		}
+		mutation.Apply = func(existing *models.Todo) (*models.Todo, error) {
+			changed := input.applyTo(existing)
+			if err := validateTodoSchedule(&changed); err != nil {
+				return nil, err
+			}
+			return &changed, nil
+		}
+	}
+
+	return mutation
+}
+
+func encodeSyncToken(codec *pagination.Codec, xmin uint64, issuedAt time.Time) string {
+	return codec.Encode(pagination.Cursor{
+		Sort: syncTokenKind,
+		Time: issuedAt,
+		ID:   strconv.FormatUint(xmin, 10),
+	})
+}
+
+func decodeSyncToken(codec *pagination.Codec, token string) (uint64, time.Time, error) {
+	cursor, err := codec.Decode(token)
+	if err != nil || cursor.Sort != syncTokenKind {
+		return 0, time.Time{}, errInvalidSyncToken
+	}
+	xmin, err := strconv.ParseUint(cursor.ID, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, errInvalidSyncToken
+	}
+	return xmin, cursor.Time, nil
+}
+
+/*
+GET /sync?since=<token>
+
+回傳 since 之後新增、修改過的 todo（todos），跟丟進垃圾桶或永久刪除的 id（deleted），
+再加上下一次同步要帶的 token。沒帶 since 就是完整同步：全部還沒刪除的 todo，full = true
+
+同一筆可能在兩次同步都出現（剛好在同步的那一刻寫入），client 直接用新的覆蓋就好
+token 超過保留期限（墓碑已經被清掉，可能會漏掉刪除）回 410，client 要不帶 since 重新完整同步
+*/
+func GetSyncHandler(syncService *service.SyncService, codec *pagination.Codec, retention time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var since *uint64
+		if raw := c.Query("since"); raw != "" {
+			xmin, issuedAt, err := decodeSyncToken(codec, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if time.Since(issuedAt) > retention {
+				c.JSON(http.StatusGone, gin.H{"error": "sync token expired, sync again without since"})
+				return
+			}
+			since = &xmin
+		}
+
+		issuedAt := time.Now()
+		changes, xmin, err := syncService.Changes(c.Request.Context(), userID, since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		changes.Token = encodeSyncToken(codec, xmin, issuedAt)
+
+		c.JSON(http.StatusOK, changes)
+	}
+}
+
+/*
+POST /sync
+
+	{
+	    "mutations": [
+	        { "client_mutation_id": "c1", "op": "create", "todo": { "title": "買牛奶" } },
+	        { "client_mutation_id": "c2", "op": "update", "ref": "c1", "todo": { "completed": true }, "updated_at": "2025-01-31T17:00:00+08:00" },
+	        { "client_mutation_id": "c3", "op": "delete", "id": 9, "updated_at": "2025-01-31T17:05:00+08:00" }
+	    ]
+	}
+
+依序套用，results 的順序跟 mutations 一樣，一律回 200，每一筆看自己的 status：
+  - applied：寫入成功
+  - conflict：伺服器上的 updated_at 比 updated_at 新，保留伺服器的版本（todo 就是伺服器上的內容）
+  - rejected：驗證失敗、todo 不存在等，重送也不會成功
+
+client_mutation_id 重送（例如上一次沒收到回應）不會重複執行，回傳第一次的結果並標上 replayed
+*/
+func PostSyncHandler(syncService *service.SyncService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input SyncRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(input.Mutations) > maxSyncMutations {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("at most %d mutations per request", maxSyncMutations)})
+			return
+		}
+
+		mutations := make([]service.SyncMutation, len(input.Mutations))
+		for i := range input.Mutations {
+			mutations[i] = input.Mutations[i].toMutation()
+		}
+
+		result, err := syncService.Apply(c.Request.Context(), userID, mutations)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
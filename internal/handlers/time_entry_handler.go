@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/recurrence"
+	"todo_api/internal/repository"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 時間報表一次最多查這麼長的區間
+const maxTimeReportRange = 366 * 24 * time.Hour
+
+type StartTimerRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+type CreateTimeEntryRequest struct {
+	StartedAt time.Time `json:"started_at" binding:"required"`
+	EndedAt   time.Time `json:"ended_at" binding:"required"`
+	Note      string    `json:"note" binding:"max=1000"`
+}
+
+// 只送要改的欄位
+type UpdateTimeEntryRequest struct {
+	StartedAt *time.Time `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Note      *string    `json:"note" binding:"omitempty,max=1000"`
+}
+
+func writeTimeEntryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTodoNotFound), errors.Is(err, service.ErrTimeEntryNotFound), errors.Is(err, service.ErrNoRunningTimer):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidTimeRange), errors.Is(err, service.ErrTimeInFuture):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// 解析 /todos/:id/time-entries/:entryId 的兩個 id，失敗時自己回 400
+func parseTimeEntryIDs(c *gin.Context, withEntry bool) (int, int64, bool) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+		return 0, 0, false
+	}
+	if !withEntry {
+		return todoID, 0, true
+	}
+
+	entryID, err := strconv.ParseInt(c.Param("entryId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TIME ENTRY ID"})
+		return 0, 0, false
+	}
+	return todoID, entryID, true
+}
+
+/*
+POST /todos/:id/timer/start（body 可以不帶）
+
+	{
+	    "note": "寫報告"
+	}
+
+同一個使用者同時只能有一個計時器，已經有在跑的回 409，running 是正在跑的那一個
+*/
+func StartTimerHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTimeEntryIDs(c, false)
+		if !ok {
+			return
+		}
+
+		var input StartTimerRequest
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry, err := timeService.Start(c.Request.Context(), userID, todoID, input.Note)
+		if err != nil {
+			if errors.Is(err, repository.ErrTimerAlreadyRunning) {
+				running, runningErr := timeService.Running(c.Request.Context(), userID)
+				if runningErr != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": runningErr.Error()})
+					return
+				}
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "running": running})
+				return
+			}
+			writeTimeEntryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// POST /todos/:id/timer/stop => 停掉這個 todo 上正在跑的計時器，沒有的話 404
+func StopTimerHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTimeEntryIDs(c, false)
+		if !ok {
+			return
+		}
+
+		entry, err := timeService.Stop(c.Request.Context(), userID, todoID)
+		if err != nil {
+			writeTimeEntryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+// GET /timer => 正在跑的計時器，沒有的話 running 是 null
+func GetRunningTimerHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		running, err := timeService.Running(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"running": running})
+	}
+}
+
+// GET /todos/:id/time-entries => 新的在前，正在跑的 duration_seconds 算到現在
+func GetTimeEntriesHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTimeEntryIDs(c, false)
+		if !ok {
+			return
+		}
+
+		entries, err := timeService.List(c.Request.Context(), userID, todoID)
+		if err != nil {
+			writeTimeEntryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": entries})
+	}
+}
+
+/*
+POST /todos/:id/time-entries（手動補登）
+
+	{
+	    "started_at": "2025-01-31T09:00:00+08:00",
+	    "ended_at": "2025-01-31T10:30:00+08:00",
+	    "note": "開會"
+	}
+*/
+func CreateTimeEntryHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTimeEntryIDs(c, false)
+		if !ok {
+			return
+		}
+
+		var input CreateTimeEntryRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry, err := timeService.Create(c.Request.Context(), userID, todoID, input.StartedAt, input.EndedAt, input.Note)
+		if err != nil {
+			writeTimeEntryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+// PUT /todos/:id/time-entries/:entryId => 改開始 / 結束時間或備註；在還在跑的計時器設 ended_at 就是停掉它
+func UpdateTimeEntryHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, entryID, ok := parseTimeEntryIDs(c, true)
+		if !ok {
+			return
+		}
+
+		var input UpdateTimeEntryRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entry, err := timeService.Update(c.Request.Context(), userID, todoID, entryID, service.TimeEntryChanges{
+			StartedAt: input.StartedAt,
+			EndedAt:   input.EndedAt,
+			Note:      input.Note,
+		})
+		if err != nil {
+			writeTimeEntryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+// DELETE /todos/:id/time-entries/:entryId
+func DeleteTimeEntryHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, entryID, ok := parseTimeEntryIDs(c, true)
+		if !ok {
+			return
+		}
+
+		if err := timeService.Delete(c.Request.Context(), userID, todoID, entryID); err != nil {
+			writeTimeEntryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "time entry deleted"})
+	}
+}
+
+// 報表的區間：RFC3339，或只有日期的 2006-01-02（依 tz 的當天 00:00，to 只有日期時包含那一整天）
+func parseReportTime(raw string, loc *time.Location, inclusiveDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", raw, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if inclusiveDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+/*
+GET /reports/time?from=2025-01-01&to=2025-01-31&group_by=day&tz=Asia/Taipei
+
+  - from / to：RFC3339 或 2006-01-02，沒帶的話是最近 7 天；區間最長一年
+  - group_by：day（預設，依 tz 切天）或 todo
+  - tz：IANA 時區，預設 UTC
+*/
+func GetTimeReportHandler(timeService *service.TimeTrackingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		loc, err := recurrence.LoadLocation(c.Query("tz"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		groupBy := c.DefaultQuery("group_by", models.TimeReportGroupByDay)
+		if groupBy != models.TimeReportGroupByDay && groupBy != models.TimeReportGroupByTodo {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be day or todo"})
+			return
+		}
+
+		to := time.Now()
+		if raw := c.Query("to"); raw != "" {
+			if to, err = parseReportTime(raw, loc, true); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be RFC3339 or YYYY-MM-DD"})
+				return
+			}
+		}
+		from := to.AddDate(0, 0, -7)
+		if raw := c.Query("from"); raw != "" {
+			if from, err = parseReportTime(raw, loc, false); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be RFC3339 or YYYY-MM-DD"})
+				return
+			}
+		}
+
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+			return
+		}
+		if to.Sub(from) > maxTimeReportRange {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the report range cannot exceed one year"})
+			return
+		}
+
+		report, err := timeService.Report(c.Request.Context(), userID, from, to, groupBy, loc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
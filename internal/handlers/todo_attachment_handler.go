@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"todo_api/internal/repository"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// multipart 的 boundary、其他欄位佔的空間，body 上限是檔案上限再加這麼多
+const attachmentFormOverhead = 1 << 20
+
+func writeTodoAttachmentError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrTodoNotFound), errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAttachmentTooLarge), errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.ErrAttachmentTooLarge.Error()})
+	case errors.Is(err, service.ErrAttachmentTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrAttachmentQuotaExceeded):
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// 解析 /todos/:id/attachments/:attachmentId，失敗時自己回 400 / 404
+func parseTodoAttachmentIDs(c *gin.Context, withAttachment bool) (int, string, bool) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+		return 0, "", false
+	}
+	if !withAttachment {
+		return todoID, "", true
+	}
+
+	// 不是 UUID 的一定找不到，不用送到資料庫
+	attachmentID := c.Param("attachmentId")
+	if _, err := uuid.Parse(attachmentID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": service.ErrAttachmentNotFound.Error()})
+		return 0, "", false
+	}
+	return todoID, attachmentID, true
+}
+
+/*
+POST /todos/:id/attachments（multipart/form-data，檔案放在 file 欄位）
+
+  - 超過單一檔案上限 413
+  - 檔案類型不允許 415（依內容判斷，改副檔名沒用）
+  - 超過使用者的總用量 507
+*/
+func UploadTodoAttachmentHandler(attachmentService *service.TodoAttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTodoAttachmentIDs(c, false)
+		if !ok {
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, attachmentService.MaxFileBytes+attachmentFormOverhead)
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeTodoAttachmentError(c, err)
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("file is required: %v", err)})
+			return
+		}
+
+		attachment, err := attachmentService.Upload(c.Request.Context(), userID, todoID, fileHeader)
+		if err != nil {
+			writeTodoAttachmentError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, attachment)
+	}
+}
+
+// GET /todos/:id/attachments
+func GetTodoAttachmentsHandler(attachmentService *service.TodoAttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTodoAttachmentIDs(c, false)
+		if !ok {
+			return
+		}
+
+		attachments, err := attachmentService.List(c.Request.Context(), userID, todoID)
+		if err != nil {
+			writeTodoAttachmentError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": attachments})
+	}
+}
+
+// GET /todos/:id/attachments/:attachmentId => 下載檔案本身
+// 一律用 attachment 下載、加上 nosniff，瀏覽器不會把上傳的檔案當成網頁打開
+func DownloadTodoAttachmentHandler(attachmentService *service.TodoAttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, attachmentID, ok := parseTodoAttachmentIDs(c, true)
+		if !ok {
+			return
+		}
+
+		attachment, body, err := attachmentService.Open(c.Request.Context(), userID, todoID, attachmentID)
+		if err != nil {
+			writeTodoAttachmentError(c, err)
+			return
+		}
+		defer body.Close()
+
+		// 非 ASCII 的檔名會自動用 filename*=utf-8''... 的格式
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+
+		c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+			"Content-Disposition":    disposition,
+			"X-Content-Type-Options": "nosniff",
+			"Cache-Control":          "private, no-cache",
+		})
+	}
+}
+
+// DELETE /todos/:id/attachments/:attachmentId
+func DeleteTodoAttachmentHandler(attachmentService *service.TodoAttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, attachmentID, ok := parseTodoAttachmentIDs(c, true)
+		if !ok {
+			return
+		}
+
+		if err := attachmentService.Delete(c.Request.Context(), userID, todoID, attachmentID); err != nil {
+			writeTodoAttachmentError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"todo_api/internal/models"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// 一次最多幾個操作，避免單一 transaction 鎖太久
+const maxBulkOperations = 500
+
+// todo 依 op 不同：create 是 CreateTodoRequest，update 是 UpdateTodoRequest，其他不用
+type BulkTodoOperation struct {
+	Op      string          `json:"op" binding:"required,oneof=create update complete delete move"`
+	ID      int             `json:"id"`
+	Todo    json.RawMessage `json:"todo"`
+	Version *int            `json:"version"` // update / complete 可以帶，跟 If-Match 一樣
+	Before  *int            `json:"before"`
+	After   *int            `json:"after"`
+}
+
+type BulkTodoRequest struct {
+	// true：全部成功才 commit；false（預設）：能做的都做，每一筆各自回報結果
+	Atomic     bool                `json:"atomic"`
+	Operations []BulkTodoOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// 把 request 的一個操作轉成 service 用的格式；欄位驗證失敗記在 op.Err，由 service 當作這一筆失敗
+func (r *BulkTodoOperation) toOperation() service.BulkOperation {
+	op := service.BulkOperation{
+		Op:      r.Op,
+		ID:      r.ID,
+		Version: r.Version,
+		Before:  r.Before,
+		After:   r.After,
+	}
+
+	if r.Op != service.BulkOpCreate && r.ID <= 0 {
+		op.Err = errors.New("id is required")
+		return op
+	}
+
+	switch r.Op {
+	case service.BulkOpCreate:
+		var input CreateTodoRequest
+		if op.Err = bindBulkTodo(r.Todo, &input); op.Err != nil {
+			return op
+		}
+		op.Todo = input.toTodo()
+		op.Err = validateTodoSchedule(op.Todo)
+
+	case service.BulkOpUpdate:
+		var input UpdateTodoRequest
+		if op.Err = bindBulkTodo(r.Todo, &input); op.Err != nil {
+			return op
+		}
+		// 要等 service 鎖住現有資料後才能套用，跨欄位的驗證也在那時候做
+		op.Apply = func(existing *models.Todo) (*models.Todo, error) {
+			changed := input.applyTo(existing)
+			if err := validateTodoSchedule(&changed); err != nil {
+				return nil, err
+			}
+			return &changed, nil
+		}
+
+	case service.BulkOpMove:
+		if r.Before == nil && r.After == nil {
+			op.Err = errors.New("before or after is required")
+		}
+	}
+
+	return op
+}
+
+// 跟 ShouldBindJSON 一樣：先解 JSON 再跑 binding tag
+func bindBulkTodo(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return errors.New("todo is required")
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(v)
+}
+
+/*
+POST /todos/bulk
+
+	{
+	    "atomic": false,
+	    "operations": [
+	        { "op": "create", "todo": { "title": "買牛奶" } },
+	        { "op": "update", "id": 12, "todo": { "priority": "high" }, "version": 3 },
+	        { "op": "complete", "id": 7 },
+	        { "op": "delete", "id": 9 },
+	        { "op": "move", "id": 4, "before": 12 }
+	    ]
+	}
+
+依序執行，results 的 index 對應 operations 的位置
+  - best-effort（預設）：一律 200，每一筆看自己的 status
+  - atomic：全部成功 200；有一筆失敗整批撤銷，回 422，失敗的那一筆有 error
+*/
+func BulkTodoHandler(bulkService *service.BulkTodoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input BulkTodoRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(input.Operations) > maxBulkOperations {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("at most %d operations per request", maxBulkOperations)})
+			return
+		}
+
+		ops := make([]service.BulkOperation, len(input.Operations))
+		for i := range input.Operations {
+			ops[i] = input.Operations[i].toOperation()
+		}
+
+		result, err := bulkService.Run(c.Request.Context(), userID, ops, input.Atomic)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if input.Atomic && result.Failed > 0 {
+			c.JSON(http.StatusUnprocessableEntity, result)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -2,36 +2,251 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"todo_api/internal/models"
+	"todo_api/internal/pagination"
+	"todo_api/internal/patch"
+	"todo_api/internal/recurrence"
 	"todo_api/internal/repository"
+	"todo_api/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// 時間欄位一律用 RFC3339，例如 "2025-01-31T17:00:00+08:00"
 type CreateTodoRequest struct {
-	Title     string `json:"title" binding:"required"`
-	Completed bool   `json:"completed"`
+	Title     string     `json:"title" binding:"required,max=255"`
+	Completed bool       `json:"completed"`
+	DueAt     *time.Time `json:"due_at"`
+	Priority  string     `json:"priority" binding:"omitempty,oneof=low normal high urgent"` // 沒傳就是 normal
+	Notes     string     `json:"notes" binding:"max=5000"`
+	RemindAt  *time.Time `json:"remind_at"`
+	// 週期性 todo，例如 "FREQ=WEEKLY;BYDAY=MO"；一定要搭配 due_at（第一次的日期）
+	RecurrenceRule string `json:"recurrence_rule" binding:"max=255"`
+	RecurrenceTZ   string `json:"recurrence_tz" binding:"max=64"` // 例如 Asia/Taipei，沒傳就是 UTC
 }
 
 type UpdateTodoRequest struct {
-	Title *string `json:"title"`
+	Title *string `json:"title" binding:"omitempty,max=255"`
 	// bool 零值是 false => 如果請求中沒有 "completed" 欄位，仍然會設為 false
 	// *bool 零值是 nil => 如果請求中沒有 "completed" 欄位，仍然會設為 nil (優勢：能區分「未提供」、「true」、「false」三種狀態)
 	Completed *bool `json:"completed"`
+	// 下面這些沒傳就沿用原本的值
+	DueAt    *time.Time `json:"due_at"`
+	Priority *string    `json:"priority" binding:"omitempty,oneof=low normal high urgent"`
+	Notes    *string    `json:"notes" binding:"omitempty,max=5000"`
+	RemindAt *time.Time `json:"remind_at"`
+	// 傳空字串就是取消重複
+	RecurrenceRule *string `json:"recurrence_rule" binding:"omitempty,max=255"`
+	RecurrenceTZ   *string `json:"recurrence_tz" binding:"omitempty,max=64"`
+}
+
+// 把前端有傳的欄位套用到現有資料上，回傳一份新的 todo（不改 existing）
+func (r *UpdateTodoRequest) applyTo(existing *models.Todo) models.Todo {
+	changed := *existing
+	if r.Title != nil {
+		changed.Title = *r.Title
+	}
+	if r.Completed != nil {
+		changed.Completed = *r.Completed
+	}
+	if r.DueAt != nil {
+		changed.DueAt = r.DueAt
+	}
+	if r.Priority != nil {
+		changed.Priority = *r.Priority
+	}
+	if r.Notes != nil {
+		changed.Notes = *r.Notes
+	}
+	if r.RemindAt != nil {
+		changed.RemindAt = r.RemindAt
+	}
+	if r.RecurrenceRule != nil {
+		changed.RecurrenceRule = *r.RecurrenceRule
+	}
+	if r.RecurrenceTZ != nil {
+		changed.RecurrenceTZ = *r.RecurrenceTZ
+	}
+	resetRecurrenceStart(existing, &changed)
+	return changed
+}
+
+// 規則或時區換了，就從目前的截止時間重新起算（COUNT 也重新算）
+func resetRecurrenceStart(existing *models.Todo, changed *models.Todo) {
+	if changed.RecurrenceRule != existing.RecurrenceRule || changed.RecurrenceTZ != existing.RecurrenceTZ {
+		changed.RecurrenceStart = changed.DueAt
+	}
+}
+
+// PATCH /todos/:id 可以修改的欄位；patch 就是套用在這份文件上
+// 跟 UpdateTodoRequest 不同，這裡每個欄位都有值，merge patch 送 null / JSON patch remove 就是清空
+type TodoPatchDocument struct {
+	Title          string     `json:"title" binding:"required,max=255"`
+	Completed      bool       `json:"completed"`
+	DueAt          *time.Time `json:"due_at"`
+	Priority       string     `json:"priority" binding:"required,oneof=low normal high urgent"`
+	Notes          string     `json:"notes" binding:"max=5000"`
+	RemindAt       *time.Time `json:"remind_at"`
+	RecurrenceRule string     `json:"recurrence_rule" binding:"max=255"`
+	RecurrenceTZ   string     `json:"recurrence_tz" binding:"max=64"`
+}
+
+func newTodoPatchDocument(todo *models.Todo) TodoPatchDocument {
+	return TodoPatchDocument{
+		Title:          todo.Title,
+		Completed:      todo.Completed,
+		DueAt:          todo.DueAt,
+		Priority:       todo.Priority,
+		Notes:          todo.Notes,
+		RemindAt:       todo.RemindAt,
+		RecurrenceRule: todo.RecurrenceRule,
+		RecurrenceTZ:   todo.RecurrenceTZ,
+	}
+}
+
+func (d *TodoPatchDocument) applyTo(existing *models.Todo) models.Todo {
+	changed := *existing
+	changed.Title = d.Title
+	changed.Completed = d.Completed
+	changed.DueAt = d.DueAt
+	changed.Priority = d.Priority
+	changed.Notes = d.Notes
+	changed.RemindAt = d.RemindAt
+	changed.RecurrenceRule = d.RecurrenceRule
+	changed.RecurrenceTZ = d.RecurrenceTZ
+	resetRecurrenceStart(existing, &changed)
+	return changed
+}
+
+// 建立 todo 時共用：把 request 轉成 models.Todo，UserID / ListID 由呼叫端決定
+func (r *CreateTodoRequest) toTodo() *models.Todo {
+	return &models.Todo{
+		Title:     r.Title,
+		Completed: r.Completed,
+		DueAt:     r.DueAt,
+		Priority:  r.Priority,
+		Notes:     r.Notes,
+		RemindAt:  r.RemindAt,
+		// 第一次的截止時間就是規則的起點
+		RecurrenceRule:  r.RecurrenceRule,
+		RecurrenceTZ:    r.RecurrenceTZ,
+		RecurrenceStart: r.DueAt,
+	}
+}
+
+// binding tag 只能檢查單一欄位，跨欄位的規則放這裡：提醒時間不能晚於截止時間
+func validateTodoSchedule(todo *models.Todo) error {
+	if todo.DueAt != nil && todo.RemindAt != nil && todo.RemindAt.After(*todo.DueAt) {
+		return errors.New("remind_at must not be later than due_at")
+	}
+	return validateTodoRecurrence(todo)
+}
+
+// 週期規則要能解析、時區要存在、要有起點；順便把規則正規化成 recurrence.Rule.String() 的格式再存
+func validateTodoRecurrence(todo *models.Todo) error {
+	if todo.RecurrenceTZ == "" {
+		todo.RecurrenceTZ = "UTC"
+	}
+	if _, err := recurrence.LoadLocation(todo.RecurrenceTZ); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(todo.RecurrenceRule) == "" {
+		todo.RecurrenceRule = ""
+		todo.RecurrenceStart = nil
+		return nil
+	}
+
+	rule, err := recurrence.Parse(todo.RecurrenceRule)
+	if err != nil {
+		return err
+	}
+	if todo.DueAt == nil {
+		return errors.New("recurring todos require due_at")
+	}
+	if todo.RecurrenceStart == nil {
+		todo.RecurrenceStart = todo.DueAt
+	}
+	todo.RecurrenceRule = rule.String()
+	return nil
+}
+
+// 比對兩個 *time.Time，兩個都是 nil 也算相同
+func sameTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// 判斷 handler 套用完前端的修改之後，內容跟資料庫裡的是否一模一樣
+func sameTodoContent(a, b *models.Todo) bool {
+	return a.Title == b.Title &&
+		a.Completed == b.Completed &&
+		sameTimePtr(a.DueAt, b.DueAt) &&
+		a.Priority == b.Priority &&
+		a.Notes == b.Notes &&
+		sameTimePtr(a.RemindAt, b.RemindAt) &&
+		a.RecurrenceRule == b.RecurrenceRule &&
+		a.RecurrenceTZ == b.RecurrenceTZ
+}
+
+// 解析 query string 裡的時間，支援 RFC3339 或只有日期的 2006-01-02（視為 UTC 當天 00:00）
+func parseQueryTime(raw string) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// 所有 todo 路由都掛在 AuthMiddleware 後面，middleware 驗證完 JWT 會把 user_id 放進 gin context
+// 這裡統一取出來，取不到就代表沒有經過驗證，直接回 401
+func currentUserID(c *gin.Context) (string, bool) {
+	value, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return "", false
+	}
+
+	userID, ok := value.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return "", false
+	}
+
+	return userID, true
 }
 
 /*
 	{
 	    "title":"buy new book02",
-	    "completed": false
+	    "completed": false,
+	    "due_at": "2025-01-31T17:00:00+08:00",
+	    "priority": "high",
+	    "notes": "記得先查書單",
+	    "remind_at": "2025-01-31T09:00:00+08:00"
 	}
 */
 func CreateTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) { // 閉包
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
 		var input CreateTodoRequest
 
 		//  先驗證從 client 傳來的資料
@@ -40,11 +255,19 @@ func CreateTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		newTodo := input.toTodo()
+		newTodo.UserID = userID
+		if err := validateTodoSchedule(newTodo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// 沒問題後，把資料傳給 repository 層，透過 sql 方式把資料寫入到DB
-		todo, err := repository.CreateTodo(pool, input.Title, input.Completed)
+		todo, err := repository.CreateTodo(pool, newTodo)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
 		// 資料庫寫入正確後，回傳訊息到 client 端
@@ -52,24 +275,115 @@ func CreateTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 	}
 }
 
-func GetTodosHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+// 把 GET /todos 的 query string 轉成 models.TodoFilter，格式不對就回錯誤讓 handler 回 400
+// completed=true|false、q=關鍵字、sort=欄位:asc|desc、overdue=true、
+// due_before / due_after / created_before / created_after（RFC3339 或 2006-01-02）
+func parseTodoFilter(c *gin.Context) (models.TodoFilter, error) {
+	var filter models.TodoFilter
+
+	if completedStr := c.Query("completed"); completedStr != "" {
+		completed, err := strconv.ParseBool(completedStr)
+		if err != nil {
+			return filter, errors.New("invalid completed")
+		}
+		filter.Completed = &completed
+	}
+
+	filter.Query = strings.TrimSpace(c.Query("q"))
+
+	if overdueStr := c.Query("overdue"); overdueStr != "" {
+		overdue, err := strconv.ParseBool(overdueStr)
+		if err != nil {
+			return filter, errors.New("invalid overdue")
+		}
+		filter.Overdue = overdue
+	}
+
+	// 時間區間的參數都是同樣的解析方式，用 map 對應到 filter 裡的欄位
+	timeParams := map[string]**time.Time{
+		"due_before":     &filter.DueBefore,
+		"due_after":      &filter.DueAfter,
+		"created_before": &filter.CreatedBefore,
+		"created_after":  &filter.CreatedAfter,
+	}
+	for name, target := range timeParams {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseQueryTime(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s", name)
+		}
+		*target = t
+	}
+
+	// labels=work,home 用名稱或 slug 都可以；labels_match=any（預設，有其中一個）或 all（每個都要有）
+	if labelsStr := c.Query("labels"); labelsStr != "" {
+		for _, name := range strings.Split(labelsStr, ",") {
+			if slug := utils.Slugify(name); slug != "" && !slices.Contains(filter.Labels, slug) {
+				filter.Labels = append(filter.Labels, slug)
+			}
+		}
+		if len(filter.Labels) == 0 {
+			return filter, errors.New("invalid labels")
+		}
+	}
+	filter.LabelsMatch = c.DefaultQuery("labels_match", models.LabelMatchAny)
+	if filter.LabelsMatch != models.LabelMatchAny && filter.LabelsMatch != models.LabelMatchAll {
+		return filter, errors.New("labels_match must be any or all")
+	}
+
+	// sort=due_at 或 sort=due_at:asc / sort=priority:desc，沒帶方向預設 asc
+	filter.SortField = "created_at"
+	filter.SortDesc = true
+	if sortStr := c.Query("sort"); sortStr != "" {
+		field, direction, _ := strings.Cut(sortStr, ":")
+		if !repository.IsValidTodoSortField(field) {
+			return filter, fmt.Errorf("invalid sort field: %s", field)
+		}
+		switch strings.ToLower(direction) {
+		case "", "asc":
+			filter.SortDesc = false
+		case "desc":
+			filter.SortDesc = true
+		default:
+			return filter, fmt.Errorf("invalid sort direction: %s", direction)
+		}
+		filter.SortField = field
+	}
+
+	return filter, nil
+}
+
+func GetTodosHandler(pool *pgxpool.Pool, codec *pagination.Codec) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
-		pageStr := c.DefaultQuery("page", "1")
-		pageSizeStr := c.DefaultQuery("pageSize", "5")
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
 		// 先讓 handler 有能力接收 pagination 參數
-		page, err := strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			page = 1
+		params, err := parsePagination(c, codec, 5)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		pageSize, err := strconv.Atoi(pageSizeStr)
-		if err != nil || pageSize < 1 {
-			pageSize = 5
+		filter, err := parseTodoFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		result, err := repository.GetTodos(pool, page, pageSize)
+		result, err := repository.GetTodos(pool, userID, params, filter)
 		if err != nil {
+			// cursor 被竄改、或跟目前的排序不合
+			if errors.Is(err, pagination.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -79,6 +393,11 @@ func GetTodosHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 
 func GetTodoByIDHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
 		id, err := strconv.Atoi(c.Param("id"))
 		// "2" -----> 2, nil
 		// 'a' ----> 0,error ("invalid syntax")
@@ -86,17 +405,39 @@ func GetTodoByIDHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
 			return
 		}
-		todo, err := repository.GetTodoByID(pool, id)
+		todo, err := repository.GetTodoByID(pool, userID, id)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "todo not found"})
+			// 不是自己的 todo 也會走到這裡，不讓前端知道這個 id 是否存在
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
 				return
 			}
 
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
-		c.JSON(http.StatusOK, todo)
+		// 子任務有變動也會讓 todo 的 version +1，所以 ETag 可以只看 todo
+		if notModified(c, todo.Version) {
+			return
+		}
+
+		// 一併帶出子任務跟完成進度
+		items, err := repository.GetTodoItems(c.Request.Context(), pool, todo.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		labels, err := repository.GetTodoLabels(c.Request.Context(), pool, []int{todo.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		todo.Labels = labels[todo.ID]
+
+		setVersionETag(c, todo.Version)
+		c.JSON(http.StatusOK, models.NewTodoDetail(*todo, items))
 
 	}
 }
@@ -109,6 +450,11 @@ func GetTodoByIDHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 */
 func UpdateToDoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
 		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
@@ -132,7 +478,7 @@ func UpdateToDoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			log.Printf("[TEST] Readonly test mode enabled for todo ID %d", id)
 		}
 
-		existing, err := repository.GetTodoByID(pool, id)
+		existing, err := repository.GetTodoByID(pool, userID, id)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
@@ -142,22 +488,290 @@ func UpdateToDoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		if existing.Title == *input.Title && existing.Completed == *input.Completed {
+		// If-Match 對不上：前端手上的資料已經過期
+		if !checkIfMatch(c, existing.Version) {
+			return
+		}
+
+		// changed 帶著讀出來的 version，寫入時才能發現中間被別人改過
+		changed := input.applyTo(existing)
+
+		if sameTodoContent(existing, &changed) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "todo has not been changed"})
 			return
 		}
 
+		if err := validateTodoSchedule(&changed); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// 傳入 readonlyTest 旗標
-		todo, err := repository.UpdateTodo(pool, id, *input.Title, *input.Completed, readonlyTest)
+		todo, err := repository.UpdateTodo(pool, userID, &changed, readonlyTest)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found (concurrent deletion?)"})
 				return
 			}
+			if errors.Is(err, repository.ErrVersionConflict) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		setVersionETag(c, todo.Version)
 		c.JSON(http.StatusOK, todo)
 	}
 }
+
+/*
+PATCH /todos/:id
+
+Content-Type: application/merge-patch+json
+
+	{ "completed": true, "due_at": null }
+
+Content-Type: application/json-patch+json
+
+	[
+	    { "op": "test", "path": "/completed", "value": false },
+	    { "op": "replace", "path": "/completed", "value": true }
+	]
+
+只有真的變動的欄位會寫入；If-Match 的處理跟 PUT 一樣
+*/
+func PatchTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		existing, err := repository.GetTodoByID(pool, userID, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !checkIfMatch(c, existing.Version) {
+			return
+		}
+
+		doc, ok := bindPatch(c, newTodoPatchDocument(existing))
+		if !ok {
+			return
+		}
+
+		changed := doc.applyTo(existing)
+		if err := validateTodoSchedule(&changed); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 沒有任何變動就直接回傳目前的資料
+		updates := patch.Changes(existing, &changed, "db")
+		if len(updates) == 0 {
+			setVersionETag(c, existing.Version)
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+
+		todo, err := repository.PatchTodo(c.Request.Context(), pool, userID, id, existing.Version, updates)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrVersionConflict):
+				c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			case errors.Is(err, pgx.ErrNoRows):
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		setVersionETag(c, todo.Version)
+		c.JSON(http.StatusOK, todo)
+	}
+}
+
+// DELETE /todos/:id => 軟刪除，丟進垃圾桶
+func DeleteTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		if err := repository.DeleteTodo(pool, userID, id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GET /todos/trash => 垃圾桶列表
+func GetTrashHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		todos, err := repository.GetTrashedTodos(pool, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": todos})
+	}
+}
+
+// POST /todos/:id/restore => 從垃圾桶還原
+func RestoreTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		todo, err := repository.RestoreTodo(pool, userID, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found in trash"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, todo)
+	}
+}
+
+// before：移動後排在它前面的 todo；after：移動後排在它後面的 todo，至少要給一個
+type MoveTodoRequest struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+/*
+POST /todos/:id/move
+
+	{
+	    "before": 12,
+	    "after": 7
+	}
+
+拖拉排序用，搭配 GET /todos?sort=position；只有個人的 todo 可以移動，清單裡的 todo 回 400
+*/
+func MoveTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		var input MoveTodoRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		todo, err := repository.MoveTodo(c.Request.Context(), pool, userID, id, input.Before, input.After)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrInvalidMove):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, pgx.ErrNoRows):
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, todo)
+	}
+}
+
+// DELETE /todos/:id/purge => 永久刪除，只能刪垃圾桶裡的
+func PurgeTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		if err := repository.PurgeTodo(pool, userID, id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found in trash"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DELETE /todos/trash => 清空整個垃圾桶
+func EmptyTrashHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		purged, err := repository.EmptyTrash(pool, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"purged": purged})
+	}
+}
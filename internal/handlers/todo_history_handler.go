@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo_api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GET /todos/:id/history?limit=50 => 變更紀錄，新的在前（limit 最多 200）
+// 垃圾桶裡的 todo 也查得到
+func GetTodoHistoryHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+
+		events, err := repository.GetTodoEvents(c.Request.Context(), pool, userID, id, limit)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": events})
+	}
+}
+
+/*
+POST /todos/:id/undo => 撤銷最後一筆變更（新增的會丟進垃圾桶，刪除的會還原）
+
+最後一筆變更之後 todo 又被改過（包含提醒送出、子任務變動）就不能撤銷，回 409
+*/
+func UndoTodoHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		todo, err := repository.UndoLastTodoChange(c.Request.Context(), pool, userID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				c.JSON(http.StatusNotFound, gin.H{"error": "todo not found"})
+			case errors.Is(err, repository.ErrNothingToUndo), errors.Is(err, repository.ErrUndoConflict):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		setVersionETag(c, todo.Version)
+		c.JSON(http.StatusOK, todo)
+	}
+}
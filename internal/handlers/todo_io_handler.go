@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+	"todo_api/internal/todoio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// 匯入檔案大小跟筆數上限
+	maxImportBytes = 10 << 20
+	maxImportRows  = 10000
+)
+
+/*
+GET /todos/export?format=csv|json|ics（預設 json）
+
+一筆一筆從資料庫讀出來直接寫進 response，不會把全部 todo 放在記憶體裡
+不包含垃圾桶，依手動排序的順序
+*/
+func ExportTodosHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		format, err := todoio.ParseFormat(c.DefaultQuery("format", string(todoio.FormatJSON)))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filename := fmt.Sprintf("todos-%s.%s", time.Now().UTC().Format("20060102"), format)
+		c.Header("Content-Type", format.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+
+		encoder, err := todoio.NewEncoder(format, c.Writer)
+		if err == nil {
+			err = repository.EachTodo(c.Request.Context(), pool, userID, encoder.Encode)
+		}
+		if err == nil {
+			err = encoder.Close()
+		}
+		if err != nil {
+			// 還沒送出任何東西的話，改回一般的錯誤回應；已經送出一部分就只能中斷，檔案結尾會不完整
+			if !c.Writer.Written() {
+				c.Writer.Header().Del("Content-Disposition")
+				c.Writer.Header().Del("Content-Type")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("匯出 todos 中斷 (user %s): %v", userID, err)
+		}
+	}
+}
+
+/*
+POST /todos/import?format=csv|json|ics&dry_run=true
+
+body 直接放檔案內容，或是用 multipart/form-data 的 file 欄位上傳
+沒給 format 就依 Content-Type / 檔名判斷
+
+每一筆都跟 POST /todos 用一樣的規則驗證，不合格的放在 rejected，其他的一次寫入（COPY）
+dry_run=true 只驗證不寫入，用來先看看哪些資料會被拒絕
+*/
+func ImportTodosHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+		body, contentType, filename, err := importSource(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer body.Close()
+
+		var format todoio.Format
+		if raw := c.Query("format"); raw != "" {
+			format, err = todoio.ParseFormat(raw)
+		} else {
+			format, err = todoio.DetectFormat(contentType, filename)
+		}
+		if err != nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+
+		records, err := todoio.Decode(format, body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file is larger than %d bytes", maxImportBytes)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(records) > maxImportRows {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("at most %d todos per import", maxImportRows)})
+			return
+		}
+
+		result := models.TodoImportResult{
+			Format:   string(format),
+			DryRun:   dryRun,
+			Total:    len(records),
+			Rejected: []models.TodoImportRejection{},
+		}
+
+		accepted := make([]*models.Todo, 0, len(records))
+		for _, record := range records {
+			err := record.Err
+			if err == nil {
+				err = validateImportedTodo(record.Todo)
+			}
+			if err != nil {
+				rejection := models.TodoImportRejection{Row: record.Row, Error: err.Error()}
+				if record.Todo != nil {
+					rejection.Title = record.Todo.Title
+				}
+				result.Rejected = append(result.Rejected, rejection)
+				continue
+			}
+			accepted = append(accepted, record.Todo)
+		}
+		result.Accepted = len(accepted)
+
+		if !dryRun && len(accepted) > 0 {
+			result.Imported, err = repository.ImportTodos(c.Request.Context(), pool, userID, accepted)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// multipart 上傳就拿 file 欄位，不然整個 body 就是檔案
+func importSource(c *gin.Context) (io.ReadCloser, string, string, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return c.Request.Body, c.GetHeader("Content-Type"), "", nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", "", fmt.Errorf("file is required: %w", err)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", "", err
+	}
+	return file, header.Header.Get("Content-Type"), header.Filename, nil
+}
+
+// 跟 CreateTodoHandler 一樣的規則：binding tag + validateTodoSchedule
+func validateImportedTodo(todo *models.Todo) error {
+	input := CreateTodoRequest{
+		Title:          todo.Title,
+		Completed:      todo.Completed,
+		DueAt:          todo.DueAt,
+		Priority:       todo.Priority,
+		Notes:          todo.Notes,
+		RemindAt:       todo.RemindAt,
+		RecurrenceRule: todo.RecurrenceRule,
+		RecurrenceTZ:   todo.RecurrenceTZ,
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		return err
+	}
+	return validateTodoSchedule(todo)
+}
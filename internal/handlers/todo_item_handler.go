@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateTodoItemRequest struct {
+	Title    string `json:"title" binding:"required,max=255"`
+	Position *int   `json:"position" binding:"omitempty,min=0"`
+}
+
+// 只送要改的欄位
+type UpdateTodoItemRequest struct {
+	Title     *string `json:"title" binding:"omitempty,min=1,max=255"`
+	Completed *bool   `json:"completed"`
+	Position  *int    `json:"position" binding:"omitempty,min=0"`
+}
+
+func writeTodoItemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTodoNotFound), errors.Is(err, service.ErrTodoItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// 解析 /todos/:id/items/:itemId 的兩個 id，失敗時自己回 400
+func parseTodoItemIDs(c *gin.Context, withItem bool) (int, int, bool) {
+	todoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+		return 0, 0, false
+	}
+	if !withItem {
+		return todoID, 0, true
+	}
+
+	itemID, err := strconv.Atoi(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID ITEM ID"})
+		return 0, 0, false
+	}
+	return todoID, itemID, true
+}
+
+// GET /todos/:id/items
+func GetTodoItemsHandler(itemService *service.TodoItemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTodoItemIDs(c, false)
+		if !ok {
+			return
+		}
+
+		items, err := itemService.GetItems(c.Request.Context(), userID, todoID)
+		if err != nil {
+			writeTodoItemError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, items)
+	}
+}
+
+/*
+	{
+	    "title": "買紙箱",
+	    "position": 0
+	}
+*/
+func CreateTodoItemHandler(itemService *service.TodoItemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, _, ok := parseTodoItemIDs(c, false)
+		if !ok {
+			return
+		}
+
+		var input CreateTodoItemRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		item, err := itemService.CreateItem(c.Request.Context(), userID, todoID, input.Title, input.Position)
+		if err != nil {
+			writeTodoItemError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, item)
+	}
+}
+
+/*
+PUT /todos/:id/items/:itemId?auto_complete=true
+
+	{
+	    "completed": true
+	}
+
+auto_complete=true 時，子任務全部完成會順便把 todo 標記完成
+*/
+func UpdateTodoItemHandler(itemService *service.TodoItemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, itemID, ok := parseTodoItemIDs(c, true)
+		if !ok {
+			return
+		}
+
+		var input UpdateTodoItemRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		autoComplete := false
+		if raw := c.Query("auto_complete"); raw != "" {
+			v, err := strconv.ParseBool(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "auto_complete must be true or false"})
+				return
+			}
+			autoComplete = v
+		}
+
+		item, todoCompleted, err := itemService.UpdateItem(c.Request.Context(), userID, todoID, itemID, service.TodoItemChanges{
+			Title:     input.Title,
+			Completed: input.Completed,
+			Position:  input.Position,
+		}, autoComplete)
+		if err != nil {
+			writeTodoItemError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"item":           item,
+			"todo_completed": todoCompleted,
+		})
+	}
+}
+
+// DELETE /todos/:id/items/:itemId
+func DeleteTodoItemHandler(itemService *service.TodoItemService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		todoID, itemID, ok := parseTodoItemIDs(c, true)
+		if !ok {
+			return
+		}
+
+		if err := itemService.DeleteItem(c.Request.Context(), userID, todoID, itemID); err != nil {
+			writeTodoItemError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "todo item deleted"})
+	}
+}
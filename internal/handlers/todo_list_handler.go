@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo_api/internal/pagination"
+	"todo_api/internal/repository"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type CreateTodoListRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=5000"`
+}
+
+type InviteTodoListMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type AcceptInviteRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// service 回傳的錯誤統一在這裡轉成 HTTP status
+func writeTodoListError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTodoListNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrTodoListForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInviteInvalid), errors.Is(err, service.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInviteEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, repository.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, pagination.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// 路徑上的 UUID；格式不對的一定找不到，直接回 404，不要送到資料庫變成 500
+// 回傳的是標準格式的字串（uuid.Parse 也接受 {...}、urn:uuid:... 這些資料庫不一定認得的寫法）
+func uuidParam(c *gin.Context, name string, notFound error) (string, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound.Error()})
+		return "", false
+	}
+	return id.String(), true
+}
+
+func todoListIDParam(c *gin.Context) (string, bool) {
+	return uuidParam(c, "id", service.ErrTodoListNotFound)
+}
+
+/*
+	{
+	    "name": "搬家",
+	    "description": "跟室友一起分工"
+	}
+*/
+func CreateTodoListHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input CreateTodoListRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		list, err := listService.CreateList(c.Request.Context(), userID, input.Name, input.Description)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, list)
+	}
+}
+
+// GET /lists => 我參與的所有清單
+func GetTodoListsHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		lists, err := listService.GetLists(c.Request.Context(), userID)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": lists})
+	}
+}
+
+func GetTodoListHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		list, err := listService.GetList(c.Request.Context(), userID, listID)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+func DeleteTodoListHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		if err := listService.DeleteList(c.Request.Context(), userID, listID); err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func GetTodoListMembersHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		members, err := listService.GetMembers(c.Request.Context(), userID, listID)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": members})
+	}
+}
+
+// DELETE /lists/:id/members/:userId => owner 移除成員，或成員自己退出
+func RemoveTodoListMemberHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+		memberID, ok := uuidParam(c, "userId", service.ErrMemberNotFound)
+		if !ok {
+			return
+		}
+
+		if err := listService.RemoveMember(c.Request.Context(), userID, listID, memberID); err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+/*
+	{
+	    "email": "roommate@example.com",
+	    "role": "editor"
+	}
+*/
+func InviteTodoListMemberHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		var input InviteTodoListMemberRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		invite, token, err := listService.Invite(c.Request.Context(), userID, listID, input.Email, input.Role)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		// token 只會在這裡出現一次，前端要把它做成邀請連結交給對方
+		c.JSON(http.StatusCreated, gin.H{
+			"invite": invite,
+			"token":  token,
+		})
+	}
+}
+
+func GetTodoListInvitesHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		invites, err := listService.GetPendingInvites(c.Request.Context(), userID, listID)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"items": invites})
+	}
+}
+
+func RevokeTodoListInviteHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+		inviteID, ok := uuidParam(c, "inviteId", service.ErrInviteInvalid)
+		if !ok {
+			return
+		}
+
+		if err := listService.RevokeInvite(c.Request.Context(), userID, listID, inviteID); err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// POST /invites/accept => 被邀請的人登入之後帶 token 接受邀請
+func AcceptTodoListInviteHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input AcceptInviteRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		list, err := listService.AcceptInvite(c.Request.Context(), userID, input.Token)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// GET /lists/:id/todos => 清單裡的 todos，篩選、排序、分頁參數跟 GET /todos 一樣
+func GetTodoListTodosHandler(listService *service.TodoListService, codec *pagination.Codec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		params, err := parsePagination(c, codec, 5)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter, err := parseTodoFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 排序位置是每個建立者各自一組，放在同一個清單裡排沒有意義（見 repository.MoveTodo）
+		if filter.SortField == "position" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort by position is not supported for list todos"})
+			return
+		}
+
+		result, err := listService.GetTodos(c.Request.Context(), userID, listID, params, filter)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// POST /lists/:id/todos => body 跟 POST /todos 一樣
+func CreateTodoListTodoHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		var input CreateTodoRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		newTodo := input.toTodo()
+		if err := validateTodoSchedule(newTodo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		todo, err := listService.CreateTodo(c.Request.Context(), userID, listID, newTodo)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, todo)
+	}
+}
+
+// PUT /lists/:id/todos/:todoId => body 跟 PUT /todos/:id 一樣，editor 以上才能改
+func UpdateTodoListTodoHandler(listService *service.TodoListService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		listID, ok := todoListIDParam(c)
+		if !ok {
+			return
+		}
+
+		todoID, err := strconv.Atoi(c.Param("todoId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "INVALID TODO ID"})
+			return
+		}
+
+		var input UpdateTodoRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		existing, err := listService.GetTodo(c.Request.Context(), userID, listID, todoID)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		if !checkIfMatch(c, existing.Version) {
+			return
+		}
+
+		changed := input.applyTo(existing)
+		if sameTodoContent(existing, &changed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "todo has not been changed"})
+			return
+		}
+		if err := validateTodoSchedule(&changed); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		todo, err := listService.UpdateTodo(c.Request.Context(), userID, listID, &changed)
+		if err != nil {
+			writeTodoListError(c, err)
+			return
+		}
+
+		setVersionETag(c, todo.Version)
+		c.JSON(http.StatusOK, todo)
+	}
+}
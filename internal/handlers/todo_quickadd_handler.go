@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"unicode/utf8"
+
+	"todo_api/internal/models"
+	"todo_api/internal/quickadd"
+	"todo_api/internal/recurrence"
+	"todo_api/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuickAddTodoRequest struct {
+	Text string `json:"text" binding:"required,max=500"`
+	// 解析「明天」「5pm」用哪個時區，也是週期 todo 的 recurrence_tz；沒帶就是 UTC
+	TZ string `json:"tz" binding:"max=64"`
+}
+
+/*
+POST /todos/quick
+
+	{
+	    "text": "Pay rent every month on the 1st #home !high",
+	    "tz": "Asia/Taipei"
+	}
+
+解析出截止時間、優先順序、週期之後照 POST /todos 的流程建立，回傳 parsed（解析結果，含認得的片語）跟 todo
+#標籤會掛到 todo 上，自己還沒有的標籤會自動建立
+*/
+func QuickAddTodoHandler(parser *quickadd.Parser, labelService *service.LabelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		var input QuickAddTodoRequest
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		loc, err := recurrence.LoadLocation(input.TZ)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		parsed, err := parser.Parse(input.Text, loc)
+		if err != nil {
+			if errors.Is(err, quickadd.ErrEmptyTitle) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if utf8.RuneCountInString(parsed.Title) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title must be at most 255 characters"})
+			return
+		}
+		for _, label := range parsed.Labels {
+			if utf8.RuneCountInString(label) > 100 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "labels must be at most 100 characters"})
+				return
+			}
+		}
+
+		newTodo := &models.Todo{
+			UserID:   userID,
+			Title:    parsed.Title,
+			DueAt:    parsed.DueAt,
+			Priority: parsed.Priority,
+			// 第一次的截止時間就是規則的起點
+			RecurrenceRule:  parsed.RecurrenceRule,
+			RecurrenceTZ:    loc.String(),
+			RecurrenceStart: parsed.DueAt,
+		}
+		if err := validateTodoSchedule(newTodo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// todo 跟標籤一起寫入，標籤失敗就整筆不建立
+		todo, err := labelService.CreateTodoWithLabels(c.Request.Context(), newTodo, parsed.Labels)
+		if err != nil {
+			writeLabelError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"parsed": parsed,
+			"todo":   todo,
+		})
+	}
+}
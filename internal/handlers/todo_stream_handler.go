@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/realtime"
+	"todo_api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// 重連時最多從資料庫補幾筆，漏太多就叫前端整個重新載入
+	streamReplayLimit = 500
+	// 定期送註解行，避免 proxy 把閒置的連線切掉
+	streamHeartbeat = 25 * time.Second
+	// 告訴瀏覽器斷線後多久重連
+	streamRetry = 3 * time.Second
+)
+
+/*
+GET /todos/stream => Server-Sent Events，自己的 todo、或自己所在清單裡的 todo 有新增、修改、刪除、還原、移動、undo 就推一筆
+
+	event: todo     data 是 models.TodoStreamEvent，id 是變更紀錄的 id
+	event: ready    剛連上（沒有 Last-Event-ID），id 是目前最新的變更紀錄，之後重連就從這裡補
+	event: reset    漏掉的變更太多補不完，前端要重新 GET /todos；id 一樣是目前最新的
+
+重連時帶 Last-Event-ID header（瀏覽器的 EventSource 會自動帶）或 ?last_event_id=，會先補上中間漏掉的變更
+連線太慢跟不上、或伺服器跟資料庫的 LISTEN 連線斷過，伺服器會主動斷線，client 照樣重連補資料就好
+*/
+func StreamTodosHandler(pool *pgxpool.Pool, hub *realtime.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+
+		lastEventID, err := parseLastEventID(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		// 先訂閱再從資料庫補，補的過程中發生的變更才不會漏掉（重複的用 replayed 濾掉）
+		sub := hub.Subscribe(userID)
+		defer hub.Unsubscribe(sub)
+
+		var replay []models.TodoStreamEvent
+		if lastEventID > 0 {
+			replay, err = repository.GetTodoStreamEvents(ctx, pool, userID, lastEventID, streamReplayLimit+1)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// 沒有起點或漏太多：給前端目前最新的 id 當作新的起點
+		var control string
+		var latestID int64
+		if lastEventID <= 0 || len(replay) > streamReplayLimit {
+			control = "ready"
+			if lastEventID > 0 {
+				control = "reset"
+			}
+			replay = nil
+			if latestID, err = repository.LatestTodoEventID(ctx, pool); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no") // nginx 不要 buffer
+		c.Status(http.StatusOK)
+
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetry.Milliseconds())
+		if control != "" {
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: {}\n\n", latestID, control)
+		}
+
+		replayed := make(map[int64]bool, len(replay))
+		for _, event := range replay {
+			if err := writeStreamEvent(c.Writer, event); err != nil {
+				return
+			}
+			replayed[event.ID] = true
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-sub.Events():
+				if !ok {
+					// 被 hub 斷掉了，結束 response 讓 client 重連
+					return
+				}
+				if replayed[event.ID] {
+					continue
+				}
+				if err := writeStreamEvent(c.Writer, event); err != nil {
+					return
+				}
+				c.Writer.Flush()
+
+			case <-heartbeat.C:
+				if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// Last-Event-ID header 優先，沒有就看 ?last_event_id=；都沒有回傳 0
+func parseLastEventID(c *gin.Context) (int64, error) {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid Last-Event-ID %q", raw)
+	}
+	return id, nil
+}
+
+func writeStreamEvent(w io.Writer, event models.TodoStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: todo\ndata: %s\n\n", event.ID, data)
+	return err
+}
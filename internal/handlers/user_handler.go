@@ -1,10 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/mail"
 	"strings"
-	"time"
 
 	"todo_api/internal/config"
 	"todo_api/internal/models"
@@ -12,7 +13,6 @@ import (
 	"todo_api/internal/service"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -25,13 +25,12 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// 瀏覽器用 true：refresh token 放 HttpOnly cookie，JavaScript 讀不到
+	UseCookie bool `json:"use_cookie"`
 }
 
-type LoginResponse struct {
-	Token string `json:"token"`
-}
-
-func CreateUserHandler(pool *pgxpool.Pool) gin.HandlerFunc {
+// 註冊成功之後寄驗證信；寄信失敗不影響註冊，使用者登入之後可以用 POST /auth/resend-verification 重寄
+func CreateUserHandler(pool *pgxpool.Pool, verificationService *service.EmailVerificationService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var registerRequest RegisterRequest
 
@@ -43,6 +42,12 @@ func CreateUserHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		// 只接受單純的 email address，不接受 "Name <a@example.com>" 這種格式或前後有空白
+		if address, err := mail.ParseAddress(registerRequest.Email); err != nil || address.Address != registerRequest.Email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email address"})
+			return
+		}
+
 		if len(registerRequest.Password) < 6 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 6 characters long"})
 			return
@@ -70,11 +75,26 @@ func CreateUserHandler(pool *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
+		if err := verificationService.SendVerification(c.Request.Context(), createdUser); err != nil {
+			log.Printf("send verification email to user %s: %v\n", createdUser.ID, err)
+		}
+
 		c.JSON(http.StatusCreated, createdUser)
 	}
 }
 
-func LoginHandler(pool *pgxpool.Pool, cfg *config.Config) gin.HandlerFunc {
+/*
+POST /auth/login
+
+	{
+	    "email": "a@example.com",
+	    "password": "secret",
+	    "use_cookie": true
+	}
+
+回傳短效的 access token 跟 refresh token；use_cookie 是 true 的話 refresh token 改放 HttpOnly cookie，不放在 body
+*/
+func LoginHandler(authService *service.AuthService, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var loginRequest LoginRequest
 
@@ -83,35 +103,18 @@ func LoginHandler(pool *pgxpool.Pool, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		user, err := repository.GetUserByEmail(pool, loginRequest.Email)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
-			return
-		}
-
-		// 把存在db的加鹽密碼跟前端傳來的密碼比對
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password))
+		pair, err := authService.Login(c.Request.Context(), loginRequest.Email, loginRequest.Password, c.Request.UserAgent())
 		if err != nil {
 			// 密碼錯誤代表沒成功被賦予權限，所以失敗
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
-			return
-		}
-
-		// creating, signing, and encoding a JWT token using the HMAC signing method
-		t := jwt.NewWithClaims(jwt.SigningMethodHS256,
-			jwt.MapClaims{
-				"user_id": user.ID,
-				"email":   user.Email,
-				"exp":     time.Now().Add(24 * time.Hour).Unix(), // Unix() 代表 UTC 秒數時間戳
-			})
-
-		tokenString, err := t.SignedString([]byte(cfg.JWTSecret))
-		if err != nil {
+			if errors.Is(err, service.ErrInvalidCredentials) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token: " + err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, LoginResponse{Token: tokenString})
+		writeTokenPair(c, cfg, pair, loginRequest.UseCookie)
 	}
 }
 
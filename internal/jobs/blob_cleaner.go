@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 每一輪最多刪幾個檔案
+const blobCleanupBatchSize = 100
+
+// 定期把 pending_blob_deletions 裡的檔案從 BlobStorage 刪掉（附件被刪除、todo 被永久刪除時由 trigger 排進來）
+func StartBlobCleaner(ctx context.Context, pool *pgxpool.Pool, blobs repository.BlobStorage, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupBlobs(ctx, pool, blobs)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func cleanupBlobs(ctx context.Context, pool *pgxpool.Pool, blobs repository.BlobStorage) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	deleted, err := repository.ProcessPendingBlobDeletions(runCtx, pool, blobCleanupBatchSize, func(key string) error {
+		// 已經不在了（例如上一輪刪了但沒來得及記錄）也算成功
+		if err := blobs.Delete(runCtx, key); err != nil && !errors.Is(err, repository.ErrBlobNotFound) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("blob cleaner: %v\n", err)
+	}
+	if deleted > 0 {
+		log.Printf("blob cleaner: deleted %d blobs\n", deleted)
+	}
+}
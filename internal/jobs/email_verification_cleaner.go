@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 定期刪掉過期的 email 驗證 token；過期的連結本來就不能用，留著只會讓表越來越大
+func StartEmailVerificationCleaner(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupEmailVerificationTokens(ctx, pool)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func cleanupEmailVerificationTokens(ctx context.Context, pool *pgxpool.Pool) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	deleted, err := repository.DeleteExpiredEmailVerificationTokens(runCtx, pool, time.Now())
+	if err != nil {
+		log.Printf("email verification cleaner: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("email verification cleaner: deleted %d expired verification tokens\n", deleted)
+	}
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// 排序鍵超過這個長度就重新分配；一直插在同一個空隙時鍵才會慢慢變長
+	rankMaxKeyLength = 32
+	// 每一輪最多處理幾個使用者
+	rankRebalanceBatchSize = 50
+)
+
+// 定期把排序鍵太長的使用者重新平均分配位置
+func StartRankRebalancer(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			rebalanceTodoPositions(ctx, pool)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func rebalanceTodoPositions(ctx context.Context, pool *pgxpool.Pool) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	userIDs, err := repository.GetUsersNeedingRebalance(runCtx, pool, rankMaxKeyLength, rankRebalanceBatchSize)
+	if err != nil {
+		log.Printf("rank rebalancer: %v\n", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		count, err := repository.RebalanceTodoPositions(runCtx, pool, userID)
+		if err != nil {
+			log.Printf("rank rebalancer: user %s: %v\n", userID, err)
+			continue
+		}
+		log.Printf("rank rebalancer: rebalanced %d todos for user %s\n", count, userID)
+	}
+}
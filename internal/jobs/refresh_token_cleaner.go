@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 定期刪掉過期的 refresh token；過期的本來就不能換發，留著只會讓表越來越大
+func StartRefreshTokenCleaner(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupRefreshTokens(ctx, pool)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func cleanupRefreshTokens(ctx context.Context, pool *pgxpool.Pool) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	deleted, err := repository.DeleteExpiredRefreshTokens(runCtx, pool, time.Now())
+	if err != nil {
+		log.Printf("refresh token cleaner: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("refresh token cleaner: deleted %d expired refresh tokens\n", deleted)
+	}
+}
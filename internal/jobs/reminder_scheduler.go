@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/notifier"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 每一輪最多處理幾筆，避免一次撈太多把連線佔住
+const reminderBatchSize = 100
+
+// 定期找出 remind_at 已經到了的 todos，透過 notifier 送出提醒
+func StartReminderScheduler(ctx context.Context, pool *pgxpool.Pool, n notifier.Notifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sendDueReminders(ctx, pool, n)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func sendDueReminders(ctx context.Context, pool *pgxpool.Pool, n notifier.Notifier) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// 先認領（押上 reminded_at）再送，多個實例同時跑也不會重複提醒
+	todos, err := repository.ClaimDueReminders(runCtx, pool, time.Now(), reminderBatchSize)
+	if err != nil {
+		log.Printf("reminder scheduler: %v\n", err)
+		return
+	}
+
+	for _, todo := range todos {
+		if err := n.NotifyReminder(runCtx, todo); err != nil {
+			log.Printf("reminder scheduler: failed to notify todo %d: %v\n", todo.ID, err)
+			// 送失敗就還回去，下一輪再試
+			if err := repository.ReleaseReminder(runCtx, pool, todo.ID); err != nil {
+				log.Printf("reminder scheduler: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/notifier"
+	"todo_api/internal/testdb"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 每次都送失敗的 notifier
+type failingNotifier struct{}
+
+func (failingNotifier) NotifyReminder(ctx context.Context, todo models.Todo) error {
+	return errors.New("smtp is down")
+}
+
+// 直接 INSERT，completed / trashed 的狀態不用再呼叫一堆 repository
+func insertReminderTodo(t *testing.T, pool *pgxpool.Pool, userID string, title string, remindAt time.Time, completed bool, trashed bool) int {
+	t.Helper()
+
+	var deletedAt *time.Time
+	if trashed {
+		now := time.Now()
+		deletedAt = &now
+	}
+
+	var id int
+	err := pool.QueryRow(context.Background(), `
+		INSERT INTO todos (user_id, title, completed, remind_at, deleted_at, position)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, userID, title, completed, remindAt, deletedAt, fmt.Sprintf("%010dV", time.Now().UnixNano()%1e10)).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func sentIDs(n *notifier.MemoryNotifier) []int {
+	var ids []int
+	for _, todo := range n.Sent() {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func TestSendDueRemindersOnce(t *testing.T) {
+	pool := testdb.New(t)
+	ctx := context.Background()
+	user := testdb.CreateUser(t, pool, "reminders@example.com")
+
+	past := time.Now().Add(-time.Minute)
+	due := insertReminderTodo(t, pool, user.ID, "due", past, false, false)
+	insertReminderTodo(t, pool, user.ID, "later", time.Now().Add(time.Hour), false, false)
+	insertReminderTodo(t, pool, user.ID, "completed", past, true, false)
+	insertReminderTodo(t, pool, user.ID, "trashed", past, false, true)
+
+	// 模擬多個實例同時跑排程
+	n := notifier.NewMemoryNotifier()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sendDueReminders(ctx, pool, n)
+		}()
+	}
+	wg.Wait()
+
+	// 下一輪也不會再送
+	sendDueReminders(ctx, pool, n)
+
+	if got := sentIDs(n); len(got) != 1 || got[0] != due {
+		t.Fatalf("sent todos = %v, want only [%d]", got, due)
+	}
+}
+
+func TestSendDueRemindersReleasesClaimOnError(t *testing.T) {
+	pool := testdb.New(t)
+	ctx := context.Background()
+	user := testdb.CreateUser(t, pool, "release@example.com")
+
+	id := insertReminderTodo(t, pool, user.ID, "due", time.Now().Add(-time.Minute), false, false)
+
+	sendDueReminders(ctx, pool, failingNotifier{})
+
+	var remindedAt *time.Time
+	if err := pool.QueryRow(ctx, `SELECT reminded_at FROM todos WHERE id = $1`, id).Scan(&remindedAt); err != nil {
+		t.Fatal(err)
+	}
+	if remindedAt != nil {
+		t.Fatalf("reminded_at = %v after a failed send, want NULL", remindedAt)
+	}
+
+	// 還回去之後下一輪會再送
+	n := notifier.NewMemoryNotifier()
+	sendDueReminders(ctx, pool, n)
+	if got := sentIDs(n); len(got) != 1 || got[0] != id {
+		t.Fatalf("sent todos = %v, want [%d]", got, id)
+	}
+}
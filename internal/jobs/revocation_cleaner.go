@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+	"todo_api/internal/revocation"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 定期清掉已經過期的 access token 撤銷紀錄（資料庫跟 store 的快取）；token 過期之後驗簽就會被擋，用不到了
+func StartRevocationCleaner(ctx context.Context, pool *pgxpool.Pool, store *revocation.Store, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupRevocations(ctx, pool, store)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func cleanupRevocations(ctx context.Context, pool *pgxpool.Pool, store *revocation.Store) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	store.Prune()
+
+	deleted, err := repository.DeleteExpiredRevokedTokens(runCtx, pool, time.Now())
+	if err != nil {
+		log.Printf("revocation cleaner: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("revocation cleaner: deleted %d expired revoked tokens\n", deleted)
+	}
+}
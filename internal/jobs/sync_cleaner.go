@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 定期清掉超過 retention 的墓碑跟同步 mutation 紀錄；比這更舊的同步 token 會被 GET /sync 拒絕，所以用不到了
+func StartSyncCleaner(ctx context.Context, pool *pgxpool.Pool, retention time.Duration, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupSyncHistory(ctx, pool, retention)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func cleanupSyncHistory(ctx context.Context, pool *pgxpool.Pool, retention time.Duration) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pruned, err := repository.PruneSyncHistory(runCtx, pool, retention)
+	if err != nil {
+		log.Printf("sync cleaner: %v\n", err)
+		return
+	}
+	if pruned > 0 {
+		log.Printf("sync cleaner: pruned %d rows older than %s\n", pruned, retention)
+	}
+}
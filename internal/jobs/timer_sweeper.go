@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 定期停掉跑超過 maxDuration 的計時器（多半是忘了按停止），停在開始之後 maxDuration 的時間點並標記 auto_stopped
+func StartTimerSweeper(ctx context.Context, pool *pgxpool.Pool, maxDuration time.Duration, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sweepStaleTimers(ctx, pool, maxDuration)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func sweepStaleTimers(ctx context.Context, pool *pgxpool.Pool, maxDuration time.Duration) {
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	stopped, err := repository.StopStaleTimers(runCtx, pool, maxDuration)
+	if err != nil {
+		log.Printf("timer sweeper: %v\n", err)
+		return
+	}
+	if stopped > 0 {
+		log.Printf("timer sweeper: auto-stopped %d timers running longer than %s\n", stopped, maxDuration)
+	}
+}
@@ -0,0 +1,46 @@
+// 背景工作：跟 HTTP 請求無關、需要定期執行的事情都放這裡
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 定期把在垃圾桶放超過 retention 的 todos 永久刪除
+// ctx 被取消（服務關閉）時 goroutine 就會結束
+func StartTrashCleaner(ctx context.Context, pool *pgxpool.Pool, retention time.Duration, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupTrash(ctx, pool, retention)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func cleanupTrash(ctx context.Context, pool *pgxpool.Pool, retention time.Duration) {
+	// 每一輪都給自己的 timeout，避免某次清理卡住拖到下一輪
+	runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	purged, err := repository.PurgeExpiredTodos(runCtx, pool, retention)
+	if err != nil {
+		log.Printf("trash cleaner: %v\n", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("trash cleaner: purged %d todos older than %s\n", purged, retention)
+	}
+}
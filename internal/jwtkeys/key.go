@@ -0,0 +1,145 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RSA key 至少要這麼長
+const minRSABits = 2048
+
+// 一把簽章用的 key；Signer 是 nil 代表只有 public key，只能拿來驗證（已經退役、換下來的 key）
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+	Signer crypto.Signer
+}
+
+func (k *Key) CanSign() bool {
+	return k.Signer != nil
+}
+
+// 依 public key 的種類決定演算法：RSA => RS256、P-256 => ES256（P-384 / P-521 => ES384 / ES512）、Ed25519 => EdDSA
+func methodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", public)
+}
+
+// 用 private key 建立 Key；kid 是空字串就用 JWK thumbprint（RFC 7638）
+func NewKey(kid string, signer crypto.Signer) (*Key, error) {
+	key, err := NewPublicKey(kid, signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Signer = signer
+	return key, nil
+}
+
+// 只能驗證的 key；kid 是空字串就用 JWK thumbprint（RFC 7638）
+func NewPublicKey(kid string, public crypto.PublicKey) (*Key, error) {
+	method, err := methodFor(public)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid, Method: method, Public: public}
+	if key.ID == "" {
+		if key.ID, err = thumbprint(key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+/*
+parsePEM 讀出所有 PEM block：PRIVATE KEY（PKCS#8）、RSA PRIVATE KEY（PKCS#1）、EC PRIVATE KEY（SEC 1）
+可以簽章，PUBLIC KEY 只能驗證。kid 的順序：參數給的 > block 的 kid header > thumbprint；
+參數給了 kid 的話只能有一個 block
+*/
+func parsePEM(data []byte, kid string) ([]*Key, error) {
+	var keys []*Key
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		data = rest
+
+		blockKID := kid
+		if blockKID == "" {
+			blockKID = block.Headers["kid"]
+		}
+
+		key, err := parseBlock(block, blockKID)
+		if err != nil {
+			return nil, fmt.Errorf("%s block: %w", block.Type, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM block found")
+	}
+	if kid != "" && len(keys) > 1 {
+		return nil, fmt.Errorf("kid %q given for %d keys", kid, len(keys))
+	}
+	return keys, nil
+}
+
+func parseBlock(block *pem.Block, kid string) (*Key, error) {
+	var private any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewPublicKey(kid, public)
+	default:
+		return nil, errors.New("unsupported PEM block type")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	return NewKey(kid, signer)
+}
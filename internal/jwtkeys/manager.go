@@ -0,0 +1,185 @@
+/*
+Package jwtkeys 管 access token 的簽章 key：
+
+  - 可以同時有好幾把 RS256 / ES256 / EdDSA 的 key，用 kid 區分；簽章只用 active 那一把，其他的只拿來驗證
+  - 換 key 的做法：新 key 加進來並設成 active，舊 key 留著（或只留 public key），等舊 token 都過期再拿掉
+  - 其他服務從 GET /.well-known/jwks.json 拿 public key 驗證，不用知道任何秘密
+  - 相容舊版：有 HMAC secret（JWT_SECRET）就繼續接受 HS256；完全沒設定 key 的話也繼續用 HS256 簽
+*/
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey        = errors.New("unknown signing key")
+	ErrAlgorithmMismatch = errors.New("token algorithm does not match the signing key")
+	// 不在 ValidMethods 裡的 alg，包含 none，以及沒設定 JWT_SECRET 時的 HS256
+	ErrAlgorithmNotAccepted = errors.New("token algorithm is not accepted")
+)
+
+type Manager struct {
+	keys   map[string]*Key
+	order  []*Key // 設定的順序，JWKS 照這個順序列出
+	active *Key   // nil 代表用 HS256 簽
+	secret []byte // HS256 的 key，空的代表不接受 HS256
+}
+
+// activeKID 是空字串就用第一把可以簽章的 key；keys 是空的就只用 hmacSecret（HS256）
+func NewManager(keys []*Key, activeKID string, hmacSecret string) (*Manager, error) {
+	m := &Manager{
+		keys:   make(map[string]*Key, len(keys)),
+		secret: []byte(hmacSecret),
+	}
+
+	for _, key := range keys {
+		if _, exists := m.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate kid %q", key.ID)
+		}
+		m.keys[key.ID] = key
+		m.order = append(m.order, key)
+
+		if m.active == nil && activeKID == "" && key.CanSign() {
+			m.active = key
+		}
+	}
+
+	if activeKID != "" {
+		key, ok := m.keys[activeKID]
+		if !ok {
+			return nil, fmt.Errorf("active kid %q not found", activeKID)
+		}
+		if !key.CanSign() {
+			return nil, fmt.Errorf("active kid %q has no private key", activeKID)
+		}
+		m.active = key
+	}
+
+	if m.active == nil && len(m.secret) == 0 {
+		return nil, errors.New("no signing key: configure JWT_KEY_FILES / JWT_KEYS or JWT_SECRET")
+	}
+	return m, nil
+}
+
+/*
+Load 讀設定裡的 key：
+
+  - files：逗號分隔的 PEM 檔，每一個可以寫成 kid=path 指定 kid
+  - inline：直接放 PEM 內容（可以有好幾個 block，用 kid header 指定 kid），換行可以寫成 \n
+  - 沒指定 kid 的用 JWK thumbprint
+*/
+func Load(files string, inline string, activeKID string, hmacSecret string) (*Manager, error) {
+	var keys []*Key
+
+	for _, entry := range strings.Split(files, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path, found := strings.Cut(entry, "=")
+		if !found {
+			kid, path = "", entry
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT key %s: %w", path, err)
+		}
+		parsed, err := parsePEM(data, kid)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWT key %s: %w", path, err)
+		}
+		keys = append(keys, parsed...)
+	}
+
+	if strings.TrimSpace(inline) != "" {
+		parsed, err := parsePEM([]byte(strings.ReplaceAll(inline, `\n`, "\n")), "")
+		if err != nil {
+			return nil, fmt.Errorf("parse JWT_KEYS: %w", err)
+		}
+		keys = append(keys, parsed...)
+	}
+
+	return NewManager(keys, activeKID, hmacSecret)
+}
+
+// 用 active key 簽，header 帶 kid；沒有 active key 就用 HS256
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	if m.active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	}
+
+	token := jwt.NewWithClaims(m.active.Method, claims)
+	token.Header["kid"] = m.active.ID
+	return token.SignedString(m.active.Signer)
+}
+
+/*
+Keyfunc 給 jwt.Parse 用：HS256 用 HMAC secret，其他依 header 的 kid 找 public key
+
+token 的 alg 一定要跟那把 key 的演算法一樣，不能拿 RSA 的 public key 當 HS256 的 secret（algorithm confusion）；
+不在 ValidMethods 裡的 alg 在找 key 之前就擋掉，所以 alg=none 永遠過不了
+*/
+func (m *Manager) Keyfunc(token *jwt.Token) (any, error) {
+	alg := token.Method.Alg()
+	if !slices.Contains(m.ValidMethods(), alg) {
+		return nil, ErrAlgorithmNotAccepted
+	}
+	if alg == jwt.SigningMethodHS256.Alg() {
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if key.Method.Alg() != alg {
+		return nil, ErrAlgorithmMismatch
+	}
+	return key.Public, nil
+}
+
+// 可以接受的 alg，Keyfunc 用來擋掉其他演算法；alg=none 永遠不在裡面
+func (m *Manager) ValidMethods() []string {
+	var methods []string
+	if len(m.secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	for _, key := range m.order {
+		alg := key.Method.Alg()
+		if !slices.Contains(methods, alg) {
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// 所有 key 的 public key；HS256 的 secret 當然不會出現
+func (m *Manager) JWKS() (JWKSet, error) {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range m.order {
+		jwk, err := key.JWK()
+		if err != nil {
+			return JWKSet{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// 目前簽章用的 kid，用 HS256 簽的話是空字串
+func (m *Manager) ActiveKID() string {
+	if m.active == nil {
+		return ""
+	}
+	return m.active.ID
+}
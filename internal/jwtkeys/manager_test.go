@@ -0,0 +1,457 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-hmac-secret"
+
+func generate(t *testing.T, alg string) crypto.Signer {
+	t.Helper()
+
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case "RS256":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "EdDSA":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unknown alg %q", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func newKey(t *testing.T, kid string, signer crypto.Signer) *Key {
+	t.Helper()
+
+	key, err := NewKey(kid, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newManager(t *testing.T, keys []*Key, activeKID string, secret string) *Manager {
+	t.Helper()
+
+	m, err := NewManager(keys, activeKID, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "u1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func signWith(t *testing.T, m *Manager) string {
+	t.Helper()
+
+	token, err := m.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// 用 Keyfunc 驗證，回傳 header 方便檢查 alg / kid
+func verify(m *Manager, tokenString string) (map[string]any, error) {
+	token, err := jwt.Parse(tokenString, m.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+	return token.Header, nil
+}
+
+// 手動簽一個 token，header 可以帶任意的 kid
+func signRaw(t *testing.T, method jwt.SigningMethod, key any, kid string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestSignWithEachAlgorithm(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			m := newManager(t, []*Key{newKey(t, "k1", generate(t, alg))}, "", testSecret)
+
+			header, err := verify(m, signWith(t, m))
+			if err != nil {
+				t.Fatalf("verify() error = %v", err)
+			}
+			if header["alg"] != alg || header["kid"] != "k1" {
+				t.Fatalf("header = %v, want alg %s, kid k1", header, alg)
+			}
+		})
+	}
+
+	// 沒有設定任何 key：用 JWT_SECRET 簽 HS256，header 不帶 kid
+	t.Run("HS256 fallback", func(t *testing.T) {
+		m := newManager(t, nil, "", testSecret)
+		if m.ActiveKID() != "" {
+			t.Fatalf("ActiveKID() = %q, want empty", m.ActiveKID())
+		}
+
+		header, err := verify(m, signWith(t, m))
+		if err != nil {
+			t.Fatalf("verify() error = %v", err)
+		}
+		if header["alg"] != "HS256" || header["kid"] != nil {
+			t.Fatalf("header = %v, want HS256 without kid", header)
+		}
+	})
+}
+
+// 換 key：新 key 設成 active，舊 key 只留 public key，舊 token 還是驗得過；舊 key 拿掉之後就不行了
+func TestRotation(t *testing.T) {
+	oldSigner := generate(t, "RS256")
+	oldKey := newKey(t, "old", oldSigner)
+	newSigner := newKey(t, "new", generate(t, "ES256"))
+
+	before := newManager(t, []*Key{oldKey}, "", "")
+	oldToken := signWith(t, before)
+
+	retired, err := NewPublicKey("old", oldSigner.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := newManager(t, []*Key{retired, newSigner}, "new", "")
+	if rotated.ActiveKID() != "new" {
+		t.Fatalf("ActiveKID() = %q, want new", rotated.ActiveKID())
+	}
+
+	if _, err := verify(rotated, oldToken); err != nil {
+		t.Fatalf("old token after rotation: verify() error = %v", err)
+	}
+	header, err := verify(rotated, signWith(t, rotated))
+	if err != nil {
+		t.Fatalf("new token: verify() error = %v", err)
+	}
+	if header["kid"] != "new" {
+		t.Fatalf("new token kid = %v, want new", header["kid"])
+	}
+
+	// HS256 fallback 的 token 在設定了 key、又沒有 JWT_SECRET 之後就不接受
+	hmacToken := signWith(t, newManager(t, nil, "", testSecret))
+	if _, err := verify(rotated, hmacToken); !errors.Is(err, ErrAlgorithmNotAccepted) {
+		t.Fatalf("HS256 token after rotation: verify() error = %v, want ErrAlgorithmNotAccepted", err)
+	}
+
+	// 還有另一把 RS256 的 key，所以是找不到 kid，不是演算法不接受
+	removed := newManager(t, []*Key{newSigner, newKey(t, "other", generate(t, "RS256"))}, "", "")
+	if _, err := verify(removed, oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("old token after removal: verify() error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyfuncRejects(t *testing.T) {
+	rsaSigner := generate(t, "RS256")
+	ecSigner := generate(t, "ES256")
+	m := newManager(t, []*Key{newKey(t, "r1", rsaSigner), newKey(t, "e1", ecSigner)}, "r1", testSecret)
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"unknown kid", signRaw(t, jwt.SigningMethodRS256, rsaSigner, "nope"), ErrUnknownKey},
+		{"missing kid", signRaw(t, jwt.SigningMethodRS256, rsaSigner, ""), ErrUnknownKey},
+		{"ES256 with RSA kid", signRaw(t, jwt.SigningMethodES256, ecSigner, "r1"), ErrAlgorithmMismatch},
+		{"RS256 with EC kid", signRaw(t, jwt.SigningMethodRS256, rsaSigner, "e1"), ErrAlgorithmMismatch},
+		{"PS256 not configured", signRaw(t, jwt.SigningMethodPS256, rsaSigner, "r1"), ErrAlgorithmNotAccepted},
+		{"EdDSA not configured", signRaw(t, jwt.SigningMethodEdDSA, generate(t, "EdDSA"), "r1"), ErrAlgorithmNotAccepted},
+		{"alg none", signRaw(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "r1"), ErrAlgorithmNotAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verify(m, tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 沒有 JWT_SECRET 的話完全不接受 HS256，就算 header 帶了存在的 kid
+	rsaOnly := newManager(t, []*Key{newKey(t, "r1", rsaSigner)}, "", "")
+	if _, err := verify(rsaOnly, signRaw(t, jwt.SigningMethodHS256, []byte(testSecret), "r1")); !errors.Is(err, ErrAlgorithmNotAccepted) {
+		t.Fatalf("HS256 without secret: verify() error = %v, want ErrAlgorithmNotAccepted", err)
+	}
+}
+
+func TestNewManager(t *testing.T) {
+	signer := generate(t, "ES256")
+	public, err := NewPublicKey("pub", generate(t, "RS256").Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 沒指定 active 就用第一把可以簽章的，只有 public key 的跳過
+	m := newManager(t, []*Key{public, newKey(t, "e1", signer)}, "", "")
+	if m.ActiveKID() != "e1" {
+		t.Fatalf("ActiveKID() = %q, want e1", m.ActiveKID())
+	}
+	if got := strings.Join(m.ValidMethods(), ","); got != "RS256,ES256" {
+		t.Fatalf("ValidMethods() = %s, want RS256,ES256", got)
+	}
+	if got := strings.Join(newManager(t, []*Key{newKey(t, "e1", signer)}, "", testSecret).ValidMethods(), ","); got != "HS256,ES256" {
+		t.Fatalf("ValidMethods() with secret = %s, want HS256,ES256", got)
+	}
+
+	tests := []struct {
+		name      string
+		keys      []*Key
+		activeKID string
+		secret    string
+	}{
+		{"duplicate kid", []*Key{newKey(t, "k", signer), newKey(t, "k", generate(t, "EdDSA"))}, "", ""},
+		{"active kid not found", []*Key{newKey(t, "k", signer)}, "missing", ""},
+		{"active kid is public only", []*Key{public, newKey(t, "k", signer)}, "pub", ""},
+		{"only public keys and no secret", []*Key{public}, "", ""},
+		{"nothing configured", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(tt.keys, tt.activeKID, tt.secret); err == nil {
+				t.Fatal("NewManager() error = nil")
+			}
+		})
+	}
+}
+
+func decodeB64(t *testing.T, value string) []byte {
+	t.Helper()
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatalf("%q is not base64url: %v", value, err)
+	}
+	return raw
+}
+
+// JWKS 依設定的順序列出所有 public key，內容要能還原回同一把 public key；HS256 的 secret 不會出現
+func TestJWKS(t *testing.T) {
+	rsaSigner := generate(t, "RS256")
+	ecSigner := generate(t, "ES256")
+	edSigner := generate(t, "EdDSA")
+	retired, err := NewPublicKey("retired", generate(t, "RS256").Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newManager(t, []*Key{newKey(t, "r1", rsaSigner), newKey(t, "e1", ecSigner), newKey(t, "d1", edSigner), retired}, "e1", testSecret)
+	set, err := m.JWKS()
+	if err != nil {
+		t.Fatalf("JWKS() error = %v", err)
+	}
+
+	var kids []string
+	for _, jwk := range set.Keys {
+		kids = append(kids, jwk.Kid)
+		if jwk.Use != "sig" {
+			t.Errorf("%s: use = %q, want sig", jwk.Kid, jwk.Use)
+		}
+	}
+	if got := strings.Join(kids, ","); got != "r1,e1,d1,retired" {
+		t.Fatalf("JWKS() kids = %s, want r1,e1,d1,retired", got)
+	}
+
+	rsaJWK, ecJWK, edJWK := set.Keys[0], set.Keys[1], set.Keys[2]
+
+	rsaPublic := rsaSigner.Public().(*rsa.PublicKey)
+	if rsaJWK.Kty != "RSA" || rsaJWK.Alg != "RS256" {
+		t.Fatalf("RSA JWK = %+v", rsaJWK)
+	}
+	if new(big.Int).SetBytes(decodeB64(t, rsaJWK.N)).Cmp(rsaPublic.N) != 0 ||
+		new(big.Int).SetBytes(decodeB64(t, rsaJWK.E)).Int64() != int64(rsaPublic.E) {
+		t.Fatalf("RSA JWK n / e do not match the public key")
+	}
+
+	ecPublic := ecSigner.Public().(*ecdsa.PublicKey)
+	if ecJWK.Kty != "EC" || ecJWK.Alg != "ES256" || ecJWK.Crv != "P-256" {
+		t.Fatalf("EC JWK = %+v", ecJWK)
+	}
+	point, err := ecPublic.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := decodeB64(t, ecJWK.X), decodeB64(t, ecJWK.Y)
+	if len(x) != 32 || len(y) != 32 || string(point[1:]) != string(x)+string(y) {
+		t.Fatalf("EC JWK x / y do not match the public key")
+	}
+
+	if edJWK.Kty != "OKP" || edJWK.Alg != "EdDSA" || edJWK.Crv != "Ed25519" ||
+		string(decodeB64(t, edJWK.X)) != string(edSigner.Public().(ed25519.PublicKey)) {
+		t.Fatalf("Ed25519 JWK = %+v", edJWK)
+	}
+
+	// 只設定 HS256 的話 JWKS 是空的，但還是 [] 不是 null
+	empty, err := newManager(t, nil, "", testSecret).JWKS()
+	if err != nil || empty.Keys == nil || len(empty.Keys) != 0 {
+		t.Fatalf("JWKS() with HS256 only = %+v, %v, want empty keys", empty, err)
+	}
+}
+
+// 沒指定 kid 就用 RFC 7638 thumbprint：同一把 key 的 private / public 算出來一樣，不同的 key 不一樣
+func TestThumbprintKID(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		signer := generate(t, alg)
+		private := newKey(t, "", signer)
+		public, err := NewPublicKey("", signer.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		other := newKey(t, "", generate(t, alg))
+
+		if private.ID == "" || private.ID != public.ID {
+			t.Errorf("%s: kid = %q / %q, want the same thumbprint", alg, private.ID, public.ID)
+		}
+		if private.ID == other.ID {
+			t.Errorf("%s: two keys have the same kid %q", alg, private.ID)
+		}
+		if len(decodeB64(t, private.ID)) != 32 {
+			t.Errorf("%s: kid %q is not a SHA-256 thumbprint", alg, private.ID)
+		}
+	}
+
+	if _, err := NewKey("", generateSmallRSA(t)); err == nil {
+		t.Fatal("NewKey() with a 1024-bit RSA key error = nil")
+	}
+}
+
+func generateSmallRSA(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func pemBlock(t *testing.T, blockType string, der []byte, kid string) string {
+	t.Helper()
+
+	block := &pem.Block{Type: blockType, Bytes: der}
+	if kid != "" {
+		block.Headers = map[string]string{"kid": kid}
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestLoad(t *testing.T) {
+	rsaSigner := generate(t, "RS256").(*rsa.PrivateKey)
+	ecSigner := generate(t, "ES256").(*ecdsa.PrivateKey)
+	edSigner := generate(t, "EdDSA")
+
+	ecDER, err := x509.MarshalECPrivateKey(ecSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(rsaSigner.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	rsaPath := filepath.Join(dir, "rsa.pem")
+	ecPath := filepath.Join(dir, "ec.pem")
+	if err := os.WriteFile(rsaPath, []byte(pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaSigner), "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ecPath, []byte(pemBlock(t, "EC PRIVATE KEY", ecDER, "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 檔案一個用 kid=path、一個用 thumbprint；inline 的用 kid header，換行寫成 \n
+	inline := strings.ReplaceAll(pemBlock(t, "PRIVATE KEY", edDER, "d1")+pemBlock(t, "PUBLIC KEY", pubDER, "r-public"), "\n", `\n`)
+	m, err := Load("r1="+rsaPath+", "+ecPath, inline, "d1", "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	set, err := m.JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKID, err := thumbprint(newKey(t, "", ecSigner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kids []string
+	for _, jwk := range set.Keys {
+		kids = append(kids, jwk.Kid)
+	}
+	if got, want := strings.Join(kids, ","), "r1,"+ecKID+",d1,r-public"; got != want {
+		t.Fatalf("Load() kids = %s, want %s", got, want)
+	}
+	if m.ActiveKID() != "d1" {
+		t.Fatalf("ActiveKID() = %q, want d1", m.ActiveKID())
+	}
+
+	// 同一把 RSA key 用 r1 簽的 token，可以用 r-public 那個只有 public key 的 kid 驗
+	if _, err := verify(m, signRaw(t, jwt.SigningMethodRS256, rsaSigner, "r-public")); err != nil {
+		t.Fatalf("verify() with public-only kid error = %v", err)
+	}
+
+	for _, tt := range []struct{ name, files, inline string }{
+		{"missing file", filepath.Join(dir, "missing.pem"), ""},
+		{"not PEM", "", "not a key"},
+		{"kid for several blocks", "k=" + writeFile(t, dir, "two.pem", pemBlock(t, "EC PRIVATE KEY", ecDER, "")+pemBlock(t, "PUBLIC KEY", pubDER, "")), ""},
+		{"unsupported block", "", strings.ReplaceAll(pemBlock(t, "CERTIFICATE", []byte("x"), ""), "\n", `\n`)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.files, tt.inline, "", testSecret); err == nil {
+				t.Fatal("Load() error = nil")
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,124 @@
+/*
+寄信的方式都藏在 Mailer 介面後面，跟 notifier.Notifier 一樣，呼叫端只管要寄什麼
+
+  - SMTPMailer：正式環境
+  - FileMailer：本機開發，每封信存成 outbox 資料夾裡的 .eml 檔，用任何信件軟體都打得開
+  - MemoryMailer：測試用，寄出的信留在記憶體
+*/
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"todo_api/internal/utils"
+)
+
+// 目前只寄純文字的信
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// 收件人跟主旨會放進信件 header，有換行就能偷塞其他 header（例如 Bcc），一律拒絕
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+// RFC 5322 格式的信件內容，FileMailer 直接存檔、SMTPMailer 直接送出
+func (m Message) format(from string, now time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + m.To + "\r\n")
+	// 主旨有中文之類的非 ASCII 字元要用 RFC 2047 編碼，純 ASCII 的會原樣回傳
+	b.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", m.Subject) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	// 內文的換行也統一成 CRLF
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Text, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// 開發用：每封信存成 Dir 裡的一個 .eml 檔
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir string, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("建立 outbox 資料夾失敗: %w", err)
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	// 檔名用時間開頭，ls 就是寄出的順序；後面加亂數避免同一個時間寄兩封撞名
+	suffix, err := utils.NewRandomToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	name := now.UTC().Format("20060102T150405.000000000Z") + "-" + suffix[:8] + ".eml"
+
+	if err := os.WriteFile(filepath.Join(m.Dir, name), msg.format(m.From, now), 0o644); err != nil {
+		return fmt.Errorf("寫入 outbox 失敗: %w", err)
+	}
+	return nil
+}
+
+// 測試用：把寄出的信存在記憶體，之後可以用 Sent() 檢查
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// 回傳一份複本，避免呼叫端拿到 slice 後跟寄信的 goroutine 同時讀寫
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := make([]Message, len(m.sent))
+	copy(sent, m.sent)
+	return sent
+}
@@ -0,0 +1,92 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+/*
+SMTPMailer 每封信開一條連線寄出：伺服器支援 STARTTLS 就一定升級成 TLS，有設定帳號才登入
+
+net/smtp 的 PlainAuth 只會在 TLS 連線（或連到 localhost）時送出密碼，不會把密碼明碼送出去
+*/
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTPMailer(host string, port int, username string, password string, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	// smtp.SendMail 不吃 context，自己連線才能在 request 取消或逾時的時候中斷
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.Host, strconv.Itoa(m.Port)))
+	if err != nil {
+		return fmt.Errorf("連線 SMTP 伺服器失敗: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return fmt.Errorf("SMTP 握手失敗: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return fmt.Errorf("SMTP STARTTLS 失敗: %w", err)
+		}
+	}
+
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("SMTP 登入失敗: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM 失敗: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("SMTP RCPT TO 失敗: %w", err)
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA 失敗: %w", err)
+	}
+	if _, err := writer.Write(msg.format(m.From, time.Now())); err != nil {
+		return fmt.Errorf("寫入信件內容失敗: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("寄出信件失敗: %w", err)
+	}
+
+	return client.Quit()
+}
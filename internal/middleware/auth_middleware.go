@@ -2,80 +2,80 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
+	"errors"
+	"log"
 	"strings"
-	"time"
-	"todo_api/internal/config"
+	"todo_api/internal/authtoken"
+	"todo_api/internal/jwtkeys"
+	"todo_api/internal/revocation"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+/*
+AuthMiddleware 驗證 Authorization: Bearer <token>，驗證規則見 authtoken.Parse；
+revocations 確認 token 沒有被登出（jti）或被「登出所有裝置 / 改密碼」作廢（iat）
+
+失敗一律回 {"error": 固定的訊息, "code": 固定的 code}，不回 jwt 套件的錯誤原文
+*/
+func AuthMiddleware(keys *jwtkeys.Manager, revocations *revocation.Store, opts authtoken.Options) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// once we receive our request, the request is going to have something with it that's called a header
 		// we need to make sure it is the same user who has logged in who can create todos
 		bearer := c.GetHeader("Authorization")
 
 		if bearer == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
-			c.Abort() // https://pkg.go.dev/github.com/gin-gonic/gin#Context.Abort
+			abortAuth(c, authtoken.NewError(authtoken.CodeMissingToken, nil))
 			return
 		}
 
-		parts := strings.Split(bearer, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-			c.Abort()
+		scheme, tokenString, found := strings.Cut(bearer, " ")
+		if !found || scheme != "Bearer" || tokenString == "" || strings.Contains(tokenString, " ") {
+			abortAuth(c, authtoken.NewError(authtoken.CodeInvalidHeader, nil))
 			return
 		}
 
-		tokenString := parts[1]
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "empty token"})
-			c.Abort()
+		// parsing and validating a token
+		// 為什麼 JWT需要使用 Claims ?
+		// 使用者基本資訊、做權限控制與授權，因為有些api操作是基於特定權限條件滿足後才能使用，例如交易所這邊是刊登商品，有權限的人才能刊登
+		// 用 authtoken.Claims 這個 struct 接，欄位少了或型別不對都是驗證錯誤，不會在這裡 panic
+		claims, err := authtoken.Parse(tokenString, keys, opts)
+		if err != nil {
+			abortAuth(c, err)
 			return
 		}
 
-		// Parse 讓你用 MapClaims 自己取值，所以這邊不是用 ParseWithClaims，所以不需要自訂 struct 直接拿欄位
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok { //063951
-				return nil, fmt.Errorf("不支援的簽章演算法：%v", token.Method.Alg())
+		expiresAt := claims.ExpiresAt.Time
+		if err := revocations.Check(c.Request.Context(), claims.UserID, claims.ID, claims.IssuedAt.Time, expiresAt); err != nil {
+			if errors.Is(err, revocation.ErrTokenRevoked) {
+				abortAuth(c, authtoken.NewError(authtoken.CodeTokenRevoked, err))
+			} else {
+				abortAuth(c, authtoken.NewError(authtoken.CodeVerificationFailed, err))
 			}
-
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
 			return
 		}
 
-		// parsing and validating a token using the HMAC signing method
-		// 為什麼 JWT需要使用 Claims ?
-		// 使用者基本資訊、做權限控制與授權，因為有些api操作是基於特定權限條件滿足後才能使用，例如交易所這邊是刊登商品，有權限的人才能刊登
-		if claims, ok := token.Claims.(jwt.MapClaims); ok { // https://pkg.go.dev/github.com/golang-jwt/jwt/v5#section-readme
-			userID := claims["user_id"].(string)
-
-			// time.Now().Unix() => int64
-			//  claims["exp"] => float64
-			expFloat := claims["exp"].(float64)
-			exp := int64(expFloat) // Go 不會幫你自動轉型
-
-			if time.Now().Unix() > exp {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "token expired"})
-				c.Abort()
-				return
-			}
+		c.Set("user_id", claims.UserID) // 之後 handler 中可以用 c.Get("user_id")去取得
+		// 登出時撤銷的就是這個 token
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", expiresAt)
+		// RequireVerifiedEmail 看到 true 就不用查資料庫
+		c.Set("email_verified", claims.EmailVerified)
+		c.Next()
+	}
+}
 
-			c.Set("user_id", userID) // 之後 handler 中可以用 c.Get("user_id")去取得
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
-		}
+// 回應固定的訊息跟 code 並中止；原始錯誤只寫 log，伺服器自己的問題才需要看
+func abortAuth(c *gin.Context, err error) {
+	var authErr *authtoken.Error
+	if !errors.As(err, &authErr) {
+		authErr = authtoken.NewError(authtoken.CodeInvalidToken, err)
+	}
 
+	if authErr.Code == authtoken.CodeVerificationFailed {
+		log.Printf("auth: %v", authErr.Err)
 	}
+
+	c.JSON(authErr.Status(), gin.H{"error": authErr.Error(), "code": authErr.Code})
+	c.Abort() // https://pkg.go.dev/github.com/gin-gonic/gin#Context.Abort
 }
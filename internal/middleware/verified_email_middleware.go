@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"todo_api/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+RequireVerifiedEmail 擋掉還沒驗證 email 的使用者，要掛在 AuthMiddleware 後面
+
+access token 的 email_verified 是 true 就直接放行；false 的話可能是拿到 token 之後才點了驗證信，所以再查一次資料庫
+*/
+func RequireVerifiedEmail(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("email_verified") {
+			c.Next()
+			return
+		}
+
+		verifiedAt, err := repository.GetUserEmailVerifiedAt(c.Request.Context(), pool, c.GetString("user_id"))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("verified email check: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check email verification"})
+			c.Abort()
+			return
+		}
+
+		if verifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email address is not verified", "code": "email_not_verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo_api/internal/testdb"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 模擬 AuthMiddleware 放進 context 的值，後面接 RequireVerifiedEmail
+func serveVerified(pool *pgxpool.Pool, userID string, emailVerified bool) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/todos",
+		func(c *gin.Context) {
+			c.Set("user_id", userID)
+			c.Set("email_verified", emailVerified)
+			c.Next()
+		},
+		RequireVerifiedEmail(pool),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/todos", nil))
+	return w
+}
+
+// token 裡已經是 true 就不查資料庫（pool 是 nil 也不會用到）
+func TestRequireVerifiedEmailTrustsVerifiedClaim(t *testing.T) {
+	if w := serveVerified(nil, "u1", true); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestRequireVerifiedEmailBlocksUnverifiedUsers(t *testing.T) {
+	pool := testdb.New(t)
+	ctx := context.Background()
+
+	userID := testdb.CreateUser(t, pool, "unverified@example.com").ID
+
+	w := serveVerified(pool, userID, false)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["code"] != "email_not_verified" {
+		t.Fatalf("code = %q, want email_not_verified", body["code"])
+	}
+
+	// 拿到 token 之後才點驗證信：token 裡還是 false，但資料庫已經驗證過
+	if _, err := pool.Exec(ctx, `UPDATE users SET email_verified_at = NOW() WHERE id = $1`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if w := serveVerified(pool, userID, false); w.Code != http.StatusOK {
+		t.Fatalf("status after verifying = %d, want 200: %s", w.Code, w.Body)
+	}
+}
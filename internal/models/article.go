@@ -47,10 +47,14 @@ type ArticleListItem struct {
 // ArticleListResponse
 // 用途：
 // 列表頁的 response 格式，除了 items 以外，還會有分頁資訊。
+// mode = cursor 時 totalCount / totalPages 不會計算，改看 nextCursor / prevCursor
 type ArticleListResponse struct {
 	Items      []ArticleListItem `json:"items"`
+	Mode       string            `json:"mode"`
 	Page       int               `json:"page"`
 	PageSize   int               `json:"pageSize"`
 	TotalCount int               `json:"totalCount"`
 	TotalPages int               `json:"totalPages"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+	PrevCursor string            `json:"prevCursor,omitempty"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// 使用者自己的 todo 標籤，存在 tags 表（user_id 是擁有者），跟 todo 的關聯在 todo_tags
+type Label struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"` // GET /todos?labels= 用的是 slug
+	Color     string    `json:"color" db:"color"`
+	TodoCount int       `json:"todo_count" db:"-"` // 有幾個（不在垃圾桶裡的）todo 用了這個標籤
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// 附在 todo 上的標籤，只帶顯示需要的欄位
+type TodoLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Color string `json:"color"`
+}
+
+// GET /todos?labels=a,b&labels_match=any|all
+const (
+	LabelMatchAny = "any"
+	LabelMatchAll = "all"
+)
@@ -29,6 +29,19 @@ type Product struct {
 	Verified     bool      `json:"verified" db:"verified"`
 	Country      string    `json:"country" db:"country"`
 	Featured     bool      `json:"featured" db:"featured"`
+	Version      int       `json:"version" db:"version"` // 每次寫入 +1，ETag 用
 	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
 }
+
+// GET /products 有帶分頁參數時的回傳格式（沒帶分頁參數時維持舊的，直接回傳陣列）
+type ProductListResponse struct {
+	Items      []Product `json:"items"`
+	Mode       string    `json:"mode"`
+	Page       int       `json:"page"`
+	PageSize   int       `json:"pageSize"`
+	TotalCount int       `json:"totalCount"`
+	TotalPages int       `json:"totalPages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+	PrevCursor string    `json:"prevCursor,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// 資料庫裡的 refresh token，原始 token 只在發出去的當下出現一次
+type RefreshToken struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	FamilyID  string     `json:"family_id" db:"family_id"` // 同一次登入輪替出來的 token 共用
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// 登入跟換發 token 的回應
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"` // 改用 cookie 的時候不放在 body
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// GET /sync 的回應：since 之後新增 / 修改過的 todo，跟被刪除（丟進垃圾桶或永久刪除）的 id
+// 沒帶 since 就是完整同步：全部還沒刪除的 todo，Deleted 是空的
+type SyncChanges struct {
+	Todos   []*Todo         `json:"todos"`
+	Deleted []SyncTombstone `json:"deleted"`
+	Full    bool            `json:"full"`
+	Token   string          `json:"token"` // 下次同步帶回來的 since
+}
+
+type SyncTombstone struct {
+	ID        int       `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// POST /sync 每一個 mutation 的結果，同一個 client_mutation_id 重送時回傳的也是這一份
+type SyncMutationResult struct {
+	ClientMutationID string `json:"client_mutation_id"`
+	Op               string `json:"op"`
+	Status           string `json:"status"`       // applied / conflict / rejected
+	ID               int    `json:"id,omitempty"` // 作用的 todo，create 時是新的 id
+	// applied：寫入之後的 todo（delete 沒有）；conflict：伺服器上比較新、留下來的版本
+	Todo     *Todo  `json:"todo,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Replayed bool   `json:"replayed,omitempty"` // 之前已經處理過，這次沒有再執行
+}
+
+const (
+	SyncStatusApplied  = "applied"
+	SyncStatusConflict = "conflict" // 伺服器上的 updated_at 比較新，last-writer-wins 保留伺服器的版本
+	SyncStatusRejected = "rejected" // 驗證失敗、todo 不存在等，不會再成功，client 應該丟掉這個 mutation
+)
+
+type SyncResponse struct {
+	Applied   int                  `json:"applied"`
+	Conflicts int                  `json:"conflicts"`
+	Rejected  int                  `json:"rejected"`
+	Results   []SyncMutationResult `json:"results"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// todo 的一筆計時紀錄；EndedAt 是 nil 代表計時器還在跑
+type TimeEntry struct {
+	ID          int64      `json:"id" db:"id"`
+	TodoID      int        `json:"todo_id" db:"todo_id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	StartedAt   time.Time  `json:"started_at" db:"started_at"`
+	EndedAt     *time.Time `json:"ended_at" db:"ended_at"`
+	Note        string     `json:"note" db:"note"`
+	AutoStopped bool       `json:"auto_stopped" db:"auto_stopped"` // 跑太久被背景工作自動停掉
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+
+	// 不是資料庫欄位：計時器還在跑的話算到現在為止
+	DurationSeconds int64 `json:"duration_seconds" db:"-"`
+}
+
+// GET /reports/time 的分組方式
+const (
+	TimeReportGroupByDay  = "day"
+	TimeReportGroupByTodo = "todo"
+)
+
+// 時間報表的一組：依天分組時有 Date，依 todo 分組時有 TodoID / Title
+type TimeReportGroup struct {
+	Date    string `json:"date,omitempty"` // 2006-01-02，依 TimeZone 切天
+	TodoID  int    `json:"todo_id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Seconds int64  `json:"seconds"`
+}
+
+type TimeReport struct {
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	GroupBy      string            `json:"group_by"`
+	TimeZone     string            `json:"tz"`
+	TotalSeconds int64             `json:"total_seconds"`
+	Groups       []TimeReportGroup `json:"groups"`
+}
@@ -18,18 +18,68 @@ title = "代辦1"    ← DB 看到的欄位名
 // json 對應前端 API
 // db 對應資料庫表格中的
 type Todo struct {
-	ID        int       `json:"id" db:"id"`
-	Title     string    `json:"title"  db:"title"`
-	Completed bool      `json:"completed" db:"completed"`
+	ID        int        `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"` // 擁有者（建立者），對應 users.id
+	ListID    *string    `json:"list_id" db:"list_id"` // 所屬清單，nil 代表個人 todo
+	Title     string     `json:"title"  db:"title"`
+	Completed bool       `json:"completed" db:"completed"`
+	DueAt     *time.Time `json:"due_at" db:"due_at"`     // 截止時間，nil 代表沒有期限
+	Priority  string     `json:"priority" db:"priority"` // low / normal / high / urgent
+	Notes     string     `json:"notes" db:"notes"`
+	RemindAt  *time.Time `json:"remind_at" db:"remind_at"` // 什麼時候要提醒
+	// 提醒實際送出的時間，排程靠它避免重複提醒
+	RemindedAt *time.Time `json:"reminded_at,omitempty" db:"reminded_at"`
+	// 週期性 todo：RRULE 子集（例如 FREQ=WEEKLY;BYDAY=MO），空字串代表不重複
+	RecurrenceRule  string     `json:"recurrence_rule,omitempty" db:"recurrence_rule"`
+	RecurrenceTZ    string     `json:"recurrence_tz,omitempty" db:"recurrence_tz"`       // 用哪個時區的牆上時間展開
+	RecurrenceStart *time.Time `json:"recurrence_start,omitempty" db:"recurrence_start"` // DTSTART
+	// 完成之後自動產生的下一次
+	RecurrenceNextID *int `json:"recurrence_next_id,omitempty" db:"recurrence_next_id"`
+	// 手動排序的位置（internal/rank 的排序鍵），GET /todos?sort=position 用
+	Position  string    `json:"position" db:"position"`
+	Version   int       `json:"version" db:"version"` // 每次寫入 +1，ETag / If-Match 用
 	CreatedAt time.Time `json:"created_at" db:"created_at" `
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// 軟刪除時間，NULL 代表還沒被丟進垃圾桶
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// 標籤不是 todos 的欄位，列表跟單筆查詢才會另外帶出來
+	Labels []TodoLabel `json:"labels,omitempty" db:"-"`
+}
+
+// 優先順序，跟 migrations 裡的 chk_todos_priority 一致
+const (
+	TodoPriorityLow    = "low"
+	TodoPriorityNormal = "normal"
+	TodoPriorityHigh   = "high"
+	TodoPriorityUrgent = "urgent"
+)
+
+// GET /todos 的篩選條件，零值代表不篩選
+// 同一個 struct 也會放在 TodoListResponse.Filters 回傳，讓前端知道實際套用了哪些條件
+type TodoFilter struct {
+	Completed     *bool      `json:"completed,omitempty"`      // 只看已完成 / 未完成
+	Query         string     `json:"q,omitempty"`              // 標題全文搜尋
+	Overdue       bool       `json:"overdue,omitempty"`        // 只看逾期且未完成
+	DueBefore     *time.Time `json:"due_before,omitempty"`     // 截止時間早於
+	DueAfter      *time.Time `json:"due_after,omitempty"`      // 截止時間晚於
+	CreatedBefore *time.Time `json:"created_before,omitempty"` // 建立時間早於
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`  // 建立時間晚於
+	Labels        []string   `json:"labels,omitempty"`         // 標籤的 slug
+	LabelsMatch   string     `json:"labels_match,omitempty"`   // any：有其中一個就算；all：全部都要有
+	SortField     string     `json:"sort"`                     // 排序欄位，只接受 repository 白名單裡的欄位
+	SortDesc      bool       `json:"desc"`                     // true => DESC
 }
 
 // 你開始讓後端有能力回傳 資料本身、分頁 metadata
+// mode = offset 時看 page / totalCount / totalPages；mode = cursor 時看 nextCursor / prevCursor
 type TodoListResponse struct {
-	Items      []Todo `json:"items"`
-	Page       int    `json:"page"`
-	PageSize   int    `json:"pageSize"`
-	TotalCount int    `json:"totalCount"`
-	TotalPages int    `json:"totalPages"`
+	Items      []Todo     `json:"items"`
+	Mode       string     `json:"mode"`
+	Page       int        `json:"page"`
+	PageSize   int        `json:"pageSize"`
+	TotalCount int        `json:"totalCount"`
+	TotalPages int        `json:"totalPages"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	PrevCursor string     `json:"prevCursor,omitempty"`
+	Filters    TodoFilter `json:"filters"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// todo 的附件（中繼資料），檔案內容用 GET /todos/:id/attachments/:attachmentId 下載
+type TodoAttachment struct {
+	ID          string    `json:"id" db:"id"`
+	TodoID      int       `json:"todo_id" db:"todo_id"`
+	UserID      string    `json:"user_id" db:"user_id"` // 上傳的人
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size_bytes"`
+	StorageKey  string    `json:"-" db:"storage_key"` // BlobStorage 的 key，不給前端看
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// POST /todos/bulk 每一個操作的結果，Index 對應 request 裡 operations 的位置
+type BulkTodoResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"` // 新增成功時是新的 id
+	Status string `json:"status"`       // ok / failed / rolled_back / skipped
+	Error  string `json:"error,omitempty"`
+	Todo   *Todo  `json:"todo,omitempty"` // delete 沒有
+}
+
+// 批次操作的結果狀態
+const (
+	BulkStatusOK         = "ok"
+	BulkStatusFailed     = "failed"
+	BulkStatusRolledBack = "rolled_back" // atomic 模式下，本來成功、因為別筆失敗而一起撤銷
+	BulkStatusSkipped    = "skipped"     // atomic 模式下，前面已經失敗，沒有執行
+)
+
+type BulkTodoResponse struct {
+	Atomic    bool             `json:"atomic"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkTodoResult `json:"results"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// todo 的一筆變更紀錄，GET /todos/:id/history 回傳的就是這個（新的在前）
+type TodoEvent struct {
+	ID      int64   `json:"id" db:"id"`
+	TodoID  int     `json:"todo_id" db:"todo_id"`
+	ActorID *string `json:"actor_id" db:"actor_id"` // 誰做的，帳號刪除後是 null
+	Action  string  `json:"action" db:"action"`
+	// key 是欄位名稱，只會有真的變動的欄位
+	Changes map[string]TodoFieldChange `json:"changes" db:"changes"`
+	// 這次變更之後 todo 的 version
+	Version   int       `json:"version" db:"todo_version"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type TodoFieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// 跟 migrations 裡的 chk_todo_events_action 一致
+const (
+	TodoActionCreate  = "create"
+	TodoActionUpdate  = "update"
+	TodoActionDelete  = "delete"
+	TodoActionRestore = "restore"
+	TodoActionMove    = "move"
+	TodoActionUndo    = "undo"
+)
+
+// GET /todos/stream 推送的變更通知：只說哪個 todo 做了什麼，完整內容再用 GET /todos/:id 或 history 拿
+// （pg_notify 的 payload 有 8000 bytes 的上限，放不下整個 todo）
+type TodoStreamEvent struct {
+	ID        int64     `json:"id"`                // todo_events.id，也是 SSE 的 id，重連時的 Last-Event-ID
+	UserID    string    `json:"user_id"`           // todo 的建立者
+	ListID    *string   `json:"list_id,omitempty"` // 清單裡的 todo 推給清單目前所有的成員，用 GET /lists/:id/todos/:todoId 拿
+	TodoID    int       `json:"todo_id"`
+	Action    string    `json:"action"`
+	Version   int       `json:"version"` // 變更之後 todo 的 version，前端可以拿來跟手上的 ETag 比
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// 匯入時被拒絕的一筆，Row 是檔案裡的位置（CSV 行號、JSON 陣列第幾個、ics 的 BEGIN:VTODO 行號）
+type TodoImportRejection struct {
+	Row   int    `json:"row"`
+	Title string `json:"title,omitempty"`
+	Error string `json:"error"`
+}
+
+// POST /todos/import 的結果；dry_run 時 imported 一定是 0，accepted 是「會匯入」的筆數
+type TodoImportResult struct {
+	Format   string                `json:"format"`
+	DryRun   bool                  `json:"dry_run"`
+	Total    int                   `json:"total"`
+	Accepted int                   `json:"accepted"`
+	Imported int64                 `json:"imported"`
+	Rejected []TodoImportRejection `json:"rejected"`
+}
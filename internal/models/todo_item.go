@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// 子任務（檢查清單的一個步驟）
+type TodoItem struct {
+	ID          int        `json:"id" db:"id"`
+	TodoID      int        `json:"todo_id" db:"todo_id"`
+	Title       string     `json:"title" db:"title"`
+	Completed   bool       `json:"completed" db:"completed"`
+	Position    int        `json:"position" db:"position"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// GET /todos/:id 的回傳：todo 本身的欄位攤平，再加上子任務跟完成進度
+type TodoDetail struct {
+	Todo
+	Items    []TodoItem `json:"items"`
+	Progress int        `json:"progress"` // 0 ~ 100
+}
+
+// 完成進度（百分比，無條件捨去）
+// 沒有子任務時看 todo 本身有沒有完成
+func NewTodoDetail(todo Todo, items []TodoItem) TodoDetail {
+	detail := TodoDetail{Todo: todo, Items: items}
+	if detail.Items == nil {
+		detail.Items = []TodoItem{}
+	}
+
+	if len(items) == 0 {
+		if todo.Completed {
+			detail.Progress = 100
+		}
+		return detail
+	}
+
+	done := 0
+	for _, item := range items {
+		if item.Completed {
+			done++
+		}
+	}
+	detail.Progress = done * 100 / len(items)
+	return detail
+}
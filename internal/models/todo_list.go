@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// 清單成員的角色，跟 migrations 裡的 chk_todo_list_members_role 一致
+const (
+	ListRoleOwner  = "owner"
+	ListRoleEditor = "editor"
+	ListRoleViewer = "viewer"
+)
+
+// 角色的權限高低：owner > editor > viewer，權限檢查只要比數字
+var listRoleRank = map[string]int{
+	ListRoleViewer: 1,
+	ListRoleEditor: 2,
+	ListRoleOwner:  3,
+}
+
+// role 是否至少有 required 的權限，例如 editor 可以做 viewer 能做的事
+func ListRoleAtLeast(role string, required string) bool {
+	return listRoleRank[role] >= listRoleRank[required] && listRoleRank[role] > 0
+}
+
+type TodoList struct {
+	ID          string    `json:"id" db:"id"`
+	OwnerID     string    `json:"owner_id" db:"owner_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Role        string    `json:"role,omitempty"` // 目前登入使用者在這個清單的角色（從 todo_list_members 查出來）
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type TodoListMember struct {
+	ListID    string    `json:"list_id" db:"list_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Email     string    `json:"email"` // 從 users JOIN 出來，方便前端顯示
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type TodoListInvite struct {
+	ID         string     `json:"id" db:"id"`
+	ListID     string     `json:"list_id" db:"list_id"`
+	Email      string     `json:"email" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	InvitedBy  string     `json:"invited_by" db:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
@@ -9,4 +9,7 @@ type User struct {
 	ImageURL  string    `json:"image_url"` // GCS 流程會用到
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// nil 代表還沒點驗證信的連結，RequireVerifiedEmail 的路由不能用
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
 }
@@ -0,0 +1,67 @@
+/*
+提醒要怎麼送出去（email、推播、Slack...）都藏在 Notifier 介面後面
+排程只管「哪些 todo 該提醒了」，送出的方式之後要換只要換實作
+*/
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"todo_api/internal/models"
+)
+
+type Notifier interface {
+	NotifyReminder(ctx context.Context, todo models.Todo) error
+}
+
+// 開發用：直接把提醒印到 stdout（或任何 io.Writer）
+type LogNotifier struct {
+	Writer io.Writer
+}
+
+func NewLogNotifier(writer io.Writer) *LogNotifier {
+	return &LogNotifier{Writer: writer}
+}
+
+func (n *LogNotifier) NotifyReminder(ctx context.Context, todo models.Todo) error {
+	due := "no due date"
+	if todo.DueAt != nil {
+		due = "due " + todo.DueAt.Format(time.RFC3339)
+	}
+
+	_, err := fmt.Fprintf(n.Writer, "[reminder] user=%s todo=%d %q (%s, priority=%s)\n",
+		todo.UserID, todo.ID, todo.Title, due, todo.Priority)
+	return err
+}
+
+// 測試用：把送出的提醒存在記憶體，之後可以用 Sent() 檢查
+type MemoryNotifier struct {
+	mu   sync.Mutex
+	sent []models.Todo
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) NotifyReminder(ctx context.Context, todo models.Todo) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.sent = append(n.sent, todo)
+	return nil
+}
+
+// 回傳一份複本，避免呼叫端拿到 slice 後跟排程同時讀寫
+func (n *MemoryNotifier) Sent() []models.Todo {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	sent := make([]models.Todo, len(n.sent))
+	copy(sent, n.sent)
+	return sent
+}
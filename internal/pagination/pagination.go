@@ -0,0 +1,179 @@
+/*
+todos、articles、products 共用的分頁工具
+
+兩種模式：
+ 1. offset（舊的 page / pageSize，保留給既有前端）
+ 2. cursor（keyset）：用最後一筆的 (排序時間, id) 當下一頁的起點，WHERE (created_at, id) < ($1, $2)
+    不用 OFFSET 跳過前面的資料，也不會因為中間有人新增資料而出現重複 / 漏掉
+
+cursor 對前端來說是不透明字串：base64(JSON) + "." + HMAC 簽章，前端只能原封不動帶回來，改過就驗不過
+*/
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ModeOffset = "offset"
+	ModeCursor = "cursor"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor 裡實際存的內容
+type Cursor struct {
+	Sort     string    `json:"s"`           // 產生這個 cursor 時的排序（例如 "created_at:desc"），換了排序舊 cursor 就不能用
+	Rank     int       `json:"r,omitempty"` // 排在時間前面的排序鍵，例如 products 的 featured（1 / 0）
+	Time     time.Time `json:"t"`
+	ID       string    `json:"id"`
+	Backward bool      `json:"b,omitempty"` // true => 這是 prevCursor，往前一頁
+}
+
+// todos、products 的 id 是 SERIAL，cursor 裡存成字串，用的時候再轉回 int
+func (c Cursor) IntID() (int, error) {
+	id, err := strconv.Atoi(c.ID)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// 負責簽章 / 驗證 cursor，secret 由 config 決定
+type Codec struct {
+	secret []byte
+}
+
+func NewCodec(secret string) *Codec {
+	return &Codec{secret: []byte(secret)}
+}
+
+func (c *Codec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Codec) Encode(cursor Cursor) string {
+	raw, _ := json.Marshal(cursor) // Cursor 只有基本型別，不會失敗
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + c.sign(payload)
+}
+
+func (c *Codec) Decode(token string) (*Cursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	// hmac.Equal 是固定時間比較，避免被用時間差猜出簽章
+	if !hmac.Equal([]byte(signature), []byte(c.sign(payload))) {
+		return nil, ErrInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// handler 解析完 query string 之後交給 repository 的分頁參數
+type Params struct {
+	Mode     string
+	Page     int     // offset 模式才有意義
+	PageSize int     // 兩種模式都用
+	Cursor   *Cursor // cursor 模式的起點，nil 代表第一頁
+	Codec    *Codec  // repository 用來產生 nextCursor / prevCursor
+}
+
+func (p Params) IsCursor() bool {
+	return p.Mode == ModeCursor
+}
+
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+func (p Params) Backward() bool {
+	return p.Cursor != nil && p.Cursor.Backward
+}
+
+// 檢查帶進來的 cursor 是不是同一種排序產生的
+func (p Params) CheckSort(sort string) error {
+	if p.Cursor != nil && p.Cursor.Sort != sort {
+		return fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, p.Cursor.Sort)
+	}
+	return nil
+}
+
+// 一組 keyset 排序欄位，全部同一個方向，例如 {"created_at", "id"} DESC
+type Keyset struct {
+	Columns []string
+	Desc    bool
+}
+
+// 實際查詢的方向：往前一頁時要反過來查，查完再把結果反轉
+func (k Keyset) descending(backward bool) bool {
+	return k.Desc != backward
+}
+
+// 產生 (created_at, id) < ($3, $4) 這種 row value 比較，argIndex 是第一個參數的編號
+func (k Keyset) Where(backward bool, argIndex int) string {
+	placeholders := make([]string, 0, len(k.Columns))
+	for i := range k.Columns {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", argIndex+i))
+	}
+
+	op := ">"
+	if k.descending(backward) {
+		op = "<"
+	}
+
+	return fmt.Sprintf("(%s) %s (%s)", strings.Join(k.Columns, ", "), op, strings.Join(placeholders, ", "))
+}
+
+func (k Keyset) OrderBy(backward bool) string {
+	direction := "ASC"
+	if k.descending(backward) {
+		direction = "DESC"
+	}
+
+	parts := make([]string, 0, len(k.Columns))
+	for _, column := range k.Columns {
+		parts = append(parts, column+" "+direction)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// repository 會多查一筆（PageSize+1）來判斷後面還有沒有資料
+// fetched 是實際查到的筆數，回傳下一頁 / 上一頁是否存在
+func (p Params) Bounds(fetched int) (hasNext bool, hasPrev bool) {
+	more := fetched > p.PageSize
+	if p.Backward() {
+		// 從後面的頁往回翻，後面一定還有資料
+		return true, more
+	}
+	return more, p.Cursor != nil
+}
+
+// 往前一頁時查詢方向是反的，查完要反轉回正常順序
+func Reverse[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
@@ -0,0 +1,110 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	codec := NewCodec("test-secret")
+
+	for _, cursor := range []Cursor{
+		{Sort: "created_at:desc", Time: time.Date(2025, 1, 31, 9, 0, 0, 123456789, time.UTC), ID: "42"},
+		{Sort: "featured:desc", Rank: 1, Time: time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC), ID: "7", Backward: true},
+		{Sort: "published_at:desc", ID: "3f0c9a4e-6c8b-4f0e-9a51-2d7a3b1c0e11"},
+	} {
+		token := codec.Encode(cursor)
+		got, err := codec.Decode(token)
+		if err != nil {
+			t.Fatalf("Decode(%q) error = %v", token, err)
+		}
+		if got.Sort != cursor.Sort || got.Rank != cursor.Rank || !got.Time.Equal(cursor.Time) ||
+			got.ID != cursor.ID || got.Backward != cursor.Backward {
+			t.Fatalf("Decode() = %+v, want %+v", got, cursor)
+		}
+	}
+}
+
+func TestCodecRejectsTamperedCursors(t *testing.T) {
+	codec := NewCodec("test-secret")
+	token := codec.Encode(Cursor{Sort: "created_at:desc", Time: time.Now(), ID: "42"})
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// 把 id 改掉再重新 base64，簽章沒跟著換
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), `"42"`, `"1"`, 1)))
+
+	// 簽章對、內容不是 JSON
+	notJSON := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"tampered payload", forgedPayload + "." + signature},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(signature))},
+		{"truncated signature", payload + "." + signature[:len(signature)-1]},
+		{"signed with another secret", NewCodec("other-secret").Encode(Cursor{Sort: "created_at:desc", ID: "42"})},
+		{"valid signature over invalid base64", "!!!." + codec.sign("!!!")},
+		{"valid signature over invalid JSON", notJSON + "." + codec.sign(notJSON)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := codec.Decode(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("Decode() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCursorIntID(t *testing.T) {
+	if id, err := (Cursor{ID: "42"}).IntID(); err != nil || id != 42 {
+		t.Fatalf("IntID() = %d, %v, want 42", id, err)
+	}
+
+	for _, raw := range []string{"", "abc", "4.2", "3f0c9a4e-6c8b-4f0e-9a51-2d7a3b1c0e11", "99999999999999999999"} {
+		if _, err := (Cursor{ID: raw}).IntID(); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("IntID(%q) error = %v, want ErrInvalidCursor", raw, err)
+		}
+	}
+}
+
+func TestCheckSort(t *testing.T) {
+	params := Params{Mode: ModeCursor, Cursor: &Cursor{Sort: "created_at:desc"}}
+
+	if err := params.CheckSort("created_at:desc"); err != nil {
+		t.Fatalf("CheckSort() same sort error = %v", err)
+	}
+	if err := params.CheckSort("due_at:asc"); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("CheckSort() other sort error = %v, want ErrInvalidCursor", err)
+	}
+	if err := (Params{Mode: ModeCursor}).CheckSort("due_at:asc"); err != nil {
+		t.Fatalf("CheckSort() first page error = %v", err)
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	keyset := Keyset{Columns: []string{"created_at", "id"}, Desc: true}
+
+	if got, want := keyset.Where(false, 3), "(created_at, id) < ($3, $4)"; got != want {
+		t.Errorf("Where(forward) = %q, want %q", got, want)
+	}
+	if got, want := keyset.Where(true, 3), "(created_at, id) > ($3, $4)"; got != want {
+		t.Errorf("Where(backward) = %q, want %q", got, want)
+	}
+	if got, want := keyset.OrderBy(false), "created_at DESC, id DESC"; got != want {
+		t.Errorf("OrderBy(forward) = %q, want %q", got, want)
+	}
+	if got, want := keyset.OrderBy(true), "created_at ASC, id ASC"; got != want {
+		t.Errorf("OrderBy(backward) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package patch
+
+import (
+	"reflect"
+	"time"
+)
+
+/*
+Changes 比對同一個 struct 型別的 before / after，回傳有變動的欄位：key 是欄位的 tag（例如 db），value 是 after 的值。
+沒有 tag 或 tag 是 "-" 的欄位不比對；時間比的是時間點（同一刻不同時區算沒變）。
+*/
+func Changes(before, after any, tag string) map[string]any {
+	bv := reflect.Indirect(reflect.ValueOf(before))
+	av := reflect.Indirect(reflect.ValueOf(after))
+	if bv.Type() != av.Type() || bv.Kind() != reflect.Struct {
+		panic("patch.Changes: before and after must be the same struct type")
+	}
+
+	changes := map[string]any{}
+	t := bv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get(tag)
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		b, a := bv.Field(i).Interface(), av.Field(i).Interface()
+		if !sameValue(b, a) {
+			changes[name] = a
+		}
+	}
+	return changes
+}
+
+func sameValue(a, b any) bool {
+	switch at := a.(type) {
+	case time.Time:
+		return at.Equal(b.(time.Time))
+	case *time.Time:
+		bt := b.(*time.Time)
+		if at == nil || bt == nil {
+			return at == bt
+		}
+		return at.Equal(*bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
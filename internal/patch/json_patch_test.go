@@ -0,0 +1,117 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+// 兩份 JSON 語意上相同（物件不管 key 順序，數字比數值）
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	g, err := decode(got)
+	if err != nil {
+		t.Fatalf("result is not JSON: %v\n%s", err, got)
+	}
+	w, err := decode([]byte(want))
+	if err != nil {
+		t.Fatalf("want is not JSON: %v\n%s", err, want)
+	}
+	if !jsonEqual(g, w) {
+		t.Fatalf("result = %s, want %s", got, want)
+	}
+}
+
+// RFC 6902 Appendix A 的範例，再加上幾個邊界情況；want 是 "" 代表要失敗
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		// A.1 - A.8
+		{"A.1 add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"A.2 add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"A.3 remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"A.4 remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"A.5 replace value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{
+			"A.6 move value",
+			`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil,
+		},
+		{"A.7 move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{
+			"A.8 test success", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`, nil,
+		},
+
+		// A.9 - A.16
+		{"A.9 test failure", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", ErrTestFailed},
+		{"A.10 add nested member object", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{"A.11 ignore unrecognized elements", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`, nil},
+		{"A.12 add to nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", ErrInvalidPatch},
+		{"A.14 ~ escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, nil},
+		{"A.15 string is not a number", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, "", ErrTestFailed},
+		{"A.16 add array value", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+
+		// pointer
+		{"escaped slash", `{"a/b":1}`, `[{"op":"replace","path":"/a~1b","value":2}]`, `{"a/b":2}`, nil},
+		{"escaped tilde", `{"m~n":1}`, `[{"op":"remove","path":"/m~0n"}]`, `{}`, nil},
+		{"path without leading slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`, "", ErrInvalidPatch},
+		{"empty path replaces document", `{"a":1}`, `[{"op":"replace","path":"","value":[1,2]}]`, `[1,2]`, nil},
+		{"cannot remove document", `{"a":1}`, `[{"op":"remove","path":""}]`, "", ErrInvalidPatch},
+
+		// 陣列索引
+		{"dash appends", `{"a":[1]}`, `[{"op":"add","path":"/a/-","value":2}]`, `{"a":[1,2]}`, nil},
+		{"add at length", `{"a":[1]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2]}`, nil},
+		{"add past length", `{"a":[1]}`, `[{"op":"add","path":"/a/2","value":2}]`, "", ErrInvalidPatch},
+		{"dash is not readable", `{"a":[1]}`, `[{"op":"remove","path":"/a/-"}]`, "", ErrInvalidPatch},
+		{"leading zero", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, "", ErrInvalidPatch},
+		{"negative index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/-1"}]`, "", ErrInvalidPatch},
+		{"nested array", `{"a":[[1],[2]]}`, `[{"op":"add","path":"/a/1/0","value":0}]`, `{"a":[[1],[0,2]]}`, nil},
+
+		// move / copy
+		{"move into own child", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, "", ErrInvalidPatch},
+		{"move to same path", `{"a":1}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":1}`, nil},
+		{"move from missing", `{"a":1}`, `[{"op":"move","from":"/b","path":"/c"}]`, "", ErrInvalidPatch},
+		{
+			"copy is deep", `{"a":{"x":1}}`,
+			`[{"op":"copy","from":"/a","path":"/b"},{"op":"add","path":"/b/y","value":2}]`,
+			`{"a":{"x":1},"b":{"x":1,"y":2}}`, nil,
+		},
+
+		// test
+		{"test number value", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1}`, nil},
+		{"test object ignores key order", `{"a":{"x":1,"y":2}}`, `[{"op":"test","path":"/a","value":{"y":2,"x":1}}]`, `{"a":{"x":1,"y":2}}`, nil},
+		{"test null", `{"a":null}`, `[{"op":"test","path":"/a","value":null}]`, `{"a":null}`, nil},
+
+		// 格式
+		{"replace missing member", `{"a":1}`, `[{"op":"replace","path":"/b","value":2}]`, "", ErrInvalidPatch},
+		{"missing value", `{"a":1}`, `[{"op":"add","path":"/b"}]`, "", ErrInvalidPatch},
+		{"unknown op", `{"a":1}`, `[{"op":"merge","path":"/a","value":1}]`, "", ErrInvalidPatch},
+		{"not an array", `{"a":1}`, `{"op":"remove","path":"/a"}`, "", ErrInvalidPatch},
+		// 前面成功、後面失敗：整份都不生效（回傳錯誤，沒有部分結果）
+		{"atomic", `{"a":1}`, `[{"op":"remove","path":"/a"},{"op":"test","path":"/a","value":1}]`, "", ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("JSONPatch() = %s, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JSONPatch() error = %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Package patch 實作 PATCH 用的兩種格式：
+//   - application/merge-patch+json（RFC 7396）：送一個 JSON 物件，null 代表刪除
+//   - application/json-patch+json（RFC 6902）：送一串 add / remove / replace / move / copy / test 操作
+//
+// 兩種都是對「目前資料的 JSON」套用修改，產生新的 JSON，由呼叫端解回 struct 再驗證。
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	// Content-Type 不是上面兩種（handler 回 415）
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+	// patch 本身格式錯誤，或是路徑不存在（handler 回 400）
+	ErrInvalidPatch = errors.New("invalid patch document")
+	// JSON Patch 的 test 操作沒通過（handler 回 409）
+	ErrTestFailed = errors.New("json patch test operation failed")
+)
+
+// 依 Content-Type 選擇格式，把 patch 套用到 doc 上
+func Apply(contentType string, doc []byte, body []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, contentType)
+	}
+
+	switch mediaType {
+	case MergePatchContentType:
+		return MergePatch(doc, body)
+	case JSONPatchContentType:
+		return JSONPatch(doc, body)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mediaType)
+	}
+}
+
+// 數字一律用 json.Number 解，大整數才不會被轉成 float64 失去精度
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// RFC 7396：patch 是物件就逐一合併（null 代表刪除），不是物件就整個取代
+func MergePatch(doc []byte, mergePatch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: document: %v", ErrInvalidPatch, err)
+	}
+	p, err := decode(mergePatch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target any, p any) any {
+	patchObj, ok := p.(map[string]any)
+	if !ok {
+		return p
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergeValue(targetObj[key], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,106 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// RFC 7396 Appendix A 的範例
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.doc+" + "+tt.patch, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch() error = %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatchRejectsInvalidJSON(t *testing.T) {
+	for _, tt := range []struct{ doc, patch string }{
+		{`{"a":1}`, `{"a":`},
+		{`{"a":1}`, `{"a":1} {"b":2}`},
+		{`{"a":`, `{"a":1}`},
+	} {
+		if _, err := MergePatch([]byte(tt.doc), []byte(tt.patch)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("MergePatch(%s, %s) error = %v, want ErrInvalidPatch", tt.doc, tt.patch, err)
+		}
+	}
+}
+
+// 大整數用 json.Number 解，不會變成 float64 失去精度
+func TestPatchKeepsLargeIntegers(t *testing.T) {
+	doc := []byte(`{"id":9007199254740993,"title":"a"}`)
+
+	merged, err := MergePatch(doc, []byte(`{"title":"b"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := JSONPatch(doc, []byte(`[{"op":"replace","path":"/title","value":"b"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, got := range [][]byte{merged, patched} {
+		if !strings.Contains(string(got), "9007199254740993") {
+			t.Errorf("result = %s, lost integer precision", got)
+		}
+	}
+}
+
+func TestApplyContentType(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+		wantErr     error
+	}{
+		{MergePatchContentType, `{"a":2}`, `{"a":2}`, nil},
+		{MergePatchContentType + "; charset=utf-8", `{"a":null}`, `{}`, nil},
+		{JSONPatchContentType, `[{"op":"replace","path":"/a","value":3}]`, `{"a":3}`, nil},
+		{"application/json", `{"a":2}`, "", ErrUnsupportedMediaType},
+		{"", `{"a":2}`, "", ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		got, err := Apply(tt.contentType, doc, []byte(tt.body))
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Apply(%q) error = %v, want %v", tt.contentType, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Apply(%q) error = %v", tt.contentType, err)
+			continue
+		}
+		assertJSON(t, got, tt.want)
+	}
+}
@@ -0,0 +1,184 @@
+// Package quickadd 把一行自然語言（例如「Pay rent every month on the 1st #home !high」、「明天下午5點交報告」）
+// 解析成 todo 的欄位：截止時間、優先順序、標籤、週期規則，認得的片語拿掉之後剩下的就是標題。
+//
+// 「現在」由建立 Parser 時給的 Clock 決定，同一個時間、同一個時區解析同一句話一定得到同樣的結果。
+package quickadd
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"todo_api/internal/recurrence"
+)
+
+// 回傳現在時間，正式環境用 time.Now，測試可以固定在某個時間點
+type Clock func() time.Time
+
+// 只有日期沒有時間的截止時間，算到當天 23:59
+const (
+	allDayHour   = 23
+	allDayMinute = 59
+)
+
+// Token.Kind
+const (
+	KindLabel      = "label"
+	KindPriority   = "priority"
+	KindRecurrence = "recurrence"
+	KindDue        = "due"
+	KindTime       = "time"
+)
+
+var ErrEmptyTitle = errors.New("title is empty after removing dates, priority, labels and recurrence")
+
+// 認得的片語，依解析的順序；前端可以拿來標示哪些字被當成日期、標籤
+type Token struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+type Result struct {
+	Title          string     `json:"title"`
+	DueAt          *time.Time `json:"due_at"`
+	AllDay         bool       `json:"all_day"` // 只有日期，DueAt 是當天 23:59
+	Priority       string     `json:"priority,omitempty"`
+	Labels         []string   `json:"labels"`
+	RecurrenceRule string     `json:"recurrence_rule,omitempty"`
+	TimeZone       string     `json:"tz"`
+	Tokens         []Token    `json:"tokens"`
+}
+
+type Parser struct {
+	now Clock
+}
+
+// clock 是 nil 就用 time.Now
+func NewParser(clock Clock) *Parser {
+	if clock == nil {
+		clock = time.Now
+	}
+	return &Parser{now: clock}
+}
+
+// 解析過程中還沒被認走的文字，認走的片語換成一個空白
+type state struct {
+	text   string
+	tokens []Token
+}
+
+// 找 re 第一個 match，accept 回傳 true 才把這段文字拿掉；回傳的 groups 跟 FindStringSubmatch 一樣
+func (s *state) take(re *regexp.Regexp, kind string, accept func(groups []string) bool) bool {
+	loc := re.FindStringSubmatchIndex(s.text)
+	if loc == nil {
+		return false
+	}
+
+	groups := make([]string, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = s.text[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	if !accept(groups) {
+		return false
+	}
+
+	s.tokens = append(s.tokens, Token{Kind: kind, Text: strings.TrimSpace(groups[0])})
+	s.text = s.text[:loc[0]] + " " + s.text[loc[1]:]
+	return true
+}
+
+/*
+Parse 依序找出標籤（#home）、優先順序（!high、!!!）、週期（every month on the 1st、每週一三五）、
+日期（tomorrow、next Friday、明天、1月31日）、時間（5pm、17:30、下午5點半），每一類只取第一個
+
+截止時間的規則：
+  - 只有日期：當天 23:59（AllDay）
+  - 只有時間：今天那個時間，已經過了就是明天
+  - 有週期沒有日期：從今天起第一個符合規則的日子
+*/
+func (p *Parser) Parse(input string, loc *time.Location) (*Result, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := p.now().In(loc)
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	s := &state{text: input}
+	result := &Result{Labels: []string{}, TimeZone: loc.String()}
+
+	result.Labels = parseLabels(s)
+	result.Priority = parsePriority(s)
+	result.RecurrenceRule = parseRecurrence(s)
+
+	date, hasDate := parseDate(s, now, today)
+	var clock timeOfDay
+	hasTime := false
+	if date.at == nil {
+		clock, hasTime = parseTime(s)
+	}
+
+	result.Tokens = s.tokens
+	if result.Tokens == nil {
+		result.Tokens = []Token{}
+	}
+	result.Title = strings.Join(strings.Fields(s.text), " ")
+	if result.Title == "" {
+		return nil, ErrEmptyTitle
+	}
+
+	if date.at != nil {
+		result.DueAt = date.at
+		return result, nil
+	}
+	if !hasDate && !hasTime && result.RecurrenceRule == "" {
+		return result, nil
+	}
+
+	day := today
+	if hasDate {
+		day = date.day
+	}
+	switch {
+	case hasTime:
+	case date.defaultTime != nil:
+		clock = *date.defaultTime
+	default:
+		clock = timeOfDay{allDayHour, allDayMinute}
+		result.AllDay = true
+	}
+	due := time.Date(day.Year(), day.Month(), day.Day(), clock.hour, clock.minute, 0, 0, loc)
+
+	switch {
+	case result.RecurrenceRule != "" && !hasDate:
+		due = firstOccurrence(result.RecurrenceRule, due, now)
+	case !hasDate && !due.After(now):
+		due = due.AddDate(0, 0, 1)
+	}
+	result.DueAt = &due
+
+	return result, nil
+}
+
+// 週期沒有指定日期時，截止時間是從 start（今天的那個時間）起第一個符合規則、而且還沒過的日子
+// 這一天會變成週期的起點，所以不管 INTERVAL：every 2 weeks on monday 就是下一個星期一，之後每兩週一次
+func firstOccurrence(rrule string, start time.Time, now time.Time) time.Time {
+	rule, err := recurrence.Parse(rrule)
+	if err != nil {
+		return start
+	}
+	rule.Interval = 1
+
+	after := start.Add(-time.Nanosecond)
+	if now.After(after) {
+		after = now
+	}
+	next, ok := rule.Next(start, after)
+	if !ok {
+		return start
+	}
+	return next
+}
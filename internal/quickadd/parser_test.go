@@ -0,0 +1,134 @@
+package quickadd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-03-11 是星期三，下午兩點
+	now := time.Date(2026, 3, 11, 14, 0, 0, 0, taipei)
+	at := func(month time.Month, day int, hour int, minute int) *time.Time {
+		due := time.Date(2026, month, day, hour, minute, 0, 0, taipei)
+		return &due
+	}
+
+	tests := []struct {
+		input    string
+		title    string
+		due      *time.Time
+		allDay   bool
+		priority string
+		labels   []string
+		rrule    string
+	}{
+		{input: "Call mom tomorrow 5pm", title: "Call mom", due: at(3, 12, 17, 0)},
+		{input: "Team lunch next Friday", title: "Team lunch", due: at(3, 20, 23, 59), allDay: true},
+		{input: "Team lunch this Friday", title: "Team lunch", due: at(3, 13, 23, 59), allDay: true},
+		{input: "明天下午5點半交報告", title: "交報告", due: at(3, 12, 17, 30)},
+		{
+			input: "Pay rent every month on the 1st #home !high", title: "Pay rent",
+			due: at(4, 1, 23, 59), allDay: true, priority: "high", labels: []string{"home"},
+			rrule: "FREQ=MONTHLY;BYMONTHDAY=1",
+		},
+		{input: "每週一三五 健身", title: "健身", due: at(3, 11, 23, 59), allDay: true, rrule: "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{input: "Gym every monday", title: "Gym", due: at(3, 16, 23, 59), allDay: true, rrule: "FREQ=WEEKLY;BYDAY=MO"},
+
+		// 只有時間：還沒到就是今天，已經過了就是明天
+		{input: "Stand-up at 15:30", title: "Stand-up", due: at(3, 11, 15, 30)},
+		{input: "Stand-up at 9:00", title: "Stand-up", due: at(3, 12, 9, 0)},
+		{input: "早上9點 晨會", title: "晨會", due: at(3, 12, 9, 0)},
+		{input: "Coffee at 2pm", title: "Coffee", due: at(3, 12, 14, 0)},
+
+		{input: "Report in 2 hours !!!", title: "Report", due: at(3, 11, 16, 0), priority: "urgent"},
+		{input: "Dinner tonight", title: "Dinner", due: at(3, 11, 20, 0)},
+		{input: "Buy milk", title: "Buy milk"},
+		{input: "  Buy   milk  #errands #Errands ", title: "Buy milk", labels: []string{"errands"}},
+	}
+
+	parser := NewParser(func() time.Time { return now })
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parser.Parse(tt.input, taipei)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if got.Title != tt.title {
+				t.Errorf("Title = %q, want %q", got.Title, tt.title)
+			}
+			switch {
+			case got.DueAt == nil && tt.due == nil:
+			case got.DueAt == nil || tt.due == nil || !got.DueAt.Equal(*tt.due):
+				t.Errorf("DueAt = %v, want %v", got.DueAt, tt.due)
+			}
+			if got.AllDay != tt.allDay {
+				t.Errorf("AllDay = %v, want %v", got.AllDay, tt.allDay)
+			}
+			if got.Priority != tt.priority {
+				t.Errorf("Priority = %q, want %q", got.Priority, tt.priority)
+			}
+			labels := tt.labels
+			if labels == nil {
+				labels = []string{}
+			}
+			if !reflect.DeepEqual(got.Labels, labels) {
+				t.Errorf("Labels = %q, want %q", got.Labels, labels)
+			}
+			if got.RecurrenceRule != tt.rrule {
+				t.Errorf("RecurrenceRule = %q, want %q", got.RecurrenceRule, tt.rrule)
+			}
+			if got.TimeZone != "Asia/Taipei" {
+				t.Errorf("TimeZone = %q", got.TimeZone)
+			}
+		})
+	}
+}
+
+func TestParseEmptyTitle(t *testing.T) {
+	parser := NewParser(func() time.Time { return time.Date(2026, 3, 11, 14, 0, 0, 0, time.UTC) })
+
+	for _, input := range []string{"", "   ", "tomorrow 5pm", "#home !high", "明天下午5點", "every monday"} {
+		if _, err := parser.Parse(input, time.UTC); !errors.Is(err, ErrEmptyTitle) {
+			t.Errorf("Parse(%q) error = %v, want ErrEmptyTitle", input, err)
+		}
+	}
+}
+
+// 同一個時間點在不同時區，「今天」不一樣
+func TestParseUsesLocation(t *testing.T) {
+	// 台北 2026-03-12 01:00，紐約還是 03-11 中午
+	now := time.Date(2026, 3, 11, 17, 0, 0, 0, time.UTC)
+	parser := NewParser(func() time.Time { return now })
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		loc  *time.Location
+		want time.Time
+	}{
+		{newYork, time.Date(2026, 3, 12, 23, 59, 0, 0, newYork)},
+		{taipei, time.Date(2026, 3, 13, 23, 59, 0, 0, taipei)},
+	} {
+		got, err := parser.Parse("Pay bills tomorrow", tt.loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.DueAt == nil || !got.DueAt.Equal(tt.want) {
+			t.Errorf("%s: DueAt = %v, want %v", tt.loc, got.DueAt, tt.want)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package quickadd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 英文的星期、月份名稱（縮寫也可以），放進 regexp 用
+const (
+	weekdayPattern = `monday|mon|tuesday|tues|tue|wednesday|wed|thursday|thurs|thur|thu|friday|fri|saturday|sat|sunday|sun`
+	monthPattern   = `january|jan|february|feb|march|mar|april|apr|may|june|jun|july|jul|august|aug|september|sept|sep|october|oct|november|nov|december|dec`
+	// 中文數字（一 ~ 九十九），日期、時間、間隔都用得到
+	cnNumberPattern = `[零一二兩两三四五六七八九十]+`
+	// 中文的「星期」
+	cnWeekPattern = `(?:週|周|星期|禮拜|礼拜)`
+	// 英文日期前面常接的介系詞，一起拿掉標題才不會剩下「Submit report by」
+	enDatePrefix = `(?:(?:by|on|due)\s+)?`
+)
+
+var enWeekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+var cnWeekdays = map[rune]time.Weekday{
+	'一': time.Monday, '二': time.Tuesday, '三': time.Wednesday, '四': time.Thursday,
+	'五': time.Friday, '六': time.Saturday, '日': time.Sunday, '天': time.Sunday,
+}
+
+var cnDigits = map[rune]int{
+	'零': 0, '一': 1, '二': 2, '兩': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// RRULE 的 BYDAY 代碼
+var weekdayCodes = map[time.Weekday]string{
+	time.Monday: "MO", time.Tuesday: "TU", time.Wednesday: "WE", time.Thursday: "TH",
+	time.Friday: "FR", time.Saturday: "SA", time.Sunday: "SU",
+}
+
+// 英文星期名稱取前三個字母就能對應
+func enWeekday(name string) time.Weekday {
+	return enWeekdays[strings.ToLower(name)[:3]]
+}
+
+func enMonth(name string) time.Month {
+	prefix := strings.ToLower(name)[:3]
+	for m := time.January; m <= time.December; m++ {
+		if strings.ToLower(m.String())[:3] == prefix {
+			return m
+		}
+	}
+	return 0
+}
+
+// 阿拉伯數字或中文數字（一 ~ 九十九、兩）
+func parseNumber(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+
+	runes := []rune(s)
+	if len(runes) == 0 || len(runes) > 3 {
+		return 0, false
+	}
+
+	ten := -1
+	for i, r := range runes {
+		if r == '十' {
+			if ten >= 0 {
+				return 0, false
+			}
+			ten = i
+		} else if _, ok := cnDigits[r]; !ok {
+			return 0, false
+		}
+	}
+
+	if ten < 0 {
+		if len(runes) != 1 {
+			return 0, false
+		}
+		return cnDigits[runes[0]], true
+	}
+
+	tens, ones := 1, 0
+	if ten == 1 {
+		tens = cnDigits[runes[0]]
+	} else if ten > 1 {
+		return 0, false
+	}
+	if ten < len(runes)-1 {
+		if ten+2 != len(runes) {
+			return 0, false
+		}
+		ones = cnDigits[runes[ten+1]]
+	}
+	return tens*10 + ones, true
+}
+
+// ---------- 標籤 ----------
+
+// 至少要有一個字母或數字，#-- 這種不算標籤
+var labelRe = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]*[\p{L}\p{N}][\p{L}\p{N}_-]*)`)
+
+// 所有 #標籤，轉小寫、去掉重複
+func parseLabels(s *state) []string {
+	labels := []string{}
+	seen := map[string]bool{}
+	addLabel := func(g []string) bool {
+		label := strings.ToLower(g[1])
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+		return true
+	}
+	for s.take(labelRe, KindLabel, addLabel) {
+	}
+	return labels
+}
+
+// ---------- 優先順序 ----------
+
+var (
+	priorityWordRe = regexp.MustCompile(`(?i)(?:^|\s)!(low|normal|high|urgent)\b`)
+	// !! = high、!!! = urgent
+	priorityBangRe = regexp.MustCompile(`(?:^|\s)(!{2,3})(?:\s|$)`)
+)
+
+func parsePriority(s *state) string {
+	priority := ""
+	if s.take(priorityWordRe, KindPriority, func(g []string) bool {
+		priority = strings.ToLower(g[1])
+		return true
+	}) {
+		return priority
+	}
+
+	s.take(priorityBangRe, KindPriority, func(g []string) bool {
+		priority = "high"
+		if len(g[1]) == 3 {
+			priority = "urgent"
+		}
+		return true
+	})
+	return priority
+}
+
+// ---------- 週期 ----------
+
+type recurrenceRule struct {
+	re    *regexp.Regexp
+	rrule func(g []string) (string, bool)
+}
+
+func byDay(days []time.Weekday) string {
+	codes := make([]string, len(days))
+	for i, day := range days {
+		codes[i] = weekdayCodes[day]
+	}
+	return strings.Join(codes, ",")
+}
+
+func withInterval(freq string, raw string) (string, bool) {
+	if raw == "" {
+		return "FREQ=" + freq, true
+	}
+	n, ok := parseNumber(raw)
+	if strings.EqualFold(raw, "other") {
+		n, ok = 2, true
+	}
+	if !ok || n < 1 {
+		return "", false
+	}
+	if n == 1 {
+		return "FREQ=" + freq, true
+	}
+	return fmt.Sprintf("FREQ=%s;INTERVAL=%d", freq, n), true
+}
+
+var weekdayNameRe = regexp.MustCompile(`(?i)` + weekdayPattern)
+
+var enUnitFreq = map[string]string{"day": "DAILY", "week": "WEEKLY", "month": "MONTHLY", "year": "YEARLY"}
+
+var cnUnitFreq = map[string]string{
+	"天": "DAILY", "日": "DAILY",
+	"週": "WEEKLY", "周": "WEEKLY", "星期": "WEEKLY", "禮拜": "WEEKLY", "礼拜": "WEEKLY",
+	"月": "MONTHLY", "年": "YEARLY",
+}
+
+// 依序嘗試，長的片語放前面（「每月1號」要比「每月」先）
+var recurrenceRules = []recurrenceRule{
+	{
+		re: regexp.MustCompile(`(?i)\bevery\s+(weekday|workday|weekend)s?\b`),
+		rrule: func(g []string) (string, bool) {
+			if strings.EqualFold(g[1], "weekend") {
+				return "FREQ=WEEKLY;BYDAY=SA,SU", true
+			}
+			return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", true
+		},
+	},
+	{
+		// every month on the 1st、every 2 weeks on monday、every other day
+		re: regexp.MustCompile(`(?i)\bevery\s+(?:(\d+|other)\s+)?(day|week|month|year)s?(?:\s+on\s+(?:(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)?|(` + weekdayPattern + `)))?\b`),
+		rrule: func(g []string) (string, bool) {
+			rule, ok := withInterval(enUnitFreq[strings.ToLower(g[2])], g[1])
+			if !ok {
+				return "", false
+			}
+			if g[3] != "" {
+				day, _ := strconv.Atoi(g[3])
+				if day < 1 || day > 31 {
+					return "", false
+				}
+				rule += ";BYMONTHDAY=" + g[3]
+			}
+			if g[4] != "" {
+				rule += ";BYDAY=" + weekdayCodes[enWeekday(g[4])]
+			}
+			return rule, true
+		},
+	},
+	{
+		// every monday、every mon, wed and fri
+		re: regexp.MustCompile(`(?i)\bevery\s+((?:` + weekdayPattern + `)(?:\s*(?:,|and|&)\s*(?:` + weekdayPattern + `))*)\b`),
+		rrule: func(g []string) (string, bool) {
+			names := weekdayNameRe.FindAllString(g[1], -1)
+			days := make([]time.Weekday, len(names))
+			for i, name := range names {
+				days[i] = enWeekday(name)
+			}
+			return "FREQ=WEEKLY;BYDAY=" + byDay(days), true
+		},
+	},
+	{
+		// every 15th
+		re: regexp.MustCompile(`(?i)\b(?:every|each)\s+(\d{1,2})(?:st|nd|rd|th)\b`),
+		rrule: func(g []string) (string, bool) {
+			day, _ := strconv.Atoi(g[1])
+			if day < 1 || day > 31 {
+				return "", false
+			}
+			return "FREQ=MONTHLY;BYMONTHDAY=" + g[1], true
+		},
+	},
+	{
+		re: regexp.MustCompile(`(?i)\b(daily|weekly|biweekly|monthly|yearly|annually)\b`),
+		rrule: func(g []string) (string, bool) {
+			switch strings.ToLower(g[1]) {
+			case "daily":
+				return "FREQ=DAILY", true
+			case "weekly":
+				return "FREQ=WEEKLY", true
+			case "biweekly":
+				return "FREQ=WEEKLY;INTERVAL=2", true
+			case "monthly":
+				return "FREQ=MONTHLY", true
+			default:
+				return "FREQ=YEARLY", true
+			}
+		},
+	},
+	{
+		re: regexp.MustCompile(`每(?:個|个)?(?:工作[天日]|平日)`),
+		rrule: func(g []string) (string, bool) {
+			return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", true
+		},
+	},
+	{
+		re: regexp.MustCompile(`每(?:個|个)?週末|每(?:個|个)?周末`),
+		rrule: func(g []string) (string, bool) {
+			return "FREQ=WEEKLY;BYDAY=SA,SU", true
+		},
+	},
+	{
+		// 每月1號、每個月十五日
+		re: regexp.MustCompile(`每(?:個|个)?月\s*(\d{1,2}|` + cnNumberPattern + `)\s*[號号日]`),
+		rrule: func(g []string) (string, bool) {
+			day, ok := parseNumber(g[1])
+			if !ok || day < 1 || day > 31 {
+				return "", false
+			}
+			return fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%d", day), true
+		},
+	},
+	{
+		// 每週一、每週一三五
+		re: regexp.MustCompile(`每(?:個|个)?` + cnWeekPattern + `([一二三四五六日天]+)`),
+		rrule: func(g []string) (string, bool) {
+			var days []time.Weekday
+			for _, r := range g[1] {
+				days = append(days, cnWeekdays[r])
+			}
+			return "FREQ=WEEKLY;BYDAY=" + byDay(days), true
+		},
+	},
+	{
+		// 每天、每兩週、每隔3天、每個月、每年
+		re: regexp.MustCompile(`每(?:隔)?\s*(\d+|` + cnNumberPattern + `)?\s*(?:個|个)?(天|日|週|周|星期|禮拜|礼拜|月|年)`),
+		rrule: func(g []string) (string, bool) {
+			return withInterval(cnUnitFreq[g[2]], g[1])
+		},
+	},
+}
+
+func parseRecurrence(s *state) string {
+	for _, rule := range recurrenceRules {
+		var rrule string
+		if s.take(rule.re, KindRecurrence, func(g []string) bool {
+			var ok bool
+			rrule, ok = rule.rrule(g)
+			return ok
+		}) {
+			return rrule
+		}
+	}
+	return ""
+}
+
+// ---------- 日期 ----------
+
+type timeOfDay struct {
+	hour   int
+	minute int
+}
+
+// 解析出來的日期：day 是當地日期的 00:00；at 是「2 小時後」這種直接給時間點的
+type dateSpec struct {
+	day         time.Time
+	defaultTime *timeOfDay // tonight、明晚這種沒講幾點但有大概時段的
+	at          *time.Time
+}
+
+type dateRule struct {
+	re   *regexp.Regexp
+	date func(g []string, now time.Time, today time.Time) (dateSpec, bool)
+}
+
+var (
+	morning = &timeOfDay{9, 0}
+	evening = &timeOfDay{20, 0}
+)
+
+func onDay(today time.Time, days int) dateSpec {
+	return dateSpec{day: today.AddDate(0, 0, days)}
+}
+
+// 指定月日，year 是 0 時用今年，已經過了就是明年；日期不存在（2/30）回傳 false
+func calendarDate(today time.Time, year int, month time.Month, day int) (dateSpec, bool) {
+	guessYear := year == 0
+	if guessYear {
+		year = today.Year()
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, today.Location())
+	if date.Month() != month || date.Day() != day {
+		return dateSpec{}, false
+	}
+	if guessYear && date.Before(today) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return dateSpec{day: date}, true
+}
+
+// 今天或之後第一個 weekday
+func upcomingWeekday(today time.Time, weekday time.Weekday) dateSpec {
+	return onDay(today, (int(weekday)-int(today.Weekday())+7)%7)
+}
+
+// 往後 weeks 週（週一開始）的那個星期幾，weeks = 0 是這一週
+func weekdayInWeek(today time.Time, weeks int, weekday time.Weekday) dateSpec {
+	offset := (int(today.Weekday()) + 6) % 7 // 今天是這週的第幾天，週一是 0
+	target := (int(weekday) + 6) % 7
+	return onDay(today, weeks*7+target-offset)
+}
+
+func addUnits(now time.Time, today time.Time, n int, unit string) dateSpec {
+	var at time.Time
+	switch unit {
+	case "minute", "分鐘", "分钟":
+		at = now.Add(time.Duration(n) * time.Minute)
+	case "hour", "小時", "小时":
+		at = now.Add(time.Duration(n) * time.Hour)
+	case "day", "天":
+		return onDay(today, n)
+	case "week", "週", "周", "星期":
+		return onDay(today, 7*n)
+	case "month", "個月", "个月":
+		return dateSpec{day: today.AddDate(0, n, 0)}
+	default:
+		return dateSpec{day: today.AddDate(n, 0, 0)}
+	}
+	return dateSpec{at: &at}
+}
+
+var enUnitAliases = map[string]string{"min": "minute", "hr": "hour"}
+
+var enCountWords = map[string]int{"a": 1, "an": 1, "one": 1, "two": 2, "three": 3}
+
+// 依序嘗試，第一個對到的就用；長的片語放前面（day after tomorrow 要比 tomorrow 先、大後天要比後天先）
+var dateRules = []dateRule{
+	{
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `(\d{4})-(\d{1,2})-(\d{1,2})\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			year, _ := strconv.Atoi(g[1])
+			month, _ := strconv.Atoi(g[2])
+			day, _ := strconv.Atoi(g[3])
+			return calendarDate(today, year, time.Month(month), day)
+		},
+	},
+	{
+		// Jan 31、January 31st, 2026
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `(` + monthPattern + `)\.?\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			day, _ := strconv.Atoi(g[2])
+			year, _ := strconv.Atoi(g[3])
+			return calendarDate(today, year, enMonth(g[1]), day)
+		},
+	},
+	{
+		// 31 Jan、31st of January
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?(` + monthPattern + `)(?:,?\s+(\d{4}))?\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			day, _ := strconv.Atoi(g[1])
+			year, _ := strconv.Atoi(g[3])
+			return calendarDate(today, year, enMonth(g[2]), day)
+		},
+	},
+	{
+		// 1/31、1/31/2026（月/日）
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `(\d{1,2})/(\d{1,2})(?:/(\d{4}))?\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			month, _ := strconv.Atoi(g[1])
+			day, _ := strconv.Atoi(g[2])
+			year, _ := strconv.Atoi(g[3])
+			return calendarDate(today, year, time.Month(month), day)
+		},
+	},
+	{
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `(?:the\s+)?day\s+after\s+tomorrow\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			return onDay(today, 2), true
+		},
+	},
+	{
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `(today|tonight|tomorrow|tmrw|tmr)\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			switch strings.ToLower(g[1]) {
+			case "today":
+				return onDay(today, 0), true
+			case "tonight":
+				spec := onDay(today, 0)
+				spec.defaultTime = evening
+				return spec, true
+			default:
+				return onDay(today, 1), true
+			}
+		},
+	},
+	{
+		// in 3 days、in an hour
+		re: regexp.MustCompile(`(?i)\bin\s+(\d+|an?|one|two|three)\s+(minute|min|hour|hr|day|week|month|year)s?\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			n, ok := enCountWords[strings.ToLower(g[1])]
+			if !ok {
+				n, _ = strconv.Atoi(g[1])
+			}
+			unit := strings.ToLower(g[2])
+			if alias, ok := enUnitAliases[unit]; ok {
+				unit = alias
+			}
+			return addUnits(now, today, n, unit), true
+		},
+	},
+	{
+		// next week = 下週一、next month = 下個月 1 號、next year = 明年 1 月 1 號
+		re: regexp.MustCompile(`(?i)\b` + enDatePrefix + `next\s+(week|month|year)\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			switch strings.ToLower(g[1]) {
+			case "week":
+				return weekdayInWeek(today, 1, time.Monday), true
+			case "month":
+				return dateSpec{day: time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location())}, true
+			default:
+				return dateSpec{day: time.Date(today.Year()+1, time.January, 1, 0, 0, 0, 0, today.Location())}, true
+			}
+		},
+	},
+	{
+		// friday / this friday / on friday = 今天或之後第一個星期五；next friday = 下週（週一開始）的星期五，跟「下週五」一樣
+		// 縮寫前面一定要有介系詞，不然「the cat sat on the mat」的 sat 也會被當成星期六
+		re: regexp.MustCompile(`(?i)\b((?:by|on|due)\s+)?(?:(next|this)\s+)?(` + weekdayPattern + `)\b`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			if g[1] == "" && g[2] == "" && !strings.HasSuffix(strings.ToLower(g[3]), "day") {
+				return dateSpec{}, false
+			}
+			weekday := enWeekday(g[3])
+			if strings.EqualFold(g[2], "next") {
+				return weekdayInWeek(today, 1, weekday), true
+			}
+			return upcomingWeekday(today, weekday), true
+		},
+	},
+	{
+		// 2026年1月31日、1月31號
+		re: regexp.MustCompile(`(?:(\d{4})\s*年\s*)?(\d{1,2}|` + cnNumberPattern + `)\s*月\s*(\d{1,2}|` + cnNumberPattern + `)\s*[日號号]`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			year, _ := strconv.Atoi(g[1])
+			month, ok := parseNumber(g[2])
+			if !ok {
+				return dateSpec{}, false
+			}
+			day, ok := parseNumber(g[3])
+			if !ok {
+				return dateSpec{}, false
+			}
+			return calendarDate(today, year, time.Month(month), day)
+		},
+	},
+	{
+		// 3天後、兩個小時后
+		re: regexp.MustCompile(`(\d+|` + cnNumberPattern + `)\s*(分鐘|分钟|小時|小时|天|週|周|星期|個月|个月|年)[後后]`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			n, ok := parseNumber(g[1])
+			if !ok {
+				return dateSpec{}, false
+			}
+			return addUnits(now, today, n, g[2]), true
+		},
+	},
+	{
+		re: regexp.MustCompile(`大[後后]天`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			return onDay(today, 3), true
+		},
+	},
+	{
+		re: regexp.MustCompile(`[後后]天`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			return onDay(today, 2), true
+		},
+	},
+	{
+		re: regexp.MustCompile(`(今|明)(天|日|早|晚)`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			spec := onDay(today, 0)
+			if g[1] == "明" {
+				spec = onDay(today, 1)
+			}
+			switch g[2] {
+			case "早":
+				spec.defaultTime = morning
+			case "晚":
+				spec.defaultTime = evening
+			}
+			return spec, true
+		},
+	},
+	{
+		// 下週五、下下週一
+		re: regexp.MustCompile(`(下+)(?:個|个)?` + cnWeekPattern + `([一二三四五六日天])`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			return weekdayInWeek(today, len([]rune(g[1])), cnWeekdays[[]rune(g[2])[0]]), true
+		},
+	},
+	{
+		// 這週五、週五：今天或之後第一個
+		re: regexp.MustCompile(`(?:這|这|本)?(?:個|个)?` + cnWeekPattern + `([一二三四五六日天])`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			return upcomingWeekday(today, cnWeekdays[[]rune(g[1])[0]]), true
+		},
+	},
+	{
+		// 下週 = 下週一、下個月 = 下個月 1 號
+		re: regexp.MustCompile(`下(?:個|个)?(週|周|星期|禮拜|礼拜|月)`),
+		date: func(g []string, now, today time.Time) (dateSpec, bool) {
+			if g[1] == "月" {
+				return dateSpec{day: time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location())}, true
+			}
+			return weekdayInWeek(today, 1, time.Monday), true
+		},
+	},
+}
+
+func parseDate(s *state, now time.Time, today time.Time) (dateSpec, bool) {
+	for _, rule := range dateRules {
+		var spec dateSpec
+		if s.take(rule.re, KindDue, func(g []string) bool {
+			var ok bool
+			spec, ok = rule.date(g, now, today)
+			return ok
+		}) {
+			return spec, true
+		}
+	}
+	return dateSpec{}, false
+}
+
+// ---------- 時間 ----------
+
+type timeRule struct {
+	re    *regexp.Regexp
+	clock func(g []string) (timeOfDay, bool)
+}
+
+var timeRules = []timeRule{
+	{
+		// 5pm、at 5:30 pm
+		re: regexp.MustCompile(`(?i)\b(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*(am|pm)\b`),
+		clock: func(g []string) (timeOfDay, bool) {
+			hour, _ := strconv.Atoi(g[1])
+			minute, _ := strconv.Atoi(g[2])
+			if hour < 1 || hour > 12 || minute > 59 {
+				return timeOfDay{}, false
+			}
+			hour %= 12
+			if strings.EqualFold(g[3], "pm") {
+				hour += 12
+			}
+			return timeOfDay{hour, minute}, true
+		},
+	},
+	{
+		// 17:30、at 9:00
+		re: regexp.MustCompile(`(?i)\b(?:at\s+)?(\d{1,2}):(\d{2})\b`),
+		clock: func(g []string) (timeOfDay, bool) {
+			hour, _ := strconv.Atoi(g[1])
+			minute, _ := strconv.Atoi(g[2])
+			if hour > 23 || minute > 59 {
+				return timeOfDay{}, false
+			}
+			return timeOfDay{hour, minute}, true
+		},
+	},
+	{
+		re: regexp.MustCompile(`(?i)\b(?:at\s+)?(noon|midnight)\b`),
+		clock: func(g []string) (timeOfDay, bool) {
+			if strings.EqualFold(g[1], "noon") {
+				return timeOfDay{12, 0}, true
+			}
+			return timeOfDay{0, 0}, true
+		},
+	},
+	{
+		// 下午5點、晚上八點半、早上9點15分
+		re: regexp.MustCompile(`(早上|上午|中午|下午|晚上|凌晨)?\s*(\d{1,2}|` + cnNumberPattern + `)\s*[點点](?:\s*(半)|\s*(\d{1,2}|` + cnNumberPattern + `)\s*分?)?`),
+		clock: func(g []string) (timeOfDay, bool) {
+			// 「快一點」的一點不是一點鐘：中文數字的鐘點前面一定要有時段
+			if _, err := strconv.Atoi(g[2]); err != nil && g[1] == "" {
+				return timeOfDay{}, false
+			}
+			hour, ok := parseNumber(g[2])
+			if !ok || hour > 23 {
+				return timeOfDay{}, false
+			}
+			minute := 0
+			if g[3] != "" {
+				minute = 30
+			} else if g[4] != "" {
+				if minute, ok = parseNumber(g[4]); !ok || minute > 59 {
+					return timeOfDay{}, false
+				}
+			}
+
+			switch g[1] {
+			case "下午", "晚上":
+				if hour < 12 {
+					hour += 12
+				}
+			case "中午":
+				if hour < 6 {
+					hour += 12
+				}
+			case "凌晨", "早上", "上午":
+				if hour == 12 {
+					hour = 0
+				}
+			}
+			return timeOfDay{hour, minute}, true
+		},
+	},
+}
+
+func parseTime(s *state) (timeOfDay, bool) {
+	for _, rule := range timeRules {
+		var clock timeOfDay
+		if s.take(rule.re, KindTime, func(g []string) bool {
+			var ok bool
+			clock, ok = rule.clock(g)
+			return ok
+		}) {
+			return clock, true
+		}
+	}
+	return timeOfDay{}, false
+}
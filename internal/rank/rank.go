@@ -0,0 +1,177 @@
+// Package rank 產生可以插在任意兩個值中間的排序鍵（fractional indexing），
+// 拖拉排序時只要改被移動的那一筆，不用把後面的全部往後挪。
+//
+// 鍵是 base62 的小數部分，例如 "V" 可以看成 0.V；兩個鍵直接比字串大小就是順序，
+// 所以資料庫欄位一定要用 COLLATE "C"（逐 byte 比較），不能用會忽略大小寫的語系排序。
+package rank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 照 ASCII 順序排好，字串比較結果才會跟數值大小一致
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+var (
+	ErrInvalidKey = errors.New("invalid rank key")
+	ErrNoRoom     = errors.New("rank keys are not in order")
+)
+
+func digitIndex(ch byte) int {
+	return strings.IndexByte(digits, ch)
+}
+
+// 只能是 base62 字元，而且不能以 0 結尾（"V" 跟 "V0" 數值一樣，中間會插不進東西）
+func Validate(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidKey)
+	}
+	for i := 0; i < len(key); i++ {
+		if digitIndex(key[i]) < 0 {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	if key[len(key)-1] == digits[0] {
+		return fmt.Errorf("%w: %q ends with %c", ErrInvalidKey, key, digits[0])
+	}
+	return nil
+}
+
+/*
+Between 回傳一個嚴格介於 a、b 之間的鍵：
+
+	Between("", "")   => 第一筆
+	Between("", b)    => 排在 b 前面
+	Between(a, "")    => 排在 a 後面
+	Between(a, b)     => 插在 a、b 中間，a 必須小於 b
+
+鍵的長度只會在兩邊已經很接近時才變長，長到一定程度再交給 Spread 重新平均分配
+*/
+func Between(a, b string) (string, error) {
+	if a != "" {
+		if err := Validate(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := Validate(b); err != nil {
+			return "", err
+		}
+	}
+	if a != "" && b != "" && a >= b {
+		return "", fmt.Errorf("%w: %q >= %q", ErrNoRoom, a, b)
+	}
+	return midpoint(a, b), nil
+}
+
+// a < b（b 為空字串代表 1），兩者都沒有結尾的 0
+func midpoint(a, b string) string {
+	if b != "" {
+		// 共同的前綴照抄，a 比較短時後面當作補 0
+		n := 0
+		for n < len(b) {
+			ca := digits[0]
+			if n < len(a) {
+				ca = a[n]
+			}
+			if ca != b[n] {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	// 第一個位數已經不同
+	da := 0
+	if a != "" {
+		da = digitIndex(a[0])
+	}
+	db := base
+	if b != "" {
+		db = digitIndex(b[0])
+	}
+
+	if db-da > 1 {
+		return string(digits[(da+db)/2])
+	}
+
+	// 第一位只差 1：b 還有後面的位數就直接取 b 的第一位（一定比 b 小、比 a 大）
+	if b != "" && len(b) > 1 {
+		return b[:1]
+	}
+
+	// 否則沿用 a 的第一位，往下一位找 a 剩下的部分跟 1 的中間
+	rest := ""
+	if a != "" {
+		rest = a[1:]
+	}
+	return string(digits[da]) + midpoint(rest, "")
+}
+
+// 產生 n 個由小到大、平均分布的鍵，重新平衡時用；鍵的長度只跟 n 有關
+func Spread(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	// 找最短的長度 width，讓 base^width 至少有 n+1 個間隔
+	width, space := 1, uint64(base)
+	for space <= uint64(n) && width < 10 {
+		width++
+		space *= uint64(base)
+	}
+	step := space / uint64(n+1)
+	if step == 0 {
+		step = 1
+	}
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = encode((uint64(i)+1)*step, width)
+	}
+	return keys
+}
+
+// 產生 n 個依序排在 a 後面的鍵，批次新增用
+// 連續呼叫 Between(prev, "") 每次只往後挪一半，幾百筆之後鍵會變很長；
+// 這裡先取一個比 a 大的前綴，後面接 Spread(n)，只多 log62(n) 位
+func After(a string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	prefix, err := Between(a, "")
+	if err != nil {
+		return nil, err
+	}
+	if n == 1 {
+		return []string{prefix}, nil
+	}
+
+	keys := Spread(n)
+	for i := range keys {
+		keys[i] = prefix + keys[i]
+	}
+	return keys, nil
+}
+
+// value 轉成固定 width 位的 base62，再把結尾的 0 去掉（數值不變，順序也不變）
+func encode(value uint64, width int) string {
+	buf := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		buf[i] = digits[value%uint64(base)]
+		value /= uint64(base)
+	}
+	return strings.TrimRight(string(buf), digits[:1])
+}
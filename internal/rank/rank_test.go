@@ -0,0 +1,163 @@
+package rank
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func assertIncreasing(t *testing.T, keys []string) {
+	t.Helper()
+
+	for i, key := range keys {
+		if err := Validate(key); err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+		if i > 0 && keys[i-1] >= key {
+			t.Fatalf("keys[%d] = %q is not after keys[%d] = %q", i, key, i-1, keys[i-1])
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"", "1"},
+		{"", "01"},
+		{"", "V"},
+		{"z", ""},
+		{"zzz", ""},
+		{"V", ""},
+		{"1", "2"},
+		{"V", "W"},
+		{"V", "V1"},
+		{"A", "A01"},
+		{"Vz", "W"},
+		{"Vzz", "W1"},
+		{"0V", "1"},
+		{"a", "z"},
+	}
+
+	for _, tt := range tests {
+		got, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Between(%q, %q) error = %v", tt.a, tt.b, err)
+			continue
+		}
+		if err := Validate(got); err != nil {
+			t.Errorf("Between(%q, %q) = %q: %v", tt.a, tt.b, got, err)
+		}
+		if tt.a != "" && got <= tt.a {
+			t.Errorf("Between(%q, %q) = %q, not after %q", tt.a, tt.b, got, tt.a)
+		}
+		if tt.b != "" && got >= tt.b {
+			t.Errorf("Between(%q, %q) = %q, not before %q", tt.a, tt.b, got, tt.b)
+		}
+	}
+}
+
+func TestBetweenRejectsBadInput(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want error
+	}{
+		{"V", "V", ErrNoRoom},
+		{"W", "V", ErrNoRoom},
+		{"V1", "V", ErrNoRoom},
+		{"V0", "", ErrInvalidKey},
+		{"", "a-b", ErrInvalidKey},
+		{"", "0", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		if _, err := Between(tt.a, tt.b); !errors.Is(err, tt.want) {
+			t.Errorf("Between(%q, %q) error = %v, want %v", tt.a, tt.b, err, tt.want)
+		}
+	}
+}
+
+// 模擬拖拉排序：一直插在隨機的兩筆中間、最前面或最後面，順序要一直保持正確
+func TestBetweenRepeatedInserts(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	keys := []string{}
+
+	for range 2000 {
+		i := r.Intn(len(keys) + 1)
+		a, b := "", ""
+		if i > 0 {
+			a = keys[i-1]
+		}
+		if i < len(keys) {
+			b = keys[i]
+		}
+
+		key, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) error = %v", a, b, err)
+		}
+		keys = slices.Insert(keys, i, key)
+	}
+
+	assertIncreasing(t, keys)
+}
+
+// 一直插在同一個位置前面是最壞的情況，鍵會越來越長，但還是要插得進去
+func TestBetweenSameGap(t *testing.T) {
+	a, b := "V", "W"
+	for range 200 {
+		key, err := Between(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key <= a || key >= b {
+			t.Fatalf("Between(%q, %q) = %q", a, b, key)
+		}
+		b = key
+	}
+}
+
+func TestSpread(t *testing.T) {
+	if keys := Spread(0); keys != nil {
+		t.Fatalf("Spread(0) = %v, want nil", keys)
+	}
+
+	for _, n := range []int{1, 2, 10, 61, 62, 100, 3843, 5000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		assertIncreasing(t, keys)
+
+		// 鍵的長度只跟 n 有關：62 個以內一位就夠
+		if n < base && len(keys[n-1]) != 1 {
+			t.Errorf("Spread(%d) keys are longer than one digit: %q", n, keys[n-1])
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	if keys, err := After("V", 0); err != nil || keys != nil {
+		t.Fatalf("After(V, 0) = %v, %v", keys, err)
+	}
+
+	for _, a := range []string{"", "V", "z", "zzz", "A01"} {
+		for _, n := range []int{1, 2, 100, 1000} {
+			keys, err := After(a, n)
+			if err != nil {
+				t.Fatalf("After(%q, %d) error = %v", a, n, err)
+			}
+			if len(keys) != n {
+				t.Fatalf("After(%q, %d) returned %d keys", a, n, len(keys))
+			}
+			assertIncreasing(t, keys)
+			if a != "" && keys[0] <= a {
+				t.Fatalf("After(%q, %d)[0] = %q, not after %q", a, n, keys[0], a)
+			}
+		}
+	}
+
+	if _, err := After("V0", 3); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("After(V0) error = %v, want ErrInvalidKey", err)
+	}
+}
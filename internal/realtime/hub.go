@@ -0,0 +1,110 @@
+/*
+GET /todos/stream 的即時推送：listener 用一條獨立的連線 LISTEN todo 的變更，
+收到的通知交給 Hub 依使用者分送給每一個連著的 SSE client
+*/
+package realtime
+
+import (
+	"sync"
+
+	"todo_api/internal/models"
+)
+
+/*
+Hub 把變更通知分送給收件人的所有連線（多個分頁 / 裝置）；收件人是誰由 listener 決定
+
+每個連線都有固定大小的 buffer，送的時候不會等：buffer 滿了（client 讀太慢）就直接把那條連線斷掉，
+不會卡住 listener 跟其他人。被斷掉的 client 會自動重連並帶 Last-Event-ID，從資料庫補回漏掉的通知
+*/
+type Hub struct {
+	mu          sync.Mutex
+	bufferSize  int
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+// 一條 SSE 連線的訂閱；Events() 被關掉代表 hub 把這條連線斷了，handler 應該結束 response
+type Subscription struct {
+	UserID string
+	events chan models.TodoStreamEvent
+}
+
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		bufferSize:  bufferSize,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (s *Subscription) Events() <-chan models.TodoStreamEvent {
+	return s.events
+}
+
+func (h *Hub) Subscribe(userID string) *Subscription {
+	sub := &Subscription{
+		UserID: userID,
+		events: make(chan models.TodoStreamEvent, h.bufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// 連線結束時呼叫；已經被 hub 斷掉的也可以再呼叫
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(sub)
+}
+
+// 送給 userIDs 裡每個使用者的所有連線，不會阻塞
+func (h *Hub) Publish(userIDs []string, event models.TodoStreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for sub := range h.subscribers[userID] {
+			select {
+			case sub.events <- event:
+			default:
+				// buffer 滿了：斷掉讓它重連補資料，比讓它拖慢所有人好
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// 斷掉所有連線；listener 重新連上資料庫時用，斷線期間的通知讓 client 重連時自己從資料庫補
+func (h *Hub) DisconnectAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// 呼叫端要先拿到 h.mu
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subscribers[sub.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.UserID)
+	}
+	close(sub.events)
+}
@@ -0,0 +1,69 @@
+package realtime
+
+import (
+	"slices"
+	"testing"
+
+	"todo_api/internal/models"
+)
+
+func received(sub *Subscription) []int64 {
+	var ids []int64
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return ids
+			}
+			ids = append(ids, event.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestPublishOnlyReachesRecipients(t *testing.T) {
+	hub := NewHub(4)
+	owner := hub.Subscribe("owner")
+	ownerTab := hub.Subscribe("owner")
+	editor := hub.Subscribe("editor")
+	removed := hub.Subscribe("removed")
+
+	listID := "list-1"
+	hub.Publish([]string{"owner", "editor"}, models.TodoStreamEvent{ID: 1, UserID: "removed", ListID: &listID})
+	hub.Publish([]string{"editor"}, models.TodoStreamEvent{ID: 2, UserID: "editor"})
+
+	for _, tt := range []struct {
+		name string
+		sub  *Subscription
+		want []int64
+	}{
+		{"owner", owner, []int64{1}},
+		{"owner second tab", ownerTab, []int64{1}},
+		{"editor", editor, []int64{1, 2}},
+		// 建立者被移出清單之後，就算事件的 user_id 是他也收不到
+		{"removed creator", removed, nil},
+	} {
+		if got := received(tt.sub); !slices.Equal(got, tt.want) {
+			t.Errorf("%s received %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublishDisconnectsSlowSubscribers(t *testing.T) {
+	hub := NewHub(1)
+	slow := hub.Subscribe("u1")
+
+	hub.Publish([]string{"u1"}, models.TodoStreamEvent{ID: 1})
+	hub.Publish([]string{"u1"}, models.TodoStreamEvent{ID: 2})
+
+	if event := <-slow.Events(); event.ID != 1 {
+		t.Fatalf("first event = %d, want 1", event.ID)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("slow subscriber was not disconnected")
+	}
+
+	// 已經被斷掉的再 Unsubscribe 也沒關係
+	hub.Unsubscribe(slow)
+}
@@ -0,0 +1,129 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// 連線斷掉後重連的等待時間，每次失敗加倍
+	listenerMinBackoff = time.Second
+	listenerMaxBackoff = 30 * time.Second
+
+	// 這麼久沒收到通知就 ping 一下，確認連線還活著（斷得不乾淨時 WaitForNotification 可能永遠等不到）
+	listenerPingInterval = 30 * time.Second
+)
+
+/*
+StartListener 開一條獨立的連線（不從 pool 借，LISTEN 會一直佔著連線）在 repository.TodoEventsChannel 上 LISTEN，
+收到的通知交給 hub
+
+連線斷掉會一直重連；重新 LISTEN 成功後把所有 SSE 連線斷掉，讓 client 帶 Last-Event-ID 重連，
+補回斷線期間漏掉的通知
+*/
+func StartListener(ctx context.Context, databaseURL string, hub *Hub) {
+	go func() {
+		backoff := listenerMinBackoff
+
+		for {
+			err := listen(ctx, databaseURL, hub, func() {
+				backoff = listenerMinBackoff
+				hub.DisconnectAll()
+			})
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Printf("todo stream listener: %v, reconnecting in %s\n", err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, listenerMaxBackoff)
+		}
+	}()
+}
+
+// 連上、LISTEN，之後一直收通知直到連線出錯或 ctx 被取消；LISTEN 成功時呼叫 onListening
+func listen(ctx context.Context, databaseURL string, hub *Hub, onListening func()) error {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return fmt.Errorf("連線失敗: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{repository.TodoEventsChannel}.Sanitize()); err != nil {
+		return fmt.Errorf("LISTEN 失敗: %w", err)
+	}
+	onListening()
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, listenerPingInterval)
+		notification, err := conn.WaitForNotification(waitCtx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !pgconn.Timeout(err) {
+				return fmt.Errorf("等待通知失敗: %w", err)
+			}
+
+			// 只是一段時間沒有通知，確認連線還在就繼續等
+			pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err = conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("ping 失敗: %w", err)
+			}
+			continue
+		}
+
+		var event models.TodoStreamEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Printf("todo stream listener: invalid payload %q: %v\n", notification.Payload, err)
+			continue
+		}
+
+		recipients, err := streamRecipients(ctx, conn, event)
+		if err != nil {
+			// 查不到就當作斷線處理：重連之後所有 client 會從資料庫補
+			return err
+		}
+		hub.Publish(recipients, event)
+	}
+}
+
+/*
+誰會收到這個通知，跟重連補資料（repository.GetTodoStreamEvents）同一套規則：
+個人 todo 只給建立者；清單裡的 todo 給清單「現在」的成員，建立者被移出清單之後就收不到了
+
+用 LISTEN 的那條連線查：等通知的時候連線是閒著的
+*/
+func streamRecipients(ctx context.Context, conn *pgx.Conn, event models.TodoStreamEvent) ([]string, error) {
+	if event.ListID == nil {
+		return []string{event.UserID}, nil
+	}
+
+	members, err := repository.GetTodoListMembers(ctx, conn, *event.ListID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	return userIDs, nil
+}
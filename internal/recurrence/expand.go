@@ -0,0 +1,267 @@
+package recurrence
+
+import (
+	"sort"
+	"time"
+)
+
+// 展開的上限（以 FREQ 為單位的週期數），避免永遠對不到的規則（例如 FEB 的 BYMONTHDAY=30）跑成無窮迴圈
+// DAILY 大約是 137 年
+const maxPeriods = 50000
+
+// 日曆上的一天，不帶時區；日期運算都在 UTC 上做，才不會被日光節約時間影響
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) civilDate {
+	y, m, d := t.Date()
+	return civilDate{y, m, d}
+}
+
+func (d civilDate) utc() time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.UTC)
+}
+
+func (d civilDate) addDays(n int) civilDate {
+	return dateOf(d.utc().AddDate(0, 0, n))
+}
+
+func (d civilDate) before(o civilDate) bool {
+	return d.utc().Before(o.utc())
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+/*
+Occurrences 從 dtstart 開始展開，回傳嚴格晚於 after 的前 n 個日期。
+
+  - 時間是以 dtstart 所在時區的「牆上時間」展開：每天早上 9 點就是當地 9 點，跨過日光節約時間也一樣
+  - 遇到不存在的時間（春天撥快的那一小時），會往後順延到撥快之後，跟 RFC 5545 的處理一致
+  - dtstart 本身不符合規則時不算一次（跟 python-dateutil 一樣），但 COUNT 一律從 dtstart 開始算
+*/
+func (r *Rule) Occurrences(dtstart, after time.Time, n int) []time.Time {
+	var out []time.Time
+	if n <= 0 {
+		return out
+	}
+
+	r.each(dtstart, func(t time.Time) bool {
+		if t.After(after) {
+			out = append(out, t)
+		}
+		return len(out) < n
+	})
+
+	return out
+}
+
+// 嚴格晚於 after 的下一次；規則已經結束（COUNT 用完、超過 UNTIL）時回傳 false
+func (r *Rule) Next(dtstart, after time.Time) (time.Time, bool) {
+	next := r.Occurrences(dtstart, after, 1)
+	if len(next) == 0 {
+		return time.Time{}, false
+	}
+	return next[0], true
+}
+
+// 依時間順序把每一次丟給 fn，fn 回傳 false 就停止
+func (r *Rule) each(dtstart time.Time, fn func(time.Time) bool) {
+	loc := dtstart.Location()
+	start := dateOf(dtstart)
+	hour, min, sec := dtstart.Clock()
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	emitted := 0
+	for period := 0; period < maxPeriods; period++ {
+		for _, d := range r.setPos(r.candidates(start, period*interval)) {
+			t := wallTime(d, hour, min, sec, dtstart.Nanosecond(), loc)
+			if t.Before(dtstart) {
+				continue
+			}
+			if r.pastUntil(d, t) {
+				return
+			}
+
+			emitted++
+			if !fn(t) {
+				return
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// 當地時間 d hour:min:sec；落在春天撥快跳過的那段時間時，往後順延跳過的長度（02:30 => 03:30）
+// time.Date 遇到這種時間會用撥快前後哪個 offset 沒有保證，所以比對牆上時間自己修正
+func wallTime(d civilDate, hour, min, sec, nsec int, loc *time.Location) time.Time {
+	t := time.Date(d.year, d.month, d.day, hour, min, sec, nsec, loc)
+
+	want := time.Date(d.year, d.month, d.day, hour, min, sec, nsec, time.UTC)
+	gy, gm, gd := t.Date()
+	gh, gmin, gs := t.Clock()
+	got := time.Date(gy, gm, gd, gh, gmin, gs, nsec, time.UTC)
+
+	if gap := want.Sub(got); gap > 0 {
+		t = t.Add(gap)
+	}
+	return t
+}
+
+func (r *Rule) pastUntil(d civilDate, t time.Time) bool {
+	if r.Until.IsZero() {
+		return false
+	}
+	if r.untilIsDate {
+		return dateOf(r.Until).before(d)
+	}
+	return t.After(r.Until)
+}
+
+// 第 offset 個週期（已乘上 INTERVAL）裡符合 BYxxx 的日期，由小到大
+func (r *Rule) candidates(start civilDate, offset int) []civilDate {
+	var out []civilDate
+
+	switch r.Freq {
+	case Daily:
+		d := start.addDays(offset)
+		if r.matchWeekday(d) && r.matchMonthDay(d) {
+			out = append(out, d)
+		}
+
+	case Weekly:
+		// 週一當作一週的開始（WKST=MO）
+		weekday := int(start.utc().Weekday()+6) % 7
+		monday := start.addDays(-weekday + offset*7)
+		for i := 0; i < 7; i++ {
+			d := monday.addDays(i)
+			if len(r.ByDay) == 0 {
+				if d.utc().Weekday() == start.utc().Weekday() {
+					out = append(out, d)
+				}
+			} else if r.matchWeekday(d) {
+				out = append(out, d)
+			}
+		}
+
+	case Monthly:
+		first := time.Date(start.year, start.month+time.Month(offset), 1, 0, 0, 0, 0, time.UTC)
+		out = r.monthCandidates(first.Year(), first.Month(), start.day)
+
+	case Yearly:
+		// 只在 dtstart 的那個月份展開，BYDAY / BYMONTHDAY 都套在那個月裡
+		out = r.monthCandidates(start.year+offset, start.month, start.day)
+	}
+
+	return out
+}
+
+func (r *Rule) monthCandidates(year int, month time.Month, startDay int) []civilDate {
+	var out []civilDate
+	total := daysIn(year, month)
+
+	for day := 1; day <= total; day++ {
+		d := civilDate{year, month, day}
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			// 沒有指定就用 dtstart 的日期；31 號遇到小月直接跳過，不會挪到月底
+			if day == startDay {
+				out = append(out, d)
+			}
+			continue
+		}
+		if r.matchMonthDay(d) && r.matchWeekdayInMonth(d, total) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (r *Rule) matchWeekday(d civilDate) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	weekday := d.utc().Weekday()
+	for _, wd := range r.ByDay {
+		if wd.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// 月份裡的第 n 個 / 倒數第 n 個星期幾
+func (r *Rule) matchWeekdayInMonth(d civilDate, total int) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	weekday := d.utc().Weekday()
+	for _, wd := range r.ByDay {
+		if wd.Weekday != weekday {
+			continue
+		}
+		switch {
+		case wd.Ordinal == 0:
+			return true
+		case wd.Ordinal > 0 && (d.day-1)/7+1 == wd.Ordinal:
+			return true
+		case wd.Ordinal < 0 && (total-d.day)/7+1 == -wd.Ordinal:
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchMonthDay(d civilDate) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	total := daysIn(d.year, d.month)
+	for _, md := range r.ByMonthDay {
+		if md > 0 && d.day == md {
+			return true
+		}
+		if md < 0 && d.day == total+md+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// BYSETPOS：從這個週期的候選日期裡挑第 n 個（負數從後面數）
+func (r *Rule) setPos(dates []civilDate) []civilDate {
+	if len(r.BySetPos) == 0 || len(dates) == 0 {
+		return dates
+	}
+
+	picked := map[int]bool{}
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(dates) + pos
+		}
+		if i >= 0 && i < len(dates) {
+			picked[i] = true
+		}
+	}
+
+	indexes := make([]int, 0, len(picked))
+	for i := range picked {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	out := make([]civilDate, len(indexes))
+	for j, i := range indexes {
+		out[j] = dates[i]
+	}
+	return out
+}
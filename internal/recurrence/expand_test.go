@@ -0,0 +1,256 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, s string) *Rule {
+	t.Helper()
+
+	rule, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", s, err)
+	}
+	return rule
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// 用當地時間 + 時區縮寫比對，日光節約時間的 offset 錯了一眼就看得出來
+func format(times []time.Time) []string {
+	out := make([]string, len(times))
+	for i, t := range times {
+		out[i] = t.Format("2006-01-02 15:04 MST")
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOccurrences(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	taipei := mustLoad(t, "Asia/Taipei")
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		after   time.Time // zero 代表從 dtstart 之前開始
+		n       int
+		want    []string
+	}{
+		// 2026-03-08 02:00 America/New_York 撥快一小時（EST => EDT）
+		{
+			name:    "daily across spring forward keeps wall time",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 3, 7, 9, 0, 0, 0, newYork),
+			n:       3,
+			want:    []string{"2026-03-07 09:00 EST", "2026-03-08 09:00 EDT", "2026-03-09 09:00 EDT"},
+		},
+		{
+			name:    "nonexistent wall time moves past the gap",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 3, 7, 2, 30, 0, 0, newYork),
+			n:       3,
+			want:    []string{"2026-03-07 02:30 EST", "2026-03-08 03:30 EDT", "2026-03-09 02:30 EDT"},
+		},
+		{
+			name:    "weekly across spring forward",
+			rule:    "FREQ=WEEKLY;BYDAY=SU",
+			dtstart: time.Date(2026, 3, 1, 8, 0, 0, 0, newYork),
+			n:       3,
+			want:    []string{"2026-03-01 08:00 EST", "2026-03-08 08:00 EDT", "2026-03-15 08:00 EDT"},
+		},
+		// 2026-11-01 02:00 America/New_York 撥慢一小時（EDT => EST）
+		{
+			name:    "daily across fall back keeps wall time",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 10, 31, 9, 0, 0, 0, newYork),
+			n:       3,
+			want:    []string{"2026-10-31 09:00 EDT", "2026-11-01 09:00 EST", "2026-11-02 09:00 EST"},
+		},
+		{
+			name:    "monthly across both transitions",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=1",
+			dtstart: time.Date(2026, 2, 1, 18, 0, 0, 0, newYork),
+			n:       4,
+			want:    []string{"2026-02-01 18:00 EST", "2026-03-01 18:00 EST", "2026-04-01 18:00 EDT", "2026-05-01 18:00 EDT"},
+		},
+		{
+			name:    "after skips earlier occurrences",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 3, 1, 9, 0, 0, 0, newYork),
+			after:   time.Date(2026, 3, 8, 9, 0, 0, 0, newYork),
+			n:       2,
+			want:    []string{"2026-03-09 09:00 EDT", "2026-03-10 09:00 EDT"},
+		},
+
+		// COUNT / UNTIL
+		{
+			name:    "count stops expansion",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, taipei),
+			n:       10,
+			want:    []string{"2026-01-01 09:00 CST", "2026-01-02 09:00 CST", "2026-01-03 09:00 CST"},
+		},
+		{
+			name:    "count is counted from dtstart even when after is later",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, taipei),
+			after:   time.Date(2026, 1, 2, 9, 0, 0, 0, taipei),
+			n:       10,
+			want:    []string{"2026-01-03 09:00 CST"},
+		},
+		{
+			name:    "until date is inclusive in local time",
+			rule:    "FREQ=DAILY;UNTIL=20260103",
+			dtstart: time.Date(2026, 1, 1, 23, 0, 0, 0, taipei),
+			n:       10,
+			want:    []string{"2026-01-01 23:00 CST", "2026-01-02 23:00 CST", "2026-01-03 23:00 CST"},
+		},
+		{
+			name:    "until timestamp cuts off by instant",
+			rule:    "FREQ=DAILY;UNTIL=20260103T010000Z", // 台北 2026-01-03 09:00
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, taipei),
+			n:       10,
+			want:    []string{"2026-01-01 09:00 CST", "2026-01-02 09:00 CST", "2026-01-03 09:00 CST"},
+		},
+		{
+			name:    "until timestamp one second earlier",
+			rule:    "FREQ=DAILY;UNTIL=20260103T005959Z",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, taipei),
+			n:       10,
+			want:    []string{"2026-01-01 09:00 CST", "2026-01-02 09:00 CST"},
+		},
+
+		// BYxxx
+		{
+			name:    "weekly interval with several days",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			dtstart: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), // 星期一
+			n:       4,
+			want:    []string{"2026-01-05 09:00 UTC", "2026-01-07 09:00 UTC", "2026-01-19 09:00 UTC", "2026-01-21 09:00 UTC"},
+		},
+		{
+			name:    "monthly on the 31st skips short months",
+			rule:    "FREQ=MONTHLY",
+			dtstart: time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			n:       3,
+			want:    []string{"2026-01-31 09:00 UTC", "2026-03-31 09:00 UTC", "2026-05-31 09:00 UTC"},
+		},
+		{
+			name:    "last friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+			n:       3,
+			want:    []string{"2026-01-30 09:00 UTC", "2026-02-27 09:00 UTC", "2026-03-27 09:00 UTC"},
+		},
+		{
+			name:    "last weekday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+			n:       3,
+			want:    []string{"2026-01-30 09:00 UTC", "2026-02-27 09:00 UTC", "2026-03-31 09:00 UTC"},
+		},
+		{
+			name:    "dtstart not matching the rule is not an occurrence",
+			rule:    "FREQ=WEEKLY;BYDAY=FR",
+			dtstart: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), // 星期一
+			n:       2,
+			want:    []string{"2026-01-09 09:00 UTC", "2026-01-16 09:00 UTC"},
+		},
+		{
+			name:    "yearly on feb 29 only in leap years",
+			rule:    "FREQ=YEARLY",
+			dtstart: time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC),
+			n:       2,
+			want:    []string{"2028-02-29 09:00 UTC", "2032-02-29 09:00 UTC"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.after
+			if after.IsZero() {
+				after = tt.dtstart.Add(-time.Second)
+			}
+
+			got := format(mustParse(t, tt.rule).Occurrences(tt.dtstart, after, tt.n))
+			if !equal(got, tt.want) {
+				t.Fatalf("Occurrences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrencesKeepElapsedHoursAcrossDST(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	rule := mustParse(t, "FREQ=DAILY")
+
+	// 牆上時間固定，所以撥快那天只隔 23 小時，撥慢那天隔 25 小時
+	spring := rule.Occurrences(time.Date(2026, 3, 7, 9, 0, 0, 0, newYork), time.Time{}, 2)
+	if got := spring[1].Sub(spring[0]); got != 23*time.Hour {
+		t.Fatalf("spring forward gap = %v, want 23h", got)
+	}
+
+	fall := rule.Occurrences(time.Date(2026, 10, 31, 9, 0, 0, 0, newYork), time.Time{}, 2)
+	if got := fall[1].Sub(fall[0]); got != 25*time.Hour {
+		t.Fatalf("fall back gap = %v, want 25h", got)
+	}
+}
+
+func TestNext(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	dtstart := time.Date(2026, 3, 6, 9, 0, 0, 0, newYork)
+
+	rule := mustParse(t, "FREQ=DAILY;COUNT=3")
+
+	next, ok := rule.Next(dtstart, dtstart)
+	if !ok || next.Format("2006-01-02 15:04 MST") != "2026-03-07 09:00 EST" {
+		t.Fatalf("Next() = %v, %v", next, ok)
+	}
+
+	next, ok = rule.Next(dtstart, next)
+	if !ok || next.Format("2006-01-02 15:04 MST") != "2026-03-08 09:00 EDT" {
+		t.Fatalf("Next() across spring forward = %v, %v", next, ok)
+	}
+
+	// COUNT 用完了
+	if next, ok := rule.Next(dtstart, next); ok {
+		t.Fatalf("Next() after last occurrence = %v, want none", next)
+	}
+
+	// UNTIL 已經過了
+	until := mustParse(t, "FREQ=DAILY;UNTIL=20260307")
+	if next, ok := until.Next(dtstart, time.Date(2026, 3, 7, 9, 0, 0, 0, newYork)); ok {
+		t.Fatalf("Next() after UNTIL = %v, want none", next)
+	}
+}
+
+func TestOccurrencesNeverMatchingRuleTerminates(t *testing.T) {
+	// 二月沒有 30 號，展開到 maxPeriods 就停，不會卡住
+	rule := mustParse(t, "FREQ=YEARLY;BYMONTHDAY=30")
+	got := rule.Occurrences(time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC), time.Time{}, 1)
+	if len(got) != 0 {
+		t.Fatalf("Occurrences() = %v, want none", got)
+	}
+}
@@ -0,0 +1,285 @@
+// Package recurrence 解析、展開 iCalendar RRULE 的子集（FREQ、INTERVAL、BYDAY、BYMONTHDAY、BYSETPOS、COUNT、UNTIL），
+// 用來產生週期性 todo 的下一次日期。
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// 容器裡不一定有系統時區資料，直接嵌進執行檔，LoadLocation 才不會失敗
+	_ "time/tzdata"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// BYDAY 的一個值，例如 MO、2TU（第二個星期二）、-1FR（最後一個星期五）
+// Ordinal 為 0 表示「每一個」
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func (w WeekdayNum) String() string {
+	code := strings.ToUpper(w.Weekday.String()[:2])
+	if w.Ordinal == 0 {
+		return code
+	}
+	return strconv.Itoa(w.Ordinal) + code
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	BySetPos   []int
+	Count      int       // 0 表示不限次數
+	Until      time.Time // zero 表示沒有結束時間
+
+	// UNTIL 只給日期（20260131）時，比的是當地日期而不是時間點
+	untilIsDate bool
+}
+
+const (
+	untilLayoutUTC   = "20060102T150405Z"
+	untilLayoutLocal = "20060102T150405"
+	untilLayoutDate  = "20060102"
+)
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+}
+
+/*
+Parse 解析 RRULE 字串，例如：
+
+	FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE
+	FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1   // 每個月最後一個工作天
+	RRULE:FREQ=DAILY;COUNT=10
+
+不認得的欄位直接回錯誤，不默默忽略，免得使用者以為規則有生效
+*/
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+	if s == "" {
+		return nil, invalid("empty rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, invalid("malformed part %q", part)
+		}
+		if seen[key] {
+			return nil, invalid("duplicate %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = f
+			default:
+				err = invalid("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parsePositive(key, value)
+		case "COUNT":
+			rule.Count, err = parsePositive(key, value)
+		case "UNTIL":
+			err = rule.parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(key, value, 31)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(key, value, 366)
+		default:
+			err = invalid("unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Freq == "" {
+		return invalid("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return invalid("COUNT and UNTIL cannot be used together")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return invalid("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	for _, wd := range r.ByDay {
+		if wd.Ordinal == 0 {
+			continue
+		}
+		// 「第 n 個星期幾」只有在以月為單位展開時才有意義
+		if r.Freq != Monthly && r.Freq != Yearly {
+			return invalid("BYDAY ordinals require FREQ=MONTHLY or FREQ=YEARLY")
+		}
+		if wd.Ordinal < -5 || wd.Ordinal > 5 {
+			return invalid("BYDAY ordinal %d out of range", wd.Ordinal)
+		}
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		return invalid("BYSETPOS requires BYDAY or BYMONTHDAY")
+	}
+	return nil
+}
+
+func parsePositive(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, invalid("%s must be a positive integer", key)
+	}
+	return n, nil
+}
+
+// 逗號分隔的整數，不能是 0，絕對值不能超過 limit（負數表示從後面數）
+func parseIntList(key, value string, limit int) ([]int, error) {
+	var out []int
+	for _, raw := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n == 0 || n < -limit || n > limit {
+			return nil, invalid("%s value %q out of range", key, raw)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var out []WeekdayNum
+	for _, raw := range strings.Split(value, ",") {
+		if len(raw) < 2 {
+			return nil, invalid("BYDAY value %q", raw)
+		}
+		code := raw[len(raw)-2:]
+		weekday, ok := weekdayCodes[code]
+		if !ok {
+			return nil, invalid("BYDAY value %q", raw)
+		}
+
+		wd := WeekdayNum{Weekday: weekday}
+		if prefix := raw[:len(raw)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 {
+				return nil, invalid("BYDAY value %q", raw)
+			}
+			wd.Ordinal = n
+		}
+		out = append(out, wd)
+	}
+	return out, nil
+}
+
+func (r *Rule) parseUntil(value string) error {
+	if t, err := time.Parse(untilLayoutUTC, value); err == nil {
+		r.Until = t
+		return nil
+	}
+	// 沒有 Z 的本地時間，這裡一律當 UTC 處理
+	if t, err := time.Parse(untilLayoutLocal, value); err == nil {
+		r.Until = t
+		return nil
+	}
+	if t, err := time.Parse(untilLayoutDate, value); err == nil {
+		r.Until = t
+		r.untilIsDate = true
+		return nil
+	}
+	return invalid("UNTIL %q is not a valid date", value)
+}
+
+// 正規化後的字串，存進資料庫用；Parse(r.String()) 會得到相同的規則
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.untilIsDate {
+			parts = append(parts, "UNTIL="+r.Until.Format(untilLayoutDate))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayoutUTC))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strconv.Itoa(v)
+	}
+	return strings.Join(out, ",")
+}
+
+// 時區名稱（例如 Asia/Taipei），空字串視為 UTC
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
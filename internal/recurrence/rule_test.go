@@ -0,0 +1,120 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseNormalizes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;interval=2;byday=mo,we", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{"  FREQ=DAILY;INTERVAL=1  ", "FREQ=DAILY"}, // INTERVAL=1 是預設值，不寫出來
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+		{"FREQ=MONTHLY;BYDAY=2TU", "FREQ=MONTHLY;BYDAY=2TU"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15,-1", "FREQ=MONTHLY;BYMONTHDAY=1,15,-1"},
+		{"FREQ=YEARLY;BYDAY=4TH", "FREQ=YEARLY;BYDAY=4TH"},
+		{"FREQ=DAILY;COUNT=10", "FREQ=DAILY;COUNT=10"},
+		{"FREQ=DAILY;UNTIL=20260131", "FREQ=DAILY;UNTIL=20260131"},
+		{"FREQ=DAILY;UNTIL=20260131T235959Z", "FREQ=DAILY;UNTIL=20260131T235959Z"},
+		{"FREQ=DAILY;UNTIL=20260131T120000", "FREQ=DAILY;UNTIL=20260131T120000Z"}, // 沒有 Z 的當 UTC
+		{"FREQ=WEEKLY;;BYDAY=SU", "FREQ=WEEKLY;BYDAY=SU"},                         // 空的片段略過
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			rule, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.in, err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Fatalf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+			}
+
+			// String() 的結果再 Parse 一次要得到一樣的規則
+			again, err := Parse(rule.String())
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", rule.String(), err)
+			}
+			if again.String() != rule.String() {
+				t.Fatalf("round trip = %q, want %q", again.String(), rule.String())
+			}
+		})
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	rule, err := Parse("FREQ=MONTHLY;INTERVAL=3;BYDAY=2TU,-1FR;COUNT=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rule.Freq != Monthly || rule.Interval != 3 || rule.Count != 4 {
+		t.Fatalf("rule = %+v", rule)
+	}
+	want := []WeekdayNum{{Ordinal: 2, Weekday: time.Tuesday}, {Ordinal: -1, Weekday: time.Friday}}
+	if len(rule.ByDay) != len(want) || rule.ByDay[0] != want[0] || rule.ByDay[1] != want[1] {
+		t.Fatalf("ByDay = %v, want %v", rule.ByDay, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",             // 沒有 FREQ
+		"FREQ=HOURLY",            // 不支援的 FREQ
+		"FREQ",                   // 沒有 =
+		"FREQ=",                  // 沒有值
+		"FREQ=DAILY;FREQ=WEEKLY", // 重複
+		"FREQ=DAILY;WKST=SU",     // 不支援的欄位不能默默忽略
+		"FREQ=DAILY;INTERVAL=0",  // 要是正整數
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=x",
+		"FREQ=DAILY;COUNT=3;UNTIL=20260101", // COUNT 跟 UNTIL 不能同時用
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=WEEKLY;BYDAY=2MO", // 序數只能用在 MONTHLY / YEARLY
+		"FREQ=DAILY;BYDAY=-1FR",
+		"FREQ=MONTHLY;BYDAY=6MO", // 一個月最多 5 個
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=M",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=MONTHLY;BYSETPOS=1", // BYSETPOS 要搭配 BYDAY 或 BYMONTHDAY
+		"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=0",
+		"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=367",
+	}
+
+	for _, in := range tests {
+		t.Run(in, func(t *testing.T) {
+			rule, err := Parse(in)
+			if err == nil {
+				t.Fatalf("Parse(%q) = %q, want error", in, rule.String())
+			}
+			if !errors.Is(err, ErrInvalidRule) {
+				t.Fatalf("Parse(%q) error = %v, want ErrInvalidRule", in, err)
+			}
+		})
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	if loc, err := LoadLocation(""); err != nil || loc != time.UTC {
+		t.Fatalf("LoadLocation(\"\") = %v, %v; want UTC", loc, err)
+	}
+	if loc, err := LoadLocation("America/New_York"); err != nil || loc.String() != "America/New_York" {
+		t.Fatalf("LoadLocation(America/New_York) = %v, %v", loc, err)
+	}
+	if _, err := LoadLocation("Mars/Olympus_Mons"); err == nil {
+		t.Fatal("LoadLocation(Mars/Olympus_Mons) error = nil")
+	}
+}
@@ -7,13 +7,15 @@ import (
 	"time"
 
 	"todo_api/internal/models"
+	"todo_api/internal/pagination"
 	"todo_api/internal/utils"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // 查文章列表資料。 /articles?page=1&pageSize=5&difficulty=beginner&tag=beginner-friendly,deep-dive
-func GetArticles(pool *pgxpool.Pool, page int, pageSize int, tag string, difficulty string) (*models.ArticleListResponse, error) {
+// cursor 模式： /articles?paging=cursor&pageSize=5 之後帶 cursor=nextCursor
+func GetArticles(pool *pgxpool.Pool, params pagination.Params, tag string, difficulty string) (*models.ArticleListResponse, error) {
 	var ctx context.Context
 	var cancel context.CancelFunc
 
@@ -22,11 +24,6 @@ func GetArticles(pool *pgxpool.Pool, page int, pageSize int, tag string, difficu
 
 	utils.PerformOperation(ctx)
 
-	// 從第幾筆資料開始取
-	// page=1, pageSize=5 -> offset=0（從第1筆開始）
-	// page=2, pageSize=5 -> offset=5（從第6筆開始）
-	offset := (page - 1) * pageSize
-
 	conditions := []string{"a.status = 'published'"}
 
 	args := []interface{}{}
@@ -92,28 +89,74 @@ func GetArticles(pool *pgxpool.Pool, page int, pageSize int, tag string, difficu
 		// strings.Join(placeholders, ", ") => "$2, $3"
 	}
 
-	// 把多個條件組成 WHERE 子句
-	whereClause := strings.Join(conditions, " AND ")
+	response := &models.ArticleListResponse{
+		Mode:     params.Mode,
+		Page:     params.Page, // 第幾頁
+		PageSize: params.PageSize,
+	}
 
-	// =========================
-	// 1. 先查總筆數
-	// =========================
-	var totalCount int
-	countQuery := fmt.Sprintf(`
-		SELECT COUNT(*)
-		FROM articles a
-		WHERE %s
-	`, whereClause)
+	// cursor 模式的排序：發布時間新到舊，published_at 可能是 NULL，用 created_at 補上；最後用 id 當 tie-breaker
+	keyset := pagination.Keyset{Columns: []string{"COALESCE(a.published_at, a.created_at)", "a.id"}, Desc: true}
+	const articleSort = "published_at:desc"
+
+	// offset 模式維持原本的排序（published_at 是 NULL 的排最前面），跟改版前的頁碼對得上
+	orderClause := "a.published_at DESC, a.created_at DESC"
+	var pagingClause string
+	if params.IsCursor() {
+		orderClause = keyset.OrderBy(params.Backward())
+		if err := params.CheckSort(articleSort); err != nil {
+			return nil, err
+		}
+		// 從 cursor 那一筆的後面（或前面）開始：(發布時間, id) < ($n, $n+1)
+		if params.Cursor != nil {
+			conditions = append(conditions, keyset.Where(params.Backward(), argIndex))
+			args = append(args, params.Cursor.Time, params.Cursor.ID)
+			argIndex += 2
+		}
+		// 多拿一筆，用來判斷還有沒有下一頁
+		pagingClause = fmt.Sprintf("LIMIT $%d", argIndex)
+		args = append(args, params.PageSize+1)
+	} else {
+		// =========================
+		// 1. 先查總筆數（cursor 模式不需要，省掉一次 COUNT）
+		// =========================
+		countQuery := fmt.Sprintf(`
+			SELECT COUNT(*)
+			FROM articles a
+			WHERE %s
+		`, strings.Join(conditions, " AND "))
+
+		err := pool.QueryRow(ctx, countQuery, args...).Scan(&response.TotalCount)
+		if err != nil {
+			return nil, fmt.Errorf("查詢 articles 總數失敗: %w", err)
+		}
 
-	err := pool.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
-	if err != nil {
-		return nil, fmt.Errorf("查詢 articles 總數失敗: %w", err)
+		// 向上取整，算總頁數 => 每一頁顯示多少筆 (pageSize) 跟 知道總筆數(totalCount)，可以知道總頁數，
+		// 根據不同 whereClause 條件，總筆數都不同，所以總頁數也會不同。
+		response.TotalPages = (response.TotalCount + params.PageSize - 1) / params.PageSize
+		if response.TotalPages == 0 {
+			response.TotalPages = 1
+		}
+
+		// 從第幾筆資料開始取
+		// page=1, pageSize=5 -> offset=0（從第1筆開始）
+		// page=2, pageSize=5 -> offset=5（從第6筆開始）
+		// 例子，假設：
+		// args 原先是 [difficulty]
+		// pageSize = 5
+		// offset = 0
+		// args = []interface{}{"advanced", 5, 0}
+		pagingClause = fmt.Sprintf("LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+		args = append(args, params.PageSize, params.Offset())
 	}
 
+	// 把多個條件組成 WHERE 子句
+	whereClause := strings.Join(conditions, " AND ")
+
 	// =========================
 	// 2. 再查當前頁資料
-	// 先跳過前 OFFSET 筆的資料（也就是「從第幾筆開始」查
-	// 再取最多 LIMIT 筆資料（也就是「最多查幾筆」
+	// offset 模式：先跳過前 OFFSET 筆的資料，再取最多 LIMIT 筆
+	// cursor 模式：WHERE 已經限定從 cursor 之後開始，直接取 LIMIT 筆
 	// =========================
 	listQuery := fmt.Sprintf(`
 		SELECT
@@ -127,24 +170,17 @@ func GetArticles(pool *pgxpool.Pool, page int, pageSize int, tag string, difficu
 			a.view_count,
 			u.id AS author_id,
 			u.email AS author_email,
-			u.image_url AS author_image_url
+			u.image_url AS author_image_url,
+			COALESCE(a.published_at, a.created_at) AS sort_time
 		FROM articles a
 		JOIN users u ON a.author_id = u.id
 		WHERE %s
-		ORDER BY a.published_at DESC, a.created_at DESC
-		LIMIT $%d OFFSET $%d
-	`, whereClause, argIndex, argIndex+1)
-
-	// 例子，假設：
-	// args 原先是 [difficulty]
-	// pageSize = 5
-	// offset = 0
-	// listArgs := append(args, pageSize, offset)
-	// listArgs = []interface{}{"advanced", 5, 0}
-	// listArgs: [advanced 5 0]
-	listArgs := append(args, pageSize, offset)
-	// 再把 listArgs 傳給 Query 執行 SQL 查詢
-	rows, err := pool.Query(ctx, listQuery, listArgs...)
+		ORDER BY %s
+		%s
+	`, whereClause, orderClause, pagingClause)
+
+	// 再把 args 傳給 Query 執行 SQL 查詢
+	rows, err := pool.Query(ctx, listQuery, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("查詢 articles 失敗: %w", err)
@@ -152,9 +188,12 @@ func GetArticles(pool *pgxpool.Pool, page int, pageSize int, tag string, difficu
 	defer rows.Close()
 
 	var articles []models.ArticleListItem
+	// sort_time 不回傳給前端，只拿來產生 cursor
+	var sortTimes []time.Time
 
 	for rows.Next() {
 		var article models.ArticleListItem
+		var sortTime time.Time
 
 		if err := rows.Scan(
 			&article.ID,
@@ -168,32 +207,42 @@ func GetArticles(pool *pgxpool.Pool, page int, pageSize int, tag string, difficu
 			&article.AuthorID,
 			&article.AuthorEmail,
 			&article.AuthorImage,
+			&sortTime,
 		); err != nil {
 			return nil, fmt.Errorf("讀取 article 失敗: %w", err)
 		}
 
 		articles = append(articles, article)
+		sortTimes = append(sortTimes, sortTime)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("讀取 articles 失敗: %w", err)
 	}
 
-	// 向上取整，算總頁數 => 每一頁顯示多少筆 (pageSize) 跟 知道總筆數(totalCount)，可以知道總頁數，
-	// 根據不同 whereClause 條件，總筆數都不同，所以總頁數也會不同。
-	totalPages := (totalCount + pageSize - 1) / pageSize
-	if totalPages == 0 {
-		totalPages = 1
-	}
+	if params.IsCursor() {
+		hasNext, hasPrev := params.Bounds(len(articles))
+		if len(articles) > params.PageSize {
+			articles = articles[:params.PageSize]
+			sortTimes = sortTimes[:params.PageSize]
+		}
+		if params.Backward() {
+			pagination.Reverse(articles)
+			pagination.Reverse(sortTimes)
+		}
 
-	response := &models.ArticleListResponse{
-		Items:      articles,
-		Page:       page, // 第幾頁
-		PageSize:   pageSize,
-		TotalCount: totalCount,
-		TotalPages: totalPages,
+		if len(articles) > 0 {
+			last := len(articles) - 1
+			if hasNext {
+				response.NextCursor = params.Codec.Encode(pagination.Cursor{Sort: articleSort, Time: sortTimes[last], ID: articles[last].ID})
+			}
+			if hasPrev {
+				response.PrevCursor = params.Codec.Encode(pagination.Cursor{Sort: articleSort, Time: sortTimes[0], ID: articles[0].ID, Backward: true})
+			}
+		}
 	}
 
+	response.Items = articles
 	return response, nil
 }
 
@@ -0,0 +1,27 @@
+/*
+檔案內容（頭像、todo 附件）的存放位置藏在 BlobStorage 介面後面
+資料庫只記 key 跟中繼資料，正式環境放 GCS，本機開發放在硬碟上的資料夾
+*/
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+type BlobOptions struct {
+	ContentType  string
+	CacheControl string
+}
+
+type BlobStorage interface {
+	// 寫入（已經存在就覆蓋）；寫到一半失敗不會留下不完整的檔案
+	Put(ctx context.Context, key string, body io.Reader, opts BlobOptions) error
+	// 不存在回傳 ErrBlobNotFound，呼叫端要負責 Close
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// 不存在回傳 ErrBlobNotFound
+	Delete(ctx context.Context, key string) error
+}
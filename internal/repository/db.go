@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// *pgxpool.Pool 跟 pgx.Tx 都有這三個方法
+// repository 函式收 DBTX 的話，service 可以決定要直接用連線池，還是放在同一個 transaction 裡執行
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// 帶著 version 更新，但資料庫裡的 version 已經被別人改過了（handler 回 412 Precondition Failed）
+var ErrVersionConflict = errors.New("resource was modified by someone else")
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+/*
+只要是下面情況都可以放這裡。
+1. 操作 email_verification_tokens
+2. 簽章、期限、單次使用的判斷在 service.EmailVerificationService，這裡只負責 SQL
+
+token 一律用 utils.HashToken 算過的值查詢
+*/
+
+const emailVerificationTokenColumns = `id, user_id, email, expires_at, used_at, created_at`
+
+func scanEmailVerificationToken(row pgx.Row, token *models.EmailVerificationToken) error {
+	return row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Email,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+}
+
+func CreateEmailVerificationToken(ctx context.Context, db DBTX, userID string, email string, tokenHash string, expiresAt time.Time) (*models.EmailVerificationToken, error) {
+	query := `
+		INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + emailVerificationTokenColumns
+
+	var created models.EmailVerificationToken
+	if err := scanEmailVerificationToken(db.QueryRow(ctx, query, userID, email, tokenHash, expiresAt), &created); err != nil {
+		return nil, fmt.Errorf("新增 email 驗證 token 失敗: %w", err)
+	}
+
+	return &created, nil
+}
+
+// FOR UPDATE 鎖住這筆 token，同一個連結同時點兩次只有一個會成功
+// 一定要在 transaction 裡呼叫才有意義；找不到回傳 pgx.ErrNoRows
+func GetEmailVerificationTokenByHashForUpdate(ctx context.Context, db DBTX, tokenHash string) (*models.EmailVerificationToken, error) {
+	query := `
+		SELECT ` + emailVerificationTokenColumns + `
+		FROM email_verification_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	var token models.EmailVerificationToken
+	if err := scanEmailVerificationToken(db.QueryRow(ctx, query, tokenHash), &token); err != nil {
+		return nil, fmt.Errorf("查詢 email 驗證 token 失敗: %w", err)
+	}
+
+	return &token, nil
+}
+
+// 使用者最近一次寄驗證信的時間，重寄的冷卻時間用；從來沒寄過回傳 nil
+func GetLatestEmailVerificationSentAt(ctx context.Context, db DBTX, userID string) (*time.Time, error) {
+	var sentAt *time.Time
+	err := db.QueryRow(ctx, `
+		SELECT MAX(created_at)
+		FROM email_verification_tokens
+		WHERE user_id = $1
+	`, userID).Scan(&sentAt)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 email 驗證 token 失敗: %w", err)
+	}
+	return sentAt, nil
+}
+
+// 驗證成功或重寄時呼叫：使用者所有還沒用過的 token 都標記用過，只有最新寄出的那封信有效
+func MarkUserEmailVerificationTokensUsed(ctx context.Context, db DBTX, userID string) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("更新 email 驗證 token 失敗: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// 過期的 token 已經不能用了，直接刪掉
+func DeleteExpiredEmailVerificationTokens(ctx context.Context, db DBTX, now time.Time) (int64, error) {
+	tag, err := db.Exec(ctx, `DELETE FROM email_verification_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("刪除過期的 email 驗證 token 失敗: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+type GCSBlobStorage struct {
+	Storage    *storage.Client
+	BucketName string
+}
+
+func NewGCSBlobStorage(storageClient *storage.Client, bucketName string) *GCSBlobStorage {
+	return &GCSBlobStorage{
+		Storage:    storageClient,
+		BucketName: bucketName,
+	}
+}
+
+func (s *GCSBlobStorage) object(key string) *storage.ObjectHandle {
+	return s.Storage.Bucket(s.BucketName).Object(key)
+}
+
+func (s *GCSBlobStorage) Put(ctx context.Context, key string, body io.Reader, opts BlobOptions) error {
+	// ctx 被取消時 GCS 會放棄這次上傳，不會留下寫一半的 object
+	writeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	writer := s.object(key).NewWriter(writeCtx)
+	writer.ContentType = opts.ContentType
+	writer.CacheControl = opts.CacheControl
+
+	if _, err := io.Copy(writer, body); err != nil {
+		cancel()
+		_ = writer.Close()
+		return fmt.Errorf("寫入 GCS 失敗: %w", err)
+	}
+
+	// 一定要 Close，GCS 寫入才算真正完成
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("寫入 GCS 失敗: %w", err)
+	}
+	return nil
+}
+
+func (s *GCSBlobStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	reader, err := s.object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("讀取 GCS 失敗: %w", err)
+	}
+	return reader, nil
+}
+
+func (s *GCSBlobStorage) Delete(ctx context.Context, key string) error {
+	if err := s.object(key).Delete(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return ErrBlobNotFound
+		}
+		return fmt.Errorf("刪除 GCS object 失敗: %w", err)
+	}
+	return nil
+}
+
+// 公開讀取的網址（頭像用）
+func (s *GCSBlobStorage) URL(key string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", s.BucketName, key)
+}
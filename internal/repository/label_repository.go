@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrLabelSlugTaken = errors.New("a label with the same name already exists")
+	// 要掛到 todo 上的標籤有的不存在（或不是自己的）
+	ErrUnknownLabel = errors.New("label not found")
+)
+
+// todo 標籤存在 tags 表，user_id 是擁有者；user_id 是 NULL 的是文章用的全站標籤，這裡都不會碰到
+const labelColumns = `t.id, t.name, t.slug, t.color, t.created_at, t.updated_at`
+
+// 不在垃圾桶裡的 todo 才算
+const labelTodoCount = `(
+	SELECT COUNT(*)
+	FROM todo_tags tt
+	JOIN todos td ON td.id = tt.todo_id
+	WHERE tt.tag_id = t.id AND td.deleted_at IS NULL
+)`
+
+func scanLabel(row pgx.Row, label *models.Label) error {
+	return row.Scan(
+		&label.ID,
+		&label.Name,
+		&label.Slug,
+		&label.Color,
+		&label.CreatedAt,
+		&label.UpdatedAt,
+		&label.TodoCount,
+	)
+}
+
+// uq_tags_user_slug 擋下來的就是同一個使用者已經有同名（同 slug）的標籤
+func isLabelSlugConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "uq_tags_user_slug"
+}
+
+// 依名稱排序
+func GetLabels(ctx context.Context, db DBTX, userID string) ([]models.Label, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+labelColumns+`, `+labelTodoCount+`
+		FROM tags t
+		WHERE t.user_id = $1
+		ORDER BY t.name, t.id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢標籤失敗: %w", err)
+	}
+	defer rows.Close()
+
+	labels := []models.Label{}
+	for rows.Next() {
+		var label models.Label
+		if err := scanLabel(rows, &label); err != nil {
+			return nil, fmt.Errorf("讀取標籤失敗: %w", err)
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
+
+// 鎖住自己的一個標籤準備改名 / 合併 / 刪除；不存在回傳 pgx.ErrNoRows
+func GetLabelForUpdate(ctx context.Context, db DBTX, userID string, id string) (*models.Label, error) {
+	var label models.Label
+	err := scanLabel(db.QueryRow(ctx, `
+		SELECT `+labelColumns+`, `+labelTodoCount+`
+		FROM tags t
+		WHERE t.id = $1 AND t.user_id = $2
+		FOR UPDATE
+	`, id, userID), &label)
+	if err != nil {
+		return nil, fmt.Errorf("查詢標籤失敗: %w", err)
+	}
+	return &label, nil
+}
+
+// slug 由名稱產生；同一個使用者已經有同 slug 的標籤回傳 ErrLabelSlugTaken
+func CreateLabel(ctx context.Context, db DBTX, userID string, name string, color string) (*models.Label, error) {
+	var label models.Label
+	err := scanLabel(db.QueryRow(ctx, `
+		INSERT INTO tags AS t (user_id, name, slug, color)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+labelColumns+`, 0
+	`, userID, name, utils.Slugify(name), color), &label)
+	if err != nil {
+		if isLabelSlugConflict(err) {
+			return nil, ErrLabelSlugTaken
+		}
+		return nil, fmt.Errorf("新增標籤失敗: %w", err)
+	}
+	return &label, nil
+}
+
+/*
+UpdateLabel 改名（slug 跟著變）或換顏色，existing 是 GetLabelForUpdate 鎖住的現有資料
+
+todo 的內容包含標籤，所以用了這個標籤的 todo 都會一起更新 updated_at（version +1、離線同步也會重新拿到）
+新名稱跟另一個標籤撞名回傳 ErrLabelSlugTaken，要合併請用 MergeLabels
+*/
+func UpdateLabel(ctx context.Context, db DBTX, existing *models.Label, name string, color string) (*models.Label, error) {
+	updates := map[string]any{}
+	if name != existing.Name {
+		updates["name"] = name
+		updates["slug"] = utils.Slugify(name)
+	}
+	if color != existing.Color {
+		updates["color"] = color
+	}
+	if len(updates) == 0 {
+		return existing, nil
+	}
+	updates["updated_at"] = time.Now()
+
+	query, args, err := buildUpdate("tags AS t", []string{"name", "slug", "color", "updated_at"}, updates, nil,
+		"t.id = $1",
+		[]any{existing.ID},
+		labelColumns+", "+labelTodoCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated models.Label
+	if err := scanLabel(db.QueryRow(ctx, query, args...), &updated); err != nil {
+		if isLabelSlugConflict(err) {
+			return nil, ErrLabelSlugTaken
+		}
+		return nil, fmt.Errorf("修改標籤失敗: %w", err)
+	}
+
+	if err := touchLabelTodos(ctx, db, existing.ID); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+/*
+MergeLabels 把 source 併進 target：用了 source 的 todo 都改成用 target（已經兩個都有的就只留 target），再刪掉 source
+兩個標籤都要先用 GetLabelForUpdate 鎖住；受影響的 todo 都會更新 updated_at
+*/
+func MergeLabels(ctx context.Context, db DBTX, source *models.Label, target *models.Label) error {
+	if err := touchLabelTodos(ctx, db, source.ID); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(ctx, `
+		INSERT INTO todo_tags (todo_id, tag_id)
+		SELECT todo_id, $2
+		FROM todo_tags
+		WHERE tag_id = $1
+		ON CONFLICT (todo_id, tag_id) DO NOTHING
+	`, source.ID, target.ID); err != nil {
+		return fmt.Errorf("合併標籤失敗: %w", err)
+	}
+
+	if _, err := db.Exec(ctx, `DELETE FROM tags WHERE id = $1`, source.ID); err != nil {
+		return fmt.Errorf("刪除合併掉的標籤失敗: %w", err)
+	}
+	return nil
+}
+
+// 關聯由 todo_tags 的 ON DELETE CASCADE 一起刪掉；用了這個標籤的 todo 都會更新 updated_at
+func DeleteLabel(ctx context.Context, db DBTX, label *models.Label) error {
+	if err := touchLabelTodos(ctx, db, label.ID); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, `DELETE FROM tags WHERE id = $1`, label.ID); err != nil {
+		return fmt.Errorf("刪除標籤失敗: %w", err)
+	}
+	return nil
+}
+
+// 標籤改名、合併、刪除時，用了它的 todo 內容也跟著變了；跟 TouchTodo 一樣靠 updated_at 讓 version +1
+func touchLabelTodos(ctx context.Context, db DBTX, labelID string) error {
+	if _, err := db.Exec(ctx, `
+		UPDATE todos
+		SET updated_at = NOW()
+		WHERE id IN (SELECT todo_id FROM todo_tags WHERE tag_id = $1)
+	`, labelID); err != nil {
+		return fmt.Errorf("更新標籤的 todo 失敗: %w", err)
+	}
+	return nil
+}
+
+// 依名稱找自己的標籤，沒有的就建立（POST /todos/quick 的 #標籤），回傳的順序跟 names 一樣、去掉重複
+func EnsureLabels(ctx context.Context, db DBTX, userID string, names []string) ([]models.TodoLabel, error) {
+	var slugs, labelNames []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+		labelNames = append(labelNames, name)
+	}
+	if len(slugs) == 0 {
+		return []models.TodoLabel{}, nil
+	}
+
+	if _, err := db.Exec(ctx, `
+		INSERT INTO tags (user_id, name, slug)
+		SELECT $1, n.name, n.slug
+		FROM unnest($2::text[], $3::text[]) AS n(name, slug)
+		ON CONFLICT (user_id, slug) WHERE user_id IS NOT NULL DO NOTHING
+	`, userID, labelNames, slugs); err != nil {
+		return nil, fmt.Errorf("新增標籤失敗: %w", err)
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT t.id, t.name, t.slug, t.color
+		FROM tags t
+		JOIN unnest($2::text[]) WITH ORDINALITY AS s(slug, ord) ON s.slug = t.slug
+		WHERE t.user_id = $1
+		ORDER BY s.ord
+	`, userID, slugs)
+	if err != nil {
+		return nil, fmt.Errorf("查詢標籤失敗: %w", err)
+	}
+	return collectTodoLabels(rows)
+}
+
+func collectTodoLabels(rows pgx.Rows) ([]models.TodoLabel, error) {
+	defer rows.Close()
+
+	labels := []models.TodoLabel{}
+	for rows.Next() {
+		var label models.TodoLabel
+		if err := rows.Scan(&label.ID, &label.Name, &label.Slug, &label.Color); err != nil {
+			return nil, fmt.Errorf("讀取標籤失敗: %w", err)
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
+
+/*
+AddTodoLabels 把自己的標籤掛到 todo 上，已經有的不重複；呼叫端要先鎖住 todo 確認是自己的
+labelIDs 裡有不存在或別人的標籤回傳 ErrUnknownLabel，整批都不會掛上去
+*/
+func AddTodoLabels(ctx context.Context, db DBTX, userID string, todoID int, labelIDs []string) error {
+	var found int
+	if err := db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM tags WHERE id = ANY($1::uuid[]) AND user_id = $2`,
+		labelIDs, userID,
+	).Scan(&found); err != nil {
+		return fmt.Errorf("查詢標籤失敗: %w", err)
+	}
+	if found != countDistinct(labelIDs) {
+		return ErrUnknownLabel
+	}
+
+	cmdTag, err := db.Exec(ctx, `
+		INSERT INTO todo_tags (todo_id, tag_id)
+		SELECT $1, id
+		FROM tags
+		WHERE id = ANY($2::uuid[]) AND user_id = $3
+		ON CONFLICT (todo_id, tag_id) DO NOTHING
+	`, todoID, labelIDs, userID)
+	if err != nil {
+		return fmt.Errorf("新增 todo 標籤失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() > 0 {
+		return TouchTodo(ctx, db, todoID)
+	}
+	return nil
+}
+
+func countDistinct(values []string) int {
+	seen := map[string]bool{}
+	for _, v := range values {
+		seen[v] = true
+	}
+	return len(seen)
+}
+
+// todo 上沒有這個標籤回傳 pgx.ErrNoRows；呼叫端要先鎖住 todo 確認是自己的
+func RemoveTodoLabel(ctx context.Context, db DBTX, todoID int, labelID string) error {
+	cmdTag, err := db.Exec(ctx, `DELETE FROM todo_tags WHERE todo_id = $1 AND tag_id = $2`, todoID, labelID)
+	if err != nil {
+		return fmt.Errorf("移除 todo 標籤失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return TouchTodo(ctx, db, todoID)
+}
+
+// 一次查多個 todo 的標籤，依名稱排序；沒有標籤的 todo 不會出現在 map 裡
+func GetTodoLabels(ctx context.Context, db DBTX, todoIDs []int) (map[int][]models.TodoLabel, error) {
+	result := map[int][]models.TodoLabel{}
+	if len(todoIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT tt.todo_id, t.id, t.name, t.slug, t.color
+		FROM todo_tags tt
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.todo_id = ANY($1)
+		ORDER BY t.name, t.id
+	`, todoIDs)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 todo 標籤失敗: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		var label models.TodoLabel
+		if err := rows.Scan(&todoID, &label.ID, &label.Name, &label.Slug, &label.Color); err != nil {
+			return nil, fmt.Errorf("讀取 todo 標籤失敗: %w", err)
+		}
+		result[todoID] = append(result[todoID], label)
+	}
+	return result, rows.Err()
+}
+
+// 把標籤填進 todos 的 Labels
+func attachTodoLabels(ctx context.Context, db DBTX, todos []models.Todo) error {
+	ids := make([]int, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+	}
+
+	labels, err := GetTodoLabels(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		todos[i].Labels = labels[todos[i].ID]
+	}
+	return nil
+}
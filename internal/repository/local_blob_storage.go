@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// 本機開發用：檔案放在 Root 資料夾底下，key 就是相對路徑
+type LocalBlobStorage struct {
+	Root string
+}
+
+func NewLocalBlobStorage(root string) (*LocalBlobStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("建立 blob 資料夾失敗: %w", err)
+	}
+	return &LocalBlobStorage{Root: root}, nil
+}
+
+// key 不能跳出 Root（例如 ../../etc/passwd）
+func (s *LocalBlobStorage) path(key string) (string, error) {
+	if key == "" || !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalBlobStorage) Put(ctx context.Context, key string, body io.Reader, opts BlobOptions) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("建立 blob 資料夾失敗: %w", err)
+	}
+
+	// 先寫到暫存檔再改名，寫到一半失敗不會留下不完整的檔案
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("寫入 blob 失敗: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("寫入 blob 失敗: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("寫入 blob 失敗: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("寫入 blob 失敗: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalBlobStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("讀取 blob 失敗: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalBlobStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrBlobNotFound
+		}
+		return fmt.Errorf("刪除 blob 失敗: %w", err)
+	}
+
+	// 順手清掉空的資料夾，刪不掉（還有其他檔案）就算了
+	for dir := filepath.Dir(path); dir != filepath.Clean(s.Root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -2,14 +2,42 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"todo_api/internal/models" // 或 "github.com/gin-gonic/gin" 的 logger
+	"todo_api/internal/pagination"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// 每支查詢 RETURNING / SELECT 的欄位順序都要跟 scanProduct 一致
+const productColumns = `id, owner_id, title, game, platform, username, views, monthly_views, price, description, verified, country, featured, version, created_at, updated_at`
+
+func scanProduct(row pgx.Row, product *models.Product) error {
+	return row.Scan(
+		&product.ID,
+		&product.OwnerID,
+		&product.Title,
+		&product.Game,
+		&product.Platform,
+		&product.Username,
+		&product.Views,
+		&product.MonthlyViews,
+		&product.Price,
+		&product.Description,
+		&product.Verified,
+		&product.Country,
+		&product.Featured,
+		&product.Version,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+}
+
 // TODO: 交易所API
 // 建立物件 → 寫入資料庫 → 回傳完整物件
 func CreateProduct(pool *pgxpool.Pool, ownerId string, title string, game string, platform string, username string, views int, monthlyViews int, price int, description string, verified bool, country string, featured bool) (*models.Product, error) {
@@ -27,29 +55,12 @@ func CreateProduct(pool *pgxpool.Pool, ownerId string, title string, game string
 	query := `
 		INSERT INTO products (owner_id, title, game, platform, username, views, monthly_views, price, description, verified, country, featured)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, owner_id, title, game, platform, username, views, monthly_views, price, description, verified, country, featured, created_at, updated_at
-	`
+		RETURNING ` + productColumns
 
 	// 其實是在做「執行 SQL（只拿一筆結果）→ 把回傳欄位塞進 todo 這個 struct」
 	// ownerId, title, game ...等, 會依序對應到 SQL 裡的欄位，也就是 VALUES ($1, $2)
 	// => 所以前端傳來的 ownerId, title, game ...等, 會依序對應到 VALUES ($1, $2)
-	err := pool.QueryRow(ctx, query, ownerId, title, game, platform, username, views, monthlyViews, price, description, verified, country, featured).Scan(
-		&product.ID,
-		&product.OwnerID,
-		&product.Title,
-		&product.Game,
-		&product.Platform,
-		&product.Username,
-		&product.Views,
-		&product.MonthlyViews,
-		&product.Price,
-		&product.Description,
-		&product.Verified,
-		&product.Country,
-		&product.Featured,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	err := scanProduct(pool.QueryRow(ctx, query, ownerId, title, game, platform, username, views, monthlyViews, price, description, verified, country, featured), &product)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert product: %w", err)
@@ -72,7 +83,7 @@ func GetAllProducts(pool *pgxpool.Pool) ([]models.Product, error) {
 	// 效果 : featured 為ture 會排在最前面，即使刊登時間不是最新，也會排在最前面，然後再從熱推當中時間最新的在最前面
 	// 第二順位才是 featured是 false (非熱推) 進行排序，但一樣是 非熱推中最新的擺最前面
 	query := `
-		SELECT id, owner_id, title, game, platform, username, views, monthly_views, price, description, verified, country, featured, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM products
 		ORDER BY featured DESC, created_at DESC
 	`
@@ -85,23 +96,7 @@ func GetAllProducts(pool *pgxpool.Pool) ([]models.Product, error) {
 
 	for rows.Next() {
 		var product models.Product
-		if err := rows.Scan(
-			&product.ID,
-			&product.OwnerID,
-			&product.Title,
-			&product.Game,
-			&product.Platform,
-			&product.Username,
-			&product.Views,
-			&product.MonthlyViews,
-			&product.Price,
-			&product.Description,
-			&product.Verified,
-			&product.Country,
-			&product.Featured,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		); err != nil {
+		if err := scanProduct(rows, &product); err != nil {
 			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
 		products = append(products, product)
@@ -110,6 +105,125 @@ func GetAllProducts(pool *pgxpool.Pool) ([]models.Product, error) {
 	return products, nil
 }
 
+// GetAllProducts 的分頁版本，排序一樣是熱推優先、再依建立時間新到舊
+// offset 模式：page / pageSize；cursor 模式：用 (featured, created_at, id) 當 keyset
+func GetProductsPage(pool *pgxpool.Pool, params pagination.Params) (*models.ProductListResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// featured 是 boolean，轉成 int 才能放進 row value 比較，DESC 時 true(1) 一樣排在前面
+	keyset := pagination.Keyset{Columns: []string{"featured::int", "created_at", "id"}, Desc: true}
+	const productSort = "featured,created_at:desc"
+
+	response := &models.ProductListResponse{
+		Mode:     params.Mode,
+		Page:     params.Page,
+		PageSize: params.PageSize,
+	}
+
+	var conditions []string
+	var args []any
+	argIndex := 1
+
+	var pagingClause string
+	if params.IsCursor() {
+		if err := params.CheckSort(productSort); err != nil {
+			return nil, err
+		}
+		if params.Cursor != nil {
+			cursorID, err := params.Cursor.IntID()
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, keyset.Where(params.Backward(), argIndex))
+			args = append(args, params.Cursor.Rank, params.Cursor.Time, cursorID)
+			argIndex += 3
+		}
+		pagingClause = fmt.Sprintf("LIMIT $%d", argIndex)
+		args = append(args, params.PageSize+1)
+	} else {
+		if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM products`).Scan(&response.TotalCount); err != nil {
+			return nil, fmt.Errorf("failed to count products: %w", err)
+		}
+		response.TotalPages = (response.TotalCount + params.PageSize - 1) / params.PageSize
+		if response.TotalPages == 0 {
+			response.TotalPages = 1
+		}
+
+		pagingClause = fmt.Sprintf("LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+		args = append(args, params.PageSize, params.Offset())
+	}
+
+	whereClause := "TRUE"
+	if len(conditions) > 0 {
+		whereClause = strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM products
+		WHERE %s
+		ORDER BY %s
+		%s
+	`, productColumns, whereClause, keyset.OrderBy(params.Backward()), pagingClause)
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read products: %w", err)
+	}
+
+	if params.IsCursor() {
+		hasNext, hasPrev := params.Bounds(len(products))
+		if len(products) > params.PageSize {
+			products = products[:params.PageSize]
+		}
+		if params.Backward() {
+			pagination.Reverse(products)
+		}
+
+		if len(products) > 0 {
+			first, last := products[0], products[len(products)-1]
+			if hasNext {
+				response.NextCursor = params.Codec.Encode(productCursor(productSort, last, false))
+			}
+			if hasPrev {
+				response.PrevCursor = params.Codec.Encode(productCursor(productSort, first, true))
+			}
+		}
+	}
+
+	response.Items = products
+	return response, nil
+}
+
+func productCursor(sort string, product models.Product, backward bool) pagination.Cursor {
+	rank := 0
+	if product.Featured {
+		rank = 1
+	}
+	return pagination.Cursor{
+		Sort:     sort,
+		Rank:     rank,
+		Time:     product.CreatedAt,
+		ID:       strconv.Itoa(product.ID),
+		Backward: backward,
+	}
+}
+
 // 需要知道特定id才查得到商品
 func GetProductById(pool *pgxpool.Pool, id int) (*models.Product, error) {
 	var ctx context.Context
@@ -118,33 +232,17 @@ func GetProductById(pool *pgxpool.Pool, id int) (*models.Product, error) {
 	defer cancel()
 
 	query := `
-		SELECT id, owner_id, title, game, platform, username, views, monthly_views, price, description, verified, country, featured, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM products
 		WHERE id = $1
 	`
 
 	var product models.Product
 
-	err := pool.QueryRow(ctx, query, id).Scan(
-		&product.ID,
-		&product.OwnerID,
-		&product.Title,
-		&product.Game,
-		&product.Platform,
-		&product.Username,
-		&product.Views,
-		&product.MonthlyViews,
-		&product.Price,
-		&product.Description,
-		&product.Verified,
-		&product.Country,
-		&product.Featured,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	err := scanProduct(pool.QueryRow(ctx, query, id), &product)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert product: %w", err)
+		return nil, fmt.Errorf("failed to get product: %w", err)
 	}
 
 	return &product, nil
@@ -160,8 +258,7 @@ func SearchProducts(pool *pgxpool.Pool, keyword string) ([]models.Product, error
 	defer cancel()
 
 	query := `
-        SELECT id, owner_id, title, game, platform, username, views, monthly_views,
-               price, description, verified, country, featured, created_at, updated_at
+        SELECT ` + productColumns + `
         FROM products
         WHERE title ILIKE $1
     `
@@ -177,23 +274,7 @@ func SearchProducts(pool *pgxpool.Pool, keyword string) ([]models.Product, error
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		if err := rows.Scan(
-			&p.ID,
-			&p.OwnerID,
-			&p.Title,
-			&p.Game,
-			&p.Platform,
-			&p.Username,
-			&p.Views,
-			&p.MonthlyViews,
-			&p.Price,
-			&p.Description,
-			&p.Verified,
-			&p.Country,
-			&p.Featured,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-		); err != nil {
+		if err := scanProduct(rows, &p); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -217,49 +298,44 @@ var editableFields = []string{
 // 1. 不限制前端更新那些欄位，之前的寫法是所有欄位的值都要提供給後端，現在是只提供需要更新的欄位。
 // 2. 那些不該被更改的內容，像是 ownerId 這種與帳號綁定的，如前端誤傳錯誤的值，後端這邊會擋掉。
 // 3. 在此處 ToUpdates 方法中，定義了那些欄位才能被更新，沒出現在這上面的都不能修改。
-func UpdateProduct(pool *pgxpool.Pool, id int, updates map[string]any) (*models.Product, error) {
+// expectedVersion 不是 nil 時，只有資料庫裡的 version 還是這個值才會寫入，否則回傳 ErrVersionConflict
+func UpdateProduct(pool *pgxpool.Pool, id int, updates map[string]any, expectedVersion *int) (*models.Product, error) {
 	var ctx context.Context
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 建構動態 SET 子句
-	var setClauses []string
-	var args []any
-	argIndex := 1 // $1 給 WHERE id
-
-	for _, field := range editableFields {
-		if val, ok := updates[field]; ok && val != nil {
-			setClauses = append(setClauses, fmt.Sprintf("%s=$%d", field, argIndex+1))
-			args = append(args, val)
-			argIndex++
+	// 值是 nil 的欄位一樣略過（products 的欄位都不能是 NULL）
+	values := make(map[string]any, len(updates))
+	for field, val := range updates {
+		if val != nil {
+			values[field] = val
 		}
 	}
 
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no valid fields to update")
+	where := "id = $1"
+	whereArgs := []any{id}
+	// version 由 trigger 在每次 UPDATE 時 +1，這裡只負責比對
+	if expectedVersion != nil {
+		where += " AND version = $2"
+		whereArgs = append(whereArgs, *expectedVersion)
 	}
 
-	// 動態查詢（安全，參數化）
-	query := fmt.Sprintf(`
-        UPDATE products 
-        SET %s, updated_at = NOW()
-        WHERE id = $1
-        RETURNING id, owner_id, title, game, platform, username, views, monthly_views, 
-                  price, description, verified, country, featured, created_at, updated_at
-    `, strings.Join(setClauses, ", "))
-
-	args = append([]interface{}{id}, args...) // id 第一個
+	// 動態查詢（安全，參數化），跟 PATCH /todos/:id 共用 buildUpdate
+	query, args, err := buildUpdate("products", editableFields, values, []string{"updated_at = NOW()"}, where, whereArgs, productColumns)
+	if err != nil {
+		return nil, err
+	}
 
 	var updatedProduct models.Product
-	err := pool.QueryRow(ctx, query, args...).Scan(
-		&updatedProduct.ID, &updatedProduct.OwnerID, &updatedProduct.Title,
-		&updatedProduct.Game, &updatedProduct.Platform, &updatedProduct.Username,
-		&updatedProduct.Views, &updatedProduct.MonthlyViews, &updatedProduct.Price,
-		&updatedProduct.Description, &updatedProduct.Verified, &updatedProduct.Country,
-		&updatedProduct.Featured, &updatedProduct.CreatedAt, &updatedProduct.UpdatedAt,
-	)
-
+	err = scanProduct(pool.QueryRow(ctx, query, args...), &updatedProduct)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) && expectedVersion != nil {
+			// 分辨是商品不存在，還是被別人先改過了
+			var exists bool
+			if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, id).Scan(&exists); err == nil && exists {
+				return nil, ErrVersionConflict
+			}
+		}
 		return nil, fmt.Errorf("failed to update product: %w", err)
 	}
 
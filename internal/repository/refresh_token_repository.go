@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+/*
+只要是下面情況都可以放這裡。
+1. 操作 refresh_tokens
+2. 輪替、重複使用的判斷在 service.AuthService，這裡只負責 SQL
+
+token 一律用 utils.HashToken 算過的值查詢
+*/
+
+const refreshTokenColumns = `id, user_id, family_id, expires_at, used_at, revoked_at, user_agent, created_at`
+
+func scanRefreshToken(row pgx.Row, token *models.RefreshToken) error {
+	return row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.UserAgent,
+		&token.CreatedAt,
+	)
+}
+
+// familyID 是空字串就開一個新的 family（登入），換發時沿用舊 token 的 family
+func CreateRefreshToken(ctx context.Context, db DBTX, userID string, familyID string, tokenHash string, expiresAt time.Time, userAgent string) (*models.RefreshToken, error) {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, user_agent)
+		VALUES ($1, COALESCE(NULLIF($2, '')::uuid, gen_random_uuid()), $3, $4, $5)
+		RETURNING ` + refreshTokenColumns
+
+	var created models.RefreshToken
+	if err := scanRefreshToken(db.QueryRow(ctx, query, userID, familyID, tokenHash, expiresAt, userAgent), &created); err != nil {
+		return nil, fmt.Errorf("新增 refresh token 失敗: %w", err)
+	}
+
+	return &created, nil
+}
+
+// 換發時用，FOR UPDATE 鎖住這筆 token，同一個 token 同時送兩次只有一個能換到新的
+// 一定要在 transaction 裡呼叫才有意義；找不到回傳 pgx.ErrNoRows
+func GetRefreshTokenByHashForUpdate(ctx context.Context, db DBTX, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT ` + refreshTokenColumns + `
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	var token models.RefreshToken
+	if err := scanRefreshToken(db.QueryRow(ctx, query, tokenHash), &token); err != nil {
+		return nil, fmt.Errorf("查詢 refresh token 失敗: %w", err)
+	}
+
+	return &token, nil
+}
+
+func MarkRefreshTokenUsed(ctx context.Context, db DBTX, id string) error {
+	if _, err := db.Exec(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("更新 refresh token 失敗: %w", err)
+	}
+	return nil
+}
+
+// 撤銷整個 family 還沒撤銷的 token（登出、偵測到重複使用）
+func RevokeRefreshTokenFamily(ctx context.Context, db DBTX, familyID string) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		return 0, fmt.Errorf("撤銷 refresh token 失敗: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// 撤銷使用者所有還沒撤銷的 refresh token（登出所有裝置、改密碼）
+func RevokeUserRefreshTokens(ctx context.Context, db DBTX, userID string) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("撤銷 refresh token 失敗: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// 過期的 token 已經不能換發，重複使用也沒有意義了，直接刪掉
+func DeleteExpiredRefreshTokens(ctx context.Context, db DBTX, now time.Time) (int64, error) {
+	tag, err := db.Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("刪除過期的 refresh token 失敗: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
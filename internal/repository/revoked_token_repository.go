@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/*
+只要是下面情況都可以放這裡。
+1. 操作 revoked_tokens（登出的 access token）
+2. users.tokens_valid_after（在這之前發的 access token 都失效）
+
+快取在 revocation.Store，這裡只負責 SQL
+*/
+
+// 同一個 jti 登出兩次不算錯
+func RevokeToken(ctx context.Context, db DBTX, jti string, userID string, expiresAt time.Time) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, userID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("撤銷 token 失敗: %w", err)
+	}
+	return nil
+}
+
+// 一次查出 jti 有沒有被撤銷，跟使用者的 tokens_valid_after；使用者不存在回傳 pgx.ErrNoRows
+func GetTokenRevocation(ctx context.Context, db DBTX, userID string, jti string) (*time.Time, bool, error) {
+	var validAfter *time.Time
+	var revoked bool
+	err := db.QueryRow(ctx, `
+		SELECT u.tokens_valid_after, EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $2)
+		FROM users u
+		WHERE u.id = $1
+	`, userID, jti).Scan(&validAfter, &revoked)
+	if err != nil {
+		return nil, false, fmt.Errorf("查詢 token 狀態失敗: %w", err)
+	}
+	return validAfter, revoked, nil
+}
+
+// 把使用者的 tokens_valid_after 設成現在，回傳設定的時間
+func SetTokensValidAfter(ctx context.Context, db DBTX, userID string) (time.Time, error) {
+	var validAfter time.Time
+	err := db.QueryRow(ctx, `
+		UPDATE users
+		SET tokens_valid_after = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING tokens_valid_after
+	`, userID).Scan(&validAfter)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("更新 tokens_valid_after 失敗: %w", err)
+	}
+	return validAfter, nil
+}
+
+// token 本身已經過期，AuthMiddleware 驗簽的時候就會擋掉，撤銷紀錄用不到了
+func DeleteExpiredRevokedTokens(ctx context.Context, db DBTX, now time.Time) (int64, error) {
+	tag, err := db.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("刪除過期的撤銷紀錄失敗: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/patch"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+/*
+GetSyncChanges 回傳 since 之後變動過的 todo，跟下一次同步要用的 xmin
+
+since 是上一次同步時 snapshot 的 xmin（見 migrations 000017）；nil 代表完整同步
+整個查詢在同一個 REPEATABLE READ 的 snapshot 裡，回傳的 xmin 也是這個 snapshot 的，
+所以這次沒看到的寫入，下次一定會被 sync_xid >= xmin 撈到
+
+被移出清單時，自己在清單裡建立的 todo 會由 migrations 000025 的 trigger 寫墓碑，跟永久刪除一樣從 todo_tombstones 送出；
+重新加入時墓碑拿掉、todo 的 sync_xid 更新，下一次又會出現在 Todos 裡
+*/
+func GetSyncChanges(ctx context.Context, pool *pgxpool.Pool, userID string, since *uint64) (*models.SyncChanges, uint64, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// 第一個查詢決定 snapshot，之後的查詢看到的都是同一份
+	var rawXmin string
+	if err := tx.QueryRow(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot())::text`).Scan(&rawXmin); err != nil {
+		return nil, 0, fmt.Errorf("查詢 snapshot 失敗: %w", err)
+	}
+	xmin, err := strconv.ParseUint(rawXmin, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("解析 snapshot xmin 失敗: %w", err)
+	}
+
+	changes := &models.SyncChanges{Deleted: []models.SyncTombstone{}}
+
+	if since == nil {
+		changes.Full = true
+		changes.Todos, err = queryTodos(ctx, tx, `
+			SELECT `+todoColumns+`
+			FROM todos
+			WHERE user_id = $1 AND deleted_at IS NULL AND `+todoAccessCondition+`
+			ORDER BY position, id
+		`, userID)
+		if err != nil {
+			return nil, 0, err
+		}
+		return changes, xmin, tx.Commit(ctx)
+	}
+
+	// xid8 沒辦法直接當參數傳，先轉成字串
+	sinceArg := strconv.FormatUint(*since, 10)
+
+	changes.Todos, err = queryTodos(ctx, tx, `
+		SELECT `+todoColumns+`
+		FROM todos
+		WHERE user_id = $1 AND sync_xid >= $2::text::xid8 AND deleted_at IS NULL AND `+todoAccessCondition+`
+		ORDER BY position, id
+	`, userID, sinceArg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 丟進垃圾桶的跟永久刪除的，對 client 來說都是刪除
+	rows, err := tx.Query(ctx, `
+		SELECT id, deleted_at
+		FROM todos
+		WHERE user_id = $1 AND sync_xid >= $2::text::xid8 AND deleted_at IS NOT NULL AND `+todoAccessCondition+`
+		UNION ALL
+		SELECT todo_id, deleted_at
+		FROM todo_tombstones
+		WHERE user_id = $1 AND sync_xid >= $2::text::xid8
+	`, userID, sinceArg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查詢刪除的 todo 失敗: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tombstone models.SyncTombstone
+		if err := rows.Scan(&tombstone.ID, &tombstone.DeletedAt); err != nil {
+			return nil, 0, fmt.Errorf("讀取刪除的 todo 失敗: %w", err)
+		}
+		changes.Deleted = append(changes.Deleted, tombstone)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("讀取刪除的 todo 失敗: %w", err)
+	}
+
+	return changes, xmin, tx.Commit(ctx)
+}
+
+/*
+搶下這個 client_mutation_id：第一次看到回傳 true，之後由呼叫端在同一個 transaction 裡 SaveSyncMutationResult
+已經處理過（或另一個 request 正在處理，會等它 commit）回傳 false，呼叫端改用 GetSyncMutation 拿當時的結果
+*/
+func ClaimSyncMutation(ctx context.Context, db DBTX, userID string, clientMutationID string) (bool, error) {
+	cmdTag, err := db.Exec(ctx, `
+		INSERT INTO sync_mutations (user_id, client_mutation_id, result)
+		VALUES ($1, $2, '{}')
+		ON CONFLICT (user_id, client_mutation_id) DO NOTHING
+	`, userID, clientMutationID)
+	if err != nil {
+		return false, fmt.Errorf("記錄同步 mutation 失敗: %w", err)
+	}
+	return cmdTag.RowsAffected() == 1, nil
+}
+
+func SaveSyncMutationResult(ctx context.Context, db DBTX, userID string, result *models.SyncMutationResult) error {
+	_, err := db.Exec(ctx, `
+		UPDATE sync_mutations
+		SET result = $3
+		WHERE user_id = $1 AND client_mutation_id = $2
+	`, userID, result.ClientMutationID, result)
+	if err != nil {
+		return fmt.Errorf("記錄同步 mutation 失敗: %w", err)
+	}
+	return nil
+}
+
+// 之前處理過的 mutation 的結果，沒有的話回傳 pgx.ErrNoRows
+func GetSyncMutation(ctx context.Context, db DBTX, userID string, clientMutationID string) (*models.SyncMutationResult, error) {
+	var result models.SyncMutationResult
+	err := db.QueryRow(ctx, `
+		SELECT result
+		FROM sync_mutations
+		WHERE user_id = $1 AND client_mutation_id = $2
+	`, userID, clientMutationID).Scan(&result)
+	if err != nil {
+		return nil, fmt.Errorf("查詢同步 mutation 失敗: %w", err)
+	}
+	return &result, nil
+}
+
+// 跟 GetTodoForUpdate 一樣鎖住這一筆，但垃圾桶裡的也找得到（離線時的修改可能比刪除還新）
+func GetTodoForSync(ctx context.Context, db DBTX, userID string, id int) (*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE id = $1 AND user_id = $2 AND ` + todoAccessCondition + `
+		FOR UPDATE
+	`
+
+	var todo models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, id, userID), &todo); err != nil {
+		return nil, fmt.Errorf("查詢 todo 失敗: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// 離線修改可以寫回的欄位：可以編輯的欄位，再加上垃圾桶狀態（比刪除還新的修改會把 todo 救回來）
+var todoSyncFields = append(append([]string{}, todoEditableFields...), "deleted_at", "updated_at")
+
+/*
+SyncUpdateTodo 把離線時的修改寫回去，existing 是 GetTodoForSync 鎖住的現有資料，changed 是套用修改之後的內容
+
+updated_at 用 client 做修改的時間（last-writer-wins 比的就是這個），但不會超過現在，
+避免時間設錯的裝置之後永遠蓋掉別人；週期性 todo 的下一次跟變更紀錄都跟 UpdateTodo 一樣處理
+*/
+func SyncUpdateTodo(ctx context.Context, db DBTX, actorID string, existing *models.Todo, changed *models.Todo, updatedAt time.Time) (*models.Todo, error) {
+	updates := patch.Changes(existing, changed, "db")
+	if now := time.Now(); updatedAt.After(now) {
+		updatedAt = now
+	}
+	updates["updated_at"] = updatedAt
+
+	var extraSet []string
+	if _, ok := updates["remind_at"]; ok {
+		extraSet = append(extraSet, "reminded_at = NULL")
+	}
+
+	query, args, err := buildUpdate("todos", todoSyncFields, updates, extraSet,
+		"id = $1 AND user_id = $2 AND version = $3",
+		[]any{existing.ID, existing.UserID, existing.Version},
+		todoColumns,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, args...), &updated); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrVersionConflict
+		}
+		return nil, fmt.Errorf("同步 todo 失敗: %w", err)
+	}
+
+	if err := spawnNextOccurrence(ctx, db, actorID, &updated); err != nil {
+		return nil, err
+	}
+
+	if err := recordTodoEvent(ctx, db, actorID, models.TodoActionUpdate, existing, &updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// 背景清理用：同步 token 最多用 retention 這麼久，比這更舊的墓碑跟 mutation 紀錄都用不到了
+func PruneSyncHistory(ctx context.Context, pool *pgxpool.Pool, retention time.Duration) (int64, error) {
+	before := time.Now().Add(-retention)
+
+	tombstones, err := pool.Exec(ctx, `DELETE FROM todo_tombstones WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("清理 todo 墓碑失敗: %w", err)
+	}
+	mutations, err := pool.Exec(ctx, `DELETE FROM sync_mutations WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("清理同步 mutation 紀錄失敗: %w", err)
+	}
+
+	return tombstones.RowsAffected() + mutations.RowsAffected(), nil
+}
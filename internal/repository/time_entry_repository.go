@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/patch"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTimerAlreadyRunning = errors.New("another timer is already running")
+
+const timeEntryColumns = `id, todo_id, user_id, started_at, ended_at, note, auto_stopped, created_at, updated_at`
+
+// 計時紀錄可以修改的欄位
+var timeEntryEditableFields = []string{"started_at", "ended_at", "note", "updated_at"}
+
+func scanTimeEntry(row pgx.Row, entry *models.TimeEntry) error {
+	err := row.Scan(
+		&entry.ID,
+		&entry.TodoID,
+		&entry.UserID,
+		&entry.StartedAt,
+		&entry.EndedAt,
+		&entry.Note,
+		&entry.AutoStopped,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now()
+	if entry.EndedAt != nil {
+		end = *entry.EndedAt
+	}
+	if end.After(entry.StartedAt) {
+		entry.DurationSeconds = int64(end.Sub(entry.StartedAt) / time.Second)
+	}
+	return nil
+}
+
+// uq_time_entries_running 擋下來的就是同一個使用者已經有計時器在跑
+func isRunningTimerConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "uq_time_entries_running"
+}
+
+/*
+StartTimer 在 todo 上開始計時，從現在開始算
+
+  - todo 不是自己的或在垃圾桶裡：pgx.ErrNoRows
+  - 已經有別的計時器在跑（不管是哪個 todo）：ErrTimerAlreadyRunning，由 partial unique index 保證，同時開始也只會成功一個
+*/
+func StartTimer(ctx context.Context, db DBTX, userID string, todoID int, note string) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	err := scanTimeEntry(db.QueryRow(ctx, `
+		INSERT INTO time_entries (todo_id, user_id, started_at, note)
+		SELECT id, user_id, CURRENT_TIMESTAMP, $3
+		FROM todos
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND `+todoAccessCondition+`
+		RETURNING `+timeEntryColumns,
+		todoID, userID, note,
+	), &entry)
+	if err != nil {
+		if isRunningTimerConflict(err) {
+			return nil, ErrTimerAlreadyRunning
+		}
+		return nil, fmt.Errorf("開始計時失敗: %w", err)
+	}
+	return &entry, nil
+}
+
+// 停掉這個 todo 上正在跑的計時器；沒有的話回傳 pgx.ErrNoRows
+func StopTimer(ctx context.Context, db DBTX, userID string, todoID int) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	err := scanTimeEntry(db.QueryRow(ctx, `
+		UPDATE time_entries
+		SET ended_at = GREATEST(CURRENT_TIMESTAMP, started_at), updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND todo_id = $2 AND ended_at IS NULL
+		RETURNING `+timeEntryColumns,
+		userID, todoID,
+	), &entry)
+	if err != nil {
+		return nil, fmt.Errorf("停止計時失敗: %w", err)
+	}
+	return &entry, nil
+}
+
+// 使用者正在跑的計時器，沒有的話回傳 pgx.ErrNoRows
+func GetRunningTimer(ctx context.Context, db DBTX, userID string) (*models.TimeEntry, error) {
+	var entry models.TimeEntry
+	err := scanTimeEntry(db.QueryRow(ctx, `
+		SELECT `+timeEntryColumns+`
+		FROM time_entries
+		WHERE user_id = $1 AND ended_at IS NULL
+	`, userID), &entry)
+	if err != nil {
+		return nil, fmt.Errorf("查詢計時器失敗: %w", err)
+	}
+	return &entry, nil
+}
+
+// 新的在前；todo 不是自己的回傳 pgx.ErrNoRows
+func GetTimeEntries(ctx context.Context, db DBTX, userID string, todoID int) ([]models.TimeEntry, error) {
+	if err := CheckActiveTodo(ctx, db, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT `+timeEntryColumns+`
+		FROM time_entries
+		WHERE todo_id = $1 AND user_id = $2
+		ORDER BY started_at DESC, id DESC
+	`, todoID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢計時紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.TimeEntry{}
+	for rows.Next() {
+		var entry models.TimeEntry
+		if err := scanTimeEntry(rows, &entry); err != nil {
+			return nil, fmt.Errorf("讀取計時紀錄失敗: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// 手動補登一筆已經結束的時間；todo 不是自己的或在垃圾桶裡回傳 pgx.ErrNoRows
+func CreateTimeEntry(ctx context.Context, db DBTX, entry *models.TimeEntry) (*models.TimeEntry, error) {
+	var created models.TimeEntry
+	err := scanTimeEntry(db.QueryRow(ctx, `
+		INSERT INTO time_entries (todo_id, user_id, started_at, ended_at, note)
+		SELECT id, user_id, $3, $4, $5
+		FROM todos
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND `+todoAccessCondition+`
+		RETURNING `+timeEntryColumns,
+		entry.TodoID, entry.UserID, entry.StartedAt, entry.EndedAt, entry.Note,
+	), &created)
+	if err != nil {
+		return nil, fmt.Errorf("新增計時紀錄失敗: %w", err)
+	}
+	return &created, nil
+}
+
+// 鎖住一筆計時紀錄準備修改；todo 或紀錄不存在都回傳 pgx.ErrNoRows
+func GetTimeEntryForUpdate(ctx context.Context, db DBTX, userID string, todoID int, id int64) (*models.TimeEntry, error) {
+	if err := CheckActiveTodo(ctx, db, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	var entry models.TimeEntry
+	err := scanTimeEntry(db.QueryRow(ctx, `
+		SELECT `+timeEntryColumns+`
+		FROM time_entries
+		WHERE id = $1 AND todo_id = $2 AND user_id = $3
+		FOR UPDATE
+	`, id, todoID, userID), &entry)
+	if err != nil {
+		return nil, fmt.Errorf("查詢計時紀錄失敗: %w", err)
+	}
+	return &entry, nil
+}
+
+// existing 是 GetTimeEntryForUpdate 鎖住的現有資料，changed 是改完的內容，沒有變動回傳 ErrNoFieldsToUpdate
+func UpdateTimeEntry(ctx context.Context, db DBTX, existing *models.TimeEntry, changed *models.TimeEntry) (*models.TimeEntry, error) {
+	updates := patch.Changes(existing, changed, "db")
+	if len(updates) == 0 {
+		return nil, ErrNoFieldsToUpdate
+	}
+	updates["updated_at"] = time.Now()
+
+	query, args, err := buildUpdate("time_entries", timeEntryEditableFields, updates, nil,
+		"id = $1 AND user_id = $2",
+		[]any{existing.ID, existing.UserID},
+		timeEntryColumns,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated models.TimeEntry
+	if err := scanTimeEntry(db.QueryRow(ctx, query, args...), &updated); err != nil {
+		return nil, fmt.Errorf("修改計時紀錄失敗: %w", err)
+	}
+	return &updated, nil
+}
+
+// todo 或紀錄不存在都回傳 pgx.ErrNoRows
+func DeleteTimeEntry(ctx context.Context, db DBTX, userID string, todoID int, id int64) error {
+	if err := CheckActiveTodo(ctx, db, userID, todoID); err != nil {
+		return err
+	}
+
+	cmdTag, err := db.Exec(ctx, `DELETE FROM time_entries WHERE id = $1 AND todo_id = $2 AND user_id = $3`, id, todoID, userID)
+	if err != nil {
+		return fmt.Errorf("刪除計時紀錄失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// 時間報表用的一段時間，已經切齊在查詢的區間裡
+type TimeSpan struct {
+	TodoID int
+	Title  string
+	Start  time.Time
+	End    time.Time
+}
+
+/*
+GetTimeSpans 回傳跟 [from, to) 重疊的計時紀錄，頭尾超出區間的部分已經切掉，正在跑的算到現在
+垃圾桶裡的 todo 的時間也算（時間確實花了），永久刪除之後才會跟著消失
+*/
+func GetTimeSpans(ctx context.Context, db DBTX, userID string, from time.Time, to time.Time) ([]TimeSpan, error) {
+	rows, err := db.Query(ctx, `
+		SELECT e.todo_id, t.title,
+			GREATEST(e.started_at, $2),
+			LEAST(COALESCE(e.ended_at, CURRENT_TIMESTAMP), $3)
+		FROM time_entries e
+		JOIN todos t ON t.id = e.todo_id
+		WHERE e.user_id = $1
+			AND e.started_at < $3
+			AND COALESCE(e.ended_at, CURRENT_TIMESTAMP) > $2
+		ORDER BY e.started_at, e.id
+	`, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("查詢計時紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+
+	spans := []TimeSpan{}
+	for rows.Next() {
+		var span TimeSpan
+		if err := rows.Scan(&span.TodoID, &span.Title, &span.Start, &span.End); err != nil {
+			return nil, fmt.Errorf("讀取計時紀錄失敗: %w", err)
+		}
+		spans = append(spans, span)
+	}
+	return spans, rows.Err()
+}
+
+/*
+背景工作用：跑超過 maxDuration 的計時器視為忘了停，直接停在開始之後 maxDuration 的時間點（不是現在，避免灌水），
+標記 auto_stopped 讓使用者知道要確認；回傳停掉幾個
+*/
+func StopStaleTimers(ctx context.Context, pool *pgxpool.Pool, maxDuration time.Duration) (int64, error) {
+	cmdTag, err := pool.Exec(ctx, `
+		UPDATE time_entries
+		SET ended_at = started_at + $1::interval, auto_stopped = true, updated_at = CURRENT_TIMESTAMP
+		WHERE ended_at IS NULL AND started_at < CURRENT_TIMESTAMP - $1::interval
+	`, maxDuration)
+	if err != nil {
+		return 0, fmt.Errorf("自動停止計時器失敗: %w", err)
+	}
+	return cmdTag.RowsAffected(), nil
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAttachmentQuotaExceeded = errors.New("attachment storage quota exceeded")
+
+const todoAttachmentColumns = `id, todo_id, user_id, filename, content_type, size_bytes, storage_key, created_at`
+
+func scanTodoAttachment(row pgx.Row, attachment *models.TodoAttachment) error {
+	return row.Scan(
+		&attachment.ID,
+		&attachment.TodoID,
+		&attachment.UserID,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.StorageKey,
+		&attachment.CreatedAt,
+	)
+}
+
+// 同一個使用者的上傳要排隊檢查用量，兩個檔案同時上傳才不會一起超過 quota
+func lockAttachmentQuota(ctx context.Context, db DBTX, userID string) error {
+	if _, err := db.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('attachment_quota:' || $1))`, userID); err != nil {
+		return fmt.Errorf("鎖定附件用量失敗: %w", err)
+	}
+	return nil
+}
+
+// 這個使用者所有附件加起來多大（包含垃圾桶裡的 todo 的附件，永久刪除之後才會釋放）
+func GetAttachmentUsage(ctx context.Context, db DBTX, userID string) (int64, error) {
+	var used int64
+	if err := db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(size_bytes), 0) FROM todo_attachments WHERE user_id = $1`,
+		userID,
+	).Scan(&used); err != nil {
+		return 0, fmt.Errorf("查詢附件用量失敗: %w", err)
+	}
+	return used, nil
+}
+
+/*
+檔案已經寫進 BlobStorage 之後才記錄中繼資料；同一個 transaction 裡再檢查一次 todo 跟用量：
+  - todo 不存在或已經在垃圾桶：pgx.ErrNoRows
+  - 加上這個檔案會超過 quotaBytes：ErrAttachmentQuotaExceeded
+
+回傳錯誤時呼叫端要自己把剛寫進去的檔案刪掉
+*/
+func CreateTodoAttachment(ctx context.Context, pool *pgxpool.Pool, attachment *models.TodoAttachment, quotaBytes int64) (*models.TodoAttachment, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockAttachmentQuota(ctx, tx, attachment.UserID); err != nil {
+		return nil, err
+	}
+	if err := CheckActiveTodo(ctx, tx, attachment.UserID, attachment.TodoID); err != nil {
+		return nil, err
+	}
+
+	used, err := GetAttachmentUsage(ctx, tx, attachment.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if used+attachment.Size > quotaBytes {
+		return nil, ErrAttachmentQuotaExceeded
+	}
+
+	var created models.TodoAttachment
+	err = scanTodoAttachment(tx.QueryRow(ctx, `
+		INSERT INTO todo_attachments (todo_id, user_id, filename, content_type, size_bytes, storage_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+todoAttachmentColumns,
+		attachment.TodoID,
+		attachment.UserID,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.StorageKey,
+	), &created)
+	if err != nil {
+		return nil, fmt.Errorf("新增附件失敗: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// 舊的在前；todo 不是自己的回傳 pgx.ErrNoRows
+func GetTodoAttachments(ctx context.Context, db DBTX, userID string, todoID int) ([]models.TodoAttachment, error) {
+	if err := CheckActiveTodo(ctx, db, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT `+todoAttachmentColumns+`
+		FROM todo_attachments
+		WHERE todo_id = $1
+		ORDER BY created_at, id
+	`, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢附件失敗: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.TodoAttachment{}
+	for rows.Next() {
+		var attachment models.TodoAttachment
+		if err := scanTodoAttachment(rows, &attachment); err != nil {
+			return nil, fmt.Errorf("讀取附件失敗: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// todo 或附件不存在都回傳 pgx.ErrNoRows
+func GetTodoAttachment(ctx context.Context, db DBTX, userID string, todoID int, id string) (*models.TodoAttachment, error) {
+	if err := CheckActiveTodo(ctx, db, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	var attachment models.TodoAttachment
+	err := scanTodoAttachment(db.QueryRow(ctx, `
+		SELECT `+todoAttachmentColumns+`
+		FROM todo_attachments
+		WHERE id = $1 AND todo_id = $2
+	`, id, todoID), &attachment)
+	if err != nil {
+		return nil, fmt.Errorf("查詢附件失敗: %w", err)
+	}
+	return &attachment, nil
+}
+
+// 只刪中繼資料，檔案由 trigger 排進 pending_blob_deletions，背景工作再去刪
+func DeleteTodoAttachment(ctx context.Context, db DBTX, userID string, todoID int, id string) error {
+	if err := CheckActiveTodo(ctx, db, userID, todoID); err != nil {
+		return err
+	}
+
+	cmdTag, err := db.Exec(ctx, `DELETE FROM todo_attachments WHERE id = $1 AND todo_id = $2`, id, todoID)
+	if err != nil {
+		return fmt.Errorf("刪除附件失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+/*
+背景清理用：一次拿一批待刪除的檔案交給 deleteBlob，成功的才從待刪除清單移掉，失敗的下一輪再試
+FOR UPDATE SKIP LOCKED 讓多個實例同時跑也不會搶同一筆；回傳刪掉幾個
+*/
+func ProcessPendingBlobDeletions(ctx context.Context, pool *pgxpool.Pool, limit int, deleteBlob func(key string) error) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT storage_key
+		FROM pending_blob_deletions
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("查詢待刪除的檔案失敗: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("讀取待刪除的檔案失敗: %w", err)
+	}
+
+	var done []string
+	var firstErr error
+	for _, key := range keys {
+		if err := deleteBlob(key); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("刪除檔案 %s 失敗: %w", key, err)
+			}
+			continue
+		}
+		done = append(done, key)
+	}
+
+	if len(done) > 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM pending_blob_deletions WHERE storage_key = ANY($1)`, done); err != nil {
+			return 0, fmt.Errorf("更新待刪除的檔案失敗: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(done), firstErr
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 批次新增時第幾筆失敗；同一個 batch 的其他筆也跟著 rollback
+type BatchInsertError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchInsertError) Error() string {
+	return fmt.Sprintf("新增第 %d 筆 todo 失敗: %v", e.Index, e.Err)
+}
+
+func (e *BatchInsertError) Unwrap() error {
+	return e.Err
+}
+
+/*
+CreateTodosBatch 一次新增多筆 todo（同一個使用者），依序排在最後面
+
+排序位置只鎖一次、一次算出 n 個鍵（rank.After），
+所有 INSERT 用 pgx.Batch 一次送出，只有一次來回，匯入幾百筆也不會慢
+tx 裡任何一筆失敗，整個 transaction 就不能再用了，回傳 *BatchInsertError 讓呼叫端知道是第幾筆
+*/
+func CreateTodosBatch(ctx context.Context, tx pgx.Tx, userID string, todos []*models.Todo) ([]*models.Todo, error) {
+	if len(todos) == 0 {
+		return nil, nil
+	}
+
+	positions, err := nextTodoPositions(ctx, tx, userID, len(todos))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO todos (user_id, list_id, title, completed, due_at, priority, notes, remind_at,
+		                   recurrence_rule, recurrence_tz, recurrence_start, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + todoColumns
+
+	batch := &pgx.Batch{}
+	for i, todo := range todos {
+		if todo.Priority == "" {
+			todo.Priority = models.TodoPriorityNormal
+		}
+		if todo.RecurrenceTZ == "" {
+			todo.RecurrenceTZ = "UTC"
+		}
+		batch.Queue(query,
+			userID,
+			todo.ListID,
+			todo.Title,
+			todo.Completed,
+			todo.DueAt,
+			todo.Priority,
+			todo.Notes,
+			todo.RemindAt,
+			todo.RecurrenceRule,
+			todo.RecurrenceTZ,
+			todo.RecurrenceStart,
+			positions[i],
+		)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+
+	created := make([]*models.Todo, 0, len(todos))
+	for i := range todos {
+		var todo models.Todo
+		if err := scanTodo(results.QueryRow(), &todo); err != nil {
+			return nil, &BatchInsertError{Index: i, Err: err}
+		}
+		created = append(created, &todo)
+	}
+
+	// Close 才會拿到最後的錯誤，之後 tx 才能繼續用
+	if err := results.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := recordTodoCreateEvents(ctx, tx, userID, created); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// 匯入用：資料已經在 handler 驗證過，用 COPY 寫入，不需要拿回每一筆，幾萬筆也只要一次來回
+// 回傳實際寫入的筆數；排序位置的鎖到 tx commit 才會放開
+func CopyTodos(ctx context.Context, tx pgx.Tx, userID string, todos []*models.Todo) (int64, error) {
+	if len(todos) == 0 {
+		return 0, nil
+	}
+
+	positions, err := nextTodoPositions(ctx, tx, userID, len(todos))
+	if err != nil {
+		return 0, err
+	}
+
+	// COPY 一律用 binary 格式，UUID 欄位不能直接給字串
+	owner, err := copyUUID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := []string{"user_id", "title", "completed", "due_at", "priority", "notes", "remind_at",
+		"recurrence_rule", "recurrence_tz", "recurrence_start", "position"}
+
+	rows := pgx.CopyFromSlice(len(todos), func(i int) ([]any, error) {
+		todo := todos[i]
+		if todo.Priority == "" {
+			todo.Priority = models.TodoPriorityNormal
+		}
+		if todo.RecurrenceTZ == "" {
+			todo.RecurrenceTZ = "UTC"
+		}
+		return []any{
+			owner,
+			todo.Title,
+			todo.Completed,
+			todo.DueAt,
+			todo.Priority,
+			todo.Notes,
+			todo.RemindAt,
+			todo.RecurrenceRule,
+			todo.RecurrenceTZ,
+			todo.RecurrenceStart,
+			positions[i],
+		}, nil
+	})
+
+	count, err := tx.CopyFrom(ctx, pgx.Identifier{"todos"}, columns, rows)
+	if err != nil {
+		return 0, fmt.Errorf("匯入 todo 失敗: %w", err)
+	}
+
+	// COPY 拿不到新的 id，用剛剛分配的排序位置查回來（同一個使用者的位置不會重複）
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1 AND position = ANY($2)
+	`
+	created, err := queryTodos(ctx, tx, query, userID, positions)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordTodoCreateEvents(ctx, tx, userID, created); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// 匯出用：一筆一筆交給 fn，不會把全部 todo 載入記憶體；fn 回傳錯誤就停止
+// 依手動排序的順序，不包含垃圾桶
+func EachTodo(ctx context.Context, pool *pgxpool.Pool, userID string, fn func(todo *models.Todo) error) error {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1 AND deleted_at IS NULL AND ` + todoAccessCondition + `
+		ORDER BY position, id
+	`
+
+	rows, err := pool.Query(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("查詢 todos 失敗: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todo models.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return fmt.Errorf("讀取 todo 失敗: %w", err)
+		}
+		if err := fn(&todo); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// POST /todos/import：全部寫入或全部不寫
+func ImportTodos(ctx context.Context, pool *pgxpool.Pool, userID string, todos []*models.Todo) (int64, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	count, err := CopyTodos(ctx, tx, userID, todos)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// COPY 用的 UUID 參數；空字串是 NULL
+func copyUUID(id string) (pgtype.UUID, error) {
+	var u pgtype.UUID
+	if id == "" {
+		return u, nil
+	}
+	if err := u.Scan(id); err != nil {
+		return u, fmt.Errorf("invalid uuid %q: %w", id, err)
+	}
+	return u, nil
+}
+
+func queryTodos(ctx context.Context, db DBTX, query string, args ...any) ([]*models.Todo, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 todos 失敗: %w", err)
+	}
+	defer rows.Close()
+
+	todos := []*models.Todo{}
+	for rows.Next() {
+		var todo models.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("讀取 todo 失敗: %w", err)
+		}
+		todos = append(todos, &todo)
+	}
+	return todos, rows.Err()
+}
@@ -0,0 +1,408 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/patch"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// 這個 todo 沒有任何紀錄可以撤銷（handler 回 409）
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// 最後一筆紀錄之後 todo 又被改過了，或是撤銷後的狀態跟現在的資料衝突（handler 回 409）
+	ErrUndoConflict = errors.New("cannot undo")
+)
+
+const todoEventColumns = `id, todo_id, actor_id, action, changes, todo_version, created_at`
+
+// 每寫一筆變更紀錄就在這個 channel 上 pg_notify，realtime 的 listener 收到後推給 GET /todos/stream
+// NOTIFY 在 commit 之後才會送出，rollback 的變更不會通知
+const TodoEventsChannel = "todo_events"
+
+// pg_notify 的 payload，就是 models.TodoStreamEvent 的 JSON；e 是 todo_events、t 是 todos
+const todoNotifyPayload = `json_build_object(
+	'id', e.id,
+	'user_id', t.user_id,
+	'list_id', t.list_id,
+	'todo_id', e.todo_id,
+	'action', e.action,
+	'version', e.todo_version,
+	'created_at', e.created_at
+)::text`
+
+func scanTodoEvent(row pgx.Row, event *models.TodoEvent) error {
+	return row.Scan(
+		&event.ID,
+		&event.TodoID,
+		&event.ActorID,
+		&event.Action,
+		&event.Changes,
+		&event.Version,
+		&event.CreatedAt,
+	)
+}
+
+// 自動維護或內部用的欄位，不算在變更紀錄裡
+var todoEventIgnoredFields = map[string]bool{
+	"id":                 true,
+	"user_id":            true,
+	"version":            true,
+	"created_at":         true,
+	"updated_at":         true,
+	"reminded_at":        true,
+	"recurrence_next_id": true,
+}
+
+// before / after 有差異的欄位；before 是 nil 代表新增，from 一律是 null
+func todoChanges(before, after *models.Todo) map[string]models.TodoFieldChange {
+	created := before == nil
+	if created {
+		before = &models.Todo{}
+	}
+
+	to := patch.Changes(before, after, "db")
+	from := patch.Changes(after, before, "db")
+
+	changes := make(map[string]models.TodoFieldChange, len(to))
+	for field, value := range to {
+		if todoEventIgnoredFields[field] {
+			continue
+		}
+		change := models.TodoFieldChange{To: value}
+		if !created {
+			change.From = from[field]
+		}
+		changes[field] = change
+	}
+	return changes
+}
+
+// 空字串（例如系統自己做的變更）存成 NULL
+func actorArg(actorID string) any {
+	if actorID == "" {
+		return nil
+	}
+	return actorID
+}
+
+// 跟寫入 todo 放在同一個 transaction；after.Version 必須是這次寫入之後的 version
+// 更新但沒有任何欄位真的變動時不記錄
+func recordTodoEvent(ctx context.Context, db DBTX, actorID string, action string, before, after *models.Todo) error {
+	changes := todoChanges(before, after)
+	if len(changes) == 0 && action == models.TodoActionUpdate {
+		return nil
+	}
+
+	_, err := db.Exec(ctx, `
+		WITH e AS (
+			INSERT INTO todo_events (todo_id, actor_id, action, changes, todo_version)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING *
+		)
+		SELECT pg_notify($6, `+todoNotifyPayload+`)
+		FROM e
+		JOIN todos t ON t.id = e.todo_id
+	`, after.ID, actorArg(actorID), action, changes, after.Version, TodoEventsChannel)
+	if err != nil {
+		return fmt.Errorf("記錄 todo 變更失敗: %w", err)
+	}
+	return nil
+}
+
+// 批次新增 / 匯入用：一次用 COPY 寫入所有 create 紀錄
+func recordTodoCreateEvents(ctx context.Context, tx pgx.Tx, actorID string, todos []*models.Todo) error {
+	actor, err := copyUUID(actorID)
+	if err != nil {
+		return err
+	}
+
+	columns := []string{"todo_id", "actor_id", "action", "changes", "todo_version"}
+	rows := pgx.CopyFromSlice(len(todos), func(i int) ([]any, error) {
+		return []any{todos[i].ID, actor, models.TodoActionCreate, todoChanges(nil, todos[i]), todos[i].Version}, nil
+	})
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"todo_events"}, columns, rows); err != nil {
+		return fmt.Errorf("記錄 todo 變更失敗: %w", err)
+	}
+
+	// COPY 拿不到新的 id，寫完再一次查出來通知
+	ids := make([]int, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	if _, err := tx.Exec(ctx, `
+		SELECT pg_notify($1, `+todoNotifyPayload+`)
+		FROM todo_events e
+		JOIN todos t ON t.id = e.todo_id
+		WHERE e.todo_id = ANY($2)
+		ORDER BY e.id
+	`, TodoEventsChannel, ids); err != nil {
+		return fmt.Errorf("通知 todo 變更失敗: %w", err)
+	}
+	return nil
+}
+
+// 變更紀錄，新的在前；垃圾桶裡的 todo 也查得到（才知道是誰刪的、可以 undo）
+// 不是自己的 todo 回傳 pgx.ErrNoRows
+func GetTodoEvents(ctx context.Context, pool *pgxpool.Pool, userID string, todoID int, limit int) ([]models.TodoEvent, error) {
+	var exists bool
+	if err := pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1 AND user_id = $2 AND `+todoAccessCondition+`)`,
+		todoID, userID,
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("查詢 todo 失敗: %w", err)
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	query := `
+		SELECT ` + todoEventColumns + `
+		FROM todo_events
+		WHERE todo_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	rows, err := pool.Query(ctx, query, todoID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 todo 變更紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.TodoEvent{}
+	for rows.Next() {
+		var event models.TodoEvent
+		if err := scanTodoEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("讀取 todo 變更紀錄失敗: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+/*
+GET /todos/stream 斷線重連用：這個使用者 afterID 之後的變更，舊的在前，最多 limit 筆
+
+跟即時推送（realtime.Hub）同一套規則：自己的個人 todo，加上自己「目前」是成員的清單裡的 todo（不管是誰建立的）
+
+id 是 commit 前就拿到的，同時進行的 transaction 可能比較小的 id 比較晚 commit，
+剛好在那一瞬間斷線的話這種變更可能補不到；前端重新整理列表就會拿到最新狀態
+*/
+func GetTodoStreamEvents(ctx context.Context, pool *pgxpool.Pool, userID string, afterID int64, limit int) ([]models.TodoStreamEvent, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT e.id, t.user_id, t.list_id, e.todo_id, e.action, e.todo_version, e.created_at
+		FROM todo_events e
+		JOIN todos t ON t.id = e.todo_id
+		WHERE e.id > $2
+		  AND (
+		      (t.list_id IS NULL AND t.user_id = $1)
+		      OR EXISTS (SELECT 1 FROM todo_list_members m WHERE m.list_id = t.list_id AND m.user_id = $1)
+		  )
+		ORDER BY e.id
+		LIMIT $3
+	`, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 todo 變更紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.TodoStreamEvent{}
+	for rows.Next() {
+		var event models.TodoStreamEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.ListID, &event.TodoID, &event.Action, &event.Version, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("讀取 todo 變更紀錄失敗: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// 目前最新的變更紀錄 id（不分使用者，id 是全域遞增的），一筆都沒有回傳 0
+// 新連上 GET /todos/stream 時當作起點，之後重連就從這裡補
+func LatestTodoEventID(ctx context.Context, pool *pgxpool.Pool) (int64, error) {
+	var id int64
+	if err := pool.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM todo_events`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("查詢 todo 變更紀錄失敗: %w", err)
+	}
+	return id, nil
+}
+
+// undo 可以還原的欄位：可以編輯的欄位，再加上排序位置跟垃圾桶狀態
+var todoUndoableFields = append(append([]string{}, todoEditableFields...), "position", "deleted_at")
+
+/*
+UndoLastTodoChange 撤銷這個 todo 的最後一筆變更：
+
+  - create：丟進垃圾桶
+  - 其他（update / move / delete / restore / undo）：把有變動的欄位改回 from 的值
+    delete 的 from 是 deleted_at = null，所以撤銷刪除就是還原；撤銷 undo 等於重做
+
+最後一筆紀錄改的欄位之後又被改過就不能撤銷，回傳 ErrUndoConflict
+只比這筆紀錄有改的欄位、不比 version：提醒排程、標籤、子任務這些不記錄的寫入也會讓 version +1，不應該擋住 undo
+撤銷本身也會記一筆 undo，回傳撤銷後的 todo
+*/
+func UndoLastTodoChange(ctx context.Context, pool *pgxpool.Pool, userID string, id int) (*models.Todo, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// 垃圾桶裡的也要找得到，才能撤銷刪除
+	var current models.Todo
+	err = scanTodo(tx.QueryRow(ctx, `
+		SELECT `+todoColumns+`
+		FROM todos
+		WHERE id = $1 AND user_id = $2 AND `+todoAccessCondition+`
+		FOR UPDATE
+	`, id, userID), &current)
+	if err != nil {
+		return nil, err
+	}
+
+	var last models.TodoEvent
+	err = scanTodoEvent(tx.QueryRow(ctx, `
+		SELECT `+todoEventColumns+`
+		FROM todo_events
+		WHERE todo_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, id), &last)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNothingToUndo
+		}
+		return nil, err
+	}
+
+	if field, err := modifiedSince(&current, &last); err != nil {
+		return nil, err
+	} else if field != "" {
+		return nil, fmt.Errorf("%w: %s has been modified since the last %s", ErrUndoConflict, field, last.Action)
+	}
+
+	updates, err := undoUpdates(&current, &last)
+	if err != nil {
+		return nil, err
+	}
+	if len(updates) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	// 改回原本的位置前先拿排序的鎖，避免跟同時在拖拉的人撞到
+	if _, ok := updates["position"]; ok {
+		if err := lockTodoPositions(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	extraSet := []string{"updated_at = CURRENT_TIMESTAMP"}
+	if _, ok := updates["remind_at"]; ok {
+		extraSet = append(extraSet, "reminded_at = NULL")
+	}
+
+	query, args, err := buildUpdate("todos", todoUndoableFields, updates, extraSet,
+		"id = $1 AND user_id = $2",
+		[]any{id, userID},
+		todoColumns,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted models.Todo
+	if err := scanTodo(tx.QueryRow(ctx, query, args...), &reverted); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("%w: the original position is taken by another todo", ErrUndoConflict)
+		}
+		return nil, fmt.Errorf("撤銷 todo 變更失敗: %w", err)
+	}
+
+	if err := spawnNextOccurrence(ctx, tx, userID, &reverted); err != nil {
+		return nil, err
+	}
+
+	if err := recordTodoEvent(ctx, tx, userID, models.TodoActionUndo, &current, &reverted); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &reverted, nil
+}
+
+// 最後一筆紀錄改過的欄位裡，目前的值跟當時寫入的 to 不一樣的第一個欄位；都一樣回傳空字串
+func modifiedSince(current *models.Todo, last *models.TodoEvent) (string, error) {
+	expected, err := applyTodoChanges(current, last.Changes, func(change models.TodoFieldChange) any { return change.To })
+	if err != nil {
+		return "", err
+	}
+
+	modified := patch.Changes(current, expected, "db")
+	fields := make([]string, 0, len(modified))
+	for field := range modified {
+		if _, ok := last.Changes[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return "", nil
+	}
+	slices.Sort(fields)
+	return fields[0], nil
+}
+
+// 要寫回去的欄位（key 是欄位名稱）
+func undoUpdates(current *models.Todo, last *models.TodoEvent) (map[string]any, error) {
+	if last.Action == models.TodoActionCreate {
+		if current.DeletedAt != nil {
+			return nil, nil
+		}
+		return map[string]any{"deleted_at": time.Now()}, nil
+	}
+
+	reverted, err := applyTodoChanges(current, last.Changes, func(change models.TodoFieldChange) any { return change.From })
+	if err != nil {
+		return nil, err
+	}
+
+	updates := patch.Changes(current, reverted, "db")
+	for field := range updates {
+		if _, ok := last.Changes[field]; !ok {
+			delete(updates, field)
+		}
+	}
+	return updates, nil
+}
+
+// 把紀錄裡每個欄位的值（from 或 to）套到 todo 的副本上：先組成 JSON 再解回 models.Todo，時間、指標這些型別才會對
+func applyTodoChanges(todo *models.Todo, changes map[string]models.TodoFieldChange, value func(models.TodoFieldChange) any) (*models.Todo, error) {
+	values := make(map[string]any, len(changes))
+	for field, change := range changes {
+		values[field] = value(change)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := *todo
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUndoConflict, err)
+	}
+	return &applied, nil
+}
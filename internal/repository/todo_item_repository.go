@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// 子任務的 SQL 都在這裡，ownership 檢查跟 transaction 由 service.TodoItemService 負責
+
+const todoItemColumns = `id, todo_id, title, completed, position, completed_at, created_at, updated_at`
+
+func scanTodoItem(row pgx.Row, item *models.TodoItem) error {
+	return row.Scan(
+		&item.ID,
+		&item.TodoID,
+		&item.Title,
+		&item.Completed,
+		&item.Position,
+		&item.CompletedAt,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+}
+
+// 鎖住自己的 todo（SELECT ... FOR UPDATE），transaction 結束前其他人不能改這筆
+// 不是自己的、不存在、在垃圾桶裡，都回傳 pgx.ErrNoRows
+func GetTodoForUpdate(ctx context.Context, db DBTX, userID string, id int) (*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND ` + todoAccessCondition + `
+		FOR UPDATE
+	`
+
+	var todo models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, id, userID), &todo); err != nil {
+		return nil, fmt.Errorf("查詢 todo 失敗: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// 只確認 todo 是自己的、不在垃圾桶裡（附件、計時紀錄用），不鎖；不是的話回傳 pgx.ErrNoRows
+func CheckActiveTodo(ctx context.Context, db DBTX, userID string, todoID int) error {
+	var exists bool
+	if err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND `+todoAccessCondition+`)`,
+		todoID, userID,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("查詢 todo 失敗: %w", err)
+	}
+	if !exists {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func GetTodoItems(ctx context.Context, db DBTX, todoID int) ([]models.TodoItem, error) {
+	query := `
+		SELECT ` + todoItemColumns + `
+		FROM todo_items
+		WHERE todo_id = $1
+		ORDER BY position, id
+	`
+
+	rows, err := db.Query(ctx, query, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢子任務失敗: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.TodoItem{}
+	for rows.Next() {
+		var item models.TodoItem
+		if err := scanTodoItem(rows, &item); err != nil {
+			return nil, fmt.Errorf("讀取子任務失敗: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取子任務失敗: %w", err)
+	}
+
+	return items, nil
+}
+
+// position 沒給（nil）就排在最後面
+func CreateTodoItem(ctx context.Context, db DBTX, todoID int, title string, position *int) (*models.TodoItem, error) {
+	query := `
+		INSERT INTO todo_items (todo_id, title, position)
+		VALUES ($1, $2, COALESCE($3, (SELECT COALESCE(MAX(position), -1) + 1 FROM todo_items WHERE todo_id = $1)))
+		RETURNING ` + todoItemColumns
+
+	var item models.TodoItem
+	if err := scanTodoItem(db.QueryRow(ctx, query, todoID, title, position), &item); err != nil {
+		return nil, fmt.Errorf("新增子任務失敗: %w", err)
+	}
+
+	return &item, nil
+}
+
+// item 是 service 套用完修改之後的完整內容；completed 從 false 變 true 時押上 completed_at
+func UpdateTodoItem(ctx context.Context, db DBTX, item *models.TodoItem) (*models.TodoItem, error) {
+	query := `
+		UPDATE todo_items
+		SET title = $1,
+		    completed_at = CASE
+		        WHEN $2 AND NOT completed THEN NOW()
+		        WHEN NOT $2 THEN NULL
+		        ELSE completed_at
+		    END,
+		    completed = $2,
+		    position = $3,
+		    updated_at = NOW()
+		WHERE id = $4 AND todo_id = $5
+		RETURNING ` + todoItemColumns
+
+	var updated models.TodoItem
+	if err := scanTodoItem(db.QueryRow(ctx, query, item.Title, item.Completed, item.Position, item.ID, item.TodoID), &updated); err != nil {
+		return nil, fmt.Errorf("更新子任務失敗: %w", err)
+	}
+
+	return &updated, nil
+}
+
+func GetTodoItemByID(ctx context.Context, db DBTX, todoID int, itemID int) (*models.TodoItem, error) {
+	query := `
+		SELECT ` + todoItemColumns + `
+		FROM todo_items
+		WHERE id = $1 AND todo_id = $2
+	`
+
+	var item models.TodoItem
+	if err := scanTodoItem(db.QueryRow(ctx, query, itemID, todoID), &item); err != nil {
+		return nil, fmt.Errorf("查詢子任務失敗: %w", err)
+	}
+
+	return &item, nil
+}
+
+func DeleteTodoItem(ctx context.Context, db DBTX, todoID int, itemID int) error {
+	cmdTag, err := db.Exec(ctx, `DELETE FROM todo_items WHERE id = $1 AND todo_id = $2`, itemID, todoID)
+	if err != nil {
+		return fmt.Errorf("刪除子任務失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// 子任務全部完成時，把 todo 本身也標記完成；回傳這次有沒有真的改到 todo
+// 有改到的話跟其他完成的方式一樣，週期性的 todo 產生下一次，再記一筆變更紀錄；actorID 是完成最後一個子任務的人
+func CompleteTodoIfAllItemsDone(ctx context.Context, db DBTX, actorID string, todoID int) (bool, error) {
+	query := `
+		UPDATE todos
+		SET completed = true, updated_at = NOW()
+		WHERE id = $1
+		  AND completed = false
+		  AND deleted_at IS NULL
+		  AND EXISTS (SELECT 1 FROM todo_items WHERE todo_id = $1)
+		  AND NOT EXISTS (SELECT 1 FROM todo_items WHERE todo_id = $1 AND completed = false)
+		RETURNING ` + todoColumns
+
+	var completed models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, todoID), &completed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("自動完成 todo 失敗: %w", err)
+	}
+
+	before := completed
+	before.Completed = false
+	if err := spawnNextOccurrence(ctx, db, actorID, &completed); err != nil {
+		return false, err
+	}
+	if err := recordTodoEvent(ctx, db, actorID, models.TodoActionUpdate, &before, &completed); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 子任務有變動時更新父 todo 的 updated_at，version 也會跟著 +1（GET /todos/:id 的 ETag 包含子任務）
+func TouchTodo(ctx context.Context, db DBTX, todoID int) error {
+	if _, err := db.Exec(ctx, `UPDATE todos SET updated_at = NOW() WHERE id = $1`, todoID); err != nil {
+		return fmt.Errorf("更新 todo 失敗: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+/*
+只要是下面情況都可以放這裡。
+1. 操作 todo_lists / todo_list_members / todo_list_invites
+2. 這裡只負責 SQL，誰可以做什麼（權限）由 service.TodoListService 判斷
+
+函式都收 ctx 跟 DBTX，service 需要的時候可以把多個步驟放進同一個 transaction
+*/
+
+func CreateTodoList(ctx context.Context, db DBTX, list *models.TodoList) (*models.TodoList, error) {
+	query := `
+		INSERT INTO todo_lists (owner_id, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, owner_id, name, description, created_at, updated_at
+	`
+
+	var created models.TodoList
+	err := db.QueryRow(ctx, query, list.OwnerID, list.Name, list.Description).Scan(
+		&created.ID,
+		&created.OwnerID,
+		&created.Name,
+		&created.Description,
+		&created.CreatedAt,
+		&created.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("新增清單失敗: %w", err)
+	}
+
+	return &created, nil
+}
+
+// 我參與的所有清單（不管是 owner、editor 還是 viewer），連同我的角色一起回傳
+func GetTodoListsForUser(ctx context.Context, db DBTX, userID string) ([]models.TodoList, error) {
+	query := `
+		SELECT l.id, l.owner_id, l.name, l.description, m.role, l.created_at, l.updated_at
+		FROM todo_lists l
+		JOIN todo_list_members m ON m.list_id = l.id
+		WHERE m.user_id = $1
+		ORDER BY l.created_at DESC
+	`
+
+	rows, err := db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢清單失敗: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []models.TodoList{}
+	for rows.Next() {
+		var list models.TodoList
+		if err := rows.Scan(&list.ID, &list.OwnerID, &list.Name, &list.Description, &list.Role, &list.CreatedAt, &list.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("讀取清單失敗: %w", err)
+		}
+		lists = append(lists, list)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取清單失敗: %w", err)
+	}
+
+	return lists, nil
+}
+
+// 不是成員的話回傳 pgx.ErrNoRows
+func GetTodoListForUser(ctx context.Context, db DBTX, listID string, userID string) (*models.TodoList, error) {
+	query := `
+		SELECT l.id, l.owner_id, l.name, l.description, m.role, l.created_at, l.updated_at
+		FROM todo_lists l
+		JOIN todo_list_members m ON m.list_id = l.id
+		WHERE l.id = $1 AND m.user_id = $2
+	`
+
+	var list models.TodoList
+	err := db.QueryRow(ctx, query, listID, userID).Scan(&list.ID, &list.OwnerID, &list.Name, &list.Description, &list.Role, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("查詢清單失敗: %w", err)
+	}
+
+	return &list, nil
+}
+
+func DeleteTodoList(ctx context.Context, db DBTX, listID string) error {
+	cmdTag, err := db.Exec(ctx, `DELETE FROM todo_lists WHERE id = $1`, listID)
+	if err != nil {
+		return fmt.Errorf("刪除清單失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// 使用者在清單裡的角色，不是成員的話回傳 pgx.ErrNoRows
+func GetTodoListMemberRole(ctx context.Context, db DBTX, listID string, userID string) (string, error) {
+	var role string
+	err := db.QueryRow(ctx, `SELECT role FROM todo_list_members WHERE list_id = $1 AND user_id = $2`, listID, userID).Scan(&role)
+	if err != nil {
+		return "", fmt.Errorf("查詢清單成員失敗: %w", err)
+	}
+	return role, nil
+}
+
+// 加入成員；已經是成員的話更新角色，但不會把 owner 降級
+func AddTodoListMember(ctx context.Context, db DBTX, listID string, userID string, role string) error {
+	query := `
+		INSERT INTO todo_list_members (list_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (list_id, user_id)
+		DO UPDATE SET role = EXCLUDED.role
+		WHERE todo_list_members.role <> 'owner'
+	`
+
+	if _, err := db.Exec(ctx, query, listID, userID, role); err != nil {
+		return fmt.Errorf("新增清單成員失敗: %w", err)
+	}
+	return nil
+}
+
+func GetTodoListMembers(ctx context.Context, db DBTX, listID string) ([]models.TodoListMember, error) {
+	query := `
+		SELECT m.list_id, m.user_id, u.email, m.role, m.created_at
+		FROM todo_list_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.list_id = $1
+		ORDER BY m.created_at
+	`
+
+	rows, err := db.Query(ctx, query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢清單成員失敗: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.TodoListMember{}
+	for rows.Next() {
+		var member models.TodoListMember
+		if err := rows.Scan(&member.ListID, &member.UserID, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("讀取清單成員失敗: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取清單成員失敗: %w", err)
+	}
+
+	return members, nil
+}
+
+// 移除成員，owner 不能被移除；沒有刪到任何一筆時回傳 pgx.ErrNoRows
+func RemoveTodoListMember(ctx context.Context, db DBTX, listID string, userID string) error {
+	query := `
+		DELETE FROM todo_list_members
+		WHERE list_id = $1 AND user_id = $2 AND role <> 'owner'
+	`
+
+	cmdTag, err := db.Exec(ctx, query, listID, userID)
+	if err != nil {
+		return fmt.Errorf("移除清單成員失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+const todoListInviteColumns = `id, list_id, email, role, invited_by, expires_at, accepted_at, revoked_at, created_at`
+
+func scanTodoListInvite(row pgx.Row, invite *models.TodoListInvite) error {
+	return row.Scan(
+		&invite.ID,
+		&invite.ListID,
+		&invite.Email,
+		&invite.Role,
+		&invite.InvitedBy,
+		&invite.ExpiresAt,
+		&invite.AcceptedAt,
+		&invite.RevokedAt,
+		&invite.CreatedAt,
+	)
+}
+
+// tokenHash 是 utils.HashToken 算出來的值，原始 token 不進資料庫
+func CreateTodoListInvite(ctx context.Context, db DBTX, invite *models.TodoListInvite, tokenHash string) (*models.TodoListInvite, error) {
+	query := `
+		INSERT INTO todo_list_invites (list_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + todoListInviteColumns
+
+	var created models.TodoListInvite
+	err := scanTodoListInvite(db.QueryRow(ctx, query, invite.ListID, invite.Email, invite.Role, tokenHash, invite.InvitedBy, invite.ExpiresAt), &created)
+	if err != nil {
+		return nil, fmt.Errorf("新增邀請失敗: %w", err)
+	}
+
+	return &created, nil
+}
+
+// 接受邀請時用，FOR UPDATE 鎖住這筆邀請，避免同一個 token 被同時接受兩次
+// 一定要在 transaction 裡呼叫才有意義
+func GetTodoListInviteByTokenHashForUpdate(ctx context.Context, db DBTX, tokenHash string) (*models.TodoListInvite, error) {
+	query := `
+		SELECT ` + todoListInviteColumns + `
+		FROM todo_list_invites
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	var invite models.TodoListInvite
+	if err := scanTodoListInvite(db.QueryRow(ctx, query, tokenHash), &invite); err != nil {
+		return nil, fmt.Errorf("查詢邀請失敗: %w", err)
+	}
+
+	return &invite, nil
+}
+
+// 還沒被接受、沒被撤銷、也還沒過期的邀請
+func GetPendingTodoListInvites(ctx context.Context, db DBTX, listID string, now time.Time) ([]models.TodoListInvite, error) {
+	query := `
+		SELECT ` + todoListInviteColumns + `
+		FROM todo_list_invites
+		WHERE list_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.Query(ctx, query, listID, now)
+	if err != nil {
+		return nil, fmt.Errorf("查詢邀請失敗: %w", err)
+	}
+	defer rows.Close()
+
+	invites := []models.TodoListInvite{}
+	for rows.Next() {
+		var invite models.TodoListInvite
+		if err := scanTodoListInvite(rows, &invite); err != nil {
+			return nil, fmt.Errorf("讀取邀請失敗: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取邀請失敗: %w", err)
+	}
+
+	return invites, nil
+}
+
+func MarkTodoListInviteAccepted(ctx context.Context, db DBTX, inviteID string) error {
+	if _, err := db.Exec(ctx, `UPDATE todo_list_invites SET accepted_at = NOW() WHERE id = $1`, inviteID); err != nil {
+		return fmt.Errorf("更新邀請失敗: %w", err)
+	}
+	return nil
+}
+
+// 撤銷還沒被接受的邀請；沒有符合的邀請時回傳 pgx.ErrNoRows
+func RevokeTodoListInvite(ctx context.Context, db DBTX, listID string, inviteID string) error {
+	query := `
+		UPDATE todo_list_invites
+		SET revoked_at = NOW()
+		WHERE id = $1 AND list_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+
+	cmdTag, err := db.Exec(ctx, query, inviteID, listID)
+	if err != nil {
+		return fmt.Errorf("撤銷邀請失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
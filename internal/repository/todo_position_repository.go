@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"todo_api/internal/models"
+	"todo_api/internal/rank"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrInvalidMove = errors.New("invalid move")
+
+/*
+排序位置是「每個建立者」一組（uq_todos_user_id_position），清單裡的 todo 也是用建立者的位置，
+同一個清單裡不同人建立的 todo 位置互相沒有關係，所以手動排序只開放給個人的 todo：
+MoveTodo 遇到清單裡的 todo 回傳 ErrInvalidMove，GET /lists/:id/todos 也不能用 sort=position
+*/
+
+/*
+同一個使用者的排序位置都要先拿這把鎖（transaction 結束自動放開）：
+兩個人同時把不同的 todo 拖到同一個空隙，算出來的鍵會一樣，
+先排隊再算就不會撞；uq_todos_user_id_position 是最後一道防線
+*/
+func lockTodoPositions(ctx context.Context, db DBTX, userID string) error {
+	if _, err := db.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('todo_position:' || $1))`, userID); err != nil {
+		return fmt.Errorf("鎖定排序位置失敗: %w", err)
+	}
+	return nil
+}
+
+// 排在這個使用者所有 todo 的最後面（包含垃圾桶裡的，避免還原時撞到）
+func nextTodoPosition(ctx context.Context, db DBTX, userID string) (string, error) {
+	positions, err := nextTodoPositions(ctx, db, userID, 1)
+	if err != nil {
+		return "", err
+	}
+	return positions[0], nil
+}
+
+// 批次新增用：一次拿 n 個依序排在最後面的位置
+func nextTodoPositions(ctx context.Context, db DBTX, userID string, n int) ([]string, error) {
+	if err := lockTodoPositions(ctx, db, userID); err != nil {
+		return nil, err
+	}
+
+	var last *string
+	if err := db.QueryRow(ctx, `SELECT MAX(position) FROM todos WHERE user_id = $1`, userID).Scan(&last); err != nil {
+		return nil, fmt.Errorf("查詢排序位置失敗: %w", err)
+	}
+
+	lower := ""
+	if last != nil {
+		lower = *last
+	}
+	return rank.After(lower, n)
+}
+
+// 要移動的 todo 跟鄰居的位置；不是自己的、在垃圾桶裡的都當作不存在，清單裡的 todo 回傳 ErrInvalidMove
+func todoPosition(ctx context.Context, db DBTX, userID string, id int) (string, error) {
+	var position string
+	var inList bool
+	err := db.QueryRow(ctx,
+		`SELECT position, list_id IS NOT NULL FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND `+todoAccessCondition,
+		id, userID,
+	).Scan(&position, &inList)
+	if err != nil {
+		return "", err
+	}
+	if inList {
+		return "", fmt.Errorf("%w: todo %d is in a shared list and cannot be reordered", ErrInvalidMove, id)
+	}
+	return position, nil
+}
+
+/*
+MoveTodo 把 todo 移到 before 跟 after 中間：
+  - before：移動後排在它「前面」的 todo
+  - after：移動後排在它「後面」的 todo
+
+只給一邊時，另一邊用目前緊鄰的那一筆；只會改被移動的這一筆
+鄰居不存在回傳 pgx.ErrNoRows，兩個鄰居順序顛倒、是自己、或是清單裡的 todo 回傳 ErrInvalidMove
+*/
+func MoveTodo(ctx context.Context, pool *pgxpool.Pool, userID string, id int, beforeID *int, afterID *int) (*models.Todo, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	moved, err := MoveTodoTx(ctx, tx, userID, id, beforeID, afterID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
+
+// MoveTodo 的本體，在呼叫端的 transaction 裡執行（批次操作也會用到）
+func MoveTodoTx(ctx context.Context, tx pgx.Tx, userID string, id int, beforeID *int, afterID *int) (*models.Todo, error) {
+	if beforeID == nil && afterID == nil {
+		return nil, fmt.Errorf("%w: before or after is required", ErrInvalidMove)
+	}
+	if (beforeID != nil && *beforeID == id) || (afterID != nil && *afterID == id) {
+		return nil, fmt.Errorf("%w: cannot move a todo next to itself", ErrInvalidMove)
+	}
+
+	if err := lockTodoPositions(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	original, err := todoPosition(ctx, tx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	lower, upper := "", ""
+	if beforeID != nil {
+		if lower, err = todoPosition(ctx, tx, userID, *beforeID); err != nil {
+			return nil, err
+		}
+	}
+	if afterID != nil {
+		if upper, err = todoPosition(ctx, tx, userID, *afterID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 只給一邊：找出另一邊緊鄰的位置（跳過自己；垃圾桶裡的也算，新位置才不會跟它們重複）
+	if afterID == nil {
+		upper, err = adjacentPosition(ctx, tx, userID, id, `position > $3`, `MIN`, lower)
+	} else if beforeID == nil {
+		lower, err = adjacentPosition(ctx, tx, userID, id, `position < $3`, `MAX`, upper)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := rank.Between(lower, upper)
+	if err != nil {
+		if errors.Is(err, rank.ErrNoRoom) {
+			return nil, fmt.Errorf("%w: before must come ahead of after", ErrInvalidMove)
+		}
+		return nil, err
+	}
+
+	query := `
+		UPDATE todos
+		SET position = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL AND ` + todoAccessCondition + `
+		RETURNING ` + todoColumns
+
+	var moved models.Todo
+	if err := scanTodo(tx.QueryRow(ctx, query, position, id, userID), &moved); err != nil {
+		return nil, fmt.Errorf("移動 todo 失敗: %w", err)
+	}
+
+	before := moved
+	before.Position = original
+	if err := recordTodoEvent(ctx, tx, userID, models.TodoActionMove, &before, &moved); err != nil {
+		return nil, err
+	}
+
+	return &moved, nil
+}
+
+// 緊鄰 pivot 的位置，沒有的話回傳空字串（代表最前面 / 最後面）
+func adjacentPosition(ctx context.Context, db DBTX, userID string, excludeID int, cond string, agg string, pivot string) (string, error) {
+	query := fmt.Sprintf(`SELECT %s(position) FROM todos WHERE user_id = $1 AND id <> $2 AND %s`, agg, cond)
+
+	var position *string
+	if err := db.QueryRow(ctx, query, userID, excludeID, pivot).Scan(&position); err != nil {
+		return "", fmt.Errorf("查詢排序位置失敗: %w", err)
+	}
+	if position == nil {
+		return "", nil
+	}
+	return *position, nil
+}
+
+// 排序鍵長度超過 maxLength 的使用者，交給 RebalanceTodoPositions 重新分配
+func GetUsersNeedingRebalance(ctx context.Context, db DBTX, maxLength int, limit int) ([]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT user_id::text
+		FROM todos
+		WHERE user_id IS NOT NULL AND length(position) > $1
+		LIMIT $2
+	`, maxLength, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢需要重新排序的使用者失敗: %w", err)
+	}
+
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("讀取需要重新排序的使用者失敗: %w", err)
+	}
+	return userIDs, nil
+}
+
+/*
+把一個使用者所有 todo 的位置照目前順序重新平均分配（rank.Spread），鍵會變回很短
+整批改寫的過程中新舊鍵可能暫時重複，所以把 unique 檢查延到 commit
+只改 position、不碰 updated_at，version 不會 +1，client 手上的 ETag 不會因為背景工作失效
+回傳改寫了幾筆
+*/
+func RebalanceTodoPositions(ctx context.Context, pool *pgxpool.Pool, userID string) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockTodoPositions(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `SET CONSTRAINTS uq_todos_user_id_position DEFERRED`); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id FROM todos WHERE user_id = $1 ORDER BY position, id`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("查詢排序位置失敗: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, fmt.Errorf("讀取排序位置失敗: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// 一次 UPDATE 全部寫回，不用一筆一筆來回
+	positions := rank.Spread(len(ids))
+	if _, err := tx.Exec(ctx, `
+		UPDATE todos t
+		SET position = p.position
+		FROM unnest($1::int[], $2::text[]) AS p(id, position)
+		WHERE t.id = p.id AND t.user_id = $3
+	`, ids, positions, userID); err != nil {
+		return 0, fmt.Errorf("重新排序失敗: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
@@ -2,21 +2,86 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 	"todo_api/internal/models"
+	"todo_api/internal/pagination"
+	"todo_api/internal/recurrence"
 	"todo_api/internal/utils"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // repository層: 建立物件 → 寫入資料庫 → 回傳完整物件
 
-// 傳入的是 todo 結構體對應的json的key名稱 	(上層)todo, err := repository.CreateTodo(pool, input.Title, input.Completed)
-func CreateTodo(pool *pgxpool.Pool, title string, completed bool) (*models.Todo, error) {
+// 每支查詢 RETURNING / SELECT 的欄位順序都要跟 scanTodo 一致，集中在這裡避免各自漏掉新欄位
+const todoColumns = `id, user_id, list_id, title, completed, due_at, priority, notes, remind_at, reminded_at, recurrence_rule, recurrence_tz, recurrence_start, recurrence_next_id, position, version, created_at, updated_at, deleted_at`
+
+/*
+個人路由（/todos、/todos/:id/...、/sync）的權限：除了 user_id 是自己，清單裡的 todo 還要自己目前仍是清單成員，
+不然被移出清單的人還能用 /todos/:id 繼續看、改自己在清單裡建立的 todo
+alias 是 todos 在查詢裡的名稱；清單路由（/lists/:id/todos）由 TodoListService 檢查角色，不用這個條件
+*/
+func todoAccessible(alias string) string {
+	return fmt.Sprintf(`(%[1]s.list_id IS NULL OR EXISTS (
+		SELECT 1 FROM todo_list_members m WHERE m.list_id = %[1]s.list_id AND m.user_id = %[1]s.user_id
+	))`, alias)
+}
+
+var todoAccessCondition = todoAccessible("todos")
+
+// pgx.Row 跟 pgx.Rows 都有 Scan，所以 QueryRow 跟 rows.Next() 都可以共用
+func scanTodo(row pgx.Row, todo *models.Todo) error {
+	return row.Scan(
+		&todo.ID,
+		&todo.UserID,
+		&todo.ListID,
+		&todo.Title,
+		&todo.Completed,
+		&todo.DueAt,
+		&todo.Priority,
+		&todo.Notes,
+		&todo.RemindAt,
+		&todo.RemindedAt,
+		&todo.RecurrenceRule,
+		&todo.RecurrenceTZ,
+		&todo.RecurrenceStart,
+		&todo.RecurrenceNextID,
+		&todo.Position,
+		&todo.Version,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.DeletedAt,
+	)
+}
+
+// GET /todos 可以排序的欄位白名單：key 是前端傳的名稱，value 是實際的 SQL 運算式
+// 欄位名稱不能用 $1 參數化，所以一定要走白名單，不能把前端字串直接拼進 ORDER BY
+var todoSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"due_at":     "due_at",
+	"title":      "title",
+	"position":   "position",
+	// priority 是文字，直接排會變成字母順序，所以換成數字
+	"priority": "CASE priority WHEN 'low' THEN 0 WHEN 'normal' THEN 1 WHEN 'high' THEN 2 WHEN 'urgent' THEN 3 END",
+}
+
+// 讓 handler 在呼叫 repository 前先檢查排序欄位，不合法就回 400
+func IsValidTodoSortField(field string) bool {
+	_, ok := todoSortColumns[field]
+	return ok
+}
+
+// 跟 CreateUser 一樣傳入 struct，欄位越來越多，不再一個一個參數傳
+// todo.UserID 來自 AuthMiddleware 放在 gin context 的 user_id，新增的 todo 一律歸屬於目前登入的使用者
+func CreateTodo(pool *pgxpool.Pool, todo *models.Todo) (*models.Todo, error) {
 	// 建立帶有背景上下文的連線池
 	var ctx context.Context
 	var cancel context.CancelFunc
@@ -26,22 +91,95 @@ func CreateTodo(pool *pgxpool.Pool, title string, completed bool) (*models.Todo,
 
 	utils.PerformOperation(ctx)
 
+	// 新的 todo 排在最後面，算位置跟新增要在同一個 transaction 裡（見 insertTodo）
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := insertTodo(ctx, tx, todo.UserID, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// 跟 CreateTodo 一樣，但由呼叫端的 transaction 決定什麼時候 commit（快速新增要跟標籤一起寫入）
+func CreateTodoTx(ctx context.Context, tx pgx.Tx, todo *models.Todo) (*models.Todo, error) {
+	return insertTodo(ctx, tx, todo.UserID, todo)
+}
+
+// CreateTodo 跟產生下一次週期 todo 共用；db 必須是 transaction，排序位置的鎖到 commit 才會放開
+// actorID 是做這個動作的使用者，記在變更紀錄裡
+func insertTodo(ctx context.Context, db DBTX, actorID string, todo *models.Todo) (*models.Todo, error) {
+	position, err := nextTodoPosition(ctx, db, todo.UserID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 在資料表名稱 todos 中，對 表 的欄位新增一筆資料
-	query := `INSERT INTO todos (title, completed) VALUES ($1, $2) RETURNING id, title, completed, created_at, updated_at`
+	query := `
+		INSERT INTO todos (user_id, list_id, title, completed, due_at, priority, notes, remind_at,
+		                   recurrence_rule, recurrence_tz, recurrence_start, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + todoColumns
 
-	var todo models.Todo
-	// 其實是在做「執行 SQL（只拿一筆結果）→ 把回傳欄位塞進 todo 這個 struct」
-	// title, completed：會依序對應到 SQL 裡的 $1, $2，也就是 VALUES ($1, $2) => 所以前端傳來的 title, completed 會依序寫入到 $1, $2
-	err := pool.QueryRow(ctx, query, title, completed).Scan(&todo.ID, &todo.Title, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
+	if todo.Priority == "" {
+		todo.Priority = models.TodoPriorityNormal
+	}
+	if todo.RecurrenceTZ == "" {
+		todo.RecurrenceTZ = "UTC"
+	}
+
+	var created models.Todo
+	// 其實是在做「執行 SQL（只拿一筆結果）→ 把回傳欄位塞進 created 這個 struct」
+	// 參數會依序對應到 SQL 裡的 $1 ~ $12
+	err = scanTodo(db.QueryRow(ctx, query,
+		todo.UserID,
+		todo.ListID,
+		todo.Title,
+		todo.Completed,
+		todo.DueAt,
+		todo.Priority,
+		todo.Notes,
+		todo.RemindAt,
+		todo.RecurrenceRule,
+		todo.RecurrenceTZ,
+		todo.RecurrenceStart,
+		position,
+	), &created)
 
 	if err != nil {
 		return nil, fmt.Errorf("新增 todo 失敗: %w", err)
 	}
 
-	return &todo, nil
+	if err := recordTodoEvent(ctx, db, actorID, models.TodoActionCreate, nil, &created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
 }
 
-func GetTodos(pool *pgxpool.Pool, page int, pageSize int) (*models.TodoListResponse, error) {
+// 只查目前登入使用者自己的 todos，已經丟進垃圾桶的不算
+// /todos?page=1&pageSize=5&completed=false&q=買書&sort=due_at:asc&overdue=true&due_before=2025-01-31&created_after=2025-01-01
+// 分頁用 params：offset 模式（page / pageSize）或 cursor 模式（cursor=...），見 pagination 套件
+func GetTodos(pool *pgxpool.Pool, userID string, params pagination.Params, filter models.TodoFilter) (*models.TodoListResponse, error) {
+	return getTodosWhere(pool, "user_id = $1 AND "+todoAccessCondition, userID, params, filter)
+}
+
+// 清單裡的 todos（不管是誰建立的），權限檢查由 service.TodoListService 負責
+func GetListTodos(pool *pgxpool.Pool, listID string, params pagination.Params, filter models.TodoFilter) (*models.TodoListResponse, error) {
+	return getTodosWhere(pool, "list_id = $1", listID, params, filter)
+}
+
+// GetTodos / GetListTodos 共用：scope 是第一個條件（一定用 $1），其他篩選、排序、分頁都一樣
+func getTodosWhere(pool *pgxpool.Pool, scope string, scopeArg any, params pagination.Params, filter models.TodoFilter) (*models.TodoListResponse, error) {
 
 	// 建立帶有背景上下文的連線池
 	var ctx context.Context
@@ -52,24 +190,156 @@ func GetTodos(pool *pgxpool.Pool, page int, pageSize int) (*models.TodoListRespo
 
 	utils.PerformOperation(ctx)
 
-	offset := (page - 1) * pageSize // 決定前面要先跳過多少筆資料，第 1 頁：前面不用跳過，第 2 頁：先跳過第 1 頁那些資料，第 3 頁：先跳過前 2 頁那些資料
-	// 先查總筆數
-	var totalCount int
-	var countQuery string = `SELECT COUNT(*) FROM todos`
-	err := pool.QueryRow(ctx, countQuery).Scan(&totalCount)
-	if err != nil {
-		return nil, fmt.Errorf("查詢 todos 總數失敗: %w", err)
+	// 跟 GetArticles 一樣，用 conditions + argIndex 組出參數化的 WHERE 子句
+	conditions := []string{scope, "deleted_at IS NULL"}
+	args := []interface{}{scopeArg}
+	argIndex := 2
+
+	if filter.Completed != nil {
+		conditions = append(conditions, fmt.Sprintf("completed = $%d", argIndex))
+		args = append(args, *filter.Completed)
+		argIndex++
+	}
+
+	// 全文搜尋，websearch_to_tsquery 支援 "買 書"、"-已取消" 這種寫法，而且不會因為使用者亂打符號就語法錯誤
+	if filter.Query != "" {
+		conditions = append(conditions, fmt.Sprintf("search_vector @@ websearch_to_tsquery('simple', $%d)", argIndex))
+		args = append(args, filter.Query)
+		argIndex++
+	}
+
+	// 逾期：有截止時間、已經過了、而且還沒完成
+	if filter.Overdue {
+		conditions = append(conditions, "due_at < NOW()", "completed = false")
+	}
+
+	if filter.DueBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("due_at < $%d", argIndex))
+		args = append(args, *filter.DueBefore)
+		argIndex++
+	}
+
+	if filter.DueAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("due_at > $%d", argIndex))
+		args = append(args, *filter.DueAfter)
+		argIndex++
+	}
+
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argIndex))
+		args = append(args, *filter.CreatedBefore)
+		argIndex++
+	}
+
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at > $%d", argIndex))
+		args = append(args, *filter.CreatedAfter)
+		argIndex++
+	}
+
+	// 標籤用 slug 篩選：any 是有其中一個就算，all 是每一個都要有
+	if len(filter.Labels) > 0 {
+		if filter.LabelsMatch == models.LabelMatchAll {
+			conditions = append(conditions, fmt.Sprintf(`(
+				SELECT COUNT(DISTINCT t.slug)
+				FROM todo_tags tt
+				JOIN tags t ON t.id = tt.tag_id
+				WHERE tt.todo_id = todos.id AND t.slug = ANY($%d)
+			) = $%d`, argIndex, argIndex+1))
+			args = append(args, filter.Labels, len(filter.Labels))
+			argIndex += 2
+		} else {
+			conditions = append(conditions, fmt.Sprintf(`EXISTS (
+				SELECT 1
+				FROM todo_tags tt
+				JOIN tags t ON t.id = tt.tag_id
+				WHERE tt.todo_id = todos.id AND t.slug = ANY($%d)
+			)`, argIndex))
+			args = append(args, filter.Labels)
+			argIndex++
+		}
+	}
+
+	// 排序：沒指定或不在白名單就用預設的 created_at DESC
+	sortExpr, ok := todoSortColumns[filter.SortField]
+	if !ok {
+		filter.SortField = "created_at"
+		filter.SortDesc = true
+		sortExpr = todoSortColumns["created_at"]
+	}
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
 	}
 
+	response := &models.TodoListResponse{
+		Mode:     params.Mode,
+		Page:     params.Page,
+		PageSize: params.PageSize,
+		Filters:  filter,
+	}
+
+	var orderClause string
+	var pagingClause string
+	if params.IsCursor() {
+		// keyset 需要「不會是 NULL」的排序欄位，due_at / title / priority 都不適合
+		if !todoKeysetSortFields[filter.SortField] {
+			return nil, fmt.Errorf("%w: sort by %s does not support cursor paging", pagination.ErrInvalidCursor, filter.SortField)
+		}
+		sortSpec := filter.SortField + ":" + strings.ToLower(direction)
+		if err := params.CheckSort(sortSpec); err != nil {
+			return nil, err
+		}
+
+		keyset := pagination.Keyset{Columns: []string{filter.SortField, "id"}, Desc: filter.SortDesc}
+		if params.Cursor != nil {
+			cursorID, err := params.Cursor.IntID()
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, keyset.Where(params.Backward(), argIndex))
+			args = append(args, params.Cursor.Time, cursorID)
+			argIndex += 2
+		}
+
+		orderClause = keyset.OrderBy(params.Backward())
+		// 多拿一筆，用來判斷還有沒有下一頁
+		pagingClause = fmt.Sprintf("LIMIT $%d", argIndex)
+		args = append(args, params.PageSize+1)
+	} else {
+		// due_at 可能是 NULL，沒有期限的一律排最後；最後用 id 當 tie-breaker，分頁才不會前後頁重複
+		orderClause = fmt.Sprintf("%s %s NULLS LAST, id %s", sortExpr, direction, direction)
+
+		// 先查總筆數（只有 offset 模式需要，cursor 模式就是為了省掉這個 COUNT）
+		whereClause := strings.Join(conditions, " AND ")
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM todos WHERE %s`, whereClause)
+		err := pool.QueryRow(ctx, countQuery, args...).Scan(&response.TotalCount)
+		if err != nil {
+			return nil, fmt.Errorf("查詢 todos 總數失敗: %w", err)
+		}
+
+		// ceil(totalCount / pageSize) => 向上取整
+		response.TotalPages = (response.TotalCount + params.PageSize - 1) / params.PageSize
+		if response.TotalPages == 0 {
+			response.TotalPages = 1
+		}
+
+		// LIMIT => 最多取幾筆, OFFSET => 跳過幾筆
+		pagingClause = fmt.Sprintf("LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+		args = append(args, params.PageSize, params.Offset())
+	}
+
+	whereClause := strings.Join(conditions, " AND ")
+
 	// 再查當前頁資料
-	var query string = `
-		SELECT id, title, completed, created_at, updated_at
+	query := fmt.Sprintf(`
+		SELECT %s
 		FROM todos
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
-	// LIMIT => 最多取幾筆, OFFSET => 跳過幾筆
-	rows, err := pool.Query(ctx, query, pageSize, offset)
+		WHERE %s
+		ORDER BY %s
+		%s
+	`, todoColumns, whereClause, orderClause, pagingClause)
+	rows, err := pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("查詢 todos 失敗: %w", err)
 	}
@@ -78,7 +348,7 @@ func GetTodos(pool *pgxpool.Pool, page int, pageSize int) (*models.TodoListRespo
 	var todos []models.Todo
 	for rows.Next() {
 		var todo models.Todo
-		if err := rows.Scan(&todo.ID, &todo.Title, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
+		if err := scanTodo(rows, &todo); err != nil {
 			return nil, fmt.Errorf("讀取 todo 失敗: %w", err)
 		}
 		todos = append(todos, todo)
@@ -88,24 +358,50 @@ func GetTodos(pool *pgxpool.Pool, page int, pageSize int) (*models.TodoListRespo
 		return nil, fmt.Errorf("讀取 todos 失敗: %w", err)
 	}
 
-	// ceil(totalCount / pageSize) => 向上取整
-	totalPages := (totalCount + pageSize - 1) / pageSize
-	if totalPages == 0 {
-		totalPages = 1
+	if params.IsCursor() {
+		hasNext, hasPrev := params.Bounds(len(todos))
+		if len(todos) > params.PageSize {
+			todos = todos[:params.PageSize]
+		}
+		if params.Backward() {
+			pagination.Reverse(todos)
+		}
+
+		sortSpec := filter.SortField + ":" + strings.ToLower(direction)
+		if len(todos) > 0 {
+			first, last := todos[0], todos[len(todos)-1]
+			if hasNext {
+				response.NextCursor = params.Codec.Encode(pagination.Cursor{Sort: sortSpec, Time: todoSortTime(last, filter.SortField), ID: strconv.Itoa(last.ID)})
+			}
+			if hasPrev {
+				response.PrevCursor = params.Codec.Encode(pagination.Cursor{Sort: sortSpec, Time: todoSortTime(first, filter.SortField), ID: strconv.Itoa(first.ID), Backward: true})
+			}
+		}
 	}
 
-	response := &models.TodoListResponse{
-		Items:      todos,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalCount: totalCount,
-		TotalPages: totalPages,
+	if err := attachTodoLabels(ctx, pool, todos); err != nil {
+		return nil, err
 	}
 
+	response.Items = todos
 	return response, nil
 }
 
-func GetTodoByID(pool *pgxpool.Pool, id int) (*models.Todo, error) {
+// 可以用 cursor 分頁的排序欄位（一定有值的時間欄位）
+var todoKeysetSortFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+func todoSortTime(todo models.Todo, field string) time.Time {
+	if field == "updated_at" {
+		return todo.UpdatedAt
+	}
+	return todo.CreatedAt
+}
+
+// 同時比對 id 跟 user_id，別人的 todo、已經在垃圾桶的 todo、被移出的清單裡的 todo 都會跟不存在的 todo 一樣回傳 pgx.ErrNoRows
+func GetTodoByID(pool *pgxpool.Pool, userID string, id int) (*models.Todo, error) {
 	// 建立帶有背景上下文的連線池
 	var ctx context.Context
 	var cancel context.CancelFunc
@@ -117,17 +413,16 @@ func GetTodoByID(pool *pgxpool.Pool, id int) (*models.Todo, error) {
 
 	// 在資料表名稱 todos 中，對 表 的欄位新增一筆資料
 	var query string = `
-		SELECT id, title, completed, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos
-		WHERE id = $1
-	`
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND ` + todoAccessCondition
 
 	var todo models.Todo
 	// 其實是在做「執行 SQL（只拿一筆結果）→ 把回傳欄位塞進 todo 這個 struct」
-	err := pool.QueryRow(ctx, query, id).Scan(&todo.ID, &todo.Title, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
+	err := scanTodo(pool.QueryRow(ctx, query, id, userID), &todo)
 
 	if err != nil {
-		return nil, fmt.Errorf("新增 todo 失敗: %w", err)
+		return nil, fmt.Errorf("查詢 todo 失敗: %w", err)
 	}
 
 	return &todo, nil
@@ -146,7 +441,9 @@ func GetTodoByID(pool *pgxpool.Pool, id int) (*models.Todo, error) {
 
 目前這個做法已經很務實了，先把「資料不壞」守住是最重要的，其他 call 的問題相對次要（除非你已經看到有大量異常呼叫在打）。
 */
-func UpdateTodo(pool *pgxpool.Pool, id int, title string, completed bool, readonlyTest bool) (*models.Todo, error) {
+// todo 是 handler 已經把前端有傳的欄位套用到現有資料之後的完整內容，這裡整筆寫回去
+// actorID 是做這個動作的使用者（清單裡的 todo 不一定是擁有者），記在變更紀錄裡
+func UpdateTodo(pool *pgxpool.Pool, actorID string, todo *models.Todo, readonlyTest bool) (*models.Todo, error) {
 	const maxRetries = 1
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
@@ -166,20 +463,9 @@ func UpdateTodo(pool *pgxpool.Pool, id int, title string, completed bool, readon
 		}
 		// ──────────────────────────────────────────────────────────────
 
-		var query = `
-            UPDATE todos
-            SET title = $1, completed = $2, updated_at = CURRENT_TIMESTAMP
-            WHERE id = $3
-            RETURNING id, title, completed, created_at, updated_at
-        `
-
-		var todo models.Todo
-		err := pool.QueryRow(ctx, query, title, completed, id).Scan(
-			&todo.ID, &todo.Title, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt,
-		)
-
+		updated, err := updateTodoOnce(ctx, pool, actorID, todo)
 		if err == nil {
-			return &todo, nil
+			return updated, nil
 		}
 
 		// 你的 read-only 偵測邏輯...
@@ -200,6 +486,273 @@ func UpdateTodo(pool *pgxpool.Pool, id int, title string, completed bool, readon
 	return nil, fmt.Errorf("超過重試次數")
 }
 
+/*
+整體流程（同一個 transaction）：
+1. 整筆寫回 todo，todo.Version 必須跟資料庫裡的一致，否則回傳 ErrVersionConflict
+2. 如果是週期性的 todo、這次變成已完成、還沒產生過下一次 => 依規則算出下一次的日期，新增一筆 todo
+3. 把新 todo 的 id 記在 recurrence_next_id，之後取消完成再完成也不會重複產生
+4. 記一筆變更紀錄（改了哪些欄位、誰改的）
+第 1 步的 UPDATE 會鎖住這一筆，兩個 request 同時完成同一個 todo 時，第二個會看到第一個寫好的 recurrence_next_id
+*/
+func updateTodoOnce(ctx context.Context, pool *pgxpool.Pool, actorID string, todo *models.Todo) (*models.Todo, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	updated, err := UpdateTodoTx(ctx, tx, actorID, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// UpdateTodo 的本體，在呼叫端的 transaction 裡執行（批次操作也會用到）
+func UpdateTodoTx(ctx context.Context, db DBTX, actorID string, todo *models.Todo) (*models.Todo, error) {
+	// 變更紀錄要有改之前的值；順便鎖住這一筆
+	before, err := GetTodoForUpdate(ctx, db, todo.UserID, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return updateLockedTodo(ctx, db, actorID, before, todo)
+}
+
+/*
+清單裡的 todo 整筆寫回，權限檢查由 service.TodoListService 負責
+
+用 list_id 鎖住這一筆，不用 GetTodoForUpdate：那個會檢查建立者還是不是成員，
+建立者被移出清單之後，其他成員還是要能改清單裡的 todo
+*/
+func UpdateListTodo(ctx context.Context, pool *pgxpool.Pool, actorID string, listID string, todo *models.Todo) (*models.Todo, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := GetListTodoForUpdate(ctx, tx, listID, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := updateLockedTodo(ctx, tx, actorID, before, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// before 是呼叫端已經用 FOR UPDATE 鎖住的那一筆
+func updateLockedTodo(ctx context.Context, db DBTX, actorID string, before *models.Todo, todo *models.Todo) (*models.Todo, error) {
+	query := `
+		UPDATE todos
+		SET title = $1,
+		    completed = $2,
+		    due_at = $3,
+		    priority = $4,
+		    notes = $5,
+		    -- 提醒時間被改掉的話，要重新提醒一次
+		    reminded_at = CASE WHEN remind_at IS DISTINCT FROM $6 THEN NULL ELSE reminded_at END,
+		    remind_at = $6,
+		    recurrence_rule = $7,
+		    recurrence_tz = $8,
+		    recurrence_start = $9,
+		    updated_at = CURRENT_TIMESTAMP
+		-- version 對不上代表讀出來之後被別人改過了，不能蓋掉（version 由 trigger 自動 +1）
+		WHERE id = $10 AND user_id = $11 AND deleted_at IS NULL AND version = $12
+		RETURNING ` + todoColumns
+
+	if todo.RecurrenceTZ == "" {
+		todo.RecurrenceTZ = "UTC"
+	}
+
+	var updated models.Todo
+	err := scanTodo(db.QueryRow(ctx, query,
+		todo.Title,
+		todo.Completed,
+		todo.DueAt,
+		todo.Priority,
+		todo.Notes,
+		todo.RemindAt,
+		todo.RecurrenceRule,
+		todo.RecurrenceTZ,
+		todo.RecurrenceStart,
+		todo.ID,
+		todo.UserID,
+		todo.Version,
+	), &updated)
+	if err != nil {
+		return nil, todoWriteError(ctx, db, todo.UserID, todo.ID, err)
+	}
+
+	if err := spawnNextOccurrence(ctx, db, actorID, &updated); err != nil {
+		return nil, err
+	}
+
+	// 產生下一次會再改一次 version，所以紀錄放在最後，記的才是最新的 version
+	if err := recordTodoEvent(ctx, db, actorID, models.TodoActionUpdate, before, &updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// 帶 version 的 UPDATE 沒有更新到任何一筆時，分辨是 todo 不存在，還是 version 過期
+func todoWriteError(ctx context.Context, db DBTX, userID string, id int, err error) error {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	var exists bool
+	if err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+		id, userID,
+	).Scan(&exists); err == nil && exists {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+// 週期性的 todo 這次變成已完成、還沒產生過下一次 => 產生下一次，並把 id 寫回 todo
+func spawnNextOccurrence(ctx context.Context, db DBTX, actorID string, todo *models.Todo) error {
+	if !todo.Completed || todo.RecurrenceRule == "" || todo.RecurrenceNextID != nil {
+		return nil
+	}
+
+	next, err := createNextOccurrence(ctx, db, actorID, todo)
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		todo.RecurrenceNextID = &next.ID
+	}
+	return nil
+}
+
+// PATCH /todos/:id 可以寫入的欄位（白名單）；recurrence_start 不是前端直接給的，由 handler 依規則計算
+var todoEditableFields = []string{
+	"title", "completed", "due_at", "priority", "notes", "remind_at",
+	"recurrence_rule", "recurrence_tz", "recurrence_start",
+}
+
+/*
+PATCH 用：只寫入有變動的欄位（updates 的 key 是欄位名稱），跟 UpdateProduct 共用 buildUpdate
+  - expectedVersion 對不上回傳 ErrVersionConflict
+  - 提醒時間有改就清掉 reminded_at，讓排程重新提醒
+  - 跟 UpdateTodo 一樣，週期性的 todo 完成時在同一個 transaction 產生下一次，並記一筆變更紀錄
+*/
+func PatchTodo(ctx context.Context, pool *pgxpool.Pool, userID string, id int, expectedVersion int, updates map[string]any) (*models.Todo, error) {
+	extraSet := []string{"updated_at = CURRENT_TIMESTAMP"}
+	if _, ok := updates["remind_at"]; ok {
+		extraSet = append(extraSet, "reminded_at = NULL")
+	}
+
+	query, args, err := buildUpdate("todos", todoEditableFields, updates, extraSet,
+		"id = $1 AND user_id = $2 AND deleted_at IS NULL AND version = $3 AND "+todoAccessCondition,
+		[]any{id, userID, expectedVersion},
+		todoColumns,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := GetTodoForUpdate(ctx, tx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated models.Todo
+	if err := scanTodo(tx.QueryRow(ctx, query, args...), &updated); err != nil {
+		return nil, todoWriteError(ctx, tx, userID, id, err)
+	}
+
+	if err := spawnNextOccurrence(ctx, tx, userID, &updated); err != nil {
+		return nil, err
+	}
+
+	if err := recordTodoEvent(ctx, tx, userID, models.TodoActionUpdate, before, &updated); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// 依規則新增下一次的 todo；規則已經結束（COUNT 用完、超過 UNTIL）時回傳 nil, nil
+func createNextOccurrence(ctx context.Context, db DBTX, actorID string, todo *models.Todo) (*models.Todo, error) {
+	if todo.DueAt == nil || todo.RecurrenceStart == nil {
+		return nil, nil
+	}
+
+	rule, err := recurrence.Parse(todo.RecurrenceRule)
+	if err != nil {
+		return nil, fmt.Errorf("todo %d 的週期規則無法解析: %w", todo.ID, err)
+	}
+	loc, err := recurrence.LoadLocation(todo.RecurrenceTZ)
+	if err != nil {
+		return nil, err
+	}
+
+	nextDue, ok := rule.Next(todo.RecurrenceStart.In(loc), *todo.DueAt)
+	if !ok {
+		return nil, nil
+	}
+
+	next := &models.Todo{
+		UserID:          todo.UserID,
+		ListID:          todo.ListID,
+		Title:           todo.Title,
+		DueAt:           &nextDue,
+		Priority:        todo.Priority,
+		Notes:           todo.Notes,
+		RecurrenceRule:  todo.RecurrenceRule,
+		RecurrenceTZ:    todo.RecurrenceTZ,
+		RecurrenceStart: todo.RecurrenceStart,
+	}
+	// 提醒時間跟截止時間保持一樣的間隔
+	if todo.RemindAt != nil {
+		remindAt := nextDue.Add(todo.RemindAt.Sub(*todo.DueAt))
+		next.RemindAt = &remindAt
+	}
+
+	created, err := insertTodo(ctx, db, actorID, next)
+	if err != nil {
+		return nil, err
+	}
+
+	// 這次 UPDATE 也會讓 version +1，寫回 todo，回傳給前端的 ETag 才會是最新的
+	err = db.QueryRow(ctx,
+		`UPDATE todos SET recurrence_next_id = $1 WHERE id = $2 RETURNING version`,
+		created.ID, todo.ID,
+	).Scan(&todo.Version)
+	if err != nil {
+		return nil, fmt.Errorf("記錄下一次 todo 失敗: %w", err)
+	}
+
+	return created, nil
+}
+
 // 更精確的 retry 判斷（可再擴充）
 func isRetryablePostgresError(err error) bool {
 	if err == nil {
@@ -244,7 +797,9 @@ func (p *Pool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.C
 
 */
 
-func DeletTodo(pool *pgxpool.Pool, id int) error {
+// 軟刪除：只押上 deleted_at，資料還在，之後可以從垃圾桶還原
+// 沒有任何一筆被更新（不存在、不是自己的、已經在垃圾桶）時回傳 pgx.ErrNoRows，讓 handler 回 404
+func DeleteTodo(pool *pgxpool.Pool, userID string, id int) error {
 	// 建立帶有背景上下文的連線池
 	var ctx context.Context
 	var cancel context.CancelFunc
@@ -252,11 +807,27 @@ func DeletTodo(pool *pgxpool.Pool, id int) error {
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel() // 釋放記憶
 
-	// 在資料表名稱 todos 中，對 表 的欄位新增一筆資料
+	// 刪除跟變更紀錄要一起成功
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := DeleteTodoTx(ctx, tx, userID, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteTodo 的本體，在呼叫端的 transaction 裡執行（批次操作也會用到）
+func DeleteTodoTx(ctx context.Context, db DBTX, userID string, id int) error {
 	var query string = `
-		DELETE FROM todos
-		WHERE id = $1
-	`
+		UPDATE todos
+		SET deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND ` + todoAccessCondition + `
+		RETURNING ` + todoColumns
 
 	/* 搜關鍵字找得到 :　how to delete item in db by using pgxpool for golang range
 		deleteItem deletes a record from the "users" table by ID
@@ -264,8 +835,225 @@ func DeletTodo(pool *pgxpool.Pool, id int) error {
 		// Use Exec for non-SELECT queries
 		cmdTag, err := pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
 	*/
-	_, err := pool.Exec(ctx, query, id)
+	// 沒有更新到任何一筆時 Scan 會回傳 pgx.ErrNoRows
+	var deleted models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, id, userID), &deleted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		return fmt.Errorf("刪除 todo 失敗: %w", err)
+	}
+
+	// 刪除只改了 deleted_at，改之前就是還沒刪除的樣子
+	before := deleted
+	before.DeletedAt = nil
+	return recordTodoEvent(ctx, db, userID, models.TodoActionDelete, &before, &deleted)
+}
+
+// 垃圾桶列表，最近刪除的排最前面
+func GetTrashedTodos(pool *pgxpool.Pool, userID string) ([]models.Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1 AND deleted_at IS NOT NULL AND ` + todoAccessCondition + `
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := pool.Query(ctx, query, userID)
 	if err != nil {
+		return nil, fmt.Errorf("查詢垃圾桶失敗: %w", err)
 	}
-	return err
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		var todo models.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("讀取 todo 失敗: %w", err)
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取垃圾桶失敗: %w", err)
+	}
+
+	return todos, nil
+}
+
+// 從垃圾桶還原，只有已經軟刪除的 todo 才能還原
+func RestoreTodo(pool *pgxpool.Pool, userID string, id int) (*models.Todo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// 先鎖住拿到刪除的時間，變更紀錄才有 from
+	var deletedAt *time.Time
+	err = tx.QueryRow(ctx,
+		`SELECT deleted_at FROM todos WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL AND `+todoAccessCondition+` FOR UPDATE`,
+		id, userID,
+	).Scan(&deletedAt)
+	if err != nil {
+		return nil, fmt.Errorf("還原 todo 失敗: %w", err)
+	}
+
+	query := `
+		UPDATE todos
+		SET deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING ` + todoColumns
+
+	var todo models.Todo
+	if err := scanTodo(tx.QueryRow(ctx, query, id, userID), &todo); err != nil {
+		return nil, fmt.Errorf("還原 todo 失敗: %w", err)
+	}
+
+	before := todo
+	before.DeletedAt = deletedAt
+	if err := recordTodoEvent(ctx, tx, userID, models.TodoActionRestore, &before, &todo); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// 永久刪除（真的 DELETE），為了避免誤刪，只允許刪除已經在垃圾桶裡的 todo
+func PurgeTodo(pool *pgxpool.Pool, userID string, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM todos
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL AND ` + todoAccessCondition
+
+	cmdTag, err := pool.Exec(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("永久刪除 todo 失敗: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// 清空垃圾桶，回傳實際刪掉幾筆
+func EmptyTrash(pool *pgxpool.Pool, userID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmdTag, err := pool.Exec(ctx, `DELETE FROM todos WHERE user_id = $1 AND deleted_at IS NOT NULL AND `+todoAccessCondition, userID)
+	if err != nil {
+		return 0, fmt.Errorf("清空垃圾桶失敗: %w", err)
+	}
+	return cmdTag.RowsAffected(), nil
+}
+
+// 背景清理用：不分使用者，把在垃圾桶放超過 retention 的 todo 永久刪除
+// ctx 由呼叫端（jobs）決定，服務關閉時可以直接取消
+func PurgeExpiredTodos(ctx context.Context, pool *pgxpool.Pool, retention time.Duration) (int64, error) {
+	query := `
+		DELETE FROM todos
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+	`
+
+	cmdTag, err := pool.Exec(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("清理過期垃圾桶失敗: %w", err)
+	}
+	return cmdTag.RowsAffected(), nil
+}
+
+// 提醒排程用：一次認領一批「提醒時間已到、還沒提醒過」的 todos，並先押上 reminded_at
+// FOR UPDATE SKIP LOCKED 讓多個實例同時跑排程也不會拿到同一筆；只改 reminded_at 不會讓 version +1（見 migrations/000024）
+func ClaimDueReminders(ctx context.Context, pool *pgxpool.Pool, now time.Time, limit int) ([]models.Todo, error) {
+	query := `
+		UPDATE todos
+		SET reminded_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM todos
+			WHERE remind_at <= $1
+			  AND reminded_at IS NULL
+			  AND deleted_at IS NULL
+			  AND completed = false
+			ORDER BY remind_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + todoColumns
+
+	rows, err := pool.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("認領提醒失敗: %w", err)
+	}
+	defer rows.Close()
+
+	var todos []models.Todo
+	for rows.Next() {
+		var todo models.Todo
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("讀取 todo 失敗: %w", err)
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("讀取提醒失敗: %w", err)
+	}
+
+	return todos, nil
+}
+
+// 提醒送不出去時把 reminded_at 清掉，下一輪排程會再試一次
+func ReleaseReminder(ctx context.Context, pool *pgxpool.Pool, id int) error {
+	_, err := pool.Exec(ctx, `UPDATE todos SET reminded_at = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("釋放提醒失敗: %w", err)
+	}
+	return nil
+}
+
+// 清單裡的單一 todo 並鎖住（SELECT ... FOR UPDATE），不在這個清單或在垃圾桶裡回傳 pgx.ErrNoRows
+func GetListTodoForUpdate(ctx context.Context, db DBTX, listID string, id int) (*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE id = $1 AND list_id = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var todo models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, id, listID), &todo); err != nil {
+		return nil, fmt.Errorf("查詢 todo 失敗: %w", err)
+	}
+
+	return &todo, nil
+}
+
+// 清單裡的單一 todo，權限檢查由 service.TodoListService 負責
+func GetListTodoByID(ctx context.Context, db DBTX, listID string, id int) (*models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE id = $1 AND list_id = $2 AND deleted_at IS NULL
+	`
+
+	var todo models.Todo
+	if err := scanTodo(db.QueryRow(ctx, query, id, listID), &todo); err != nil {
+		return nil, fmt.Errorf("查詢 todo 失敗: %w", err)
+	}
+
+	return &todo, nil
 }
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNoFieldsToUpdate = errors.New("no valid fields to update")
+
+/*
+動態 UPDATE 共用的組裝：
+
+  - 只有 allowed（白名單）裡的欄位會被寫入，欄位名稱一律來自白名單，前端字串不會拼進 SQL
+  - 值全部參數化；where 裡用 $1 ~ $n 對應 whereArgs，SET 的參數接在後面
+  - extraSet 是固定的 SQL 片段（例如 updated_at = NOW()），不帶參數
+  - 依白名單的順序組 SET，同樣的 updates 每次產生的 SQL 都一樣
+
+例如 buildUpdate("products", editableFields, updates, []string{"updated_at = NOW()"}, "id = $1", []any{id}, productColumns)
+*/
+func buildUpdate(table string, allowed []string, updates map[string]any, extraSet []string, where string, whereArgs []any, returning string) (string, []any, error) {
+	args := append([]any{}, whereArgs...)
+	var setClauses []string
+
+	for _, field := range allowed {
+		value, ok := updates[field]
+		if !ok {
+			continue
+		}
+		args = append(args, value)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", field, len(args)))
+	}
+
+	if len(setClauses) == 0 {
+		return "", nil, ErrNoFieldsToUpdate
+	}
+	setClauses = append(setClauses, extraSet...)
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET %s
+		WHERE %s
+		RETURNING %s
+	`, table, strings.Join(setClauses, ", "), where, returning)
+
+	return query, args, nil
+}
@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"time"
 	"todo_api/internal/models"
 	"todo_api/internal/utils"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -28,12 +30,13 @@ func CreateUser(pool *pgxpool.Pool, user *models.User) (*models.User, error) {
 	var query string = `
 		INSERT INTO users (email, password)
 		VALUES ($1, $2)
-		RETURNING id, email, created_at, updated_at
+		RETURNING id, email, email_verified_at, created_at, updated_at
 	`
 
 	err := pool.QueryRow(ctx, query, user.Email, user.Password).Scan(
 		&user.ID,
 		&user.Email,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -53,7 +56,7 @@ func GetUserByEmail(pool *pgxpool.Pool, email string) (*models.User, error) {
 	utils.PerformOperation(ctx)
 
 	var query string = `
-		SELECT id, email, password, image_url, created_at, updated_at
+		SELECT id, email, password, image_url, email_verified_at, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -64,6 +67,7 @@ func GetUserByEmail(pool *pgxpool.Pool, email string) (*models.User, error) {
 		&user.Email,
 		&user.Password,
 		&user.ImageURL,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -83,7 +87,7 @@ func GetUserByID(pool *pgxpool.Pool, id string) (*models.User, error) {
 	utils.PerformOperation(ctx)
 
 	var query string = `
-		SELECT id, email, password, COALESCE(image_url, ''), created_at, updated_at
+		SELECT id, email, password, COALESCE(image_url, ''), email_verified_at, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -94,6 +98,7 @@ func GetUserByID(pool *pgxpool.Pool, id string) (*models.User, error) {
 		&user.Email,
 		&user.Password,
 		&user.ImageURL,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -118,7 +123,7 @@ func UpdateUserImage(
 		SET image_url = $1,
 		    updated_at = NOW()
 		WHERE id = $2
-		RETURNING id, email, password, image_url, created_at, updated_at
+		RETURNING id, email, password, image_url, email_verified_at, created_at, updated_at
 	`
 
 	var user models.User
@@ -127,6 +132,7 @@ func UpdateUserImage(
 		&user.Email,
 		&user.Password,
 		&user.ImageURL,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -136,3 +142,45 @@ func UpdateUserImage(
 
 	return &user, nil
 }
+
+// hashedPassword 是 bcrypt 算過的值；改密碼時跟 SetTokensValidAfter 放在同一個 transaction
+func UpdateUserPassword(ctx context.Context, db DBTX, id string, hashedPassword string) error {
+	tag, err := db.Exec(ctx, `
+		UPDATE users
+		SET password = $1,
+		    updated_at = NOW()
+		WHERE id = $2
+	`, hashedPassword, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// email 要跟現在的 email 一樣才會更新，避免舊 email 的驗證信驗證到新的 email；已經驗證過的保留原本的時間
+func MarkUserEmailVerified(ctx context.Context, db DBTX, id string, email string) (time.Time, error) {
+	var verifiedAt time.Time
+	err := db.QueryRow(ctx, `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, NOW()),
+		    updated_at = NOW()
+		WHERE id = $1 AND email = $2
+		RETURNING email_verified_at
+	`, id, email).Scan(&verifiedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("更新 email 驗證狀態失敗: %w", err)
+	}
+	return verifiedAt, nil
+}
+
+// RequireVerifiedEmail 每個請求都會查；nil 代表還沒驗證，使用者不存在回傳 pgx.ErrNoRows
+func GetUserEmailVerifiedAt(ctx context.Context, db DBTX, id string) (*time.Time, error) {
+	var verifiedAt *time.Time
+	if err := db.QueryRow(ctx, `SELECT email_verified_at FROM users WHERE id = $1`, id).Scan(&verifiedAt); err != nil {
+		return nil, fmt.Errorf("查詢 email 驗證狀態失敗: %w", err)
+	}
+	return verifiedAt, nil
+}
@@ -0,0 +1,109 @@
+/*
+Package revocation 讓簽章正確、還沒過期的 access token 也可以在伺服器端失效：
+
+  - 登出：把 token 的 jti 記在 revoked_tokens
+  - 登出所有裝置、改密碼：users.tokens_valid_after 之前發的 token（看 iat）全部失效
+
+AuthMiddleware 每個請求都要問一次，所以前面放一層 LRU。查詢結果最多快取 cacheTTL，
+同一台機器上的撤銷會馬上更新快取；多台機器的話，別台撤銷的 token 最晚 cacheTTL 之後失效
+*/
+package revocation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+type Store struct {
+	db       *pgxpool.Pool
+	cacheTTL time.Duration
+	// jti => 是否已撤銷
+	tokens *lru[string, bool]
+	// user_id => tokens_valid_after（零值代表沒有限制）
+	users *lru[string, time.Time]
+}
+
+func NewStore(db *pgxpool.Pool, cacheSize int, cacheTTL time.Duration) *Store {
+	return &Store{
+		db:       db,
+		cacheTTL: cacheTTL,
+		tokens:   newLRU[string, bool](cacheSize),
+		users:    newLRU[string, time.Time](cacheSize),
+	}
+}
+
+/*
+Check 確認 token 沒有被撤銷，被撤銷回傳 ErrTokenRevoked；使用者已經被刪除也算撤銷
+
+tokens_valid_after 只比到秒（iat 只有秒），同一秒內發的 token 還是有效，
+改密碼之後馬上發的新 token 才不會被自己擋掉
+*/
+func (s *Store) Check(ctx context.Context, userID string, jti string, issuedAt time.Time, expiresAt time.Time) error {
+	now := time.Now()
+
+	revoked, tokenCached := s.tokens.Get(jti, now)
+	validAfter, userCached := s.users.Get(userID, now)
+
+	if !tokenCached || !userCached {
+		dbValidAfter, dbRevoked, err := repository.GetTokenRevocation(ctx, s.db, userID, jti)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTokenRevoked
+			}
+			return err
+		}
+
+		revoked = dbRevoked
+		validAfter = time.Time{}
+		if dbValidAfter != nil {
+			validAfter = *dbValidAfter
+		}
+		s.rememberToken(jti, revoked, expiresAt, now)
+		s.users.Set(userID, validAfter, now.Add(s.cacheTTL))
+	}
+
+	if revoked {
+		return ErrTokenRevoked
+	}
+	if !validAfter.IsZero() && issuedAt.Unix() < validAfter.Unix() {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// 撤銷一個 token，expiresAt 是 token 的 exp，過了之後撤銷紀錄會被清掉
+func (s *Store) Revoke(ctx context.Context, userID string, jti string, expiresAt time.Time) error {
+	if err := repository.RevokeToken(ctx, s.db, jti, userID, expiresAt); err != nil {
+		return err
+	}
+	s.rememberToken(jti, true, expiresAt, time.Now())
+	return nil
+}
+
+// 呼叫端已經 Commit 了新的 tokens_valid_after（repository.SetTokensValidAfter），更新這台機器的快取
+func (s *Store) SetValidAfter(userID string, validAfter time.Time) {
+	s.users.Set(userID, validAfter, time.Now().Add(s.cacheTTL))
+}
+
+// 清掉快取裡已經過期的；資料庫裡的由 jobs.StartRevocationCleaner 清
+func (s *Store) Prune() int {
+	now := time.Now()
+	return s.tokens.Prune(now) + s.users.Prune(now)
+}
+
+// 已撤銷的結果不會再變，快取到 token 過期為止；沒撤銷的只快取 cacheTTL，別台機器撤銷了才看得到
+func (s *Store) rememberToken(jti string, revoked bool, expiresAt time.Time, now time.Time) {
+	cacheUntil := now.Add(s.cacheTTL)
+	if revoked || expiresAt.Before(cacheUntil) {
+		cacheUntil = expiresAt
+	}
+	s.tokens.Set(jti, revoked, cacheUntil)
+}
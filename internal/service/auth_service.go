@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo_api/internal/authtoken"
+	"todo_api/internal/jwtkeys"
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+	"todo_api/internal/revocation"
+	"todo_api/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// 不存在、過期或已經撤銷（登出）的 refresh token
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// 已經換發過的 refresh token 又被拿來用，可能是被偷了，整個 family 已經撤銷，要重新登入
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, please log in again")
+	ErrWrongPassword      = errors.New("current password is incorrect")
+)
+
+/*
+AuthService 發 access token（短效 JWT）跟 refresh token（不透明的亂數字串，資料庫只存 hash）
+
+  - 登入開一個新的 family，之後每次換發都把舊的 refresh token 標記用過、在同一個 family 發新的
+  - 用過的 refresh token 再出現，代表有兩個人拿著同一串 token，整個 family 撤銷，兩邊都要重新登入
+  - access token 帶 jti，登出時記進 revocation.Store；登出所有裝置、改密碼則是把之前發的全部作廢
+*/
+type AuthService struct {
+	DB          *pgxpool.Pool
+	Revocations *revocation.Store
+	Keys        *jwtkeys.Manager
+	TokenOpts   authtoken.Options // 寫進 access token 的 iss / aud，跟 AuthMiddleware 驗證用的是同一份
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+}
+
+func NewAuthService(db *pgxpool.Pool, revocations *revocation.Store, keys *jwtkeys.Manager, tokenOpts authtoken.Options, accessTTL time.Duration, refreshTTL time.Duration) *AuthService {
+	return &AuthService{
+		DB:          db,
+		Revocations: revocations,
+		Keys:        keys,
+		TokenOpts:   tokenOpts,
+		AccessTTL:   accessTTL,
+		RefreshTTL:  refreshTTL,
+	}
+}
+
+// 帳號不存在跟密碼錯誤都回傳 ErrInvalidCredentials，不讓前端知道 email 有沒有註冊過
+func (s *AuthService) Login(ctx context.Context, email string, password string, userAgent string) (*models.TokenPair, error) {
+	user, err := repository.GetUserByEmail(s.DB, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// 把存在db的加鹽密碼跟前端傳來的密碼比對
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issueTokens(ctx, s.DB, user, "", userAgent)
+}
+
+// 用 refresh token 換一組新的 access token + refresh token，舊的 refresh token 之後就不能再用
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string, userAgent string) (*models.TokenPair, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	existing, err := repository.GetRefreshTokenByHashForUpdate(ctx, tx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+
+	if existing.RevokedAt != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// 撤銷要 Commit 才算數，所以不能直接 return 讓 defer Rollback 掉
+	if existing.UsedAt != nil {
+		if _, err := repository.RevokeRefreshTokenFamily(ctx, tx, existing.FamilyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(existing.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	user, err := repository.GetUserByID(s.DB, existing.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+
+	if err := repository.MarkRefreshTokenUsed(ctx, tx, existing.ID); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issueTokens(ctx, tx, user, existing.FamilyID, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+/*
+Logout 撤銷目前這個 access token（jti），有帶 refresh token 的話連同它的 family 一起撤銷
+refresh token 不存在、已經撤銷或不是自己的就略過，登出可以重複呼叫
+*/
+func (s *AuthService) Logout(ctx context.Context, userID string, jti string, expiresAt time.Time, refreshToken string) error {
+	if refreshToken != "" {
+		if err := s.revokeRefreshFamily(ctx, userID, refreshToken); err != nil {
+			return err
+		}
+	}
+
+	return s.Revocations.Revoke(ctx, userID, jti, expiresAt)
+}
+
+func (s *AuthService) revokeRefreshFamily(ctx context.Context, userID string, refreshToken string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	existing, err := repository.GetRefreshTokenByHashForUpdate(ctx, tx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if existing.UserID != userID {
+		return nil
+	}
+
+	if _, err := repository.RevokeRefreshTokenFamily(ctx, tx, existing.FamilyID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// 登出所有裝置：現在之前發的 access token 全部失效，refresh token 全部撤銷
+func (s *AuthService) LogoutAll(ctx context.Context, userID string) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	validAfter, err := s.invalidateUserTokens(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	s.Revocations.SetValidAfter(userID, validAfter)
+	return nil
+}
+
+// 改密碼之後其他裝置都要重新登入；回傳一組新的 token 給目前這個裝置繼續用
+func (s *AuthService) ChangePassword(ctx context.Context, userID string, currentPassword string, newPassword string, userAgent string) (*models.TokenPair, error) {
+	user, err := repository.GetUserByID(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)); err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := repository.UpdateUserPassword(ctx, tx, userID, string(hashedPassword)); err != nil {
+		return nil, err
+	}
+
+	validAfter, err := s.invalidateUserTokens(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issueTokens(ctx, tx, user, "", userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	s.Revocations.SetValidAfter(userID, validAfter)
+	return pair, nil
+}
+
+// 設定 tokens_valid_after 並撤銷所有 refresh token；Commit 之後呼叫端要更新 Revocations 的快取
+func (s *AuthService) invalidateUserTokens(ctx context.Context, tx pgx.Tx, userID string) (time.Time, error) {
+	validAfter, err := repository.SetTokensValidAfter(ctx, tx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := repository.RevokeUserRefreshTokens(ctx, tx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	return validAfter, nil
+}
+
+// familyID 是空字串代表新的登入
+func (s *AuthService) issueTokens(ctx context.Context, db repository.DBTX, user *models.User, familyID string, userAgent string) (*models.TokenPair, error) {
+	now := time.Now()
+
+	accessToken, accessExpiresAt, err := s.signAccessToken(user, now)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.NewRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := repository.CreateRefreshToken(ctx, db, user.ID, familyID, utils.HashToken(refreshToken), now.Add(s.RefreshTTL), truncateUserAgent(userAgent))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+// 用 Keys 目前的 active key 簽（RS256 / ES256 / EdDSA，沒設定 key 的話是 HS256）
+func (s *AuthService) signAccessToken(user *models.User, now time.Time) (string, time.Time, error) {
+	claims := authtoken.NewClaims(user.ID, user.Email, now, s.AccessTTL, s.TokenOpts)
+	claims.EmailVerified = user.EmailVerifiedAt != nil
+
+	tokenString, err := s.Keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, claims.ExpiresAt.Time, nil
+}
+
+// refresh_tokens.user_agent 最多 255 字元，只是給使用者看「哪些裝置登入中」用的，截掉也沒關係
+func truncateUserAgent(userAgent string) string {
+	runes := []rune(userAgent)
+	if len(runes) > 255 {
+		return string(runes[:255])
+	}
+	return userAgent
+}
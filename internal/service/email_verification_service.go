@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"todo_api/internal/mailer"
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+	"todo_api/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// 簽章不對、格式不對、已經用過或被重寄的信取代
+	ErrVerificationTokenInvalid  = errors.New("invalid verification token")
+	ErrVerificationTokenExpired  = errors.New("verification token expired")
+	ErrEmailAlreadyVerified      = errors.New("email already verified")
+	ErrVerificationResendTooSoon = errors.New("verification email was sent recently, please wait before requesting another")
+)
+
+// 驗證 token 簽章裡的內容；Nonce 是亂數，讓每個 token 都不一樣，資料庫存的是整個 token 的 hash
+type verificationPayload struct {
+	UserID    string `json:"uid"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"n"`
+}
+
+/*
+EmailVerificationService 寄驗證信、驗證信裡的 token
+
+token 是 base64(JSON) + "." + HMAC 簽章（跟 pagination 的 cursor 一樣的格式）：
+簽章跟期限不用查資料庫就能擋掉亂猜的 token；單次使用則靠 email_verification_tokens 的 used_at，
+重寄的時候舊的 token 全部標記用過，只有最新那封信的連結有效
+*/
+type EmailVerificationService struct {
+	DB     *pgxpool.Pool
+	Mailer mailer.Mailer
+	secret []byte
+	// 信裡的連結是 LinkURL?token=...
+	LinkURL        string
+	TTL            time.Duration
+	ResendCooldown time.Duration
+}
+
+func NewEmailVerificationService(db *pgxpool.Pool, m mailer.Mailer, secret string, linkURL string, ttl time.Duration, resendCooldown time.Duration) *EmailVerificationService {
+	return &EmailVerificationService{
+		DB:             db,
+		Mailer:         m,
+		secret:         []byte(secret),
+		LinkURL:        linkURL,
+		TTL:            ttl,
+		ResendCooldown: resendCooldown,
+	}
+}
+
+// 註冊之後呼叫；已經驗證過回傳 ErrEmailAlreadyVerified，距離上一封太近回傳 ErrVerificationResendTooSoon
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	now := time.Now()
+	token, err := s.signToken(verificationPayload{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: now.Add(s.TTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	lastSentAt, err := repository.GetLatestEmailVerificationSentAt(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+	if lastSentAt != nil && now.Sub(*lastSentAt) < s.ResendCooldown {
+		return ErrVerificationResendTooSoon
+	}
+
+	if _, err := repository.MarkUserEmailVerificationTokensUsed(ctx, tx, user.ID); err != nil {
+		return err
+	}
+	if _, err := repository.CreateEmailVerificationToken(ctx, tx, user.ID, user.Email, utils.HashToken(token), now.Add(s.TTL)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Commit 之後才寄：寄失敗的話使用者等冷卻時間過了可以重寄，不會寄出一個資料庫裡沒有的 token
+	if err := s.Mailer.Send(ctx, s.verificationMessage(user.Email, token)); err != nil {
+		return fmt.Errorf("寄送驗證信失敗: %w", err)
+	}
+	return nil
+}
+
+// POST /auth/resend-verification：登入中的使用者自己要求重寄
+func (s *EmailVerificationService) Resend(ctx context.Context, userID string) error {
+	user, err := repository.GetUserByID(s.DB, userID)
+	if err != nil {
+		return err
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// 驗證成功回傳驗證的時間；token 不需要登入就能用（使用者可能在另一台裝置上點信裡的連結）
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) (time.Time, error) {
+	payload, err := s.parseToken(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return time.Time{}, ErrVerificationTokenExpired
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	stored, err := repository.GetEmailVerificationTokenByHashForUpdate(ctx, tx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrVerificationTokenInvalid
+		}
+		return time.Time{}, err
+	}
+	if stored.UsedAt != nil || stored.UserID != payload.UserID || stored.Email != payload.Email {
+		return time.Time{}, ErrVerificationTokenInvalid
+	}
+
+	// 寄信之後換過 email 的話這裡會找不到，舊 email 的信不能拿來驗證新的 email
+	verifiedAt, err := repository.MarkUserEmailVerified(ctx, tx, stored.UserID, stored.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, ErrVerificationTokenInvalid
+		}
+		return time.Time{}, err
+	}
+
+	if _, err := repository.MarkUserEmailVerificationTokensUsed(ctx, tx, stored.UserID); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	return verifiedAt, nil
+}
+
+func (s *EmailVerificationService) verificationMessage(email string, token string) mailer.Message {
+	link := s.LinkURL
+	if strings.Contains(link, "?") {
+		link += "&"
+	} else {
+		link += "?"
+	}
+	link += "token=" + url.QueryEscape(token)
+
+	return mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Text: "Please confirm your email address by opening the link below:\n\n" +
+			link + "\n\n" +
+			fmt.Sprintf("The link expires in %s. If you did not create an account, you can ignore this email.\n", s.TTL),
+	}
+}
+
+func (s *EmailVerificationService) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *EmailVerificationService) signToken(payload verificationPayload) (string, error) {
+	nonce, err := utils.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+	payload.Nonce = nonce
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(raw)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+func (s *EmailVerificationService) parseToken(token string) (*verificationPayload, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	// hmac.Equal 是固定時間比較，避免被用時間差猜出簽章
+	if !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	var payload verificationPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.UserID == "" || payload.Email == "" {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	return &payload, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"todo_api/internal/mailer"
+	"todo_api/internal/testdb"
+)
+
+func newTestVerificationService(t *testing.T, resendCooldown time.Duration) (*EmailVerificationService, *mailer.MemoryMailer) {
+	t.Helper()
+
+	m := mailer.NewMemoryMailer()
+	return NewEmailVerificationService(testdb.New(t), m, "test-secret", "https://example.com/verify", time.Hour, resendCooldown), m
+}
+
+// 從信裡的連結拿出 token
+func tokenFromMessage(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+
+	_, rest, ok := strings.Cut(msg.Text, "token=")
+	if !ok {
+		t.Fatalf("no token in message: %q", msg.Text)
+	}
+	escaped, _, _ := strings.Cut(rest, "\n")
+	token, err := url.QueryUnescape(escaped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyTokenIsSingleUse(t *testing.T) {
+	s, m := newTestVerificationService(t, time.Minute)
+	ctx := context.Background()
+	user := testdb.CreateUser(t, s.DB, "single-use@example.com")
+
+	if err := s.SendVerification(ctx, user); err != nil {
+		t.Fatalf("SendVerification() error = %v", err)
+	}
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0].To != user.Email {
+		t.Fatalf("Sent() = %+v, want one message to %s", sent, user.Email)
+	}
+	token := tokenFromMessage(t, sent[0])
+
+	verifiedAt, err := s.Verify(ctx, token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if verifiedAt.IsZero() {
+		t.Fatal("Verify() returned a zero time")
+	}
+
+	if _, err := s.Verify(ctx, token); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Fatalf("second Verify() error = %v, want ErrVerificationTokenInvalid", err)
+	}
+
+	// 驗證過就不會再寄
+	if err := s.Resend(ctx, user.ID); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Fatalf("Resend() after verify error = %v, want ErrEmailAlreadyVerified", err)
+	}
+	if got := len(m.Sent()); got != 1 {
+		t.Fatalf("Sent() has %d messages, want 1", got)
+	}
+}
+
+func TestResendCooldown(t *testing.T) {
+	s, m := newTestVerificationService(t, time.Hour)
+	ctx := context.Background()
+	user := testdb.CreateUser(t, s.DB, "cooldown@example.com")
+
+	if err := s.SendVerification(ctx, user); err != nil {
+		t.Fatalf("SendVerification() error = %v", err)
+	}
+	if err := s.Resend(ctx, user.ID); !errors.Is(err, ErrVerificationResendTooSoon) {
+		t.Fatalf("Resend() within cooldown error = %v, want ErrVerificationResendTooSoon", err)
+	}
+	if got := len(m.Sent()); got != 1 {
+		t.Fatalf("Sent() has %d messages, want 1", got)
+	}
+
+	// 冷卻時間過了（這裡直接拿掉冷卻）可以重寄，舊信的連結就不能用了
+	s.ResendCooldown = 0
+	if err := s.Resend(ctx, user.ID); err != nil {
+		t.Fatalf("Resend() after cooldown error = %v", err)
+	}
+	sent := m.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("Sent() has %d messages, want 2", len(sent))
+	}
+
+	if _, err := s.Verify(ctx, tokenFromMessage(t, sent[0])); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Fatalf("Verify() with replaced token error = %v, want ErrVerificationTokenInvalid", err)
+	}
+	if _, err := s.Verify(ctx, tokenFromMessage(t, sent[1])); err != nil {
+		t.Fatalf("Verify() with latest token error = %v", err)
+	}
+}
+
+// 簽章跟期限在查資料庫之前就擋掉，不需要資料庫
+func TestVerifyRejectsForgedAndExpiredTokens(t *testing.T) {
+	s := NewEmailVerificationService(nil, mailer.NewMemoryMailer(), "test-secret", "https://example.com/verify", time.Hour, time.Minute)
+	other := NewEmailVerificationService(nil, mailer.NewMemoryMailer(), "other-secret", "https://example.com/verify", time.Hour, time.Minute)
+	ctx := context.Background()
+
+	payload := verificationPayload{UserID: "u1", Email: "a@example.com", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	good, err := s.signToken(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := other.signToken(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	expired, err := s.signToken(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _, _ := strings.Cut(good, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"empty", "", ErrVerificationTokenInvalid},
+		{"no signature", encoded, ErrVerificationTokenInvalid},
+		{"signed with another secret", forged, ErrVerificationTokenInvalid},
+		{"tampered signature", encoded + ".AAAA", ErrVerificationTokenInvalid},
+		{"expired", expired, ErrVerificationTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Verify(ctx, tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
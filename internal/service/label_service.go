@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"unicode"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrLabelNotFound  = errors.New("label not found")
+	ErrLabelMergeSelf = errors.New("cannot merge a label into itself")
+	// 名稱裡沒有任何字母或數字，產生不出 slug
+	ErrInvalidLabelName = errors.New("label name must contain letters or digits")
+)
+
+// 沒指定顏色時用這個，跟 tags.color 的預設值一樣
+const DefaultLabelColor = "#9e9e9e"
+
+// 標籤的修改內容，nil 表示不改
+type LabelChanges struct {
+	Name  *string
+	Color *string
+}
+
+/*
+LabelService 管使用者自己的 todo 標籤（存在 tags 表，跟文章的全站標籤分開）
+
+  - 改名、合併、刪除都在同一個 transaction 裡把用了這個標籤的 todo 一起更新（version +1）
+  - 掛標籤、拿掉標籤都會先鎖住 todo 確認是自己的
+*/
+type LabelService struct {
+	DB *pgxpool.Pool
+}
+
+func NewLabelService(db *pgxpool.Pool) *LabelService {
+	return &LabelService{
+		DB: db,
+	}
+}
+
+func (s *LabelService) List(ctx context.Context, userID string) ([]models.Label, error) {
+	return repository.GetLabels(ctx, s.DB, userID)
+}
+
+// 同名（同 slug）的標籤已經存在回傳 repository.ErrLabelSlugTaken
+func (s *LabelService) Create(ctx context.Context, userID string, name string, color string) (*models.Label, error) {
+	if !validLabelName(name) {
+		return nil, ErrInvalidLabelName
+	}
+	if color == "" {
+		color = DefaultLabelColor
+	}
+	return repository.CreateLabel(ctx, s.DB, userID, name, color)
+}
+
+// 鎖住標籤再執行 fn，fn 沒有錯誤才 Commit
+func (s *LabelService) withLabel(ctx context.Context, userID string, id string, fn func(tx pgx.Tx, label *models.Label) error) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	label, err := repository.GetLabelForUpdate(ctx, tx, userID, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrLabelNotFound
+		}
+		return err
+	}
+
+	if err := fn(tx, label); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// 改名跟別的標籤撞名回傳 repository.ErrLabelSlugTaken，要把兩個變成一個請用 Merge
+func (s *LabelService) Update(ctx context.Context, userID string, id string, changes LabelChanges) (*models.Label, error) {
+	if changes.Name != nil && !validLabelName(*changes.Name) {
+		return nil, ErrInvalidLabelName
+	}
+
+	var updated *models.Label
+	err := s.withLabel(ctx, userID, id, func(tx pgx.Tx, label *models.Label) error {
+		name, color := label.Name, label.Color
+		if changes.Name != nil {
+			name = *changes.Name
+		}
+		if changes.Color != nil {
+			color = *changes.Color
+		}
+
+		var err error
+		updated, err = repository.UpdateLabel(ctx, tx, label, name, color)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// 把 id 併進 intoID，回傳合併之後的 intoID
+func (s *LabelService) Merge(ctx context.Context, userID string, id string, intoID string) (*models.Label, error) {
+	if id == intoID {
+		return nil, ErrLabelMergeSelf
+	}
+
+	var merged *models.Label
+	err := s.withLabel(ctx, userID, id, func(tx pgx.Tx, source *models.Label) error {
+		target, err := repository.GetLabelForUpdate(ctx, tx, userID, intoID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrLabelNotFound
+			}
+			return err
+		}
+
+		if err := repository.MergeLabels(ctx, tx, source, target); err != nil {
+			return err
+		}
+
+		// 重新查一次，todo_count 才會包含併進來的
+		merged, err = repository.GetLabelForUpdate(ctx, tx, userID, intoID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func (s *LabelService) Delete(ctx context.Context, userID string, id string) error {
+	return s.withLabel(ctx, userID, id, func(tx pgx.Tx, label *models.Label) error {
+		return repository.DeleteLabel(ctx, tx, label)
+	})
+}
+
+// 鎖住自己的 todo 再執行 fn，fn 沒有錯誤才 Commit；最後回傳 todo 現在的標籤
+func (s *LabelService) withTodoLabels(ctx context.Context, userID string, todoID int, fn func(tx pgx.Tx) error) ([]models.TodoLabel, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := repository.GetTodoForUpdate(ctx, tx, userID, todoID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
+	}
+
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+
+	labels, err := repository.GetTodoLabels(ctx, tx, []int{todoID})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	if labels[todoID] == nil {
+		return []models.TodoLabel{}, nil
+	}
+	return labels[todoID], nil
+}
+
+/*
+AddToTodo 把標籤掛到 todo 上：labelIDs 是現有的標籤，names 是用名稱指定、沒有的話順便建立（#標籤）
+labelIDs 裡有不存在的標籤回傳 repository.ErrUnknownLabel
+*/
+func (s *LabelService) AddToTodo(ctx context.Context, userID string, todoID int, labelIDs []string, names []string) ([]models.TodoLabel, error) {
+	for _, name := range names {
+		if !validLabelName(name) {
+			return nil, ErrInvalidLabelName
+		}
+	}
+
+	return s.withTodoLabels(ctx, userID, todoID, func(tx pgx.Tx) error {
+		ids := append([]string{}, labelIDs...)
+		if len(names) > 0 {
+			ensured, err := repository.EnsureLabels(ctx, tx, userID, names)
+			if err != nil {
+				return err
+			}
+			for _, label := range ensured {
+				ids = append(ids, label.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return repository.AddTodoLabels(ctx, tx, userID, todoID, ids)
+	})
+}
+
+/*
+CreateTodoWithLabels 建立 todo 並掛上用名稱指定的標籤（POST /todos/quick 的 #標籤），全部在同一個 transaction：
+標籤寫入失敗的話 todo 也不會留下來，client 重送不會多出一筆
+*/
+func (s *LabelService) CreateTodoWithLabels(ctx context.Context, todo *models.Todo, names []string) (*models.Todo, error) {
+	for _, name := range names {
+		if !validLabelName(name) {
+			return nil, ErrInvalidLabelName
+		}
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := repository.CreateTodoTx(ctx, tx, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > 0 {
+		ensured, err := repository.EnsureLabels(ctx, tx, todo.UserID, names)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(ensured))
+		for _, label := range ensured {
+			ids = append(ids, label.ID)
+		}
+		if err := repository.AddTodoLabels(ctx, tx, todo.UserID, created.ID, ids); err != nil {
+			return nil, err
+		}
+
+		// 掛標籤會 touch todo（version +1），重新讀一次，回傳的 version 才會跟 ETag 對得上
+		if created, err = repository.GetTodoForUpdate(ctx, tx, todo.UserID, created.ID); err != nil {
+			return nil, err
+		}
+		labels, err := repository.GetTodoLabels(ctx, tx, []int{created.ID})
+		if err != nil {
+			return nil, err
+		}
+		created.Labels = labels[created.ID]
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *LabelService) RemoveFromTodo(ctx context.Context, userID string, todoID int, labelID string) ([]models.TodoLabel, error) {
+	return s.withTodoLabels(ctx, userID, todoID, func(tx pgx.Tx) error {
+		err := repository.RemoveTodoLabel(ctx, tx, todoID, labelID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrLabelNotFound
+		}
+		return err
+	})
+}
+
+func validLabelName(name string) bool {
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// POST /sync 支援的 mutation
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+)
+
+var ErrUnknownSyncRef = errors.New("ref does not point to a created todo")
+
+// client 離線時做的一個修改；handler 負責解析跟欄位驗證，這裡只管寫入跟衝突判斷
+type SyncMutation struct {
+	ClientMutationID string
+	Op               string
+
+	// update / delete 的對象：伺服器上的 id，或是之前某個 create mutation 的 client_mutation_id（離線新增、還不知道 id）
+	ID  int
+	Ref string
+
+	// client 做這個修改的時間，last-writer-wins 比的就是這個
+	UpdatedAt time.Time
+
+	// create：要新增的 todo
+	Todo *models.Todo
+	// update：把修改套用到鎖住的現有資料上並驗證，回傳要寫回的完整 todo
+	Apply func(existing *models.Todo) (*models.Todo, error)
+
+	// handler 解析時就失敗的 mutation，直接當作 rejected
+	Err error
+}
+
+/*
+SyncService 處理離線 client 的同步
+
+  - Changes：since 之後變動過的 todo 跟被刪除的 id
+  - Apply：依序套用 client 的 mutation，每一筆包在自己的 savepoint 裡
+    同一個 client_mutation_id 只會執行一次，重送直接回傳第一次的結果
+    伺服器上的 updated_at 比 client 做修改的時間新就是衝突，保留伺服器的版本並回報給 client
+*/
+type SyncService struct {
+	DB *pgxpool.Pool
+}
+
+func NewSyncService(db *pgxpool.Pool) *SyncService {
+	return &SyncService{
+		DB: db,
+	}
+}
+
+// since 是上一次同步的 snapshot xmin，nil 代表完整同步；回傳的 xmin 給 handler 包成下一次的 token
+func (s *SyncService) Changes(ctx context.Context, userID string, since *uint64) (*models.SyncChanges, uint64, error) {
+	return repository.GetSyncChanges(ctx, s.DB, userID, since)
+}
+
+// 只有連線或 commit 這種整批都做不了的錯誤才會回傳 error，個別 mutation 的結果都在 response 裡
+func (s *SyncService) Apply(ctx context.Context, userID string, mutations []SyncMutation) (*models.SyncResponse, error) {
+	response := &models.SyncResponse{
+		Results: make([]models.SyncMutationResult, 0, len(mutations)),
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, mutation := range mutations {
+		result, err := s.applyOne(ctx, tx, userID, mutation)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result.Status {
+		case models.SyncStatusApplied:
+			response.Applied++
+		case models.SyncStatusConflict:
+			response.Conflicts++
+		default:
+			response.Rejected++
+		}
+		response.Results = append(response.Results, *result)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *SyncService) applyOne(ctx context.Context, tx pgx.Tx, userID string, mutation SyncMutation) (*models.SyncMutationResult, error) {
+	claimed, err := repository.ClaimSyncMutation(ctx, tx, userID, mutation.ClientMutationID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		stored, err := repository.GetSyncMutation(ctx, tx, userID, mutation.ClientMutationID)
+		if err != nil {
+			return nil, err
+		}
+		stored.Replayed = true
+		return stored, nil
+	}
+
+	var result *models.SyncMutationResult
+	err = withSavepoint(ctx, tx, func(sp pgx.Tx) error {
+		var err error
+		result, err = runSyncMutation(ctx, sp, userID, mutation)
+		return err
+	})
+	if err != nil {
+		if !isOperationError(err) {
+			return nil, err
+		}
+		result = &models.SyncMutationResult{Status: models.SyncStatusRejected, ID: mutation.ID, Error: err.Error()}
+	}
+	result.ClientMutationID = mutation.ClientMutationID
+	result.Op = mutation.Op
+
+	if err := repository.SaveSyncMutationResult(ctx, tx, userID, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func runSyncMutation(ctx context.Context, tx pgx.Tx, userID string, mutation SyncMutation) (*models.SyncMutationResult, error) {
+	if mutation.Err != nil {
+		return nil, mutation.Err
+	}
+
+	if mutation.Op == SyncOpCreate {
+		created, err := repository.CreateTodosBatch(ctx, tx, userID, []*models.Todo{mutation.Todo})
+		var batchErr *repository.BatchInsertError
+		if errors.As(err, &batchErr) {
+			err = batchErr.Err
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &models.SyncMutationResult{Status: models.SyncStatusApplied, ID: created[0].ID, Todo: created[0]}, nil
+	}
+
+	id, err := resolveSyncTarget(ctx, tx, userID, mutation)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := repository.GetTodoForSync(ctx, tx, userID, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
+	}
+
+	// last-writer-wins：伺服器上的比較新就不動它，把伺服器的版本回給 client
+	if existing.UpdatedAt.After(mutation.UpdatedAt) {
+		return &models.SyncMutationResult{Status: models.SyncStatusConflict, ID: id, Todo: existing}, nil
+	}
+
+	switch mutation.Op {
+	case SyncOpUpdate:
+		changed, err := mutation.Apply(existing)
+		if err != nil {
+			return nil, err
+		}
+		// 修改比刪除還新：從垃圾桶救回來
+		changed.DeletedAt = nil
+
+		updated, err := repository.SyncUpdateTodo(ctx, tx, userID, existing, changed, mutation.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		return &models.SyncMutationResult{Status: models.SyncStatusApplied, ID: id, Todo: updated}, nil
+
+	case SyncOpDelete:
+		// 已經在垃圾桶裡就當作成功
+		if existing.DeletedAt == nil {
+			if err := repository.DeleteTodoTx(ctx, tx, userID, id); err != nil {
+				return nil, err
+			}
+		}
+		return &models.SyncMutationResult{Status: models.SyncStatusApplied, ID: id}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown op %q", mutation.Op)
+	}
+}
+
+// update / delete 的對象：有 ref 就找那個 create mutation 新增出來的 todo
+func resolveSyncTarget(ctx context.Context, tx pgx.Tx, userID string, mutation SyncMutation) (int, error) {
+	if mutation.Ref == "" {
+		return mutation.ID, nil
+	}
+
+	created, err := repository.GetSyncMutation(ctx, tx, userID, mutation.Ref)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrUnknownSyncRef
+		}
+		return 0, err
+	}
+	if created.Op != SyncOpCreate || created.Status != models.SyncStatusApplied {
+		return 0, ErrUnknownSyncRef
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+	"todo_api/internal/testdb"
+)
+
+func syncedIDs(changes *models.SyncChanges) (todos map[int]bool, deleted map[int]bool) {
+	todos, deleted = map[int]bool{}, map[int]bool{}
+	for _, todo := range changes.Todos {
+		todos[todo.ID] = true
+	}
+	for _, tombstone := range changes.Deleted {
+		deleted[tombstone.ID] = true
+	}
+	return todos, deleted
+}
+
+// 被移出清單之後，差異同步要送出他在清單裡建立的 todo 的刪除；重新加入之後再送回來
+func TestSyncRemovesListTodosAfterLeaving(t *testing.T) {
+	pool := testdb.New(t)
+	lists := NewTodoListService(pool)
+	s := NewSyncService(pool)
+	ctx := context.Background()
+
+	owner := testdb.CreateUser(t, pool, "sync-owner@example.com")
+	member := testdb.CreateUser(t, pool, "sync-member@example.com")
+
+	list, err := lists.CreateList(ctx, owner.ID, "Shared", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.AddTodoListMember(ctx, pool, list.ID, member.ID, models.ListRoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	todo, err := lists.CreateTodo(ctx, member.ID, list.ID, &models.Todo{Title: "shared", Priority: models.TodoPriorityNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, since, err := s.Changes(ctx, member.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todos, _ := syncedIDs(full); !todos[todo.ID] {
+		t.Fatalf("full sync = %+v, want todo %d", full.Todos, todo.ID)
+	}
+
+	if err := lists.RemoveMember(ctx, owner.ID, list.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, since, err := s.Changes(ctx, member.ID, &since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todos, deleted := syncedIDs(changes); todos[todo.ID] || !deleted[todo.ID] {
+		t.Fatalf("sync after removal = %+v, want todo %d deleted", changes, todo.ID)
+	}
+
+	if err := repository.AddTodoListMember(ctx, pool, list.ID, member.ID, models.ListRoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, _, err = s.Changes(ctx, member.ID, &since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todos, deleted := syncedIDs(changes); !todos[todo.ID] || deleted[todo.ID] {
+		t.Fatalf("sync after rejoining = %+v, want todo %d back", changes, todo.ID)
+	}
+
+	// 重新送回去只是碰了 sync_xid，版本不變，client 手上的 ETag 還能用
+	for _, synced := range changes.Todos {
+		if synced.ID == todo.ID && synced.Version != todo.Version {
+			t.Fatalf("version after rejoining = %d, want %d", synced.Version, todo.Version)
+		}
+	}
+}
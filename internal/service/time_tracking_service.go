@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTimeEntryNotFound = errors.New("time entry not found")
+	ErrNoRunningTimer    = errors.New("no running timer on this todo")
+	ErrInvalidTimeRange  = errors.New("ended_at must be after started_at")
+	ErrTimeInFuture      = errors.New("time entries cannot be in the future")
+)
+
+// 裝置時間可能有一點誤差，補登 / 修改的時間比現在晚這麼多以內還可以接受
+const timeEntryClockSkew = time.Minute
+
+// 計時紀錄的修改內容，nil 表示不改；EndedAt 設在還在跑的計時器上就等於停掉它
+type TimeEntryChanges struct {
+	StartedAt *time.Time
+	EndedAt   *time.Time
+	Note      *string
+}
+
+/*
+TimeTrackingService 管 todo 的計時：
+
+  - 計時器：Start / Stop，每個使用者同時只能有一個在跑（資料庫的 partial unique index 保證）
+  - 手動補登、修改、刪除計時紀錄
+  - Report：某段時間內花了多少時間，依天或依 todo 加總
+
+跑太久忘了停的計時器由 jobs.StartTimerSweeper 自動停掉
+*/
+type TimeTrackingService struct {
+	DB *pgxpool.Pool
+}
+
+func NewTimeTrackingService(db *pgxpool.Pool) *TimeTrackingService {
+	return &TimeTrackingService{
+		DB: db,
+	}
+}
+
+// 已經有計時器在跑回傳 repository.ErrTimerAlreadyRunning，要先停掉那一個（Running 查得到是哪個）
+func (s *TimeTrackingService) Start(ctx context.Context, userID string, todoID int, note string) (*models.TimeEntry, error) {
+	entry, err := repository.StartTimer(ctx, s.DB, userID, todoID, note)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	return entry, err
+}
+
+func (s *TimeTrackingService) Stop(ctx context.Context, userID string, todoID int) (*models.TimeEntry, error) {
+	if err := repository.CheckActiveTodo(ctx, s.DB, userID, todoID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
+	}
+
+	entry, err := repository.StopTimer(ctx, s.DB, userID, todoID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoRunningTimer
+	}
+	return entry, err
+}
+
+// 正在跑的計時器，沒有的話回傳 nil
+func (s *TimeTrackingService) Running(ctx context.Context, userID string) (*models.TimeEntry, error) {
+	entry, err := repository.GetRunningTimer(ctx, s.DB, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func (s *TimeTrackingService) List(ctx context.Context, userID string, todoID int) ([]models.TimeEntry, error) {
+	entries, err := repository.GetTimeEntries(ctx, s.DB, userID, todoID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	return entries, err
+}
+
+// 手動補登，一定要有結束時間（要計時請用 Start）
+func (s *TimeTrackingService) Create(ctx context.Context, userID string, todoID int, startedAt time.Time, endedAt time.Time, note string) (*models.TimeEntry, error) {
+	if err := validateTimeEntry(startedAt, &endedAt); err != nil {
+		return nil, err
+	}
+
+	entry, err := repository.CreateTimeEntry(ctx, s.DB, &models.TimeEntry{
+		TodoID:    todoID,
+		UserID:    userID,
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+		Note:      note,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	return entry, err
+}
+
+func (s *TimeTrackingService) Update(ctx context.Context, userID string, todoID int, id int64, changes TimeEntryChanges) (*models.TimeEntry, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	existing, err := repository.GetTimeEntryForUpdate(ctx, tx, userID, todoID, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTimeEntryNotFound
+		}
+		return nil, err
+	}
+
+	changed := *existing
+	if changes.StartedAt != nil {
+		changed.StartedAt = *changes.StartedAt
+	}
+	if changes.EndedAt != nil {
+		changed.EndedAt = changes.EndedAt
+	}
+	if changes.Note != nil {
+		changed.Note = *changes.Note
+	}
+	if err := validateTimeEntry(changed.StartedAt, changed.EndedAt); err != nil {
+		return nil, err
+	}
+
+	updated, err := repository.UpdateTimeEntry(ctx, tx, existing, &changed)
+	if errors.Is(err, repository.ErrNoFieldsToUpdate) {
+		return existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *TimeTrackingService) Delete(ctx context.Context, userID string, todoID int, id int64) error {
+	err := repository.DeleteTimeEntry(ctx, s.DB, userID, todoID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTimeEntryNotFound
+	}
+	return err
+}
+
+/*
+Report 加總 [from, to) 之間花的時間，跨過區間邊界的紀錄只算在區間裡的部分，正在跑的計時器算到現在
+
+  - groupBy = day：依 loc 的日期切，跨過午夜的紀錄會拆到兩天，只列出有時間的日子，舊的在前
+  - groupBy = todo：每個 todo 一組，花最多時間的在前
+*/
+func (s *TimeTrackingService) Report(ctx context.Context, userID string, from time.Time, to time.Time, groupBy string, loc *time.Location) (*models.TimeReport, error) {
+	spans, err := repository.GetTimeSpans(ctx, s.DB, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.TimeReport{
+		From:     from,
+		To:       to,
+		GroupBy:  groupBy,
+		TimeZone: loc.String(),
+		Groups:   []models.TimeReportGroup{},
+	}
+
+	var total time.Duration
+	for _, span := range spans {
+		total += span.End.Sub(span.Start)
+	}
+	report.TotalSeconds = int64(total / time.Second)
+
+	if groupBy == models.TimeReportGroupByTodo {
+		report.Groups = groupTimeSpansByTodo(spans)
+	} else {
+		report.Groups = groupTimeSpansByDay(spans, loc)
+	}
+	return report, nil
+}
+
+func groupTimeSpansByDay(spans []repository.TimeSpan, loc *time.Location) []models.TimeReportGroup {
+	byDay := map[string]time.Duration{}
+	for _, span := range spans {
+		start := span.Start.In(loc)
+		for start.Before(span.End) {
+			y, m, d := start.Date()
+			// time.Date 會處理夏令時間，隔天 00:00 不一定是 24 小時之後
+			next := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+			end := span.End
+			if next.Before(end) {
+				end = next
+			}
+			byDay[start.Format("2006-01-02")] += end.Sub(start)
+			start = next
+		}
+	}
+
+	groups := make([]models.TimeReportGroup, 0, len(byDay))
+	for day, duration := range byDay {
+		groups = append(groups, models.TimeReportGroup{Date: day, Seconds: int64(duration / time.Second)})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Date < groups[j].Date
+	})
+	return groups
+}
+
+func groupTimeSpansByTodo(spans []repository.TimeSpan) []models.TimeReportGroup {
+	byTodo := map[int]time.Duration{}
+	titles := map[int]string{}
+	for _, span := range spans {
+		byTodo[span.TodoID] += span.End.Sub(span.Start)
+		titles[span.TodoID] = span.Title
+	}
+
+	groups := make([]models.TimeReportGroup, 0, len(byTodo))
+	for todoID, duration := range byTodo {
+		groups = append(groups, models.TimeReportGroup{TodoID: todoID, Title: titles[todoID], Seconds: int64(duration / time.Second)})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Seconds != groups[j].Seconds {
+			return groups[i].Seconds > groups[j].Seconds
+		}
+		return groups[i].TodoID < groups[j].TodoID
+	})
+	return groups
+}
+
+// endedAt 是 nil 代表還在跑的計時器
+func validateTimeEntry(startedAt time.Time, endedAt *time.Time) error {
+	latest := time.Now().Add(timeEntryClockSkew)
+	if startedAt.After(latest) || (endedAt != nil && endedAt.After(latest)) {
+		return ErrTimeInFuture
+	}
+	if endedAt != nil && !endedAt.After(startedAt) {
+		return ErrInvalidTimeRange
+	}
+	return nil
+}
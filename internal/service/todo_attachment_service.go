@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrAttachmentNotFound       = errors.New("attachment not found")
+	ErrAttachmentTooLarge       = errors.New("attachment is too large")
+	ErrAttachmentTypeNotAllowed = errors.New("attachment type is not allowed")
+)
+
+// 可以上傳的檔案類型，依檔案內容判斷（http.DetectContentType），不相信副檔名跟前端給的 Content-Type
+// 不收 HTML / SVG 這種瀏覽器會執行的格式
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+/*
+TodoAttachmentService 管 todo 的附件：檔案內容放 BlobStorage，中繼資料放 todo_attachments
+
+  - 單一檔案最大 MaxFileBytes，每個使用者所有附件加起來最多 QuotaBytes
+  - 檔案類型看內容判斷，只收 allowedAttachmentTypes
+  - 刪除附件、永久刪除 todo 時，檔案由 trigger 排進待刪除，jobs.StartBlobCleaner 再去刪
+*/
+type TodoAttachmentService struct {
+	DB           *pgxpool.Pool
+	Blobs        repository.BlobStorage
+	MaxFileBytes int64
+	QuotaBytes   int64
+}
+
+func NewTodoAttachmentService(db *pgxpool.Pool, blobs repository.BlobStorage, maxFileBytes int64, quotaBytes int64) *TodoAttachmentService {
+	return &TodoAttachmentService{
+		DB:           db,
+		Blobs:        blobs,
+		MaxFileBytes: maxFileBytes,
+		QuotaBytes:   quotaBytes,
+	}
+}
+
+/*
+整體流程：
+1. 檢查大小、todo、用量（先擋掉明顯不行的，不用白傳一次檔案）
+2. 讀前 512 bytes 判斷檔案類型
+3. 寫進 BlobStorage
+4. 記錄中繼資料，同一個 transaction 裡再檢查一次用量（同時上傳的檔案不會一起超過）
+5. 第 4 步失敗就把第 3 步寫進去的檔案刪掉
+*/
+func (s *TodoAttachmentService) Upload(ctx context.Context, userID string, todoID int, fileHeader *multipart.FileHeader) (*models.TodoAttachment, error) {
+	if fileHeader.Size > s.MaxFileBytes {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	if err := repository.CheckActiveTodo(ctx, s.DB, userID, todoID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
+	}
+	used, err := repository.GetAttachmentUsage(ctx, s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	if used+fileHeader.Size > s.QuotaBytes {
+		return nil, repository.ErrAttachmentQuotaExceeded
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("讀取上傳檔案失敗: %w", err)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !allowedAttachmentTypes[mediaType] {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, contentType)
+	}
+
+	key := fmt.Sprintf("attachments/%s/%d/%s", userID, todoID, uuid.NewString())
+	body := io.MultiReader(bytes.NewReader(head), file)
+	if err := s.Blobs.Put(ctx, key, body, repository.BlobOptions{ContentType: contentType, CacheControl: "private"}); err != nil {
+		return nil, err
+	}
+
+	attachment, err := repository.CreateTodoAttachment(ctx, s.DB, &models.TodoAttachment{
+		TodoID:      todoID,
+		UserID:      userID,
+		Filename:    cleanAttachmentFilename(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		StorageKey:  key,
+	}, s.QuotaBytes)
+	if err != nil {
+		// request 可能已經被取消，刪檔案用不會被取消的 context
+		if delErr := s.Blobs.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+			log.Printf("failed to delete orphaned attachment blob %s: %v\n", key, delErr)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTodoNotFound
+		}
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+func (s *TodoAttachmentService) List(ctx context.Context, userID string, todoID int) ([]models.TodoAttachment, error) {
+	attachments, err := repository.GetTodoAttachments(ctx, s.DB, userID, todoID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	return attachments, err
+}
+
+// 回傳的 io.ReadCloser 由呼叫端 Close
+func (s *TodoAttachmentService) Open(ctx context.Context, userID string, todoID int, id string) (*models.TodoAttachment, io.ReadCloser, error) {
+	attachment, err := repository.GetTodoAttachment(ctx, s.DB, userID, todoID, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+
+	body, err := s.Blobs.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, repository.ErrBlobNotFound) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return attachment, body, nil
+}
+
+func (s *TodoAttachmentService) Delete(ctx context.Context, userID string, todoID int, id string) error {
+	err := repository.DeleteTodoAttachment(ctx, s.DB, userID, todoID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAttachmentNotFound
+	}
+	return err
+}
+
+// 只留檔名本身（有些瀏覽器會帶完整路徑），去掉控制字元，最多 255 個字
+func cleanAttachmentFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 批次操作支援的種類
+const (
+	BulkOpCreate   = "create"
+	BulkOpUpdate   = "update"
+	BulkOpComplete = "complete"
+	BulkOpDelete   = "delete"
+	BulkOpMove     = "move"
+)
+
+// 批次裡的一個操作；handler 負責解析跟欄位驗證，這裡只管寫入
+type BulkOperation struct {
+	Op string
+	ID int // create 以外都要有
+
+	// create：要新增的 todo
+	Todo *models.Todo
+	// update：把修改套用到鎖住的現有資料上並驗證，回傳要寫回的完整 todo
+	Apply func(existing *models.Todo) (*models.Todo, error)
+	// update / complete：有給就要跟資料庫裡的 version 一致
+	Version *int
+	// move：跟 POST /todos/:id/move 一樣
+	Before *int
+	After  *int
+
+	// handler 解析時就失敗的操作（例如欄位格式錯誤），直接當作這一筆失敗
+	Err error
+}
+
+/*
+BulkTodoService 在同一個 transaction 裡依序執行一串操作
+
+  - atomic：任何一筆失敗整批 rollback，其他筆標成 rolled_back / skipped
+  - best-effort：每一筆包在自己的 savepoint 裡，失敗只撤銷那一筆，其他照常 commit
+
+連續的 create 會合併成一個 pgx.Batch 送出；best-effort 模式下整批失敗時，再退回一筆一筆新增找出是哪一筆有問題
+*/
+type BulkTodoService struct {
+	DB *pgxpool.Pool
+}
+
+func NewBulkTodoService(db *pgxpool.Pool) *BulkTodoService {
+	return &BulkTodoService{
+		DB: db,
+	}
+}
+
+// 只有連線或 commit 這種整批都做不了的錯誤才會回傳 error，個別操作的失敗都在結果裡
+func (s *BulkTodoService) Run(ctx context.Context, userID string, ops []BulkOperation, atomic bool) (*models.BulkTodoResponse, error) {
+	response := &models.BulkTodoResponse{
+		Atomic:  atomic,
+		Results: make([]models.BulkTodoResult, len(ops)),
+	}
+	for i, op := range ops {
+		response.Results[i] = models.BulkTodoResult{Index: i, Op: op.Op, ID: op.ID, Status: models.BulkStatusSkipped}
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for i := 0; i < len(ops); {
+		// 連續的 create 一起送
+		end := i + 1
+		if ops[i].Op == BulkOpCreate && ops[i].Err == nil {
+			for end < len(ops) && ops[end].Op == BulkOpCreate && ops[end].Err == nil {
+				end++
+			}
+		}
+
+		var failed int
+		if atomic {
+			failed = s.runAtomic(ctx, tx, userID, ops, i, end, response.Results)
+		} else {
+			err = s.runBestEffort(ctx, tx, userID, ops, i, end, response.Results)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if atomic && failed >= 0 {
+			// 整批撤銷：之前成功的都不算數
+			for j := 0; j < failed; j++ {
+				response.Results[j].Status = models.BulkStatusRolledBack
+				response.Results[j].Todo = nil
+				if ops[j].Op == BulkOpCreate {
+					response.Results[j].ID = 0
+				}
+			}
+			response.Failed = 1
+			return response, nil
+		}
+		i = end
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, result := range response.Results {
+		if result.Status == models.BulkStatusOK {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	return response, nil
+}
+
+// 直接在 tx 上執行 ops[start:end]；回傳失敗的那一筆的 index，全部成功回傳 -1
+func (s *BulkTodoService) runAtomic(ctx context.Context, tx pgx.Tx, userID string, ops []BulkOperation, start, end int, results []models.BulkTodoResult) int {
+	if ops[start].Op == BulkOpCreate && ops[start].Err == nil {
+		created, err := repository.CreateTodosBatch(ctx, tx, userID, createTodos(ops[start:end]))
+		if err != nil {
+			index := start
+			var batchErr *repository.BatchInsertError
+			if errors.As(err, &batchErr) {
+				index = start + batchErr.Index
+				err = batchErr.Err
+			}
+			setResult(&results[index], nil, err)
+			return index
+		}
+		for j, todo := range created {
+			setResult(&results[start+j], todo, nil)
+		}
+		return -1
+	}
+
+	todo, err := runOperation(ctx, tx, userID, ops[start])
+	setResult(&results[start], todo, err)
+	if err != nil {
+		return start
+	}
+	return -1
+}
+
+// 每一筆（或一整批 create）包在 savepoint 裡，失敗就只撤銷自己
+func (s *BulkTodoService) runBestEffort(ctx context.Context, tx pgx.Tx, userID string, ops []BulkOperation, start, end int, results []models.BulkTodoResult) error {
+	if ops[start].Op == BulkOpCreate && ops[start].Err == nil && end-start > 1 {
+		var created []*models.Todo
+		err := withSavepoint(ctx, tx, func(sp pgx.Tx) error {
+			var err error
+			created, err = repository.CreateTodosBatch(ctx, sp, userID, createTodos(ops[start:end]))
+			return err
+		})
+		if err == nil {
+			for j, todo := range created {
+				setResult(&results[start+j], todo, nil)
+			}
+			return nil
+		}
+		if !isOperationError(err) {
+			return err
+		}
+		// 整批失敗：退回一筆一筆新增，其他筆照樣成功
+	}
+
+	for j := start; j < end; j++ {
+		var todo *models.Todo
+		err := withSavepoint(ctx, tx, func(sp pgx.Tx) error {
+			var err error
+			todo, err = runOperation(ctx, sp, userID, ops[j])
+			return err
+		})
+		if err != nil && !isOperationError(err) {
+			return err
+		}
+		setResult(&results[j], todo, err)
+	}
+	return nil
+}
+
+// tx.Begin 在 transaction 裡就是 SAVEPOINT，Rollback 只撤銷到這裡
+func withSavepoint(ctx context.Context, tx pgx.Tx, fn func(sp pgx.Tx) error) error {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("建立 savepoint 失敗: %w", err)
+	}
+	defer sp.Rollback(ctx)
+
+	if err := fn(sp); err != nil {
+		return err
+	}
+	return sp.Commit(ctx)
+}
+
+// context 被取消 / 逾時時，後面的操作也做不了，整個 request 直接失敗
+func isOperationError(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func runOperation(ctx context.Context, tx pgx.Tx, userID string, op BulkOperation) (*models.Todo, error) {
+	if op.Err != nil {
+		return nil, op.Err
+	}
+
+	var todo *models.Todo
+	var err error
+	switch op.Op {
+	case BulkOpCreate:
+		var created []*models.Todo
+		created, err = repository.CreateTodosBatch(ctx, tx, userID, []*models.Todo{op.Todo})
+		var batchErr *repository.BatchInsertError
+		if errors.As(err, &batchErr) {
+			err = batchErr.Err
+		}
+		if err == nil {
+			todo = created[0]
+		}
+
+	case BulkOpUpdate, BulkOpComplete:
+		var existing *models.Todo
+		existing, err = repository.GetTodoForUpdate(ctx, tx, userID, op.ID)
+		if err != nil {
+			break
+		}
+		if op.Version != nil && *op.Version != existing.Version {
+			return nil, repository.ErrVersionConflict
+		}
+
+		changed := *existing
+		if op.Op == BulkOpComplete {
+			changed.Completed = true
+		} else {
+			var applied *models.Todo
+			if applied, err = op.Apply(existing); err != nil {
+				return nil, err
+			}
+			changed = *applied
+		}
+		todo, err = repository.UpdateTodoTx(ctx, tx, userID, &changed)
+
+	case BulkOpDelete:
+		err = repository.DeleteTodoTx(ctx, tx, userID, op.ID)
+
+	case BulkOpMove:
+		todo, err = repository.MoveTodoTx(ctx, tx, userID, op.ID, op.Before, op.After)
+
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	}
+	return todo, err
+}
+
+func createTodos(ops []BulkOperation) []*models.Todo {
+	todos := make([]*models.Todo, len(ops))
+	for i, op := range ops {
+		todos[i] = op.Todo
+	}
+	return todos
+}
+
+func setResult(result *models.BulkTodoResult, todo *models.Todo, err error) {
+	if err != nil {
+		result.Status = models.BulkStatusFailed
+		result.Error = err.Error()
+		return
+	}
+	result.Status = models.BulkStatusOK
+	result.Todo = todo
+	if todo != nil {
+		result.ID = todo.ID
+	}
+}
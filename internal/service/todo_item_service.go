@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// 不是自己的 todo 也回傳這個，不讓別人知道這個 id 是否存在
+	ErrTodoNotFound     = errors.New("todo not found")
+	ErrTodoItemNotFound = errors.New("todo item not found")
+)
+
+// 子任務的修改內容，nil 表示不改
+type TodoItemChanges struct {
+	Title     *string
+	Completed *bool
+	Position  *int
+}
+
+// 子任務 CRUD：每個動作都先鎖住父 todo 確認是自己的，
+// 再在同一個 transaction 裡改子任務（以及自動完成父 todo）
+type TodoItemService struct {
+	DB *pgxpool.Pool
+}
+
+func NewTodoItemService(db *pgxpool.Pool) *TodoItemService {
+	return &TodoItemService{
+		DB: db,
+	}
+}
+
+// 在 transaction 裡鎖住父 todo 再執行 fn，fn 沒有錯誤才 Commit
+func (s *TodoItemService) withTodo(ctx context.Context, userID string, todoID int, fn func(tx pgx.Tx, todo *models.Todo) error) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	todo, err := repository.GetTodoForUpdate(ctx, tx, userID, todoID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTodoNotFound
+		}
+		return err
+	}
+
+	if err := fn(tx, todo); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *TodoItemService) GetItems(ctx context.Context, userID string, todoID int) ([]models.TodoItem, error) {
+	var items []models.TodoItem
+	err := s.withTodo(ctx, userID, todoID, func(tx pgx.Tx, _ *models.Todo) error {
+		var err error
+		items, err = repository.GetTodoItems(ctx, tx, todoID)
+		return err
+	})
+	return items, err
+}
+
+func (s *TodoItemService) CreateItem(ctx context.Context, userID string, todoID int, title string, position *int) (*models.TodoItem, error) {
+	var item *models.TodoItem
+	err := s.withTodo(ctx, userID, todoID, func(tx pgx.Tx, _ *models.Todo) error {
+		var err error
+		item, err = repository.CreateTodoItem(ctx, tx, todoID, title, position)
+		if err != nil {
+			return err
+		}
+		return repository.TouchTodo(ctx, tx, todoID)
+	})
+	return item, err
+}
+
+/*
+整體流程：
+1. 鎖住父 todo，確認是自己的
+2. 套用修改、更新子任務
+3. autoComplete 為 true 且子任務全部完成 => 父 todo 也標記完成
+全部在同一個 transaction，不會出現子任務改了、父 todo 沒跟上的狀態
+回傳值的 bool 表示父 todo 這次有沒有被自動完成
+*/
+func (s *TodoItemService) UpdateItem(ctx context.Context, userID string, todoID int, itemID int, changes TodoItemChanges, autoComplete bool) (*models.TodoItem, bool, error) {
+	var (
+		updated       *models.TodoItem
+		todoCompleted bool
+	)
+
+	err := s.withTodo(ctx, userID, todoID, func(tx pgx.Tx, _ *models.Todo) error {
+		item, err := repository.GetTodoItemByID(ctx, tx, todoID, itemID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTodoItemNotFound
+			}
+			return err
+		}
+
+		if changes.Title != nil {
+			item.Title = *changes.Title
+		}
+		if changes.Completed != nil {
+			item.Completed = *changes.Completed
+		}
+		if changes.Position != nil {
+			item.Position = *changes.Position
+		}
+
+		updated, err = repository.UpdateTodoItem(ctx, tx, item)
+		if err != nil {
+			return err
+		}
+
+		if autoComplete && updated.Completed {
+			todoCompleted, err = repository.CompleteTodoIfAllItemsDone(ctx, tx, userID, todoID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// 自動完成已經更新過 todo 了（updated_at、version 都變了），不用再 touch 一次
+		if todoCompleted {
+			return nil
+		}
+		return repository.TouchTodo(ctx, tx, todoID)
+	})
+
+	return updated, todoCompleted, err
+}
+
+func (s *TodoItemService) DeleteItem(ctx context.Context, userID string, todoID int, itemID int) error {
+	return s.withTodo(ctx, userID, todoID, func(tx pgx.Tx, _ *models.Todo) error {
+		if err := repository.DeleteTodoItem(ctx, tx, todoID, itemID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTodoItemNotFound
+			}
+			return err
+		}
+		return repository.TouchTodo(ctx, tx, todoID)
+	})
+}
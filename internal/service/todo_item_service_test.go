@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+	"todo_api/internal/testdb"
+)
+
+// 子任務全部完成讓週期性的 todo 自動完成時，跟直接完成一樣要產生下一次
+func TestAutoCompleteSpawnsNextOccurrence(t *testing.T) {
+	s := NewTodoItemService(testdb.New(t))
+	ctx := context.Background()
+	user := testdb.CreateUser(t, s.DB, "items@example.com")
+
+	due := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)
+	todo, err := repository.CreateTodo(s.DB, &models.Todo{
+		UserID:          user.ID,
+		Title:           "daily stand-up",
+		Priority:        models.TodoPriorityNormal,
+		DueAt:           &due,
+		RecurrenceRule:  "FREQ=DAILY",
+		RecurrenceTZ:    "UTC",
+		RecurrenceStart: &due,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.CreateItem(ctx, user.ID, todo.ID, "notes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := true
+	_, completed, err := s.UpdateItem(ctx, user.ID, todo.ID, item.ID, TodoItemChanges{Completed: &done}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !completed {
+		t.Fatal("UpdateItem() did not complete the todo")
+	}
+
+	current, err := repository.GetTodoByID(s.DB, user.ID, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current.Completed || current.RecurrenceNextID == nil {
+		t.Fatalf("todo = %+v, want completed with recurrence_next_id", current)
+	}
+
+	next, err := repository.GetTodoByID(s.DB, user.ID, *current.RecurrenceNextID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := due.AddDate(0, 0, 1); next.Completed || next.DueAt == nil || !next.DueAt.Equal(want) {
+		t.Fatalf("next occurrence = %+v, want open todo due %v", next, want)
+	}
+}
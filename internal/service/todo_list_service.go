@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo_api/internal/models"
+	"todo_api/internal/pagination"
+	"todo_api/internal/repository"
+	"todo_api/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 邀請連結多久之後失效
+const todoListInviteTTL = 7 * 24 * time.Hour
+
+var (
+	// 不是成員也回傳這個，不讓別人知道這個清單是否存在
+	ErrTodoListNotFound  = errors.New("todo list not found")
+	ErrTodoListForbidden = errors.New("insufficient permission on this todo list")
+	ErrInviteInvalid     = errors.New("invite is invalid, expired or already used")
+	ErrInviteEmail       = errors.New("invite was sent to a different email")
+	ErrMemberNotFound    = errors.New("member not found or cannot be removed")
+)
+
+// 清單相關的權限檢查都集中在這裡，handler 只負責解析 request / 回傳 response
+// owner：管理成員、邀請、刪除清單
+// editor：新增 / 修改清單裡的 todo
+// viewer：只能看
+type TodoListService struct {
+	DB *pgxpool.Pool
+}
+
+func NewTodoListService(db *pgxpool.Pool) *TodoListService {
+	return &TodoListService{
+		DB: db,
+	}
+}
+
+// 檢查 userID 在 listID 至少有 required 的角色，回傳實際的角色
+func (s *TodoListService) authorize(ctx context.Context, userID string, listID string, required string) (string, error) {
+	role, err := repository.GetTodoListMemberRole(ctx, s.DB, listID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrTodoListNotFound
+		}
+		return "", err
+	}
+
+	if !models.ListRoleAtLeast(role, required) {
+		return role, ErrTodoListForbidden
+	}
+
+	return role, nil
+}
+
+/*
+整體流程：
+1. 新增清單
+2. 建立者自動成為 owner
+兩步放在同一個 transaction，不會出現沒有 owner 的清單
+*/
+func (s *TodoListService) CreateList(ctx context.Context, userID string, name string, description string) (*models.TodoList, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Commit 成功之後再 Rollback 不會有任何作用，所以可以放心 defer
+	defer tx.Rollback(ctx)
+
+	list, err := repository.CreateTodoList(ctx, tx, &models.TodoList{
+		OwnerID:     userID,
+		Name:        name,
+		Description: description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repository.AddTodoListMember(ctx, tx, list.ID, userID, models.ListRoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	list.Role = models.ListRoleOwner
+	return list, nil
+}
+
+func (s *TodoListService) GetLists(ctx context.Context, userID string) ([]models.TodoList, error) {
+	return repository.GetTodoListsForUser(ctx, s.DB, userID)
+}
+
+func (s *TodoListService) GetList(ctx context.Context, userID string, listID string) (*models.TodoList, error) {
+	list, err := repository.GetTodoListForUser(ctx, s.DB, listID, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTodoListNotFound
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *TodoListService) DeleteList(ctx context.Context, userID string, listID string) error {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleOwner); err != nil {
+		return err
+	}
+	return repository.DeleteTodoList(ctx, s.DB, listID)
+}
+
+func (s *TodoListService) GetMembers(ctx context.Context, userID string, listID string) ([]models.TodoListMember, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+	return repository.GetTodoListMembers(ctx, s.DB, listID)
+}
+
+// owner 可以移除任何非 owner 成員；其他成員只能移除自己（退出清單）
+func (s *TodoListService) RemoveMember(ctx context.Context, userID string, listID string, memberID string) error {
+	required := models.ListRoleOwner
+	if memberID == userID {
+		required = models.ListRoleViewer
+	}
+	if _, err := s.authorize(ctx, userID, listID, required); err != nil {
+		return err
+	}
+
+	if err := repository.RemoveTodoListMember(ctx, s.DB, listID, memberID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMemberNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// 建立邀請，回傳邀請內容跟原始 token（只有這一次拿得到，資料庫只存 hash）
+func (s *TodoListService) Invite(ctx context.Context, userID string, listID string, email string, role string) (*models.TodoListInvite, string, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleOwner); err != nil {
+		return nil, "", err
+	}
+
+	if role != models.ListRoleEditor && role != models.ListRoleViewer {
+		return nil, "", fmt.Errorf("invalid role: %s", role)
+	}
+
+	token, err := utils.NewRandomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	invite, err := repository.CreateTodoListInvite(ctx, s.DB, &models.TodoListInvite{
+		ListID:    listID,
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		Role:      role,
+		InvitedBy: userID,
+		ExpiresAt: time.Now().Add(todoListInviteTTL),
+	}, utils.HashToken(token))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return invite, token, nil
+}
+
+func (s *TodoListService) GetPendingInvites(ctx context.Context, userID string, listID string) ([]models.TodoListInvite, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleOwner); err != nil {
+		return nil, err
+	}
+	return repository.GetPendingTodoListInvites(ctx, s.DB, listID, time.Now())
+}
+
+func (s *TodoListService) RevokeInvite(ctx context.Context, userID string, listID string, inviteID string) error {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleOwner); err != nil {
+		return err
+	}
+
+	if err := repository.RevokeTodoListInvite(ctx, s.DB, listID, inviteID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInviteInvalid
+		}
+		return err
+	}
+	return nil
+}
+
+/*
+整體流程：
+1. 用 token 的 hash 找到邀請並鎖住
+2. 檢查邀請還有效、登入的帳號 email 跟被邀請的 email 一樣
+3. 加入成員
+4. 標記邀請已接受
+全部在同一個 transaction 裡，同一個 token 不會被用兩次
+*/
+func (s *TodoListService) AcceptInvite(ctx context.Context, userID string, token string) (*models.TodoList, error) {
+	user, err := repository.GetUserByID(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	invite, err := repository.GetTodoListInviteByTokenHashForUpdate(ctx, tx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInviteInvalid
+		}
+		return nil, err
+	}
+
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInviteInvalid
+	}
+
+	if !strings.EqualFold(invite.Email, user.Email) {
+		return nil, ErrInviteEmail
+	}
+
+	if err := repository.AddTodoListMember(ctx, tx, invite.ListID, userID, invite.Role); err != nil {
+		return nil, err
+	}
+
+	if err := repository.MarkTodoListInviteAccepted(ctx, tx, invite.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.GetList(ctx, userID, invite.ListID)
+}
+
+// 清單裡的 todos，viewer 以上可以看
+func (s *TodoListService) GetTodos(ctx context.Context, userID string, listID string, params pagination.Params, filter models.TodoFilter) (*models.TodoListResponse, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+	return repository.GetListTodos(s.DB, listID, params, filter)
+}
+
+func (s *TodoListService) GetTodo(ctx context.Context, userID string, listID string, todoID int) (*models.Todo, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleViewer); err != nil {
+		return nil, err
+	}
+	return repository.GetListTodoByID(ctx, s.DB, listID, todoID)
+}
+
+// 在清單裡新增 todo，editor 以上才可以；建立者記錄為目前登入的使用者
+func (s *TodoListService) CreateTodo(ctx context.Context, userID string, listID string, todo *models.Todo) (*models.Todo, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleEditor); err != nil {
+		return nil, err
+	}
+
+	todo.UserID = userID
+	todo.ListID = &listID
+	return repository.CreateTodo(s.DB, todo)
+}
+
+// changed 是 handler 套用完修改的完整 todo，這裡再確認一次它真的屬於這個清單
+func (s *TodoListService) UpdateTodo(ctx context.Context, userID string, listID string, changed *models.Todo) (*models.Todo, error) {
+	if _, err := s.authorize(ctx, userID, listID, models.ListRoleEditor); err != nil {
+		return nil, err
+	}
+
+	existing, err := repository.GetListTodoByID(ctx, s.DB, listID, changed.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// user_id / list_id 不能被前端改掉，一律用資料庫裡的
+	changed.UserID = existing.UserID
+	changed.ListID = existing.ListID
+	return repository.UpdateListTodo(ctx, s.DB, userID, listID, changed)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"todo_api/internal/models"
+	"todo_api/internal/repository"
+	"todo_api/internal/testdb"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// 建立者被移出清單之後，清單裡的其他成員還是要能改他建立的 todo，但他自己的 /todos/:id 就看不到了
+func TestUpdateListTodoAfterCreatorLeaves(t *testing.T) {
+	s := NewTodoListService(testdb.New(t))
+	ctx := context.Background()
+
+	owner := testdb.CreateUser(t, s.DB, "owner@example.com")
+	creator := testdb.CreateUser(t, s.DB, "creator@example.com")
+
+	list, err := s.CreateList(ctx, owner.ID, "Shared", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.AddTodoListMember(ctx, s.DB, list.ID, creator.ID, models.ListRoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	todo, err := s.CreateTodo(ctx, creator.ID, list.ID, &models.Todo{Title: "before", Priority: models.TodoPriorityNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveMember(ctx, owner.ID, list.ID, creator.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := *todo
+	changed.Title = "after"
+	updated, err := s.UpdateTodo(ctx, owner.ID, list.ID, &changed)
+	if err != nil {
+		t.Fatalf("UpdateTodo() after creator left error = %v", err)
+	}
+	if updated.Title != "after" || updated.UserID != creator.ID {
+		t.Fatalf("UpdateTodo() = %+v", updated)
+	}
+
+	if _, err := repository.GetTodoByID(s.DB, creator.ID, todo.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("GetTodoByID() for removed creator error = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+// 排序位置是每個建立者各自一組，清單裡的 todo 不能拖拉排序
+func TestMoveRejectsListTodos(t *testing.T) {
+	s := NewTodoListService(testdb.New(t))
+	ctx := context.Background()
+	owner := testdb.CreateUser(t, s.DB, "mover@example.com")
+
+	list, err := s.CreateList(ctx, owner.ID, "Shared", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inList, err := s.CreateTodo(ctx, owner.ID, list.ID, &models.Todo{Title: "in list", Priority: models.TodoPriorityNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	personal, err := repository.CreateTodo(s.DB, &models.Todo{UserID: owner.ID, Title: "personal", Priority: models.TodoPriorityNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repository.MoveTodo(ctx, s.DB, owner.ID, inList.ID, &personal.ID, nil); !errors.Is(err, repository.ErrInvalidMove) {
+		t.Fatalf("moving a list todo error = %v, want ErrInvalidMove", err)
+	}
+	if _, err := repository.MoveTodo(ctx, s.DB, owner.ID, personal.ID, nil, &inList.ID); !errors.Is(err, repository.ErrInvalidMove) {
+		t.Fatalf("moving next to a list todo error = %v, want ErrInvalidMove", err)
+	}
+}
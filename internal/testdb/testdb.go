@@ -0,0 +1,125 @@
+/*
+testdb 給需要真的 Postgres 的測試用
+
+TEST_DATABASE_URL 沒設定就 t.Skip；有設定的話每個測試建一個自己的 schema、跑完全部的 up migration，
+測試結束再整個 DROP 掉，所以可以指向一個空的開發資料庫，不會動到 public 裡的資料
+*/
+package testdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"todo_api/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func New(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL 沒有設定，略過需要資料庫的測試")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgx.ConnectConfig(ctx, config.ConnConfig.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close(ctx)
+
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		conn, err := pgx.ConnectConfig(ctx, config.ConnConfig.Copy())
+		if err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+			return
+		}
+		defer conn.Close(ctx)
+
+		if _, err := conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	// public 放在後面，資料庫層級裝的 extension 還是找得到
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := migrate(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// 照檔名順序跑 migrations/*.up.sql，跟 migrate CLI 的順序一樣
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("找不到 migration 檔案: %s", dir)
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		sql, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(sql)) == "" {
+			continue
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(name), err)
+		}
+	}
+	return nil
+}
+
+// 直接 INSERT 一個還沒驗證 email 的使用者（repository.CreateUser 會固定等 2 秒）
+func CreateUser(t *testing.T, pool *pgxpool.Pool, email string) *models.User {
+	t.Helper()
+
+	var user models.User
+	err := pool.QueryRow(context.Background(), `
+		INSERT INTO users (email, password)
+		VALUES ($1, 'not-a-real-hash')
+		RETURNING id, email, email_verified_at, created_at, updated_at
+	`, email).Scan(&user.ID, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
@@ -0,0 +1,157 @@
+package todoio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"todo_api/internal/models"
+)
+
+// 匯出的欄位順序；匯入時依標題列對應，順序不重要，不認得的欄位（例如 id）直接忽略
+var csvColumns = []string{
+	"id", "title", "completed", "due_at", "priority", "notes", "remind_at",
+	"recurrence_rule", "recurrence_tz", "recurrence_start", "position", "created_at", "updated_at",
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w)}
+	if err := e.w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvEncoder) Encode(todo *models.Todo) error {
+	createdAt, updatedAt := todo.CreatedAt, todo.UpdatedAt
+	// csv.Writer 自己有緩衝，滿了才會寫到底下的 writer
+	return e.w.Write([]string{
+		strconv.Itoa(todo.ID),
+		todo.Title,
+		strconv.FormatBool(todo.Completed),
+		formatTime(todo.DueAt),
+		todo.Priority,
+		todo.Notes,
+		formatTime(todo.RemindAt),
+		todo.RecurrenceRule,
+		todo.RecurrenceTZ,
+		formatTime(todo.RecurrenceStart),
+		todo.Position,
+		formatTime(&createdAt),
+		formatTime(&updatedAt),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func decodeCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	// 欄位數不對在下面逐筆檢查，不要讓整個檔案失敗
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: empty file", ErrInvalidFile)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	// Excel 存的 CSV 開頭會有 BOM
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["title"]; !ok {
+		return nil, fmt.Errorf("%w: header row must contain a title column", ErrInvalidFile)
+	}
+
+	var records []Record
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if isBlankRow(fields) {
+			continue
+		}
+		if len(fields) != len(header) {
+			records = append(records, Record{
+				Row: line,
+				Err: fmt.Errorf("expected %d fields, got %d", len(header), len(fields)),
+			})
+			continue
+		}
+
+		todo, err := csvTodo(index, fields)
+		records = append(records, Record{Row: line, Todo: todo, Err: err})
+	}
+
+	return records, nil
+}
+
+func isBlankRow(fields []string) bool {
+	for _, field := range fields {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func csvTodo(index map[string]int, fields []string) (*models.Todo, error) {
+	get := func(name string) string {
+		if i, ok := index[name]; ok {
+			return fields[i]
+		}
+		return ""
+	}
+
+	todo := &models.Todo{
+		Title:          get("title"),
+		Priority:       strings.TrimSpace(get("priority")),
+		Notes:          get("notes"),
+		RecurrenceRule: strings.TrimSpace(get("recurrence_rule")),
+		RecurrenceTZ:   strings.TrimSpace(get("recurrence_tz")),
+	}
+
+	if raw := strings.TrimSpace(get("completed")); raw != "" {
+		completed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return todo, fmt.Errorf("completed: invalid boolean %q", raw)
+		}
+		todo.Completed = completed
+	}
+
+	var err error
+	if todo.DueAt, err = parseTime("due_at", get("due_at")); err != nil {
+		return todo, err
+	}
+	if todo.RemindAt, err = parseTime("remind_at", get("remind_at")); err != nil {
+		return todo, err
+	}
+	if todo.RecurrenceStart, err = parseTime("recurrence_start", get("recurrence_start")); err != nil {
+		return todo, err
+	}
+
+	return todo, nil
+}
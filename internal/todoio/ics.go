@@ -0,0 +1,456 @@
+package todoio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todo_api/internal/models"
+	"todo_api/internal/recurrence"
+)
+
+/*
+iCalendar（RFC 5545）的 VTODO，一筆 todo 對應：
+
+	BEGIN:VTODO
+	UID:todo-12@todo-api
+	DTSTAMP:20250101T000000Z
+	SUMMARY:買牛奶
+	DESCRIPTION:備註
+	DUE:20250131T090000Z
+	DTSTART;TZID=Asia/Taipei:20250131T170000   <- 週期性 todo 才有，規則依這個時區展開
+	RRULE:FREQ=WEEKLY;BYDAY=MO
+	PRIORITY:3
+	STATUS:NEEDS-ACTION
+	BEGIN:VALARM                                <- 提醒
+	ACTION:DISPLAY
+	TRIGGER;VALUE=DATE-TIME:20250131T010000Z
+	END:VALARM
+	END:VTODO
+*/
+
+const icsTimeUTC = "20060102T150405Z"
+
+// 優先順序：iCalendar 是 1（最高）~ 9（最低），0 代表沒設定
+var icsPriorities = map[string]int{
+	models.TodoPriorityUrgent: 1,
+	models.TodoPriorityHigh:   3,
+	models.TodoPriorityNormal: 5,
+	models.TodoPriorityLow:    9,
+}
+
+func icsPriority(value int) string {
+	switch {
+	case value >= 1 && value <= 2:
+		return models.TodoPriorityUrgent
+	case value >= 3 && value <= 4:
+		return models.TodoPriorityHigh
+	case value >= 6 && value <= 9:
+		return models.TodoPriorityLow
+	default:
+		return models.TodoPriorityNormal
+	}
+}
+
+type icsEncoder struct {
+	w *bufio.Writer
+}
+
+func newICSEncoder(w io.Writer) (*icsEncoder, error) {
+	e := &icsEncoder{w: bufio.NewWriter(w)}
+	for _, line := range []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//todo_api//todos export//EN",
+		"CALSCALE:GREGORIAN",
+	} {
+		if err := e.writeLine(line); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// 每行最多 75 bytes，超過的折行（CRLF + 一個空白，空白也算在下一行的 75 bytes 裡），不能把 UTF-8 字元切一半
+func (e *icsEncoder) writeLine(line string) error {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, err := e.w.WriteString(line[:cut] + "\r\n "); err != nil {
+			return err
+		}
+		line = line[cut:]
+		limit = 74
+	}
+	_, err := e.w.WriteString(line + "\r\n")
+	return err
+}
+
+func (e *icsEncoder) Encode(todo *models.Todo) error {
+	lines := []string{
+		"BEGIN:VTODO",
+		fmt.Sprintf("UID:todo-%d@todo-api", todo.ID),
+		"DTSTAMP:" + todo.UpdatedAt.UTC().Format(icsTimeUTC),
+		"CREATED:" + todo.CreatedAt.UTC().Format(icsTimeUTC),
+		"LAST-MODIFIED:" + todo.UpdatedAt.UTC().Format(icsTimeUTC),
+		"SUMMARY:" + escapeICSText(todo.Title),
+	}
+	if todo.Notes != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeICSText(todo.Notes))
+	}
+	if todo.DueAt != nil {
+		lines = append(lines, "DUE:"+todo.DueAt.UTC().Format(icsTimeUTC))
+	}
+	if todo.RecurrenceRule != "" {
+		if todo.RecurrenceStart != nil {
+			lines = append(lines, icsZonedTime("DTSTART", *todo.RecurrenceStart, todo.RecurrenceTZ))
+		}
+		lines = append(lines, "RRULE:"+todo.RecurrenceRule)
+	}
+	if p, ok := icsPriorities[todo.Priority]; ok {
+		lines = append(lines, "PRIORITY:"+strconv.Itoa(p))
+	}
+	if todo.Completed {
+		lines = append(lines, "STATUS:COMPLETED")
+	} else {
+		lines = append(lines, "STATUS:NEEDS-ACTION")
+	}
+	if todo.RemindAt != nil {
+		lines = append(lines,
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			"DESCRIPTION:"+escapeICSText(todo.Title),
+			"TRIGGER;VALUE=DATE-TIME:"+todo.RemindAt.UTC().Format(icsTimeUTC),
+			"END:VALARM",
+		)
+	}
+	lines = append(lines, "END:VTODO")
+
+	for _, line := range lines {
+		if err := e.writeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *icsEncoder) Close() error {
+	if err := e.writeLine("END:VCALENDAR"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// 週期規則要照時區的牆上時間展開，所以 DTSTART 帶 TZID；UTC 就直接用 Z
+func icsZonedTime(name string, t time.Time, tz string) string {
+	if tz == "" || tz == "UTC" {
+		return name + ":" + t.UTC().Format(icsTimeUTC)
+	}
+	loc, err := recurrence.LoadLocation(tz)
+	if err != nil {
+		return name + ":" + t.UTC().Format(icsTimeUTC)
+	}
+	return fmt.Sprintf("%s;TZID=%s:%s", name, tz, t.In(loc).Format("20060102T150405"))
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+func unescapeICSText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// 一行內容：NAME;PARAM=VALUE:value（參數值可以用雙引號包起來，裡面可以有冒號）
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+func parseICSLine(line string) (icsProperty, error) {
+	prop := icsProperty{Params: map[string]string{}}
+
+	inQuotes := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ':':
+			if !inQuotes {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("malformed line %q", line)
+	}
+	prop.Value = line[colon+1:]
+
+	parts := strings.Split(line[:colon], ";")
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, nil
+}
+
+// DUE / DTSTART / TRIGGER 的時間：UTC（結尾 Z）、帶 TZID 的當地時間、沒有時區的浮動時間（當 UTC）、或只有日期
+func parseICSTime(prop icsProperty) (time.Time, error) {
+	loc := time.UTC
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = recurrence.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("%s: %v", strings.ToLower(prop.Name), err)
+		}
+	}
+
+	value := strings.TrimSpace(prop.Value)
+	var t time.Time
+	var err error
+	switch {
+	case prop.Params["VALUE"] == "DATE" || len(value) == 8:
+		t, err = time.ParseInLocation("20060102", value, loc)
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(icsTimeUTC, value)
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: invalid date-time %q", strings.ToLower(prop.Name), prop.Value)
+	}
+	return t, nil
+}
+
+// 相對的提醒時間，例如 -PT15M、-P1D、PT0S
+var icsDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICSDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	m := icsDurationPattern.FindStringSubmatch(value)
+	if m == nil || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("trigger: invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	found := false
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+		found = true
+	}
+	// 只有 "P" 沒有任何數字
+	if !found {
+		return 0, fmt.Errorf("trigger: invalid duration %q", value)
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// 解析中的一個 VTODO
+type icsTodo struct {
+	row     int
+	todo    models.Todo
+	dtstart *time.Time
+	tzid    string
+	err     error
+
+	// 相對的提醒要等整個 VTODO 讀完（知道 DUE / DTSTART）才能算
+	triggerOffset  *time.Duration
+	triggerFromEnd bool
+}
+
+func (t *icsTodo) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *icsTodo) set(prop icsProperty) {
+	switch prop.Name {
+	case "SUMMARY":
+		t.todo.Title = unescapeICSText(prop.Value)
+	case "DESCRIPTION":
+		t.todo.Notes = unescapeICSText(prop.Value)
+	case "DUE":
+		due, err := parseICSTime(prop)
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		t.todo.DueAt = &due
+	case "DTSTART":
+		start, err := parseICSTime(prop)
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		t.dtstart = &start
+		t.tzid = prop.Params["TZID"]
+	case "RRULE":
+		t.todo.RecurrenceRule = strings.TrimSpace(prop.Value)
+	case "PRIORITY":
+		p, err := strconv.Atoi(strings.TrimSpace(prop.Value))
+		if err != nil {
+			t.fail(fmt.Errorf("priority: invalid value %q", prop.Value))
+			return
+		}
+		t.todo.Priority = icsPriority(p)
+	case "STATUS":
+		t.todo.Completed = strings.EqualFold(strings.TrimSpace(prop.Value), "COMPLETED")
+	case "COMPLETED":
+		t.todo.Completed = true
+	}
+}
+
+// VALARM 裡只看第一個 TRIGGER
+func (t *icsTodo) setAlarm(prop icsProperty) {
+	if prop.Name != "TRIGGER" || t.todo.RemindAt != nil || t.triggerOffset != nil {
+		return
+	}
+	if prop.Params["VALUE"] == "DATE-TIME" {
+		at, err := parseICSTime(prop)
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		t.todo.RemindAt = &at
+		return
+	}
+	offset, err := parseICSDuration(prop.Value)
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	t.triggerOffset = &offset
+	t.triggerFromEnd = prop.Params["RELATED"] == "END"
+}
+
+func (t *icsTodo) record() Record {
+	todo := t.todo
+	if todo.RecurrenceRule != "" {
+		todo.RecurrenceStart = t.dtstart
+		todo.RecurrenceTZ = t.tzid
+	}
+
+	if t.triggerOffset != nil {
+		// 預設相對 DTSTART，沒有 DTSTART 或 RELATED=END 就相對 DUE
+		base := t.dtstart
+		if t.triggerFromEnd || base == nil {
+			base = todo.DueAt
+		}
+		if base == nil {
+			t.fail(fmt.Errorf("trigger: relative alarm needs DUE or DTSTART"))
+		} else {
+			at := base.Add(*t.triggerOffset)
+			todo.RemindAt = &at
+		}
+	}
+
+	return Record{Row: t.row, Todo: &todo, Err: t.err}
+}
+
+// 讀出一行一行（已經把折行接回去），回傳每行開始的行號
+func readICSLines(r io.Reader) ([]string, []int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	var numbers []int
+	for n := 1; scanner.Scan(); n++ {
+		raw := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += raw[1:]
+			continue
+		}
+		if raw == "" {
+			continue
+		}
+		lines = append(lines, raw)
+		numbers = append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return lines, numbers, nil
+}
+
+func decodeICS(r io.Reader) ([]Record, error) {
+	lines, numbers, err := readICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("%w: expected BEGIN:VCALENDAR", ErrInvalidFile)
+	}
+
+	var records []Record
+	var current *icsTodo
+	// VTODO 裡面的其他元件（VALARM 或不認得的），記錄目前在哪一層
+	var nested []string
+
+	for i, line := range lines {
+		prop, err := parseICSLine(line)
+		if err != nil {
+			if current != nil {
+				current.fail(err)
+				continue
+			}
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidFile, numbers[i], err)
+		}
+		component := strings.ToUpper(strings.TrimSpace(prop.Value))
+
+		switch {
+		case prop.Name == "BEGIN" && component == "VTODO" && current == nil:
+			current = &icsTodo{row: numbers[i]}
+		case prop.Name == "BEGIN" && current != nil:
+			nested = append(nested, component)
+		case prop.Name == "END" && current != nil && len(nested) > 0:
+			nested = nested[:len(nested)-1]
+		case prop.Name == "END" && component == "VTODO" && current != nil:
+			records = append(records, current.record())
+			current = nil
+		case current != nil && len(nested) == 1 && nested[0] == "VALARM":
+			current.setAlarm(prop)
+		case current != nil && len(nested) == 0:
+			current.set(prop)
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("%w: VTODO starting at line %d is not closed", ErrInvalidFile, current.row)
+	}
+	return records, nil
+}
@@ -0,0 +1,83 @@
+package todoio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"todo_api/internal/models"
+)
+
+// 匯出成 JSON 陣列，每筆就是 API 回傳的 models.Todo
+type jsonEncoder struct {
+	w     *bufio.Writer
+	count int
+}
+
+func newJSONEncoder(w io.Writer) (*jsonEncoder, error) {
+	e := &jsonEncoder{w: bufio.NewWriter(w)}
+	if _, err := e.w.WriteString("["); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *jsonEncoder) Encode(todo *models.Todo) error {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "\n"
+	}
+	e.count++
+
+	if _, err := e.w.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	if _, err := e.w.WriteString("\n]\n"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// 用 Token 一個一個讀陣列元素，某一筆欄位型別錯誤只影響那一筆
+func decodeJSON(r io.Reader) ([]Record, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	token, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("%w: expected a JSON array of todos", ErrInvalidFile)
+	}
+
+	var records []Record
+	for row := 1; dec.More(); row++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("%w: element %d: %v", ErrInvalidFile, row, err)
+		}
+
+		var todo models.Todo
+		if err := json.Unmarshal(raw, &todo); err != nil {
+			records = append(records, Record{Row: row, Err: err})
+			continue
+		}
+		records = append(records, Record{Row: row, Todo: importable(&todo)})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,153 @@
+// Package todoio 負責 todo 的匯入 / 匯出格式：CSV、JSON、iCalendar（VTODO）。
+//
+// 匯出用 Encoder 一筆一筆寫出去，不需要先把全部資料讀進記憶體；
+// 匯入用 Decode 解成 Record，每一筆各自帶著錯誤，由呼叫端決定要跳過還是整批拒絕。
+//
+// 匯入只會帶入使用者能編輯的欄位（標題、完成、截止、優先順序、備註、提醒、週期規則），
+// id、排序位置、建立時間這些都由資料庫重新產生。
+package todoio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"todo_api/internal/models"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+	FormatICS  Format = "ics"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// 整個檔案都讀不下去（例如 JSON 語法錯誤、不是 VCALENDAR），跟單一筆資料錯誤不同
+	ErrInvalidFile = errors.New("invalid import file")
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(s))) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatICS, "ical", "icalendar":
+		return FormatICS, nil
+	default:
+		return "", fmt.Errorf("%w: %q (use csv, json or ics)", ErrUnsupportedFormat, s)
+	}
+}
+
+// 上傳時沒有指定 ?format=，就從 Content-Type 或檔名判斷
+func DetectFormat(contentType string, filename string) (Format, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "text/csv":
+			return FormatCSV, nil
+		case "application/json":
+			return FormatJSON, nil
+		case "text/calendar":
+			return FormatICS, nil
+		}
+	}
+	if ext := strings.TrimPrefix(path.Ext(filename), "."); ext != "" {
+		return ParseFormat(ext)
+	}
+	return "", fmt.Errorf("%w: cannot detect format, add ?format=csv|json|ics", ErrUnsupportedFormat)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatICS:
+		return "text/calendar; charset=utf-8"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// 匯出一筆寫一筆；Close 才會寫出結尾（JSON 的 ]、iCalendar 的 END:VCALENDAR）並把緩衝區送出
+type Encoder interface {
+	Encode(todo *models.Todo) error
+	Close() error
+}
+
+func NewEncoder(format Format, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVEncoder(w)
+	case FormatJSON:
+		return newJSONEncoder(w)
+	case FormatICS:
+		return newICSEncoder(w)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// 匯入的一筆資料；Row 是檔案裡的位置（CSV 的行號、JSON 陣列的第幾個、ics 的 BEGIN:VTODO 行號）
+type Record struct {
+	Row  int
+	Todo *models.Todo
+	Err  error
+}
+
+// 解析整個檔案；只有整個檔案都讀不下去才回傳 error，個別資料的問題記在 Record.Err
+func Decode(format Format, r io.Reader) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return decodeCSV(r)
+	case FormatJSON:
+		return decodeJSON(r)
+	case FormatICS:
+		return decodeICS(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// 只留下匯入會用到的欄位
+func importable(todo *models.Todo) *models.Todo {
+	return &models.Todo{
+		Title:           todo.Title,
+		Completed:       todo.Completed,
+		DueAt:           todo.DueAt,
+		Priority:        todo.Priority,
+		Notes:           todo.Notes,
+		RemindAt:        todo.RemindAt,
+		RecurrenceRule:  todo.RecurrenceRule,
+		RecurrenceTZ:    todo.RecurrenceTZ,
+		RecurrenceStart: todo.RecurrenceStart,
+	}
+}
+
+// CSV / JSON 的時間格式；匯入也接受只有日期的 2006-01-02（當作 UTC 00:00）
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(field string, raw string) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("%s: invalid time %q (use RFC3339)", field, raw)
+}
@@ -0,0 +1,239 @@
+package todoio
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"todo_api/internal/models"
+)
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+// 匯入會用到的欄位全部都有值；時間只到秒，iCalendar 沒有更細的精度
+func fullTodo(t *testing.T) *models.Todo {
+	t.Helper()
+
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.Todo{
+		ID:        42,
+		UserID:    "user-1",
+		Title:     "準備季度報告：整理各部門的預算、人力與專案進度，週五前交給主管審閱, 別忘了附件; 還有簡報",
+		Completed: true,
+		DueAt:     ptr(time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)),
+		Priority:  models.TodoPriorityHigh,
+		Notes:     "第一行, 有逗號; 有分號\n第二行 \\ 反斜線\n\n空行之後",
+		RemindAt:  ptr(time.Date(2025, 1, 31, 1, 0, 0, 0, time.UTC)),
+
+		RecurrenceRule:  "FREQ=WEEKLY;BYDAY=MO,FR",
+		RecurrenceTZ:    "Asia/Taipei",
+		RecurrenceStart: ptr(time.Date(2025, 1, 6, 17, 30, 0, 0, taipei)),
+
+		Position:  "a0",
+		Version:   3,
+		CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func encode(t *testing.T, format Format, todos ...*models.Todo) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, todo := range todos {
+		if err := enc.Encode(todo); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// 時間用 Equal 比（CSV / JSON 保留原本的時區，iCalendar 會換成 UTC 或 TZID 的時區）
+func assertSameTodo(t *testing.T, got *models.Todo, want *models.Todo) {
+	t.Helper()
+
+	times := []struct {
+		name      string
+		got, want *time.Time
+	}{
+		{"DueAt", got.DueAt, want.DueAt},
+		{"RemindAt", got.RemindAt, want.RemindAt},
+		{"RecurrenceStart", got.RecurrenceStart, want.RecurrenceStart},
+	}
+	for _, tt := range times {
+		switch {
+		case tt.got == nil && tt.want == nil:
+		case tt.got == nil || tt.want == nil || !tt.got.Equal(*tt.want):
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	g, w := *got, *want
+	g.DueAt, g.RemindAt, g.RecurrenceStart = nil, nil, nil
+	w.DueAt, w.RemindAt, w.RecurrenceStart = nil, nil, nil
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("todo = %+v\nwant %+v", g, w)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	plain := &models.Todo{
+		ID:        7,
+		Title:     "plain",
+		Priority:  models.TodoPriorityNormal,
+		CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, format := range []Format{FormatCSV, FormatJSON, FormatICS} {
+		t.Run(string(format), func(t *testing.T) {
+			todos := []*models.Todo{fullTodo(t), plain}
+			for _, priority := range []string{models.TodoPriorityLow, models.TodoPriorityUrgent} {
+				todo := fullTodo(t)
+				todo.Priority = priority
+				todo.Completed = false
+				todos = append(todos, todo)
+			}
+
+			data := encode(t, format, todos...)
+			records, err := Decode(format, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v\n%s", err, data)
+			}
+			if len(records) != len(todos) {
+				t.Fatalf("Decode() returned %d records, want %d", len(records), len(todos))
+			}
+
+			for i, record := range records {
+				if record.Err != nil {
+					t.Fatalf("record %d: Err = %v", i, record.Err)
+				}
+				assertSameTodo(t, record.Todo, importable(todos[i]))
+			}
+		})
+	}
+}
+
+func TestICSFoldsLongLinesOnRuneBoundaries(t *testing.T) {
+	todo := fullTodo(t)
+	// 每個中文字 3 bytes，前面的 "SUMMARY:" 讓切點不會剛好落在字元邊界上
+	todo.Title = strings.Repeat("週期性待辦事項", 20)
+
+	data := encode(t, FormatICS, todo)
+
+	if !bytes.Contains(data, []byte("\r\n ")) {
+		t.Fatal("expected long SUMMARY to be folded")
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d bytes, want <= 75: %q", i+1, len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a UTF-8 character: %q", i+1, line)
+		}
+	}
+
+	records, err := Decode(FormatICS, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Err != nil {
+		t.Fatalf("Decode() = %+v", records)
+	}
+	if got := records[0].Todo.Title; got != todo.Title {
+		t.Fatalf("Title = %q, want %q", got, todo.Title)
+	}
+}
+
+func TestICSRelativeAlarm(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"SUMMARY:relative",
+		"DUE:20250131T090000Z",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=END:-PT15M",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	records, err := Decode(FormatICS, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Err != nil {
+		t.Fatalf("Decode() = %+v", records)
+	}
+	want := time.Date(2025, 1, 31, 8, 45, 0, 0, time.UTC)
+	if got := records[0].Todo.RemindAt; got == nil || !got.Equal(want) {
+		t.Fatalf("RemindAt = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeKeepsRowErrorsSeparate(t *testing.T) {
+	data := "title,completed,due_at\n" +
+		"ok,true,2025-01-31\n" +
+		"bad bool,maybe,\n" +
+		"bad time,,31/01/2025\n" +
+		"too,many,fields,here\n"
+
+	records, err := Decode(FormatCSV, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Decode() returned %d records, want 4", len(records))
+	}
+	if records[0].Err != nil || records[0].Todo.Title != "ok" || !records[0].Todo.Completed {
+		t.Fatalf("record 0 = %+v", records[0])
+	}
+	for i, record := range records[1:] {
+		if record.Err == nil {
+			t.Errorf("record %d: Err = nil, want an error", i+1)
+		}
+	}
+	if records[1].Row != 3 {
+		t.Errorf("record 1: Row = %d, want 3", records[1].Row)
+	}
+}
+
+func TestDecodeRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		format Format
+		data   string
+	}{
+		{FormatCSV, ""},
+		{FormatCSV, "id,notes\n1,x\n"},
+		{FormatJSON, `{"title":"not an array"}`},
+		{FormatJSON, `[{"title":"a"},`},
+		{FormatICS, "BEGIN:VTODO\r\nEND:VTODO\r\n"},
+		{FormatICS, "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:x\r\n"},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(tt.format, strings.NewReader(tt.data))
+		if !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("Decode(%s, %q) error = %v, want ErrInvalidFile", tt.format, tt.data, err)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_user_id_created_at;
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS fk_todos_user;
+ALTER TABLE todos DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,14 @@
+-- 讓每一筆 todo 都有擁有者，之後 repository 的所有查詢都會用 user_id 限定範圍
+-- 既有的舊資料沒有擁有者，所以欄位先允許 NULL（這些資料之後任何人都查不到）
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS user_id UUID;
+
+-- 外鍵：使用者被刪除時，他的 todos 也一起刪除
+ALTER TABLE todos
+    ADD CONSTRAINT fk_todos_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE;
+
+-- GetTodos 會用 WHERE user_id = $1 ORDER BY created_at DESC，所以索引直接照這個順序建
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_created_at ON todos(user_id, created_at DESC);
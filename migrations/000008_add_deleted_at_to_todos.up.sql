@@ -0,0 +1,6 @@
+-- 軟刪除：DELETE /todos/:id 只會押上 deleted_at，資料先進垃圾桶，之後可以還原或永久刪除
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- 垃圾桶列表跟背景清理都只會查 deleted_at 不是 NULL 的資料，用 partial index 只索引這一小部分
+CREATE INDEX IF NOT EXISTS idx_todos_deleted_at ON todos(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_todos_pending_reminders;
+DROP INDEX IF EXISTS idx_todos_user_id_due_at;
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS chk_todos_priority;
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS reminded_at,
+    DROP COLUMN IF EXISTS remind_at,
+    DROP COLUMN IF EXISTS notes,
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS due_at;
@@ -0,0 +1,19 @@
+-- 截止時間、優先順序、備註、提醒時間
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ,                                -- 截止時間，允許 NULL（沒有期限）
+    ADD COLUMN IF NOT EXISTS priority VARCHAR(20) NOT NULL DEFAULT 'normal',    -- 優先順序 (low/normal/high/urgent)
+    ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '',                    -- 備註
+    ADD COLUMN IF NOT EXISTS remind_at TIMESTAMPTZ,                             -- 什麼時候提醒
+    ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMPTZ;                           -- 提醒實際送出的時間，避免同一個提醒重複送
+
+-- 檢查約束：限制優先順序只能是四種
+ALTER TABLE todos
+    ADD CONSTRAINT chk_todos_priority
+        CHECK (priority IN ('low', 'normal', 'high', 'urgent'));
+
+-- overdue / due_before / due_after 篩選
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_due_at ON todos(user_id, due_at) WHERE deleted_at IS NULL;
+
+-- 提醒排程每一輪只會找「還沒提醒過、沒被刪除」的 todo
+CREATE INDEX IF NOT EXISTS idx_todos_pending_reminders ON todos(remind_at)
+    WHERE reminded_at IS NULL AND deleted_at IS NULL;
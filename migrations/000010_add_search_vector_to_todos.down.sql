@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_user_id_completed;
+DROP INDEX IF EXISTS idx_todos_search_vector;
+ALTER TABLE todos DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,12 @@
+-- 標題全文搜尋：GET /todos?q=... 會用 search_vector @@ websearch_to_tsquery(...)
+-- 用 GENERATED 欄位，title 改了 PostgreSQL 會自己重算，不用在程式裡維護
+-- 'simple' 不做英文詞幹處理，中英文混合的標題比較不會被切壞
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS search_vector tsvector
+        GENERATED ALWAYS AS (to_tsvector('simple', coalesce(title, ''))) STORED;
+
+-- tsvector 要用 GIN index 才有效率
+CREATE INDEX IF NOT EXISTS idx_todos_search_vector ON todos USING GIN (search_vector);
+
+-- completed 篩選 + 預設排序
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_completed ON todos(user_id, completed, created_at DESC) WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_todos_list_id_created_at;
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS fk_todos_list;
+ALTER TABLE todos DROP COLUMN IF EXISTS list_id;
+DROP TABLE IF EXISTS todo_list_invites;
+DROP TABLE IF EXISTS todo_list_members;
+DROP TABLE IF EXISTS todo_lists;
@@ -0,0 +1,83 @@
+-- 清單（專案）：todo 可以放進清單，清單可以分享給其他使用者
+CREATE TABLE IF NOT EXISTS todo_lists (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL,                          -- 建立者，同時也會是 todo_list_members 裡的 owner
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_todo_lists_owner
+        FOREIGN KEY (owner_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- 清單成員與角色：owner 可以管理成員，editor 可以新增 / 修改 todo，viewer 只能看
+CREATE TABLE IF NOT EXISTS todo_list_members (
+    list_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (list_id, user_id), -- 同一個人在同一個清單只會有一個角色
+
+    CONSTRAINT fk_todo_list_members_list
+        FOREIGN KEY (list_id)
+        REFERENCES todo_lists(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_todo_list_members_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT chk_todo_list_members_role
+        CHECK (role IN ('owner', 'editor', 'viewer'))
+);
+
+-- 查「我參與了哪些清單」
+CREATE INDEX IF NOT EXISTS idx_todo_list_members_user_id ON todo_list_members(user_id);
+
+-- 邀請：只存 token 的 SHA-256，資料庫外洩也拿不到可以直接用的邀請連結
+CREATE TABLE IF NOT EXISTS todo_list_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    list_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL,                     -- 被邀請的人，接受時要用同一個 email 的帳號登入
+    role VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_todo_list_invites_list
+        FOREIGN KEY (list_id)
+        REFERENCES todo_lists(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_todo_list_invites_invited_by
+        FOREIGN KEY (invited_by)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+
+    -- owner 只能有一個，不能用邀請的
+    CONSTRAINT chk_todo_list_invites_role
+        CHECK (role IN ('editor', 'viewer'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_list_invites_list_id ON todo_list_invites(list_id);
+
+-- todo 屬於哪個清單，NULL 代表是個人的 todo
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS list_id UUID;
+
+-- 清單刪除時，清單裡的 todos 也一起刪除
+ALTER TABLE todos
+    ADD CONSTRAINT fk_todos_list
+        FOREIGN KEY (list_id)
+        REFERENCES todo_lists(id)
+        ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_todos_list_id_created_at ON todos(list_id, created_at DESC) WHERE list_id IS NOT NULL;
@@ -0,0 +1,20 @@
+-- 子任務 / 檢查清單：一個 todo 底下可以有很多個步驟
+CREATE TABLE IF NOT EXISTS todo_items (
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,                        -- 屬於哪一個 todo
+    title VARCHAR(255) NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0,             -- 顯示順序，數字小的排前面
+    completed_at TIMESTAMPTZ,                        -- 完成的時間，取消完成時清回 NULL
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    -- todo 被永久刪除時，子任務也一起刪除
+    CONSTRAINT fk_todo_items_todo
+        FOREIGN KEY (todo_id)
+        REFERENCES todos(id)
+        ON DELETE CASCADE
+);
+
+-- 幾乎都是「某個 todo 的所有子任務，照順序排」
+CREATE INDEX IF NOT EXISTS idx_todo_items_todo_id_position ON todo_items(todo_id, position);
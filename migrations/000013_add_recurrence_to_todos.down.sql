@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_todos_user_id_recurring;
+
+ALTER TABLE todos
+    DROP CONSTRAINT IF EXISTS chk_todos_recurrence_start;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS recurrence_next_id,
+    DROP COLUMN IF EXISTS recurrence_start,
+    DROP COLUMN IF EXISTS recurrence_tz,
+    DROP COLUMN IF EXISTS recurrence_rule;
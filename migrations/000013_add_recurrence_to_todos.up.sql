@@ -0,0 +1,16 @@
+-- 週期性 todo：RRULE 子集、展開用的時區跟起點，以及已經產生的下一次
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS recurrence_rule TEXT NOT NULL DEFAULT '',          -- 例如 FREQ=WEEKLY;BYDAY=MO，空字串表示不重複
+    ADD COLUMN IF NOT EXISTS recurrence_tz VARCHAR(64) NOT NULL DEFAULT 'UTC',  -- 用哪個時區的牆上時間展開（IANA 名稱）
+    ADD COLUMN IF NOT EXISTS recurrence_start TIMESTAMPTZ,                      -- 規則的起點（DTSTART），COUNT 從這裡開始算
+    ADD COLUMN IF NOT EXISTS recurrence_next_id INTEGER                         -- 完成之後產生的下一次，避免重複產生
+        REFERENCES todos(id) ON DELETE SET NULL;
+
+-- 有設定規則就一定要有起點
+ALTER TABLE todos
+    ADD CONSTRAINT chk_todos_recurrence_start
+        CHECK (recurrence_rule = '' OR recurrence_start IS NOT NULL);
+
+-- 找出某個使用者所有週期性的 todo
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_recurring ON todos(user_id)
+    WHERE recurrence_rule <> '' AND deleted_at IS NULL;
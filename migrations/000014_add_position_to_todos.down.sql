@@ -0,0 +1,5 @@
+ALTER TABLE todos
+    DROP CONSTRAINT IF EXISTS uq_todos_user_id_position;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS position;
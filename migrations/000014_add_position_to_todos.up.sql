@@ -0,0 +1,22 @@
+-- 手動排序（拖拉）：position 是 internal/rank 產生的排序鍵，移動時只改被移動的那一筆
+-- COLLATE "C" 逐 byte 比較，大小寫才不會被語系排序打亂
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS position TEXT COLLATE "C";
+
+-- 既有資料依建立時間給初始位置；結尾補 V，避免鍵以 0 結尾（rank.Validate 的規則）
+UPDATE todos t
+SET position = ranked.position
+FROM (
+    SELECT id, lpad(row_number() OVER (PARTITION BY user_id ORDER BY created_at, id)::text, 10, '0') || 'V' AS position
+    FROM todos
+) ranked
+WHERE t.id = ranked.id;
+
+ALTER TABLE todos
+    ALTER COLUMN position SET NOT NULL;
+
+-- 同一個使用者不會有兩筆一樣的位置（包含垃圾桶裡的，還原時才不會撞到）
+-- DEFERRABLE：重新平衡時整批改寫，commit 時才檢查
+ALTER TABLE todos
+    ADD CONSTRAINT uq_todos_user_id_position UNIQUE (user_id, position)
+        DEFERRABLE INITIALLY IMMEDIATE;
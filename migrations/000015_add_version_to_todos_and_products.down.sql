@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS trg_products_bump_version ON products;
+DROP TRIGGER IF EXISTS trg_todos_bump_version ON todos;
+DROP FUNCTION IF EXISTS bump_version();
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS version;
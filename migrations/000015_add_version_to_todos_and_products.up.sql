@@ -0,0 +1,22 @@
+-- 樂觀鎖：每次寫入 version +1，GET 回傳 ETag，更新時用 If-Match 比對
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- 用 trigger 統一 +1，不管是哪一支 UPDATE（移動排序、提醒排程、子任務自動完成）都不會漏掉
+CREATE OR REPLACE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_todos_bump_version
+    BEFORE UPDATE ON todos
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
+
+CREATE TRIGGER trg_products_bump_version
+    BEFORE UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
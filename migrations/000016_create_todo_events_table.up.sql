@@ -0,0 +1,27 @@
+-- todo 的變更紀錄：誰、什麼時候、改了哪些欄位（before / after），GET /todos/:id/history 跟 undo 用
+CREATE TABLE IF NOT EXISTS todo_events (
+    id BIGSERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    actor_id UUID,                                   -- 做這個動作的使用者，帳號刪除後保留紀錄但清成 NULL
+    action VARCHAR(20) NOT NULL,                     -- create / update / delete / restore / move / undo
+    changes JSONB NOT NULL DEFAULT '{}'::jsonb,      -- {"title": {"from": "舊", "to": "新"}, ...}
+    todo_version INTEGER NOT NULL,                   -- 這次變更之後 todo 的 version，undo 用來確認之後沒有再被改過
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_todo_events_action
+        CHECK (action IN ('create', 'update', 'delete', 'restore', 'move', 'undo')),
+
+    -- todo 被永久刪除時，紀錄也一起刪除
+    CONSTRAINT fk_todo_events_todo
+        FOREIGN KEY (todo_id)
+        REFERENCES todos(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_todo_events_actor
+        FOREIGN KEY (actor_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+-- 幾乎都是「某個 todo 最新的幾筆紀錄」
+CREATE INDEX IF NOT EXISTS idx_todo_events_todo_id_id ON todo_events(todo_id, id DESC);
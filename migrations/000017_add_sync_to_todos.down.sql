@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS sync_mutations;
+DROP TRIGGER IF EXISTS trg_todos_record_tombstone ON todos;
+DROP FUNCTION IF EXISTS record_todo_tombstone();
+DROP TABLE IF EXISTS todo_tombstones;
+DROP TRIGGER IF EXISTS trg_todos_touch_sync_xid ON todos;
+DROP FUNCTION IF EXISTS touch_sync_xid();
+DROP INDEX IF EXISTS idx_todos_user_id_sync_xid;
+ALTER TABLE todos DROP COLUMN IF EXISTS sync_xid;
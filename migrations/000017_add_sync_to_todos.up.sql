@@ -0,0 +1,63 @@
+-- 離線同步（GET /sync、POST /sync）
+--
+-- sync_xid：最後一次寫入這筆 todo 的 transaction id，新增跟每次 UPDATE 都會更新
+-- 同步 token 存的是當時 snapshot 的 xmin：比 xmin 小的 transaction 一定已經結束、當時就看得到，
+-- 下次只要拿 sync_xid >= xmin 的就不會漏掉當時還沒 commit 的寫入（可能會重複送，client 覆蓋掉就好）
+ALTER TABLE todos
+    ADD COLUMN IF NOT EXISTS sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_sync_xid ON todos(user_id, sync_xid);
+
+CREATE OR REPLACE FUNCTION touch_sync_xid() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.sync_xid := pg_current_xact_id();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_todos_touch_sync_xid
+    BEFORE UPDATE ON todos
+    FOR EACH ROW EXECUTE FUNCTION touch_sync_xid();
+
+-- 永久刪除的 todo 留一筆墓碑，離線的 client 下次同步才知道要刪掉
+-- 不加 users 的外鍵：刪除帳號時會連帶刪 todos、再觸發這裡寫墓碑，過期的由背景工作清掉
+CREATE TABLE IF NOT EXISTS todo_tombstones (
+    todo_id INTEGER PRIMARY KEY,
+    user_id UUID NOT NULL,
+    sync_xid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_tombstones_user_id_sync_xid ON todo_tombstones(user_id, sync_xid);
+
+CREATE OR REPLACE FUNCTION record_todo_tombstone() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO todo_tombstones (todo_id, user_id)
+    VALUES (OLD.id, OLD.user_id)
+    ON CONFLICT (todo_id) DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_todos_record_tombstone
+    AFTER DELETE ON todos
+    FOR EACH ROW
+    WHEN (OLD.user_id IS NOT NULL)
+    EXECUTE FUNCTION record_todo_tombstone();
+
+-- POST /sync 處理過的 mutation，client 重送同一個 client_mutation_id 時直接回傳當時的結果
+CREATE TABLE IF NOT EXISTS sync_mutations (
+    user_id UUID NOT NULL,
+    client_mutation_id VARCHAR(64) NOT NULL,
+    result JSONB NOT NULL,                           -- models.SyncMutationResult；之後的 mutation 用 ref 指到 create 出來的 todo 時查 result 的 id
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, client_mutation_id),
+
+    CONSTRAINT fk_sync_mutations_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_mutations_created_at ON sync_mutations(created_at);
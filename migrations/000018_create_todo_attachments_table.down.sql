@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS trg_todo_attachments_queue_blob_deletion ON todo_attachments;
+DROP FUNCTION IF EXISTS queue_attachment_blob_deletion();
+DROP TABLE IF EXISTS pending_blob_deletions;
+DROP TABLE IF EXISTS todo_attachments;
@@ -0,0 +1,45 @@
+-- todo 的附件：這裡只放中繼資料，檔案內容在 BlobStorage（GCS / 本機資料夾），用 storage_key 對應
+CREATE TABLE IF NOT EXISTS todo_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    todo_id INTEGER NOT NULL,
+    user_id UUID NOT NULL,                           -- 上傳的人，用量（quota）算在他頭上
+    filename VARCHAR(255) NOT NULL,                  -- 原本的檔名，下載時用
+    content_type VARCHAR(255) NOT NULL,              -- 依檔案內容判斷出來的，不是前端說的
+    size_bytes BIGINT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- todo 被永久刪除時，附件的紀錄也一起刪除（檔案由下面的 trigger 排進待刪除）
+    CONSTRAINT fk_todo_attachments_todo
+        FOREIGN KEY (todo_id)
+        REFERENCES todos(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_todo_attachments_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_attachments_todo_id ON todo_attachments(todo_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_todo_attachments_user_id ON todo_attachments(user_id);
+
+-- 附件紀錄被刪掉之後要刪的檔案，由背景工作（jobs.StartBlobCleaner）真的去 BlobStorage 刪
+-- 不管是刪單一附件、永久刪除 todo、清垃圾桶還是刪帳號，都是靠 trigger 排進來，不會漏掉
+CREATE TABLE IF NOT EXISTS pending_blob_deletions (
+    storage_key TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_blob_deletion() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO pending_blob_deletions (storage_key)
+    VALUES (OLD.storage_key)
+    ON CONFLICT (storage_key) DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_todo_attachments_queue_blob_deletion
+    AFTER DELETE ON todo_attachments
+    FOR EACH ROW EXECUTE FUNCTION queue_attachment_blob_deletion();
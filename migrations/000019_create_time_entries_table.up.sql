@@ -0,0 +1,32 @@
+-- todo 的計時紀錄：計時器（ended_at 是 NULL 代表還在跑）跟手動補登的時間都放這裡
+CREATE TABLE IF NOT EXISTS time_entries (
+    id BIGSERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    user_id UUID NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    note TEXT NOT NULL DEFAULT '',
+    auto_stopped BOOLEAN NOT NULL DEFAULT false,    -- 跑太久被背景工作停掉的，前端可以提醒使用者確認時間
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_time_entries_range CHECK (ended_at IS NULL OR ended_at >= started_at),
+
+    -- todo 被永久刪除時，計時紀錄也一起刪除
+    CONSTRAINT fk_time_entries_todo
+        FOREIGN KEY (todo_id)
+        REFERENCES todos(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_time_entries_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- 每個使用者同時只能有一個正在跑的計時器
+CREATE UNIQUE INDEX IF NOT EXISTS uq_time_entries_running ON time_entries(user_id) WHERE ended_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_time_entries_todo_id ON time_entries(todo_id, started_at);
+-- 時間報表依使用者跟時間區間查
+CREATE INDEX IF NOT EXISTS idx_time_entries_user_started ON time_entries(user_id, started_at);
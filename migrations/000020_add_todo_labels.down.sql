@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS todo_tags;
+
+DELETE FROM tags WHERE user_id IS NOT NULL;
+DROP INDEX IF EXISTS uq_tags_user_slug;
+DROP INDEX IF EXISTS uq_tags_global_slug;
+ALTER TABLE tags ADD CONSTRAINT tags_slug_key UNIQUE (slug);
+
+ALTER TABLE tags
+    DROP CONSTRAINT IF EXISTS chk_tags_color,
+    DROP CONSTRAINT IF EXISTS fk_tags_user,
+    DROP COLUMN IF EXISTS color,
+    DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,38 @@
+-- todo 的標籤沿用文章的 tags 表：user_id 是 NULL 的是文章用的全站標籤，有 user_id 的是那個使用者自己的 todo 標籤
+ALTER TABLE tags
+    ADD COLUMN IF NOT EXISTS user_id UUID,
+    ADD COLUMN IF NOT EXISTS color VARCHAR(7) NOT NULL DEFAULT '#9e9e9e';   -- 前端顯示 chip 的顏色
+
+ALTER TABLE tags
+    ADD CONSTRAINT fk_tags_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    ADD CONSTRAINT chk_tags_color CHECK (color ~ '^#[0-9a-fA-F]{6}$');
+
+-- slug 改成在同一個擁有者底下唯一：全站標籤之間不能重複，每個使用者自己的標籤之間不能重複
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_slug_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tags_global_slug ON tags(slug) WHERE user_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tags_user_slug ON tags(user_id, slug) WHERE user_id IS NOT NULL;
+
+-- 跟 article_tags 一樣，一個 todo 可以有多個標籤
+CREATE TABLE IF NOT EXISTS todo_tags (
+    todo_id INTEGER NOT NULL,
+    tag_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (todo_id, tag_id),
+
+    CONSTRAINT fk_todo_tags_todo
+        FOREIGN KEY (todo_id)
+        REFERENCES todos(id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_todo_tags_tag
+        FOREIGN KEY (tag_id)
+        REFERENCES tags(id)
+        ON DELETE CASCADE
+);
+
+-- 查某個標籤底下有哪些 todo（GET /todos?labels=...、改名 / 合併時找受影響的 todo）
+CREATE INDEX IF NOT EXISTS idx_todo_tags_tag_id ON todo_tags(tag_id);
@@ -0,0 +1,18 @@
+-- 登出時把 access token 的 jti 記下來，AuthMiddleware 看到就拒絕；token 本身過期之後這筆就可以刪了
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,   -- 跟 token 的 exp 一樣
+    revoked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_revoked_tokens_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+-- 在這個時間之前發的 access token 全部失效（登出所有裝置、改密碼），NULL 代表沒有限制
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,26 @@
+-- 註冊之後要點信裡的連結驗證 email，NULL 代表還沒驗證
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- 這個欄位加上之前就註冊的帳號一律當作已經驗證，不然既有使用者會突然被擋住
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+-- 驗證信裡的 token：本身有 HMAC 簽章跟期限，資料庫只存 SHA-256，用過一次就標記 used_at
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL,                    -- 發信時的 email，換了 email 舊的 token 就不能用
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,               -- 已經驗證，或重寄之後被新的取代
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_email_verification_tokens_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);
+-- 背景工作清掉過期的
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_expires_at ON email_verification_tokens(expires_at);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trg_todos_bump_version ON todos;
+
+CREATE TRIGGER trg_todos_bump_version
+    BEFORE UPDATE ON todos
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
+
+DROP FUNCTION IF EXISTS bump_todo_version();
@@ -0,0 +1,27 @@
+-- 000015 的 trigger 每次 UPDATE 都 +1，連提醒排程押 reminded_at、背景重新編排 position 這種
+-- 使用者看不到的寫入也會讓 ETag 失效，client 拿舊的 If-Match 就會被無故擋成 412
+--
+-- todos 改用自己的 function：除了下面這幾個欄位以外什麼都沒變，就不 +1
+--   reminded_at    提醒排程認領 / 釋放
+--   position       RebalanceTodoPositions（使用者拖曳排序會一起改 updated_at，還是會 +1）
+--   search_vector  GENERATED 欄位，BEFORE trigger 裡還沒重算
+--   sync_xid       trg_todos_touch_sync_xid 每次都會改
+--   version        語句自己指定的值不算
+-- products 沒有這種內部欄位，繼續用原本的 bump_version()
+CREATE OR REPLACE FUNCTION bump_todo_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF to_jsonb(NEW) - '{reminded_at,position,search_vector,sync_xid,version}'::text[]
+        = to_jsonb(OLD) - '{reminded_at,position,search_vector,sync_xid,version}'::text[] THEN
+        NEW.version := OLD.version;
+    ELSE
+        NEW.version := OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_todos_bump_version ON todos;
+
+CREATE TRIGGER trg_todos_bump_version
+    BEFORE UPDATE ON todos
+    FOR EACH ROW EXECUTE FUNCTION bump_todo_version();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS trg_todo_list_members_restore_todos ON todo_list_members;
+DROP FUNCTION IF EXISTS restore_member_todos();
+DROP TRIGGER IF EXISTS trg_todo_list_members_record_tombstones ON todo_list_members;
+DROP FUNCTION IF EXISTS record_member_removal_tombstones();
@@ -0,0 +1,40 @@
+-- 被移出清單之後，自己在清單裡建立的 todo 就看不到了（todoAccessCondition），但 todo 本身沒變、sync_xid 不會動，
+-- 差異同步不會送出刪除，client 會一直留著這些已經沒有權限的資料
+--
+-- 移除成員時幫他在這個清單裡的 todo 寫墓碑；todo 之後真的被刪掉，000017 的 trigger 碰到已經有的墓碑就跳過
+-- 墓碑的 sync_xid 跟 deleted_at 要換成這一次的，之前留下來的墓碑（例如之前也被移出過）才不會被 since 篩掉
+CREATE OR REPLACE FUNCTION record_member_removal_tombstones() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO todo_tombstones (todo_id, user_id)
+    SELECT id, user_id
+    FROM todos
+    WHERE list_id = OLD.list_id AND user_id = OLD.user_id
+    ON CONFLICT (todo_id) DO UPDATE
+        SET user_id = EXCLUDED.user_id,
+            sync_xid = EXCLUDED.sync_xid,
+            deleted_at = EXCLUDED.deleted_at;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_todo_list_members_record_tombstones
+    AFTER DELETE ON todo_list_members
+    FOR EACH ROW EXECUTE FUNCTION record_member_removal_tombstones();
+
+-- 重新加入清單：拿掉上面寫的墓碑，再碰一下這些 todo 讓 sync_xid 更新，下一次差異同步會把它們送回去
+-- 只有 sync_xid 變的 UPDATE 不會 +version（見 000024），ETag 不受影響
+CREATE OR REPLACE FUNCTION restore_member_todos() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM todo_tombstones
+    WHERE todo_id IN (SELECT id FROM todos WHERE list_id = NEW.list_id AND user_id = NEW.user_id);
+
+    UPDATE todos
+    SET sync_xid = pg_current_xact_id()
+    WHERE list_id = NEW.list_id AND user_id = NEW.user_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_todo_list_members_restore_todos
+    AFTER INSERT ON todo_list_members
+    FOR EACH ROW EXECUTE FUNCTION restore_member_todos();